@@ -17,7 +17,9 @@ import (
 	"github.com/system-design-sandbox/server/internal/config"
 	"github.com/system-design-sandbox/server/internal/geoip"
 	"github.com/system-design-sandbox/server/internal/handler"
+	"github.com/system-design-sandbox/server/internal/jobs"
 	"github.com/system-design-sandbox/server/internal/metrics"
+	"github.com/system-design-sandbox/server/internal/ratelimit"
 	"github.com/system-design-sandbox/server/internal/storage"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
@@ -99,6 +101,7 @@ func main() {
 		os.Exit(1)
 	}
 	defer store.Close()
+	store.Quotas = cfg.Quotas
 
 	// Connect to Redis (optional)
 	rdb, err := storage.NewRedis(ctx, cfg.Redis)
@@ -111,9 +114,14 @@ func main() {
 	// Initialize auth services
 	var redisAuth *auth.RedisAuth
 	if rdb != nil {
-		redisAuth = auth.NewRedisAuth(rdb, cfg.Session.Expiry, cfg.Session.TouchMinInterval, cfg.RateLimit.PerMinute, cfg.RateLimit.PerHour)
+		redisAuth = auth.NewRedisAuth(rdb, cfg.Session.Expiry, cfg.Session.TouchMinInterval)
+		redisAuth.AbsoluteLifetime = cfg.Session.AbsoluteLifetime
+	}
+	emailSender, err := auth.NewEmailSender(cfg.SMTP)
+	if err != nil {
+		slog.Error("failed to set up email", "error", err)
+		os.Exit(1)
 	}
-	emailSender := auth.NewEmailSender(cfg.SMTP)
 
 	geo, err := geoip.New(cfg.GeoIP.GRPCAddr, cfg.GeoIP.RESTURL)
 	if err != nil {
@@ -123,6 +131,24 @@ func main() {
 		defer geo.Close()
 	}
 
+	// Bring stored architecture data to the current format version, then
+	// index the search text of rows saved before search existed
+	upgradeCtx, upgradeCancel := context.WithCancel(context.Background())
+	defer upgradeCancel()
+	go func() {
+		upgradeArchitectures(upgradeCtx, store)
+		indexArchitectureSearch(upgradeCtx, store)
+	}()
+
+	// Background jobs: email, thumbnails, retention and trash purging
+	runner := jobs.NewRunner(store)
+	runner.Concurrency = cfg.JobsConcurrency
+	if err := jobs.Register(runner, store, emailSender, redisAuth, cfg); err != nil {
+		slog.Error("failed to register jobs", "error", err)
+		os.Exit(1)
+	}
+	runner.Start()
+
 	// Metrics: hub first (collector depends on it)
 	hub := metrics.NewHub(15 * time.Second)
 	collector := metrics.NewCollector(rdb, hub, 5*time.Minute)
@@ -132,7 +158,7 @@ func main() {
 	defer metricsCancel()
 	go collector.Run(metricsCtx, cfg.Session.MetricsTick)
 
-	router := handler.NewRouter(cfg, store, redisAuth, emailSender, geo, collector, hub)
+	router := handler.NewRouter(cfg, store, redisAuth, ratelimit.New(rdb), geo, collector, hub)
 
 	srv := &http.Server{
 		Addr:              ":" + cfg.ServerPort,
@@ -163,6 +189,36 @@ func main() {
 		slog.Error("server shutdown error", "error", err)
 		os.Exit(1)
 	}
+	if err := runner.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("jobs still running at shutdown were cancelled", "error", err)
+	}
 
 	slog.Info("server stopped")
 }
+
+// upgradeArchitectures runs the architecture format migrations over all
+// stored rows and logs every architecture that failed to upgrade.
+func upgradeArchitectures(ctx context.Context, store *storage.Storage) {
+	report, err := store.UpgradeArchitectures(ctx, 200)
+	if err != nil {
+		slog.Error("architecture upgrade stopped", "error", err, "checked", report.Checked)
+		return
+	}
+	for _, f := range report.Failed {
+		slog.Warn("architecture upgrade failed", "id", f.ID, "error", f.Error)
+	}
+	slog.Info("architecture upgrade finished", "checked", report.Checked, "upgraded", report.Upgraded, "skipped", report.Skipped, "failed", len(report.Failed))
+}
+
+// indexArchitectureSearch backfills the search text of architectures that
+// have none.
+func indexArchitectureSearch(ctx context.Context, store *storage.Storage) {
+	n, err := store.IndexArchitectureSearch(ctx, 200)
+	if err != nil {
+		slog.Error("architecture search indexing stopped", "error", err, "indexed", n)
+		return
+	}
+	if n > 0 {
+		slog.Info("architecture search indexing finished", "indexed", n)
+	}
+}
@@ -0,0 +1,246 @@
+// Package analysis is a static rule engine over stored architectures. It
+// implements the checks from docs/spec.md §5.1 (SPOF, bottlenecks,
+// anti-patterns, data issues, security gaps, cost and sizing) without
+// running a simulation.
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/system-design-sandbox/server/internal/archdata"
+)
+
+type Severity string
+
+const (
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+func (s Severity) rank() int {
+	switch s {
+	case SeverityError:
+		return 3
+	case SeverityWarning:
+		return 2
+	case SeverityInfo:
+		return 1
+	}
+	return 0
+}
+
+type Category string
+
+const (
+	CategorySPOF        Category = "spof"
+	CategoryBottleneck  Category = "bottleneck"
+	CategoryAntiPattern Category = "anti_pattern"
+	CategoryData        Category = "data"
+	CategorySecurity    Category = "security"
+	CategoryCost        Category = "cost"
+	CategorySizing      Category = "sizing"
+)
+
+// Finding is a single problem reported by a rule.
+type Finding struct {
+	Rule       string   `json:"rule"`
+	Category   Category `json:"category"`
+	Severity   Severity `json:"severity"`
+	NodeIDs    []string `json:"node_ids,omitempty"`
+	EdgeIDs    []string `json:"edge_ids,omitempty"`
+	Message    string   `json:"message"`
+	Suggestion string   `json:"suggestion,omitempty"`
+}
+
+// Rule inspects a graph and returns its findings. Check only fills
+// Severity, node/edge IDs, Message and Suggestion; the engine stamps the
+// rule ID and category.
+type Rule struct {
+	ID       string
+	Category Category
+	Check    func(g *Graph) []Finding
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Rule{}
+)
+
+// Register adds a rule to the default set. It panics on duplicate IDs so
+// conflicting rules are caught at init time.
+func Register(r Rule) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[r.ID]; dup {
+		panic("analysis: duplicate rule " + r.ID)
+	}
+	registry[r.ID] = r
+}
+
+// Rules returns the registered rules sorted by ID.
+func Rules() []Rule {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	rules := make([]Rule, 0, len(registry))
+	for _, r := range registry {
+		rules = append(rules, r)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules
+}
+
+// Summary counts findings by severity.
+type Summary struct {
+	Errors   int `json:"errors"`
+	Warnings int `json:"warnings"`
+	Infos    int `json:"infos"`
+}
+
+// Report is the result of running rules over one architecture. Findings are
+// ranked by severity, then category and rule.
+type Report struct {
+	Findings []Finding        `json:"findings"`
+	Summary  Summary          `json:"summary"`
+	Score    int              `json:"score"`
+	Rules    []string         `json:"rules"`
+	Required *RequirementInfo `json:"required,omitempty"`
+}
+
+// RequirementInfo is attached to a report when a scenario requires it to be clean.
+type RequirementInfo struct {
+	Requirement
+	Passed     bool      `json:"passed"`
+	Violations []Finding `json:"violations,omitempty"`
+}
+
+// Analyze runs rules (all registered rules when nil) over s.
+func Analyze(s *archdata.Schema, rules []Rule) *Report {
+	if rules == nil {
+		rules = Rules()
+	}
+	g := NewGraph(s)
+	rep := &Report{Findings: []Finding{}, Rules: make([]string, 0, len(rules))}
+	for _, r := range rules {
+		rep.Rules = append(rep.Rules, r.ID)
+		for _, f := range r.Check(g) {
+			f.Rule = r.ID
+			f.Category = r.Category
+			rep.Findings = append(rep.Findings, f)
+		}
+	}
+
+	sort.SliceStable(rep.Findings, func(i, j int) bool {
+		a, b := rep.Findings[i], rep.Findings[j]
+		if a.Severity != b.Severity {
+			return a.Severity.rank() > b.Severity.rank()
+		}
+		if a.Category != b.Category {
+			return a.Category < b.Category
+		}
+		return a.Rule < b.Rule
+	})
+
+	score := 100
+	for _, f := range rep.Findings {
+		switch f.Severity {
+		case SeverityError:
+			rep.Summary.Errors++
+			score -= 15
+		case SeverityWarning:
+			rep.Summary.Warnings++
+			score -= 5
+		default:
+			rep.Summary.Infos++
+			score--
+		}
+	}
+	rep.Score = max(score, 0)
+	return rep
+}
+
+// Requirement describes what a scenario needs from the analysis report.
+// Findings at or above MinSeverity in the listed categories (all when
+// empty) or from the listed rules make the report unclean.
+type Requirement struct {
+	Categories  []Category `json:"categories,omitempty"`
+	Rules       []string   `json:"rules,omitempty"`
+	MinSeverity Severity   `json:"min_severity,omitempty"`
+}
+
+// Violations returns the findings that break the requirement.
+func (r Requirement) Violations(rep *Report) []Finding {
+	minRank := r.MinSeverity.rank()
+	if minRank == 0 {
+		minRank = SeverityWarning.rank()
+	}
+	var out []Finding
+	for _, f := range rep.Findings {
+		if f.Severity.rank() < minRank || !r.covers(f) {
+			continue
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+func (r Requirement) covers(f Finding) bool {
+	if len(r.Categories) == 0 && len(r.Rules) == 0 {
+		return true
+	}
+	for _, c := range r.Categories {
+		if c == f.Category {
+			return true
+		}
+	}
+	for _, id := range r.Rules {
+		if id == f.Rule {
+			return true
+		}
+	}
+	return false
+}
+
+// Check attaches the requirement outcome to the report and reports whether it passed.
+func (r Requirement) Check(rep *Report) bool {
+	v := r.Violations(rep)
+	rep.Required = &RequirementInfo{Requirement: r, Passed: len(v) == 0, Violations: v}
+	return len(v) == 0
+}
+
+// scenarioConfig is the part of scenarios.config the analyzer reads; it
+// mirrors successCriteria in packages/scenario-pack.
+type scenarioConfig struct {
+	SuccessCriteria struct {
+		CleanAnalysis json.RawMessage `json:"clean_analysis"`
+	} `json:"successCriteria"`
+}
+
+// RequirementFromScenario extracts the analysis requirement from a scenario
+// config. clean_analysis may be true or a Requirement object; scenarios
+// without it have no requirement and get nil.
+func RequirementFromScenario(config json.RawMessage) (*Requirement, error) {
+	if len(config) == 0 {
+		return nil, nil
+	}
+	var cfg scenarioConfig
+	if err := json.Unmarshal(config, &cfg); err != nil {
+		return nil, fmt.Errorf("analysis: scenario config: %w", err)
+	}
+
+	var req *Requirement
+	switch raw := cfg.SuccessCriteria.CleanAnalysis; {
+	case len(raw) == 0 || string(raw) == "null" || string(raw) == "false":
+	case string(raw) == "true":
+		req = &Requirement{}
+	default:
+		req = &Requirement{}
+		if err := json.Unmarshal(raw, req); err != nil {
+			return nil, fmt.Errorf("analysis: clean_analysis: %w", err)
+		}
+	}
+	return req, nil
+}
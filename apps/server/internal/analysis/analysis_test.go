@@ -0,0 +1,206 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/system-design-sandbox/server/internal/archdata"
+)
+
+// node and edge build a schema without the JSON boilerplate.
+func node(id, typ string, config map[string]any) archdata.Node {
+	return archdata.Node{ID: id, Data: archdata.NodeData{Label: id, ComponentType: typ, Config: config}}
+}
+
+func edge(source, target string) archdata.Edge {
+	return archdata.Edge{ID: source + "->" + target, Source: source, Target: target}
+}
+
+func findings(rep *Report, rule string) []Finding {
+	var out []Finding
+	for _, f := range rep.Findings {
+		if f.Rule == rule {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func ruleByID(t *testing.T, id string) Rule {
+	t.Helper()
+	for _, r := range Rules() {
+		if r.ID == id {
+			return r
+		}
+	}
+	t.Fatalf("rule %s is not registered", id)
+	return Rule{}
+}
+
+func TestRulesCoverEveryCategory(t *testing.T) {
+	seen := map[Category]bool{}
+	for _, r := range Rules() {
+		seen[r.Category] = true
+	}
+	for _, c := range []Category{CategorySPOF, CategoryBottleneck, CategoryAntiPattern, CategoryData, CategorySecurity, CategoryCost, CategorySizing} {
+		if !seen[c] {
+			t.Errorf("no rule registered for category %s", c)
+		}
+	}
+}
+
+func TestDatabaseWithoutReplicas(t *testing.T) {
+	s := &archdata.Schema{
+		Nodes: []archdata.Node{
+			node("single", "postgresql", nil),
+			node("replicated", "postgresql", map[string]any{"read_replicas": 1}),
+		},
+	}
+	got := findings(Analyze(s, []Rule{ruleByID(t, "spof.database_without_replicas")}), "spof.database_without_replicas")
+	if len(got) != 1 || got[0].NodeIDs[0] != "single" || got[0].Severity != SeverityError {
+		t.Fatalf("unexpected findings: %+v", got)
+	}
+}
+
+func TestUtilizationUsesPropagatedClientLoad(t *testing.T) {
+	s := &archdata.Schema{
+		Nodes: []archdata.Node{
+			// 2 * 1000 users * 5 req/s = 10k RPS
+			node("web", "web_client", map[string]any{"concurrent_users_k": 2, "requests_per_user": 5}),
+			node("lb", "load_balancer", nil),
+			node("a", "service", map[string]any{"replicas": 2, "max_rps_per_instance": 2000}),
+			node("b", "service", map[string]any{"replicas": 4, "max_rps_per_instance": 2000}),
+		},
+		Edges: []archdata.Edge{edge("web", "lb"), edge("lb", "a"), edge("lb", "b")},
+	}
+	g := NewGraph(s)
+	if got := g.Load("a"); got != 5000 {
+		t.Fatalf("load on a = %v, want 5000 (half of 10k)", got)
+	}
+
+	got := findings(Analyze(s, []Rule{ruleByID(t, "bottleneck.utilization")}), "bottleneck.utilization")
+	if len(got) != 1 || got[0].NodeIDs[0] != "a" || got[0].Severity != SeverityError {
+		t.Fatalf("expected a single overload error on a, got %+v", got)
+	}
+	if !strings.Contains(got[0].Message, "125%") {
+		t.Errorf("message should report utilization: %q", got[0].Message)
+	}
+}
+
+func TestCircularDependency(t *testing.T) {
+	s := &archdata.Schema{
+		Nodes: []archdata.Node{node("a", "service", nil), node("b", "service", nil), node("c", "service", nil), node("d", "service", nil)},
+		Edges: []archdata.Edge{edge("a", "b"), edge("b", "c"), edge("c", "a"), edge("c", "d")},
+	}
+	got := findings(Analyze(s, []Rule{ruleByID(t, "anti_pattern.circular_dependency")}), "anti_pattern.circular_dependency")
+	if len(got) != 1 {
+		t.Fatalf("expected one cycle, got %+v", got)
+	}
+	if ids := strings.Join(got[0].NodeIDs, ","); ids != "a,b,c" {
+		t.Errorf("cycle nodes = %s, want a,b,c", ids)
+	}
+}
+
+func TestSyncChain(t *testing.T) {
+	s := &archdata.Schema{}
+	for i := 0; i < 5; i++ {
+		s.Nodes = append(s.Nodes, node(fmt.Sprintf("s%d", i), "service", nil))
+		if i > 0 {
+			s.Edges = append(s.Edges, edge(fmt.Sprintf("s%d", i-1), fmt.Sprintf("s%d", i)))
+		}
+	}
+	rule := ruleByID(t, "bottleneck.sync_chain")
+	if got := findings(Analyze(s, []Rule{rule}), rule.ID); len(got) != 1 || len(got[0].NodeIDs) != 5 {
+		t.Fatalf("expected a 4-hop chain, got %+v", got)
+	}
+
+	s.Edges[2].Data.Protocol = "async"
+	if got := findings(Analyze(s, []Rule{rule}), rule.ID); len(got) != 0 {
+		t.Fatalf("async hop should break the chain, got %+v", got)
+	}
+}
+
+func TestSharedDatabaseAndKafkaPartitions(t *testing.T) {
+	s := &archdata.Schema{
+		Nodes: []archdata.Node{
+			node("orders", "service", nil),
+			node("payments", "service", nil),
+			node("db", "postgresql", nil),
+			node("bus", "kafka", map[string]any{"partitions": 4}),
+			node("consumer", "worker", map[string]any{"replicas": 6}),
+		},
+		Edges: []archdata.Edge{edge("orders", "db"), edge("payments", "db"), edge("orders", "bus"), edge("bus", "consumer")},
+	}
+	rep := Analyze(s, nil)
+	if got := findings(rep, "anti_pattern.shared_database"); len(got) != 1 || !strings.Contains(got[0].Message, "orders, payments") {
+		t.Errorf("shared database not detected: %+v", got)
+	}
+	if got := findings(rep, "sizing.kafka_partitions"); len(got) != 1 {
+		t.Errorf("partition/consumer mismatch not detected: %+v", got)
+	}
+}
+
+func TestReportIsRankedBySeverity(t *testing.T) {
+	s := &archdata.Schema{
+		Nodes: []archdata.Node{
+			node("web", "web_client", map[string]any{"concurrent_users_k": 20}),
+			node("api", "service", map[string]any{"replicas": 1}),
+			node("db", "postgresql", nil),
+		},
+		Edges: []archdata.Edge{edge("web", "api"), edge("api", "db")},
+	}
+	rep := Analyze(s, nil)
+	if len(rep.Findings) == 0 {
+		t.Fatal("expected findings")
+	}
+	for i := 1; i < len(rep.Findings); i++ {
+		if rep.Findings[i-1].Severity.rank() < rep.Findings[i].Severity.rank() {
+			t.Fatalf("findings not ranked: %s before %s", rep.Findings[i-1].Severity, rep.Findings[i].Severity)
+		}
+	}
+	if rep.Summary.Errors == 0 || rep.Score >= 100 {
+		t.Errorf("unexpected summary %+v score %d", rep.Summary, rep.Score)
+	}
+}
+
+func TestRequirementFromScenario(t *testing.T) {
+	tests := []struct {
+		config string
+		want   *Requirement
+	}{
+		{`{"successCriteria": {"min_rps": 100}}`, nil},
+		{`{"successCriteria": {"no_spof": true}}`, nil},
+		{`{"successCriteria": {"clean_analysis": true}}`, &Requirement{}},
+		{`{"successCriteria": {"clean_analysis": {"categories": ["security"], "min_severity": "error"}}}`, &Requirement{Categories: []Category{CategorySecurity}, MinSeverity: SeverityError}},
+	}
+	for _, tc := range tests {
+		got, err := RequirementFromScenario(json.RawMessage(tc.config))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.config, err)
+		}
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(tc.want)
+		if string(gotJSON) != string(wantJSON) {
+			t.Errorf("%s: got %s, want %s", tc.config, gotJSON, wantJSON)
+		}
+	}
+}
+
+func TestRequirementCheck(t *testing.T) {
+	rep := &Report{Findings: []Finding{
+		{Rule: "spof.single_instance", Category: CategorySPOF, Severity: SeverityWarning},
+		{Rule: "cost.missing_cdn", Category: CategoryCost, Severity: SeverityInfo},
+	}}
+
+	if !(Requirement{Categories: []Category{CategoryCost}}).Check(rep) {
+		t.Error("info findings should not break the default warning threshold")
+	}
+	if (Requirement{}).Check(rep) {
+		t.Error("a warning should break a clean-report requirement")
+	}
+	if rep.Required == nil || len(rep.Required.Violations) != 1 {
+		t.Errorf("requirement outcome not attached: %+v", rep.Required)
+	}
+}
@@ -0,0 +1,173 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const godServiceDependencies = 8
+
+func init() {
+	Register(Rule{ID: "anti_pattern.shared_database", Category: CategoryAntiPattern, Check: sharedDatabase})
+	Register(Rule{ID: "anti_pattern.circular_dependency", Category: CategoryAntiPattern, Check: circularDependency})
+	Register(Rule{ID: "anti_pattern.god_service", Category: CategoryAntiPattern, Check: godService})
+	Register(Rule{ID: "anti_pattern.distributed_monolith", Category: CategoryAntiPattern, Check: distributedMonolith})
+}
+
+// sharedDatabase flags databases written to by more than one service.
+func sharedDatabase(g *Graph) []Finding {
+	var out []Finding
+	for _, n := range g.Nodes {
+		if !isDatabase(n) {
+			continue
+		}
+		var writers []string
+		ids := []string{n.ID}
+		for _, src := range g.Sources(n.ID) {
+			if isCompute(src) {
+				writers = append(writers, Label(src))
+				ids = append(ids, src.ID)
+			}
+		}
+		if len(writers) < 2 {
+			continue
+		}
+		sort.Strings(writers)
+		out = append(out, Finding{
+			Severity:   SeverityWarning,
+			NodeIDs:    ids,
+			Message:    fmt.Sprintf("Shared database %s between %s", Label(n), strings.Join(writers, ", ")),
+			Suggestion: "Give each service its own database and share data through APIs or events",
+		})
+	}
+	return out
+}
+
+// circularDependency reports every strongly connected group of components.
+func circularDependency(g *Graph) []Finding {
+	index := 0
+	indices := make(map[string]int)
+	lowlink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var out []Finding
+
+	var connect func(id string)
+	connect = func(id string) {
+		indices[id] = index
+		lowlink[id] = index
+		index++
+		stack = append(stack, id)
+		onStack[id] = true
+
+		selfLoop := false
+		for _, e := range g.Out(id) {
+			t := e.Target
+			if t == id {
+				selfLoop = true
+			}
+			if _, seen := indices[t]; !seen {
+				connect(t)
+				lowlink[id] = min(lowlink[id], lowlink[t])
+			} else if onStack[t] {
+				lowlink[id] = min(lowlink[id], indices[t])
+			}
+		}
+
+		if lowlink[id] != indices[id] {
+			return
+		}
+		var group []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			group = append(group, top)
+			if top == id {
+				break
+			}
+		}
+		if len(group) < 2 && !selfLoop {
+			return
+		}
+		names := make([]string, len(group))
+		for i, gid := range group {
+			names[i] = Label(g.Node(gid))
+		}
+		sort.Strings(names)
+		sort.Strings(group)
+		out = append(out, Finding{
+			Severity:   SeverityError,
+			NodeIDs:    group,
+			Message:    fmt.Sprintf("Circular dependency between %s", strings.Join(names, ", ")),
+			Suggestion: "Break the cycle with an event or by moving shared logic into one component",
+		})
+	}
+
+	for _, n := range g.Nodes {
+		if _, seen := indices[n.ID]; !seen {
+			connect(n.ID)
+		}
+	}
+	return out
+}
+
+func godService(g *Graph) []Finding {
+	var out []Finding
+	for _, n := range g.Nodes {
+		if !isCompute(n) {
+			continue
+		}
+		deps := len(g.Out(n.ID))
+		if deps < godServiceDependencies {
+			continue
+		}
+		out = append(out, Finding{
+			Severity:   SeverityWarning,
+			NodeIDs:    []string{n.ID},
+			Message:    fmt.Sprintf("%s talks to %d components", Label(n), deps),
+			Suggestion: "Split it along business capabilities; a service that does everything scales and fails as one unit",
+		})
+	}
+	return out
+}
+
+// distributedMonolith flags three or more services where (nearly) every
+// pair is coupled by a synchronous call.
+func distributedMonolith(g *Graph) []Finding {
+	var services []string
+	for _, n := range g.Nodes {
+		if isCompute(n) {
+			services = append(services, n.ID)
+		}
+	}
+	if len(services) < 3 {
+		return nil
+	}
+	pairs := make(map[[2]string]bool)
+	for _, id := range services {
+		for _, e := range g.Out(id) {
+			t := g.Node(e.Target)
+			if !isCompute(t) || !isSync(g, e) || t.ID == id {
+				continue
+			}
+			a, b := id, t.ID
+			if a > b {
+				a, b = b, a
+			}
+			pairs[[2]string{a, b}] = true
+		}
+	}
+	n := len(services)
+	if len(pairs)*10 < n*(n-1)/2*8 {
+		return nil
+	}
+	sort.Strings(services)
+	return []Finding{{
+		Severity:   SeverityWarning,
+		NodeIDs:    services,
+		Message:    fmt.Sprintf("Distributed monolith: %d services call each other synchronously", n),
+		Suggestion: "Decouple services with events so they can be deployed and fail independently",
+	}}
+}
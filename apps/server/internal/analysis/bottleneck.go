@@ -0,0 +1,145 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	maxFanIn          = 10
+	maxSyncChain      = 3
+	hotUtilization    = 0.8
+	overloaded        = 1.0
+	readHeavyDatabase = 0.5
+)
+
+func init() {
+	Register(Rule{ID: "bottleneck.fan_in", Category: CategoryBottleneck, Check: fanIn})
+	Register(Rule{ID: "bottleneck.sync_chain", Category: CategoryBottleneck, Check: syncChain})
+	Register(Rule{ID: "bottleneck.utilization", Category: CategoryBottleneck, Check: utilization})
+	Register(Rule{ID: "bottleneck.database_without_read_replicas", Category: CategoryBottleneck, Check: databaseWithoutReadReplicas})
+}
+
+func fanIn(g *Graph) []Finding {
+	var out []Finding
+	for _, n := range g.Nodes {
+		if in := len(g.In(n.ID)); in > maxFanIn {
+			out = append(out, Finding{
+				Severity:   SeverityWarning,
+				NodeIDs:    []string{n.ID},
+				Message:    fmt.Sprintf("%s has %d incoming connections", Label(n), in),
+				Suggestion: "Put a gateway or queue in front, or split the component by responsibility",
+			})
+		}
+	}
+	return out
+}
+
+// syncChain finds the longest chain of synchronous calls between compute
+// components starting at each entry service.
+func syncChain(g *Graph) []Finding {
+	memo := make(map[string][]string)
+	var longest func(id string, onPath map[string]bool) []string
+	longest = func(id string, onPath map[string]bool) []string {
+		if p, ok := memo[id]; ok {
+			return p
+		}
+		onPath[id] = true
+		var best []string
+		for _, e := range g.Out(id) {
+			t := g.Node(e.Target)
+			if !isCompute(t) || !isSync(g, e) || onPath[t.ID] {
+				continue
+			}
+			if p := longest(t.ID, onPath); len(p) > len(best) {
+				best = p
+			}
+		}
+		delete(onPath, id)
+		path := append([]string{id}, best...)
+		memo[id] = path
+		return path
+	}
+
+	var out []Finding
+	for _, n := range g.Nodes {
+		if !isCompute(n) {
+			continue
+		}
+		entry := true
+		for _, e := range g.In(n.ID) {
+			if isCompute(g.Node(e.Source)) && isSync(g, e) {
+				entry = false
+				break
+			}
+		}
+		if !entry {
+			continue
+		}
+		path := longest(n.ID, map[string]bool{})
+		if calls := len(path) - 1; calls > maxSyncChain {
+			names := make([]string, len(path))
+			for i, id := range path {
+				names[i] = Label(g.Node(id))
+			}
+			out = append(out, Finding{
+				Severity:   SeverityWarning,
+				NodeIDs:    path,
+				Message:    fmt.Sprintf("Synchronous call chain of %d hops: %s", calls, strings.Join(names, " → ")),
+				Suggestion: "Make some calls asynchronous through a queue or collapse services; latency and failure probability add up along the chain",
+			})
+		}
+	}
+	return out
+}
+
+func utilization(g *Graph) []Finding {
+	var out []Finding
+	for _, n := range g.Nodes {
+		if isClient(n) {
+			continue
+		}
+		u := g.Utilization(n)
+		if u <= hotUtilization {
+			continue
+		}
+		sev := SeverityWarning
+		if u > overloaded {
+			sev = SeverityError
+		}
+		out = append(out, Finding{
+			Severity:   sev,
+			NodeIDs:    []string{n.ID},
+			Message:    fmt.Sprintf("%s at %.0f%% capacity (%s of %s RPS)", Label(n), u*100, formatRPS(g.Load(n.ID)), formatRPS(n.Capacity())),
+			Suggestion: "Add replicas, raise per-instance capacity or cache in front of it",
+		})
+	}
+	return out
+}
+
+func databaseWithoutReadReplicas(g *Graph) []Finding {
+	var out []Finding
+	for _, n := range g.OfType("postgresql", "mysql") {
+		if n.Number("read_replicas", 0) > 0 || g.Utilization(n) <= readHeavyDatabase {
+			continue
+		}
+		out = append(out, Finding{
+			Severity:   SeverityWarning,
+			NodeIDs:    []string{n.ID},
+			Message:    fmt.Sprintf("%s serves all reads from the primary at %.0f%% capacity", Label(n), g.Utilization(n)*100),
+			Suggestion: "Add read replicas and route read-only queries to them",
+		})
+	}
+	return out
+}
+
+func formatRPS(v float64) string {
+	switch {
+	case v >= 1e6:
+		return fmt.Sprintf("%.1fM", v/1e6)
+	case v >= 1e3:
+		return fmt.Sprintf("%.1fk", v/1e3)
+	}
+	return fmt.Sprintf("%.0f", math.Round(v))
+}
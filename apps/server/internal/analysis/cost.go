@@ -0,0 +1,89 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	idleUtilization = 0.2
+	cdnClientRPS    = 10000
+)
+
+func init() {
+	Register(Rule{ID: "cost.over_provisioned", Category: CategoryCost, Check: overProvisioned})
+	Register(Rule{ID: "cost.expensive_storage_class", Category: CategoryCost, Check: expensiveStorageClass})
+	Register(Rule{ID: "cost.missing_cdn", Category: CategoryCost, Check: missingCDN})
+}
+
+// overProvisioned flags multi-replica components that stay mostly idle at
+// the modelled peak.
+func overProvisioned(g *Graph) []Finding {
+	var out []Finding
+	for _, n := range g.Nodes {
+		if n.Replicas() <= 1 || g.Load(n.ID) == 0 || isClient(n) {
+			continue
+		}
+		u := g.Utilization(n)
+		if u == 0 || u >= idleUtilization {
+			continue
+		}
+		out = append(out, Finding{
+			Severity:   SeverityInfo,
+			NodeIDs:    []string{n.ID},
+			Message:    fmt.Sprintf("%s over-provisioned (%.0f%% utilization)", Label(n), u*100),
+			Suggestion: fmt.Sprintf("Reduce replicas from %d or use autoscaling", n.Replicas()),
+		})
+	}
+	return out
+}
+
+// expensiveStorageClass flags archive-like buckets kept in the standard class.
+func expensiveStorageClass(g *Graph) []Finding {
+	var out []Finding
+	for _, n := range g.OfType("s3") {
+		if n.String("storage_class", "standard") != "standard" {
+			continue
+		}
+		label := strings.ToLower(Label(n))
+		cold := false
+		for _, word := range []string{"backup", "archive", "log", "cold"} {
+			if strings.Contains(label, word) {
+				cold = true
+			}
+		}
+		if !cold {
+			continue
+		}
+		out = append(out, Finding{
+			Severity:   SeverityInfo,
+			NodeIDs:    []string{n.ID},
+			Message:    fmt.Sprintf("%s stores cold data in the standard storage class", Label(n)),
+			Suggestion: "Use the infrequent access or glacier class for rarely read data",
+		})
+	}
+	return out
+}
+
+func missingCDN(g *Graph) []Finding {
+	if g.Has("cdn") {
+		return nil
+	}
+	var total float64
+	var ids []string
+	for _, n := range g.Nodes {
+		if n.Data.ComponentType == "web_client" || n.Data.ComponentType == "mobile_client" {
+			total += clientRPS(n)
+			ids = append(ids, n.ID)
+		}
+	}
+	if total < cdnClientRPS {
+		return nil
+	}
+	return []Finding{{
+		Severity:   SeverityInfo,
+		NodeIDs:    ids,
+		Message:    fmt.Sprintf("No CDN for %s RPS of client traffic", formatRPS(total)),
+		Suggestion: "Add a CDN for static content to cut latency and origin load",
+	}}
+}
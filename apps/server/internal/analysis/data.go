@@ -0,0 +1,116 @@
+package analysis
+
+import (
+	"fmt"
+
+	"github.com/system-design-sandbox/server/internal/archdata"
+)
+
+const writeHeavyDatabase = 0.7
+
+func init() {
+	Register(Rule{ID: "data.no_backup", Category: CategoryData, Check: noBackup})
+	Register(Rule{ID: "data.no_cache", Category: CategoryData, Check: noCache})
+	Register(Rule{ID: "data.no_queue", Category: CategoryData, Check: noQueue})
+	Register(Rule{ID: "data.no_retention", Category: CategoryData, Check: noRetention})
+}
+
+// noBackup flags primary databases that explicitly disable backups.
+func noBackup(g *Graph) []Finding {
+	var out []Finding
+	for _, n := range g.Nodes {
+		if !isDatabase(n) || n.Bool("backup_enabled", true) {
+			continue
+		}
+		out = append(out, Finding{
+			Severity:   SeverityError,
+			NodeIDs:    []string{n.ID},
+			Message:    fmt.Sprintf("%s has backups disabled", Label(n)),
+			Suggestion: "Enable automated backups with point-in-time recovery",
+		})
+	}
+	return out
+}
+
+// noCache flags busy databases read by services that have no cache.
+func noCache(g *Graph) []Finding {
+	var out []Finding
+	for _, db := range g.Nodes {
+		if !isDatabase(db) || g.Utilization(db) <= readHeavyDatabase {
+			continue
+		}
+		for _, svc := range g.Sources(db.ID) {
+			if !isCompute(svc) || hasTargetCategory(g, svc, "cache") {
+				continue
+			}
+			out = append(out, Finding{
+				Severity:   SeverityWarning,
+				NodeIDs:    []string{svc.ID, db.ID},
+				Message:    fmt.Sprintf("%s reads %s at %.0f%% capacity without a cache", Label(svc), Label(db), g.Utilization(db)*100),
+				Suggestion: "Cache hot data in Redis or Memcached in front of the database",
+			})
+		}
+	}
+	return out
+}
+
+// noQueue flags databases near write capacity that receive writes only
+// synchronously; a queue would absorb bursts.
+func noQueue(g *Graph) []Finding {
+	var out []Finding
+	for _, db := range g.Nodes {
+		if !isDatabase(db) || g.Utilization(db) <= writeHeavyDatabase {
+			continue
+		}
+		buffered := false
+		for _, src := range g.Sources(db.ID) {
+			for _, upstream := range g.Sources(src.ID) {
+				if isMessaging(upstream) {
+					buffered = true
+				}
+			}
+		}
+		if buffered {
+			continue
+		}
+		out = append(out, Finding{
+			Severity:   SeverityWarning,
+			NodeIDs:    []string{db.ID},
+			Message:    fmt.Sprintf("%s takes all writes synchronously at %.0f%% capacity", Label(db), g.Utilization(db)*100),
+			Suggestion: "Buffer writes through a queue and a worker to smooth out peaks",
+		})
+	}
+	return out
+}
+
+func noRetention(g *Graph) []Finding {
+	var out []Finding
+	for _, n := range g.Nodes {
+		var missing bool
+		switch n.Data.ComponentType {
+		case "logging", "metrics_collector", "tracing":
+			missing = n.Number("retention_days", 1) <= 0
+		case "kafka":
+			missing = n.Number("retention_hours", 1) <= 0
+		}
+		if !missing {
+			continue
+		}
+		out = append(out, Finding{
+			Severity:   SeverityInfo,
+			NodeIDs:    []string{n.ID},
+			Message:    fmt.Sprintf("%s keeps data forever", Label(n)),
+			Suggestion: "Set a retention period so storage does not grow without bound",
+		})
+	}
+	return out
+}
+
+func hasTargetCategory(g *Graph, n *archdata.Node, category string) bool {
+	for _, t := range g.Targets(n.ID) {
+		if t.CategoryName() == category {
+			return true
+		}
+	}
+	return false
+}
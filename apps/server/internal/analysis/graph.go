@@ -0,0 +1,192 @@
+package analysis
+
+import (
+	"github.com/system-design-sandbox/server/internal/archdata"
+)
+
+// Graph is an indexed view of an architecture for rules. Container nodes
+// (racks, pods, ...) are excluded; they only group components.
+type Graph struct {
+	Schema *archdata.Schema
+	Nodes  []*archdata.Node
+
+	byID map[string]*archdata.Node
+	out  map[string][]*archdata.Edge
+	in   map[string][]*archdata.Edge
+	load map[string]float64
+}
+
+// NewGraph indexes s and estimates the steady-state load on every node.
+func NewGraph(s *archdata.Schema) *Graph {
+	g := &Graph{
+		Schema: s,
+		byID:   make(map[string]*archdata.Node),
+		out:    make(map[string][]*archdata.Edge),
+		in:     make(map[string][]*archdata.Edge),
+	}
+	for i := range s.Nodes {
+		n := &s.Nodes[i]
+		if archdata.IsContainer(n.Data.ComponentType) {
+			continue
+		}
+		g.Nodes = append(g.Nodes, n)
+		g.byID[n.ID] = n
+	}
+	for i := range s.Edges {
+		e := &s.Edges[i]
+		if g.byID[e.Source] == nil || g.byID[e.Target] == nil {
+			continue
+		}
+		g.out[e.Source] = append(g.out[e.Source], e)
+		g.in[e.Target] = append(g.in[e.Target], e)
+	}
+	g.estimateLoad()
+	return g
+}
+
+func (g *Graph) Node(id string) *archdata.Node  { return g.byID[id] }
+func (g *Graph) Out(id string) []*archdata.Edge { return g.out[id] }
+func (g *Graph) In(id string) []*archdata.Edge  { return g.in[id] }
+
+// Targets returns the nodes id sends traffic to.
+func (g *Graph) Targets(id string) []*archdata.Node {
+	var nodes []*archdata.Node
+	for _, e := range g.out[id] {
+		nodes = append(nodes, g.byID[e.Target])
+	}
+	return nodes
+}
+
+// Sources returns the nodes sending traffic to id.
+func (g *Graph) Sources(id string) []*archdata.Node {
+	var nodes []*archdata.Node
+	for _, e := range g.in[id] {
+		nodes = append(nodes, g.byID[e.Source])
+	}
+	return nodes
+}
+
+// Load is the estimated requests per second arriving at the node.
+func (g *Graph) Load(id string) float64 { return g.load[id] }
+
+// EdgeLoad is the estimated requests per second on an edge.
+func (g *Graph) EdgeLoad(e *archdata.Edge) float64 {
+	src := g.byID[e.Source]
+	if src == nil {
+		return 0
+	}
+	l := g.load[src.ID]
+	if isClient(src) {
+		l = clientRPS(src)
+	}
+	if splitsTraffic(src) && len(g.out[src.ID]) > 0 {
+		l /= float64(len(g.out[src.ID]))
+	}
+	return l
+}
+
+// Utilization is load over capacity, or 0 when either is unknown.
+func (g *Graph) Utilization(n *archdata.Node) float64 {
+	capacity := n.Capacity()
+	if capacity <= 0 {
+		return 0
+	}
+	return g.load[n.ID] / capacity
+}
+
+// OfType returns nodes with any of the given component types.
+func (g *Graph) OfType(types ...string) []*archdata.Node {
+	var nodes []*archdata.Node
+	for _, n := range g.Nodes {
+		for _, t := range types {
+			if n.Data.ComponentType == t {
+				nodes = append(nodes, n)
+				break
+			}
+		}
+	}
+	return nodes
+}
+
+// Has reports whether any node has one of the given component types.
+func (g *Graph) Has(types ...string) bool {
+	return len(g.OfType(types...)) > 0
+}
+
+// estimateLoad propagates client traffic along edges in topological order.
+// Load balancers and gateways split traffic across their targets; every
+// other component forwards its full load to each dependency. Nodes on
+// cycles keep whatever load reached them before the cycle.
+func (g *Graph) estimateLoad() {
+	g.load = make(map[string]float64)
+	indegree := make(map[string]int)
+	for _, n := range g.Nodes {
+		indegree[n.ID] = len(g.in[n.ID])
+	}
+
+	var queue []*archdata.Node
+	for _, n := range g.Nodes {
+		if indegree[n.ID] == 0 {
+			queue = append(queue, n)
+		}
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, e := range g.out[n.ID] {
+			g.load[e.Target] += g.EdgeLoad(e)
+			indegree[e.Target]--
+			if indegree[e.Target] == 0 {
+				queue = append(queue, g.byID[e.Target])
+			}
+		}
+	}
+}
+
+// Label returns the name shown for a node in messages.
+func Label(n *archdata.Node) string {
+	if name, ok := n.Data.Config["name"].(string); ok && name != "" {
+		return name
+	}
+	if n.Data.Label != "" {
+		return n.Data.Label
+	}
+	return n.Data.ComponentType
+}
+
+func isClient(n *archdata.Node) bool {
+	return n.CategoryName() == "clients"
+}
+
+func isCompute(n *archdata.Node) bool {
+	return n.CategoryName() == "compute"
+}
+
+func isDatabase(n *archdata.Node) bool {
+	return n.CategoryName() == "database" && n.Data.ComponentType != "s3"
+}
+
+func isMessaging(n *archdata.Node) bool {
+	return n.CategoryName() == "messaging"
+}
+
+func splitsTraffic(n *archdata.Node) bool {
+	switch n.Data.ComponentType {
+	case "load_balancer", "api_gateway":
+		return true
+	}
+	return false
+}
+
+// clientRPS is the traffic a client node generates.
+func clientRPS(n *archdata.Node) float64 {
+	return n.Number("concurrent_users_k", 1) * 1000 * n.Number("requests_per_user", 5)
+}
+
+// isSync reports whether the edge is a blocking request/response call.
+func isSync(g *Graph, e *archdata.Edge) bool {
+	if e.Data.Protocol == "async" {
+		return false
+	}
+	return !isMessaging(g.byID[e.Target])
+}
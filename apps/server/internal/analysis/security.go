@@ -0,0 +1,128 @@
+package analysis
+
+import (
+	"fmt"
+
+	"github.com/system-design-sandbox/server/internal/archdata"
+)
+
+func init() {
+	Register(Rule{ID: "security.no_rate_limiting", Category: CategorySecurity, Check: noRateLimiting})
+	Register(Rule{ID: "security.no_waf", Category: CategorySecurity, Check: noWAF})
+	Register(Rule{ID: "security.no_internal_auth", Category: CategorySecurity, Check: noInternalAuth})
+}
+
+// publicEntries returns the non-client components clients talk to directly,
+// following pass-through network hops (DNS, CDN, WAF, load balancers).
+func publicEntries(g *Graph) []*archdata.Node {
+	seen := make(map[string]bool)
+	var entries []*archdata.Node
+	var visit func(n *archdata.Node)
+	visit = func(n *archdata.Node) {
+		for _, t := range g.Targets(n.ID) {
+			if seen[t.ID] {
+				continue
+			}
+			seen[t.ID] = true
+			switch t.Data.ComponentType {
+			case "dns", "cdn", "waf", "load_balancer", "rate_limiter":
+				visit(t)
+			default:
+				entries = append(entries, t)
+			}
+		}
+	}
+	for _, n := range g.Nodes {
+		if isClient(n) {
+			visit(n)
+		}
+	}
+	return entries
+}
+
+// protectedUpstream reports whether any path from a client to n passes a
+// node of one of the given types.
+func protectedUpstream(g *Graph, n *archdata.Node, types ...string) bool {
+	seen := map[string]bool{n.ID: true}
+	stack := []string{n.ID}
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, src := range g.Sources(cur) {
+			if seen[src.ID] {
+				continue
+			}
+			for _, t := range types {
+				if src.Data.ComponentType == t {
+					return true
+				}
+			}
+			seen[src.ID] = true
+			stack = append(stack, src.ID)
+		}
+	}
+	return false
+}
+
+func noRateLimiting(g *Graph) []Finding {
+	var out []Finding
+	for _, n := range publicEntries(g) {
+		if !isCompute(n) && n.Data.ComponentType != "api_gateway" {
+			continue
+		}
+		if n.Bool("rate_limit_enabled", false) || protectedUpstream(g, n, "rate_limiter") {
+			continue
+		}
+		out = append(out, Finding{
+			Severity:   SeverityWarning,
+			NodeIDs:    []string{n.ID},
+			Message:    fmt.Sprintf("Public endpoint %s has no rate limiting", Label(n)),
+			Suggestion: "Enable rate limiting on the gateway or put a Rate Limiter in front",
+		})
+	}
+	return out
+}
+
+func noWAF(g *Graph) []Finding {
+	var out []Finding
+	for _, n := range publicEntries(g) {
+		if n.Data.ComponentType != "api_gateway" || protectedUpstream(g, n, "waf") {
+			continue
+		}
+		out = append(out, Finding{
+			Severity:   SeverityInfo,
+			NodeIDs:    []string{n.ID},
+			Message:    fmt.Sprintf("%s is exposed without a WAF", Label(n)),
+			Suggestion: "Put a WAF in front of the API Gateway to filter malicious traffic",
+		})
+	}
+	return out
+}
+
+// noInternalAuth flags service-to-service calls when no auth service exists.
+func noInternalAuth(g *Graph) []Finding {
+	if g.Has("auth_service") {
+		return nil
+	}
+	var ids []string
+	for _, n := range g.Nodes {
+		if !isCompute(n) {
+			continue
+		}
+		for _, t := range g.Targets(n.ID) {
+			if isCompute(t) {
+				ids = append(ids, n.ID)
+				break
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return []Finding{{
+		Severity:   SeverityWarning,
+		NodeIDs:    ids,
+		Message:    "No authentication between internal services",
+		Suggestion: "Add an Auth Service or mTLS so services verify who is calling them",
+	}}
+}
@@ -0,0 +1,99 @@
+package analysis
+
+import (
+	"fmt"
+)
+
+// defaultPayloadKB is used for bandwidth checks when the source does not
+// declare request sizes.
+const defaultPayloadKB = 1.0
+
+func init() {
+	Register(Rule{ID: "sizing.kafka_partitions", Category: CategorySizing, Check: kafkaPartitions})
+	Register(Rule{ID: "sizing.link_bandwidth", Category: CategorySizing, Check: linkBandwidth})
+	Register(Rule{ID: "sizing.disk_iops", Category: CategorySizing, Check: diskIOPS})
+}
+
+// kafkaPartitions flags topics with fewer partitions than consumer instances;
+// the extra consumers sit idle.
+func kafkaPartitions(g *Graph) []Finding {
+	var out []Finding
+	for _, n := range g.OfType("kafka") {
+		partitions := int(n.Number("partitions", 12))
+		for _, c := range g.Targets(n.ID) {
+			if !isCompute(c) || c.Replicas() <= partitions {
+				continue
+			}
+			out = append(out, Finding{
+				Severity:   SeverityWarning,
+				NodeIDs:    []string{n.ID, c.ID},
+				Message:    fmt.Sprintf("%s has %d partitions but %s runs %d consumers", Label(n), partitions, Label(c), c.Replicas()),
+				Suggestion: "Increase partitions to at least the number of consumers in the group",
+			})
+		}
+	}
+	return out
+}
+
+// linkBandwidth compares edge bandwidth with RPS × payload size.
+func linkBandwidth(g *Graph) []Finding {
+	var out []Finding
+	for i := range g.Schema.Edges {
+		e := &g.Schema.Edges[i]
+		if e.Data.BandwidthMbps <= 0 || g.Node(e.Source) == nil || g.Node(e.Target) == nil {
+			continue
+		}
+		needMbps := g.EdgeLoad(e) * payloadKB(g, e.Source) * 8 / 1000
+		if needMbps <= e.Data.BandwidthMbps {
+			continue
+		}
+		out = append(out, Finding{
+			Severity:   SeverityWarning,
+			NodeIDs:    []string{e.Source, e.Target},
+			EdgeIDs:    []string{e.ID},
+			Message:    fmt.Sprintf("Link %s → %s needs %.0f Mbps but has %.0f Mbps", Label(g.Node(e.Source)), Label(g.Node(e.Target)), needMbps, e.Data.BandwidthMbps),
+			Suggestion: "Raise link bandwidth or shrink payloads (compression, pagination)",
+		})
+	}
+	return out
+}
+
+// payloadKB is the average request size declared by a client's tag
+// distribution, or response_size_kb for other components.
+func payloadKB(g *Graph, id string) float64 {
+	n := g.Node(id)
+	if tags, ok := n.Data.Config["tagDistribution"].([]any); ok {
+		var sum, weights float64
+		for _, t := range tags {
+			m, ok := t.(map[string]any)
+			if !ok {
+				continue
+			}
+			w, _ := m["weight"].(float64)
+			kb, _ := m["requestSizeKb"].(float64)
+			sum += w * kb
+			weights += w
+		}
+		if weights > 0 && sum > 0 {
+			return sum / weights
+		}
+	}
+	return n.Number("response_size_kb", defaultPayloadKB)
+}
+
+func diskIOPS(g *Graph) []Finding {
+	var out []Finding
+	for _, n := range g.OfType("postgresql", "mysql") {
+		iops := n.Number("iops", 0)
+		if iops <= 0 || g.Load(n.ID) <= iops {
+			continue
+		}
+		out = append(out, Finding{
+			Severity:   SeverityWarning,
+			NodeIDs:    []string{n.ID},
+			Message:    fmt.Sprintf("%s is provisioned for %.0f IOPS but receives %s queries/sec", Label(n), iops, formatRPS(g.Load(n.ID))),
+			Suggestion: "Provision more IOPS or reduce write amplification with batching",
+		})
+	}
+	return out
+}
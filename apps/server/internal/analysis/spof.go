@@ -0,0 +1,160 @@
+package analysis
+
+import (
+	"fmt"
+
+	"github.com/system-design-sandbox/server/internal/archdata"
+)
+
+func init() {
+	Register(Rule{ID: "spof.database_without_replicas", Category: CategorySPOF, Check: databaseWithoutReplicas})
+	Register(Rule{ID: "spof.single_instance", Category: CategorySPOF, Check: singleInstance})
+	Register(Rule{ID: "spof.missing_load_balancer", Category: CategorySPOF, Check: missingLoadBalancer})
+	Register(Rule{ID: "spof.stateful_without_failover", Category: CategorySPOF, Check: statefulWithoutFailover})
+	Register(Rule{ID: "spof.single_zone", Category: CategorySPOF, Check: singleZone})
+}
+
+func databaseWithoutReplicas(g *Graph) []Finding {
+	var out []Finding
+	for _, n := range g.Nodes {
+		if !isDatabase(n) || n.Replicas() > 1 || n.Number("read_replicas", 0) > 0 {
+			continue
+		}
+		out = append(out, Finding{
+			Severity:   SeverityError,
+			NodeIDs:    []string{n.ID},
+			Message:    fmt.Sprintf("%s has no replicas", Label(n)),
+			Suggestion: "Add a replica or standby so the data survives a node failure",
+		})
+	}
+	return out
+}
+
+// singleInstance flags compute and entry points that receive traffic but run one instance.
+func singleInstance(g *Graph) []Finding {
+	var out []Finding
+	for _, n := range g.Nodes {
+		switch n.Data.ComponentType {
+		case "service", "service_container", "worker", "api_gateway", "load_balancer":
+		default:
+			continue
+		}
+		if n.Replicas() > 1 || len(g.In(n.ID)) == 0 {
+			continue
+		}
+		out = append(out, Finding{
+			Severity:   SeverityWarning,
+			NodeIDs:    []string{n.ID},
+			Message:    fmt.Sprintf("%s runs a single instance", Label(n)),
+			Suggestion: "Run at least two replicas so one failure does not take it down",
+		})
+	}
+	return out
+}
+
+// missingLoadBalancer mirrors apps/web/src/analysis/rules/spof.ts.
+func missingLoadBalancer(g *Graph) []Finding {
+	var out []Finding
+	for _, n := range g.OfType("service", "worker", "serverless_function") {
+		replicas := n.Replicas()
+		if replicas <= 1 || len(g.In(n.ID)) == 0 {
+			continue
+		}
+		hasLB := false
+		for _, src := range g.Sources(n.ID) {
+			if src.Data.ComponentType == "load_balancer" || src.Data.ComponentType == "api_gateway" || isMessaging(src) {
+				hasLB = true
+				break
+			}
+		}
+		if hasLB {
+			continue
+		}
+		out = append(out, Finding{
+			Severity:   SeverityWarning,
+			NodeIDs:    []string{n.ID},
+			Message:    fmt.Sprintf("%s has %d replicas but no Load Balancer", Label(n), replicas),
+			Suggestion: "Add a Load Balancer in front to distribute traffic across replicas evenly",
+		})
+	}
+	return out
+}
+
+func statefulWithoutFailover(g *Graph) []Finding {
+	var out []Finding
+	for _, n := range g.Nodes {
+		var msg string
+		switch n.Data.ComponentType {
+		case "redis":
+			if n.String("mode", "standalone") == "standalone" && n.Replicas() <= 1 {
+				msg = fmt.Sprintf("%s runs standalone without failover", Label(n))
+			}
+		case "kafka":
+			if n.Number("brokers", 3) < 2 || n.Number("replication_factor", 3) < 2 {
+				msg = fmt.Sprintf("%s does not replicate partitions across brokers", Label(n))
+			}
+		case "rabbitmq":
+			if !n.Bool("ha_mode", true) {
+				msg = fmt.Sprintf("%s has HA mode disabled", Label(n))
+			}
+		case "etcd":
+			if n.Replicas() < 3 {
+				msg = fmt.Sprintf("%s has no quorum with %d members", Label(n), n.Replicas())
+			}
+		}
+		if msg == "" {
+			continue
+		}
+		out = append(out, Finding{
+			Severity:   SeverityWarning,
+			NodeIDs:    []string{n.ID},
+			Message:    msg,
+			Suggestion: "Enable replication or a failover mode for stateful components",
+		})
+	}
+	return out
+}
+
+// singleZone reports designs that model datacenters but place everything in one.
+func singleZone(g *Graph) []Finding {
+	var dcs []*archdata.Node
+	for i := range g.Schema.Nodes {
+		if g.Schema.Nodes[i].Data.ComponentType == "datacenter" {
+			dcs = append(dcs, &g.Schema.Nodes[i])
+		}
+	}
+	if len(dcs) != 1 || len(g.Nodes) == 0 {
+		return nil
+	}
+	dc := dcs[0]
+	for _, n := range g.Nodes {
+		if isClient(n) {
+			continue
+		}
+		if !insideContainer(g.Schema, n, dc.ID) {
+			return nil
+		}
+	}
+	return []Finding{{
+		Severity:   SeverityInfo,
+		NodeIDs:    []string{dc.ID},
+		Message:    fmt.Sprintf("All components run in %s", Label(dc)),
+		Suggestion: "Spread replicas across availability zones or a second datacenter",
+	}}
+}
+
+func insideContainer(s *archdata.Schema, n *archdata.Node, containerID string) bool {
+	seen := map[string]bool{}
+	for id := n.ParentID; id != "" && !seen[id]; {
+		if id == containerID {
+			return true
+		}
+		seen[id] = true
+		p := s.NodeByID(id)
+		if p == nil {
+			return false
+		}
+		id = p.ParentID
+	}
+	return false
+}
@@ -0,0 +1,248 @@
+// Package archdata models the architecture export format stored in
+// architectures.data (see docs/export-json.md).
+package archdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Schema is the top-level architecture document produced by the web editor.
+type Schema struct {
+	Version  string   `json:"version"`
+	Metadata Metadata `json:"metadata"`
+	Nodes    []Node   `json:"nodes"`
+	Edges    []Edge   `json:"edges"`
+}
+
+type Metadata struct {
+	Name        string   `json:"name,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	CreatedAt   string   `json:"createdAt,omitempty"`
+	UpdatedAt   string   `json:"updatedAt,omitempty"`
+	ExportedAt  string   `json:"exportedAt,omitempty"`
+}
+
+type Position struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type Size struct {
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+type Node struct {
+	ID       string         `json:"id"`
+	Type     string         `json:"type,omitempty"`
+	Position Position       `json:"position"`
+	Data     NodeData       `json:"data"`
+	ParentID string         `json:"parentId,omitempty"`
+	Width    *float64       `json:"width,omitempty"`
+	Height   *float64       `json:"height,omitempty"`
+	Measured *Size          `json:"measured,omitempty"`
+	Style    map[string]any `json:"style,omitempty"`
+}
+
+type NodeData struct {
+	Label         string         `json:"label"`
+	ComponentType string         `json:"componentType"`
+	Category      string         `json:"category,omitempty"`
+	Icon          string         `json:"icon,omitempty"`
+	Config        map[string]any `json:"config,omitempty"`
+}
+
+type Edge struct {
+	ID     string   `json:"id"`
+	Source string   `json:"source"`
+	Target string   `json:"target"`
+	Type   string   `json:"type,omitempty"`
+	Data   EdgeData `json:"data"`
+}
+
+type EdgeData struct {
+	Protocol      string  `json:"protocol,omitempty"`
+	LatencyMs     float64 `json:"latencyMs,omitempty"`
+	BandwidthMbps float64 `json:"bandwidthMbps,omitempty"`
+	TimeoutMs     float64 `json:"timeoutMs,omitempty"`
+}
+
+// Parse decodes raw architecture data. Unknown fields are ignored.
+func Parse(raw []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, fmt.Errorf("archdata: %w", err)
+	}
+	return &s, nil
+}
+
+// containerTypes are component types that visually group other nodes.
+var containerTypes = map[string]bool{
+	"docker_container": true,
+	"kubernetes_pod":   true,
+	"vm_instance":      true,
+	"rack":             true,
+	"datacenter":       true,
+}
+
+// IsContainer reports whether the component type groups child nodes.
+func IsContainer(componentType string) bool {
+	return containerTypes[componentType]
+}
+
+// NodeByID returns the node with the given ID, or nil.
+func (s *Schema) NodeByID(id string) *Node {
+	for i := range s.Nodes {
+		if s.Nodes[i].ID == id {
+			return &s.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// Incoming returns edges whose target is the given node.
+func (s *Schema) Incoming(id string) []Edge {
+	var out []Edge
+	for _, e := range s.Edges {
+		if e.Target == id {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Outgoing returns edges whose source is the given node.
+func (s *Schema) Outgoing(id string) []Edge {
+	var out []Edge
+	for _, e := range s.Edges {
+		if e.Source == id {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// AbsolutePosition resolves a node position on the canvas. Positions of
+// nested nodes are stored relative to their parent container.
+func (s *Schema) AbsolutePosition(n *Node) Position {
+	pos := n.Position
+	seen := map[string]bool{n.ID: true}
+	for parentID := n.ParentID; parentID != "" && !seen[parentID]; {
+		seen[parentID] = true
+		p := s.NodeByID(parentID)
+		if p == nil {
+			break
+		}
+		pos.X += p.Position.X
+		pos.Y += p.Position.Y
+		parentID = p.ParentID
+	}
+	return pos
+}
+
+// Dimensions returns the rendered node size using the same precedence as
+// the canvas: style, measured, explicit width/height, then defaults.
+func (n *Node) Dimensions() Size {
+	size := Size{Width: 280, Height: 90}
+	if IsContainer(n.Data.ComponentType) {
+		size = Size{Width: 400, Height: 300}
+	}
+	if n.Width != nil {
+		size.Width = *n.Width
+	}
+	if n.Height != nil {
+		size.Height = *n.Height
+	}
+	if n.Measured != nil {
+		if n.Measured.Width > 0 {
+			size.Width = n.Measured.Width
+		}
+		if n.Measured.Height > 0 {
+			size.Height = n.Measured.Height
+		}
+	}
+	if w, ok := toFloat(n.Style["width"]); ok {
+		size.Width = w
+	}
+	if h, ok := toFloat(n.Style["height"]); ok {
+		size.Height = h
+	}
+	return size
+}
+
+// Number returns a numeric config value, or def when missing or not numeric.
+func (n *Node) Number(key string, def float64) float64 {
+	if v, ok := toFloat(n.Data.Config[key]); ok {
+		return v
+	}
+	return def
+}
+
+// String returns a string config value, or def when missing.
+func (n *Node) String(key, def string) string {
+	if v, ok := n.Data.Config[key].(string); ok && v != "" {
+		return v
+	}
+	return def
+}
+
+// Bool returns a boolean config value, or def when missing.
+func (n *Node) Bool(key string, def bool) bool {
+	if v, ok := n.Data.Config[key].(bool); ok {
+		return v
+	}
+	return def
+}
+
+// Replicas returns the configured replica count (at least 1), falling back
+// to the component-library default.
+func (n *Node) Replicas() int {
+	def := 1
+	if spec, ok := Catalog[n.Data.ComponentType]; ok {
+		def = spec.Replicas
+	}
+	r := int(n.Number("replicas", float64(def)))
+	if r < 1 {
+		return 1
+	}
+	return r
+}
+
+func toFloat(v any) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case int:
+		return float64(x), true
+	case json.Number:
+		f, err := x.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(x, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// HasNode reports whether the document contains a node with id.
+func (s *Schema) HasNode(id string) bool {
+	for _, n := range s.Nodes {
+		if n.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// HasEdge reports whether the document contains an edge with id.
+func (s *Schema) HasEdge(id string) bool {
+	for _, e := range s.Edges {
+		if e.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,16 @@
+package archdata
+
+import "testing"
+
+func TestHasNodeAndEdge(t *testing.T) {
+	s, err := Parse([]byte(validDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.HasNode("api") || s.HasNode("e1") {
+		t.Error("HasNode matched the wrong elements")
+	}
+	if !s.HasEdge("e1") || s.HasEdge("api") {
+		t.Error("HasEdge matched the wrong elements")
+	}
+}
@@ -0,0 +1,81 @@
+package archdata
+
+// ComponentSpec holds the component-library defaults the server needs to
+// reason about a node (packages/component-library/src/definitions/components.ts).
+type ComponentSpec struct {
+	Category string
+	// MaxRPS is the default per-instance capacity; 0 for passive components.
+	MaxRPS   float64
+	Replicas int
+}
+
+// Catalog lists every component type the web editor can place.
+var Catalog = map[string]ComponentSpec{
+	"web_client":          {"clients", 10000, 1},
+	"mobile_client":       {"clients", 5000, 1},
+	"external_api":        {"clients", 50000, 1},
+	"external_service":    {"network", 500, 1},
+	"api_gateway":         {"network", 25000, 2},
+	"load_balancer":       {"network", 50000, 2},
+	"cdn":                 {"network", 500000, 1},
+	"dns":                 {"network", 200000, 1},
+	"waf":                 {"network", 20000, 2},
+	"service":             {"compute", 2000, 3},
+	"service_container":   {"compute", 2000, 1},
+	"serverless_function": {"compute", 3000, 1},
+	"worker":              {"compute", 200, 2},
+	"cron_job":            {"compute", 1, 1},
+	"postgresql":          {"database", 5000, 1},
+	"mongodb":             {"database", 10000, 3},
+	"cassandra":           {"database", 20000, 3},
+	"mysql":               {"database", 4000, 1},
+	"clickhouse":          {"database", 10000, 3},
+	"redis":               {"cache", 100000, 1},
+	"memcached":           {"cache", 50000, 3},
+	"s3":                  {"database", 5500, 1},
+	"nfs":                 {"storage", 3000, 1},
+	"etcd":                {"database", 10000, 3},
+	"elasticsearch":       {"database", 5000, 3},
+	"kafka":               {"messaging", 100000, 1},
+	"rabbitmq":            {"messaging", 20000, 1},
+	"nats":                {"messaging", 200000, 1},
+	"docker_container":    {"infrastructure", 0, 1},
+	"kubernetes_pod":      {"infrastructure", 0, 1},
+	"vm_instance":         {"infrastructure", 0, 1},
+	"rack":                {"infrastructure", 0, 1},
+	"datacenter":          {"infrastructure", 0, 1},
+	"local_ssd":           {"storage", 80000, 1},
+	"nvme":                {"storage", 200000, 1},
+	"network_disk":        {"storage", 16000, 1},
+	"circuit_breaker":     {"reliability", 200000, 1},
+	"rate_limiter":        {"reliability", 200000, 1},
+	"health_check":        {"reliability", 200000, 1},
+	"auth_service":        {"security", 5000, 2},
+	"logging":             {"observability", 20000, 3},
+	"metrics_collector":   {"observability", 50000, 2},
+	"tracing":             {"observability", 30000, 2},
+}
+
+// CategoryName returns the component-library category of the node, preferring
+// the catalog over the value stored in the document.
+func (n *Node) CategoryName() string {
+	if spec, ok := Catalog[n.Data.ComponentType]; ok {
+		return spec.Category
+	}
+	return n.Data.Category
+}
+
+// MaxRPS returns the per-instance capacity: max_rps_per_instance from the
+// config, falling back to the catalog default.
+func (n *Node) MaxRPS() float64 {
+	return n.Number("max_rps_per_instance", Catalog[n.Data.ComponentType].MaxRPS)
+}
+
+// Capacity returns the total requests per second the node can serve across
+// its replicas. Kafka scales by brokers rather than replicas.
+func (n *Node) Capacity() float64 {
+	if n.Data.ComponentType == "kafka" {
+		return n.Number("brokers", 3) * n.Number("max_rps_per_broker", Catalog["kafka"].MaxRPS)
+	}
+	return float64(n.Replicas()) * n.MaxRPS()
+}
@@ -0,0 +1,98 @@
+package archdata
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// CurrentVersion is the export format version the server writes and the
+// target of Upgrade.
+const CurrentVersion = "1.0"
+
+// ErrUnknownVersion is returned by Upgrade when no migration chain leads
+// from the document's version to CurrentVersion.
+var ErrUnknownVersion = errors.New("archdata: unknown format version")
+
+// Migration upgrades a decoded document from one format version to the
+// next. Up edits doc in place; the version field is set by Upgrade.
+type Migration struct {
+	From string
+	To   string
+	Up   func(doc map[string]any) error
+}
+
+// migrations is the upgrade chain. Append a step whenever the web editor
+// changes the export format, then bump CurrentVersion and SupportedVersions.
+var migrations = []Migration{
+	{From: "", To: "1.0", Up: upgradeUnversioned},
+}
+
+// Upgrade brings raw architecture data to CurrentVersion by applying the
+// migration chain. It returns raw unchanged (and false) when the document
+// is already current.
+func Upgrade(raw []byte) ([]byte, bool, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return raw, false, fmt.Errorf("archdata: %w", err)
+	}
+	if doc == nil {
+		doc = map[string]any{}
+	}
+
+	version, ok := doc["version"].(string)
+	if _, present := doc["version"]; present && !ok {
+		return raw, false, fmt.Errorf("%w: version is not a string", ErrUnknownVersion)
+	}
+	if version == CurrentVersion {
+		return raw, false, nil
+	}
+
+	for version != CurrentVersion {
+		m, ok := migrationFrom(version)
+		if !ok {
+			return raw, false, fmt.Errorf("%w %q", ErrUnknownVersion, version)
+		}
+		if err := m.Up(doc); err != nil {
+			return raw, false, fmt.Errorf("archdata: upgrade %q to %q: %w", m.From, m.To, err)
+		}
+		doc["version"] = m.To
+		version = m.To
+	}
+
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return raw, false, fmt.Errorf("archdata: %w", err)
+	}
+	return out, true, nil
+}
+
+func migrationFrom(version string) (Migration, bool) {
+	for _, m := range migrations {
+		if m.From == version {
+			return m, true
+		}
+	}
+	return Migration{}, false
+}
+
+// upgradeUnversioned handles saves from before the format was versioned:
+// they may lack the nodes, edges or metadata keys, or store them as null.
+func upgradeUnversioned(doc map[string]any) error {
+	for _, key := range []string{"nodes", "edges"} {
+		switch v := doc[key].(type) {
+		case nil:
+			doc[key] = []any{}
+		case []any:
+		default:
+			return fmt.Errorf("%s is %T, want array", key, v)
+		}
+	}
+	if doc["metadata"] == nil {
+		doc["metadata"] = map[string]any{}
+	}
+	return nil
+}
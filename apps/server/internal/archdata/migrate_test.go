@@ -0,0 +1,42 @@
+package archdata
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUpgradeUnversioned(t *testing.T) {
+	out, changed, err := Upgrade([]byte(`{"nodes":[{"id":"a","position":{"x":1.5,"y":2},"data":{"label":"A","componentType":"service"}}],"edges":null}`))
+	if err != nil || !changed {
+		t.Fatalf("Upgrade() changed=%v err=%v", changed, err)
+	}
+	if errs := Validate(out, DefaultLimits); errs != nil {
+		t.Fatalf("upgraded document is invalid: %v\n%s", errs, out)
+	}
+	s, err := Parse(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Version != CurrentVersion || len(s.Nodes) != 1 || s.Nodes[0].Position.X != 1.5 || s.Edges == nil {
+		t.Errorf("upgraded = %s", out)
+	}
+}
+
+func TestUpgradeCurrentIsNoop(t *testing.T) {
+	raw := []byte(validDoc)
+	out, changed, err := Upgrade(raw)
+	if err != nil || changed || string(out) != validDoc {
+		t.Fatalf("Upgrade() changed=%v err=%v", changed, err)
+	}
+}
+
+func TestUpgradeUnknownVersion(t *testing.T) {
+	for _, doc := range []string{`{"version":"0.3"}`, `{"version":2}`} {
+		if _, _, err := Upgrade([]byte(doc)); !errors.Is(err, ErrUnknownVersion) {
+			t.Errorf("Upgrade(%s) err = %v, want ErrUnknownVersion", doc, err)
+		}
+	}
+	if _, _, err := Upgrade([]byte(`{"nodes":"x"}`)); err == nil {
+		t.Error("Upgrade() accepted nodes of the wrong type")
+	}
+}
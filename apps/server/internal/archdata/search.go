@@ -0,0 +1,26 @@
+package archdata
+
+import "strings"
+
+// SearchText returns the words of raw worth indexing for full-text search:
+// node labels and component types. Invalid data yields an empty string.
+func SearchText(raw []byte) string {
+	s, err := Parse(raw)
+	if err != nil {
+		return ""
+	}
+	seen := make(map[string]bool)
+	var words []string
+	add := func(w string) {
+		w = strings.TrimSpace(w)
+		if w != "" && !seen[w] {
+			seen[w] = true
+			words = append(words, w)
+		}
+	}
+	for _, n := range s.Nodes {
+		add(n.Data.Label)
+		add(strings.ReplaceAll(n.Data.ComponentType, "_", " "))
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,14 @@
+package archdata
+
+import "testing"
+
+func TestSearchText(t *testing.T) {
+	got := SearchText([]byte(validDoc))
+	want := "DC datacenter API service DB postgresql"
+	if got != want {
+		t.Errorf("SearchText() = %q, want %q", got, want)
+	}
+	if got := SearchText([]byte(`not json`)); got != "" {
+		t.Errorf("SearchText(invalid) = %q, want empty", got)
+	}
+}
@@ -0,0 +1,355 @@
+package archdata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SupportedVersions lists the export format versions the server accepts.
+var SupportedVersions = []string{CurrentVersion}
+
+// Limits bound the size of an architecture document.
+type Limits struct {
+	MaxBytes int64
+	MaxNodes int
+	MaxEdges int
+}
+
+// DefaultLimits apply to architectures saved through the API.
+var DefaultLimits = Limits{
+	MaxBytes: 5 << 20,
+	MaxNodes: 1000,
+	MaxEdges: 5000,
+}
+
+// maxValidationErrors caps the report so a broken document cannot produce
+// a response larger than itself.
+const maxValidationErrors = 100
+
+// ValidationError is a single schema violation. Path is a JSON pointer
+// (RFC 6901) into the document, e.g. "/nodes/3/data/componentType".
+type ValidationError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors is the list of violations found by Validate.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	if len(e) == 0 {
+		return "archdata: valid"
+	}
+	msg := fmt.Sprintf("archdata: %s: %s", e[0].Path, e[0].Message)
+	if len(e) > 1 {
+		msg += fmt.Sprintf(" (and %d more)", len(e)-1)
+	}
+	return msg
+}
+
+// Validate checks raw against the export format: the version is supported,
+// required fields are present with the right types, IDs are unique, every
+// componentType is in the catalog, edges and parentId reference existing
+// nodes, parentId chains do not loop, and the document fits in limits.
+// It returns nil when the document is valid.
+func Validate(raw []byte, limits Limits) ValidationErrors {
+	v := &validator{}
+	if limits.MaxBytes > 0 && int64(len(raw)) > limits.MaxBytes {
+		v.add("", "document is %d bytes, limit is %d", len(raw), limits.MaxBytes)
+		return v.errs
+	}
+
+	if len(bytes.TrimSpace(raw)) == 0 {
+		v.add("", "is required")
+		return v.errs
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		v.add("", "invalid JSON: %v", err)
+		return v.errs
+	}
+	root, ok := doc.(map[string]any)
+	if !ok {
+		v.add("", "must be an object")
+		return v.errs
+	}
+
+	v.version(root)
+	v.metadata(root)
+	ids := v.nodes(root, limits.MaxNodes)
+	v.edges(root, ids, limits.MaxEdges)
+	return v.errs
+}
+
+type validator struct {
+	errs ValidationErrors
+}
+
+func (v *validator) add(path, format string, args ...any) {
+	if len(v.errs) < maxValidationErrors {
+		v.errs = append(v.errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+func (v *validator) version(root map[string]any) {
+	raw, ok := root["version"]
+	if !ok {
+		v.add("/version", "is required")
+		return
+	}
+	s, ok := raw.(string)
+	if !ok {
+		v.add("/version", "must be a string")
+		return
+	}
+	for _, sv := range SupportedVersions {
+		if s == sv {
+			return
+		}
+	}
+	v.add("/version", "unsupported version %q (supported: %s)", s, strings.Join(SupportedVersions, ", "))
+}
+
+func (v *validator) metadata(root map[string]any) {
+	raw, ok := root["metadata"]
+	if !ok || raw == nil {
+		return
+	}
+	m, ok := raw.(map[string]any)
+	if !ok {
+		v.add("/metadata", "must be an object")
+		return
+	}
+	for _, key := range []string{"name", "description", "createdAt", "updatedAt", "exportedAt"} {
+		v.optionalString(m, "/metadata/"+key, key)
+	}
+	if tags, ok := m["tags"]; ok && tags != nil {
+		list, ok := tags.([]any)
+		if !ok {
+			v.add("/metadata/tags", "must be an array")
+			return
+		}
+		for i, t := range list {
+			if _, ok := t.(string); !ok {
+				v.add(fmt.Sprintf("/metadata/tags/%d", i), "must be a string")
+			}
+		}
+	}
+}
+
+// nodes validates the node list and returns the parentId of every node
+// keyed by node ID, for edge and hierarchy checks.
+func (v *validator) nodes(root map[string]any, maxNodes int) map[string]string {
+	list, ok := v.array(root, "/nodes", "nodes")
+	if !ok {
+		return nil
+	}
+	if maxNodes > 0 && len(list) > maxNodes {
+		v.add("/nodes", "has %d nodes, limit is %d", len(list), maxNodes)
+		return nil
+	}
+
+	parents := make(map[string]string, len(list))
+	index := make(map[string]int, len(list))
+	var order []string
+	for i, raw := range list {
+		path := fmt.Sprintf("/nodes/%d", i)
+		n, ok := raw.(map[string]any)
+		if !ok {
+			v.add(path, "must be an object")
+			continue
+		}
+
+		id, ok := v.requiredString(n, path+"/id", "id")
+		if ok {
+			if _, dup := index[id]; dup {
+				v.add(path+"/id", "duplicate node id %q", id)
+				ok = false
+			} else {
+				index[id] = i
+				parents[id] = ""
+				order = append(order, id)
+			}
+		}
+
+		if pos, found := v.object(n, path+"/position", "position", true); found {
+			v.number(pos, path+"/position/x", "x")
+			v.number(pos, path+"/position/y", "y")
+		}
+
+		if data, found := v.object(n, path+"/data", "data", true); found {
+			v.requiredString(data, path+"/data/label", "label")
+			if ct, found := v.requiredString(data, path+"/data/componentType", "componentType"); found {
+				if _, known := Catalog[ct]; !known {
+					v.add(path+"/data/componentType", "unknown component type %q", ct)
+				}
+			}
+			v.object(data, path+"/data/config", "config", false)
+		}
+
+		if p, found := v.optionalString(n, path+"/parentId", "parentId"); found && ok {
+			parents[id] = p
+		}
+	}
+
+	for _, id := range order {
+		p := parents[id]
+		if p == "" {
+			continue
+		}
+		if _, ok := index[p]; !ok {
+			v.add(fmt.Sprintf("/nodes/%d/parentId", index[id]), "references unknown node %q", p)
+			parents[id] = ""
+		}
+	}
+	v.parentCycles(order, index, parents)
+	return parents
+}
+
+// parentCycles reports each parentId loop once, at the node where the
+// walk first re-enters it.
+func (v *validator) parentCycles(order []string, index map[string]int, parents map[string]string) {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(parents))
+	for _, start := range order {
+		if state[start] != unvisited {
+			continue
+		}
+		var path []string
+		id := start
+		for id != "" && state[id] == unvisited {
+			state[id] = visiting
+			path = append(path, id)
+			id = parents[id]
+		}
+		if id != "" && state[id] == visiting {
+			var cycle []string
+			for j := len(path) - 1; j >= 0; j-- {
+				cycle = append([]string{path[j]}, cycle...)
+				if path[j] == id {
+					break
+				}
+			}
+			cycle = append(cycle, id)
+			v.add(fmt.Sprintf("/nodes/%d/parentId", index[cycle[len(cycle)-2]]), "parentId forms a cycle: %s", strings.Join(cycle, " → "))
+		}
+		for _, p := range path {
+			state[p] = done
+		}
+	}
+}
+
+func (v *validator) edges(root map[string]any, nodes map[string]string, maxEdges int) {
+	list, ok := v.array(root, "/edges", "edges")
+	if !ok {
+		return
+	}
+	if maxEdges > 0 && len(list) > maxEdges {
+		v.add("/edges", "has %d edges, limit is %d", len(list), maxEdges)
+		return
+	}
+
+	seen := make(map[string]bool, len(list))
+	for i, raw := range list {
+		path := fmt.Sprintf("/edges/%d", i)
+		e, ok := raw.(map[string]any)
+		if !ok {
+			v.add(path, "must be an object")
+			continue
+		}
+		if id, ok := v.requiredString(e, path+"/id", "id"); ok {
+			if seen[id] {
+				v.add(path+"/id", "duplicate edge id %q", id)
+			}
+			seen[id] = true
+		}
+		for _, end := range []string{"source", "target"} {
+			ref, ok := v.requiredString(e, path+"/"+end, end)
+			if !ok || nodes == nil {
+				continue
+			}
+			if _, exists := nodes[ref]; !exists {
+				v.add(path+"/"+end, "references unknown node %q", ref)
+			}
+		}
+		v.object(e, path+"/data", "data", false)
+	}
+}
+
+func (v *validator) array(m map[string]any, path, key string) ([]any, bool) {
+	raw, ok := m[key]
+	if !ok || raw == nil {
+		v.add(path, "is required")
+		return nil, false
+	}
+	list, ok := raw.([]any)
+	if !ok {
+		v.add(path, "must be an array")
+	}
+	return list, ok
+}
+
+func (v *validator) object(m map[string]any, path, key string, required bool) (map[string]any, bool) {
+	raw, ok := m[key]
+	if !ok || raw == nil {
+		if required {
+			v.add(path, "is required")
+		}
+		return nil, false
+	}
+	obj, ok := raw.(map[string]any)
+	if !ok {
+		v.add(path, "must be an object")
+	}
+	return obj, ok
+}
+
+func (v *validator) requiredString(m map[string]any, path, key string) (string, bool) {
+	raw, ok := m[key]
+	if !ok || raw == nil {
+		v.add(path, "is required")
+		return "", false
+	}
+	s, ok := raw.(string)
+	if !ok {
+		v.add(path, "must be a string")
+		return "", false
+	}
+	if s == "" && key != "label" {
+		v.add(path, "must not be empty")
+		return "", false
+	}
+	return s, true
+}
+
+func (v *validator) optionalString(m map[string]any, path, key string) (string, bool) {
+	raw, ok := m[key]
+	if !ok || raw == nil {
+		return "", false
+	}
+	s, ok := raw.(string)
+	if !ok {
+		v.add(path, "must be a string")
+	}
+	return s, ok
+}
+
+func (v *validator) number(m map[string]any, path, key string) {
+	raw, ok := m[key]
+	if !ok {
+		v.add(path, "is required")
+		return
+	}
+	if _, ok := raw.(json.Number); !ok {
+		v.add(path, "must be a number")
+	}
+}
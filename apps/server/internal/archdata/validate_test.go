@@ -0,0 +1,155 @@
+package archdata
+
+import (
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const validDoc = `{
+  "version": "1.0",
+  "metadata": {"name": "demo", "tags": ["a"]},
+  "nodes": [
+    {"id": "dc", "position": {"x": 0, "y": 0}, "data": {"label": "DC", "componentType": "datacenter"}},
+    {"id": "api", "parentId": "dc", "position": {"x": 10, "y": 20}, "data": {"label": "API", "componentType": "service", "config": {"replicas": 2}}},
+    {"id": "db", "position": {"x": 10, "y": 200}, "data": {"label": "DB", "componentType": "postgresql"}}
+  ],
+  "edges": [
+    {"id": "e1", "source": "api", "target": "db", "data": {"protocol": "TCP"}}
+  ]
+}`
+
+func TestValidateAcceptsExportFormat(t *testing.T) {
+	if errs := Validate([]byte(validDoc), DefaultLimits); errs != nil {
+		t.Fatalf("Validate() = %v, want nil", errs)
+	}
+}
+
+func TestValidateReportsPointers(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		path string
+		msg  string
+	}{
+		{"empty", ``, "", "is required"},
+		{"null", `null`, "", "must be an object"},
+		{"array", `[]`, "", "must be an object"},
+		{"missing version", `{"nodes": [], "edges": []}`, "/version", "is required"},
+		{"unsupported version", `{"version": "9.9", "nodes": [], "edges": []}`, "/version", "unsupported version"},
+		{"missing nodes", `{"version": "1.0", "edges": []}`, "/nodes", "is required"},
+		{"edges not array", `{"version": "1.0", "nodes": [], "edges": {}}`, "/edges", "must be an array"},
+		{"metadata tag", `{"version": "1.0", "metadata": {"tags": [1]}, "nodes": [], "edges": []}`, "/metadata/tags/0", "must be a string"},
+		{
+			"unknown component",
+			`{"version": "1.0", "nodes": [{"id": "a", "position": {"x": 0, "y": 0}, "data": {"label": "", "componentType": "quantum_db"}}], "edges": []}`,
+			"/nodes/0/data/componentType", "unknown component type",
+		},
+		{
+			"missing position",
+			`{"version": "1.0", "nodes": [{"id": "a", "data": {"label": "", "componentType": "service"}}], "edges": []}`,
+			"/nodes/0/position", "is required",
+		},
+		{
+			"string coordinate",
+			`{"version": "1.0", "nodes": [{"id": "a", "position": {"x": "1", "y": 0}, "data": {"label": "", "componentType": "service"}}], "edges": []}`,
+			"/nodes/0/position/x", "must be a number",
+		},
+		{
+			"duplicate node",
+			`{"version": "1.0", "nodes": [
+				{"id": "a", "position": {"x": 0, "y": 0}, "data": {"label": "", "componentType": "service"}},
+				{"id": "a", "position": {"x": 0, "y": 0}, "data": {"label": "", "componentType": "service"}}
+			], "edges": []}`,
+			"/nodes/1/id", "duplicate node id",
+		},
+		{
+			"dangling edge",
+			`{"version": "1.0", "nodes": [{"id": "a", "position": {"x": 0, "y": 0}, "data": {"label": "", "componentType": "service"}}],
+			  "edges": [{"id": "e", "source": "a", "target": "ghost"}]}`,
+			"/edges/0/target", `unknown node "ghost"`,
+		},
+		{
+			"unknown parent",
+			`{"version": "1.0", "nodes": [{"id": "a", "parentId": "ghost", "position": {"x": 0, "y": 0}, "data": {"label": "", "componentType": "service"}}], "edges": []}`,
+			"/nodes/0/parentId", `unknown node "ghost"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := Validate([]byte(tt.doc), DefaultLimits)
+			for _, e := range errs {
+				if e.Path == tt.path && strings.Contains(e.Message, tt.msg) {
+					return
+				}
+			}
+			t.Fatalf("Validate() = %v, want %q at %q", errs, tt.msg, tt.path)
+		})
+	}
+}
+
+func TestValidateParentCycle(t *testing.T) {
+	doc := `{"version": "1.0", "nodes": [
+		{"id": "a", "parentId": "c", "position": {"x": 0, "y": 0}, "data": {"label": "", "componentType": "rack"}},
+		{"id": "b", "parentId": "a", "position": {"x": 0, "y": 0}, "data": {"label": "", "componentType": "rack"}},
+		{"id": "c", "parentId": "b", "position": {"x": 0, "y": 0}, "data": {"label": "", "componentType": "rack"}},
+		{"id": "d", "parentId": "d", "position": {"x": 0, "y": 0}, "data": {"label": "", "componentType": "rack"}},
+		{"id": "e", "parentId": "a", "position": {"x": 0, "y": 0}, "data": {"label": "", "componentType": "service"}}
+	], "edges": []}`
+
+	errs := Validate([]byte(doc), DefaultLimits)
+	if len(errs) != 2 {
+		t.Fatalf("Validate() = %v, want one error per cycle", errs)
+	}
+	if errs[0].Path != "/nodes/1/parentId" || !strings.Contains(errs[0].Message, "a → c → b → a") {
+		t.Errorf("errs[0] = %+v", errs[0])
+	}
+	if errs[1].Path != "/nodes/3/parentId" || !strings.Contains(errs[1].Message, "d → d") {
+		t.Errorf("errs[1] = %+v", errs[1])
+	}
+}
+
+func TestValidateLimits(t *testing.T) {
+	limits := Limits{MaxBytes: int64(len(validDoc)), MaxNodes: 2, MaxEdges: 1}
+	errs := Validate([]byte(validDoc), limits)
+	if len(errs) != 1 || errs[0].Path != "/nodes" {
+		t.Fatalf("Validate() = %v, want node limit error", errs)
+	}
+
+	limits.MaxBytes = 10
+	errs = Validate([]byte(validDoc), limits)
+	if len(errs) != 1 || errs[0].Path != "" || !strings.Contains(errs[0].Message, "limit is 10") {
+		t.Fatalf("Validate() = %v, want size limit error", errs)
+	}
+}
+
+// The component types listed in docs/export-json.md are the published
+// contract; they must be exactly the ones the server accepts.
+func TestCatalogMatchesDocs(t *testing.T) {
+	doc, err := os.ReadFile("../../../../docs/export-json.md")
+	if err != nil {
+		t.Skipf("docs not available: %v", err)
+	}
+	start := strings.Index(string(doc), "### Типы компонентов")
+	end := strings.Index(string(doc), "> **Forward compatibility:**")
+	if start < 0 || end < start {
+		t.Fatal("component type list not found in docs/export-json.md")
+	}
+	var documented []string
+	for _, m := range regexp.MustCompile("`([a-z0-9_]+)`").FindAllStringSubmatch(string(doc[start:end]), -1) {
+		if m[1] != "componentType" {
+			documented = append(documented, m[1])
+		}
+	}
+	var catalog []string
+	for ct := range Catalog {
+		catalog = append(catalog, ct)
+	}
+	sort.Strings(documented)
+	sort.Strings(catalog)
+	if strings.Join(documented, " ") != strings.Join(catalog, " ") {
+		t.Errorf("docs list %v\ncatalog has %v", documented, catalog)
+	}
+}
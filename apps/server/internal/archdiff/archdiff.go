@@ -0,0 +1,212 @@
+// Package archdiff compares and merges architecture documents. It works on
+// the decoded JSON rather than archdata.Schema so fields the server does not
+// model (routing rules, zIndex, ...) are compared and survive merges.
+package archdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// element is a node or edge keyed by its ID.
+type element struct {
+	ID string
+	V  map[string]any
+}
+
+type document struct {
+	Root     map[string]any
+	Metadata map[string]any
+	Nodes    []element
+	Edges    []element
+}
+
+func decode(raw []byte) (*document, error) {
+	var root map[string]any
+	if err := json.Unmarshal(raw, &root); err != nil {
+		return nil, fmt.Errorf("archdiff: %w", err)
+	}
+	if root == nil {
+		return nil, fmt.Errorf("archdiff: document is not an object")
+	}
+	d := &document{Root: root, Nodes: elements(root["nodes"]), Edges: elements(root["edges"])}
+	d.Metadata, _ = root["metadata"].(map[string]any)
+	return d, nil
+}
+
+func elements(v any) []element {
+	list, _ := v.([]any)
+	out := make([]element, 0, len(list))
+	for _, item := range list {
+		m, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		id, _ := m["id"].(string)
+		if id == "" {
+			continue
+		}
+		out = append(out, element{ID: id, V: m})
+	}
+	return out
+}
+
+// match pairs elements of b with elements of a, first by ID and then by
+// key for whatever is left, as long as the key is unique among the
+// unmatched elements on both sides. It returns b ID → a ID and how each
+// pair was matched ("id" or the fallback name).
+func match(a, b []element, key func(element) string, fallback string) (map[string]string, map[string]string) {
+	ids := make(map[string]string)
+	by := make(map[string]string)
+	inA := make(map[string]bool, len(a))
+	for _, e := range a {
+		inA[e.ID] = true
+	}
+	for _, e := range b {
+		if inA[e.ID] {
+			ids[e.ID] = e.ID
+			by[e.ID] = "id"
+		}
+	}
+
+	matchedA := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		matchedA[id] = true
+	}
+	freeA := make(map[string][]string)
+	for _, e := range a {
+		if k := key(e); k != "" && !matchedA[e.ID] {
+			freeA[k] = append(freeA[k], e.ID)
+		}
+	}
+	freeB := make(map[string][]string)
+	for _, e := range b {
+		if k := key(e); k != "" && ids[e.ID] == "" {
+			freeB[k] = append(freeB[k], e.ID)
+		}
+	}
+	for k, bs := range freeB {
+		if as := freeA[k]; len(bs) == 1 && len(as) == 1 {
+			ids[bs[0]] = as[0]
+			by[bs[0]] = fallback
+		}
+	}
+	return ids, by
+}
+
+// nodeKey matches nodes by label within the same component type.
+func nodeKey(e element) string {
+	data, _ := e.V["data"].(map[string]any)
+	label, _ := data["label"].(string)
+	if label == "" {
+		return ""
+	}
+	ct, _ := data["componentType"].(string)
+	return ct + "\x00" + label
+}
+
+// edgeKey matches edges by their endpoints; call it on edges whose
+// endpoints are already translated into the same ID space.
+func edgeKey(e element) string {
+	src, _ := e.V["source"].(string)
+	dst, _ := e.V["target"].(string)
+	if src == "" || dst == "" {
+		return ""
+	}
+	return src + "\x00" + dst
+}
+
+// rename returns a copy of e with its ID and the node references in refs
+// mapped through ids. IDs missing from ids are kept.
+func rename(e element, id string, ids map[string]string, refs ...string) element {
+	v := make(map[string]any, len(e.V))
+	for k, x := range e.V {
+		v[k] = x
+	}
+	v["id"] = id
+	for _, ref := range refs {
+		if s, ok := v[ref].(string); ok {
+			if to, ok := ids[s]; ok {
+				v[ref] = to
+			}
+		}
+	}
+	return element{ID: id, V: v}
+}
+
+// flatten maps every leaf of v to its JSON pointer. Arrays are leaves and
+// empty objects are dropped. Top-level keys in skip are ignored.
+func flatten(v map[string]any, skip map[string]bool) map[string]any {
+	out := make(map[string]any)
+	var walk func(prefix string, m map[string]any)
+	walk = func(prefix string, m map[string]any) {
+		for k, x := range m {
+			if prefix == "" && skip[k] {
+				continue
+			}
+			path := prefix + "/" + escape(k)
+			if child, ok := x.(map[string]any); ok {
+				walk(path, child)
+				continue
+			}
+			out[path] = x
+		}
+	}
+	walk("", v)
+	return out
+}
+
+// unflatten rebuilds an object from flatten output.
+func unflatten(leaves map[string]any) map[string]any {
+	root := make(map[string]any)
+	for _, path := range sortedPaths(leaves) {
+		parts := strings.Split(path[1:], "/")
+		m := root
+		for _, p := range parts[:len(parts)-1] {
+			p = unescape(p)
+			child, ok := m[p].(map[string]any)
+			if !ok {
+				child = make(map[string]any)
+				m[p] = child
+			}
+			m = child
+		}
+		m[unescape(parts[len(parts)-1])] = leaves[path]
+	}
+	return root
+}
+
+func escape(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+func unescape(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~1", "/"), "~0", "~")
+}
+
+func sortedPaths[V any](ms ...map[string]V) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, m := range ms {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				paths = append(paths, k)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func equal(a, b any) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+func str(v map[string]any, key string) string {
+	s, _ := v[key].(string)
+	return s
+}
@@ -0,0 +1,175 @@
+package archdiff
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const base = `{
+  "version": "1.0",
+  "metadata": {"name": "shop"},
+  "nodes": [
+    {"id": "lb", "position": {"x": 0, "y": 0}, "data": {"label": "LB", "componentType": "load_balancer", "config": {"algorithm": "round_robin"}}},
+    {"id": "api", "position": {"x": 0, "y": 100}, "data": {"label": "API", "componentType": "service", "config": {"replicas": 2}}},
+    {"id": "db", "position": {"x": 0, "y": 200}, "data": {"label": "DB", "componentType": "postgresql", "config": {"replicas": 1}}}
+  ],
+  "edges": [
+    {"id": "e1", "source": "lb", "target": "api"},
+    {"id": "e2", "source": "api", "target": "db", "data": {"protocol": "TCP"}}
+  ]
+}`
+
+func TestCompare(t *testing.T) {
+	// The API node was recreated with a new ID, scaled and re-wired to a cache.
+	other := `{
+	  "version": "1.0",
+	  "metadata": {"name": "shop v2"},
+	  "nodes": [
+	    {"id": "lb", "position": {"x": 0, "y": 0}, "selected": true, "data": {"label": "LB", "componentType": "load_balancer", "config": {"algorithm": "round_robin"}}},
+	    {"id": "api-2", "position": {"x": 0, "y": 100}, "data": {"label": "API", "componentType": "service", "config": {"replicas": 4, "cpu": 2000}}},
+	    {"id": "cache", "position": {"x": 100, "y": 200}, "data": {"label": "Cache", "componentType": "redis"}}
+	  ],
+	  "edges": [
+	    {"id": "e1-new", "source": "lb", "target": "api-2"},
+	    {"id": "e3", "source": "api-2", "target": "cache"}
+	  ]
+	}`
+
+	d, err := Compare([]byte(base), []byte(other))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d.Metadata) != 1 || d.Metadata[0].Path != "/name" || d.Metadata[0].Kind != "changed" {
+		t.Errorf("metadata = %+v", d.Metadata)
+	}
+	if len(d.Nodes.Added) != 1 || d.Nodes.Added[0].ID != "cache" {
+		t.Errorf("added nodes = %+v", d.Nodes.Added)
+	}
+	if len(d.Nodes.Removed) != 1 || d.Nodes.Removed[0].ID != "db" {
+		t.Errorf("removed nodes = %+v", d.Nodes.Removed)
+	}
+	if len(d.Nodes.Changed) != 1 {
+		t.Fatalf("changed nodes = %+v, want only the API (selection is not a change)", d.Nodes.Changed)
+	}
+	api := d.Nodes.Changed[0]
+	if api.ID != "api" || api.OtherID != "api-2" || api.MatchedBy != "label" {
+		t.Errorf("api match = %+v", api)
+	}
+	want := map[string]string{"/data/config/cpu": "added", "/data/config/replicas": "changed"}
+	if len(api.Changes) != len(want) {
+		t.Errorf("api changes = %+v", api.Changes)
+	}
+	for _, c := range api.Changes {
+		if want[c.Path] != c.Kind {
+			t.Errorf("change %+v, want kind %q", c, want[c.Path])
+		}
+	}
+
+	if len(d.Edges.Changed) != 0 {
+		t.Errorf("changed edges = %+v, want e1 matched by endpoints and unchanged", d.Edges.Changed)
+	}
+	if len(d.Edges.Added) != 1 || d.Edges.Added[0].ID != "e3" {
+		t.Errorf("added edges = %+v", d.Edges.Added)
+	}
+	if len(d.Edges.Removed) != 1 || d.Edges.Removed[0].ID != "e2" {
+		t.Errorf("removed edges = %+v", d.Edges.Removed)
+	}
+
+	same, err := Compare([]byte(base), []byte(base))
+	if err != nil || !same.Empty() {
+		t.Errorf("Compare(base, base) = %+v, %v", same, err)
+	}
+}
+
+func TestMergeClean(t *testing.T) {
+	// Ours moves the DB and scales the API; theirs adds a cache and changes the LB.
+	ours := `{"version": "1.0", "metadata": {"name": "shop"}, "nodes": [
+	    {"id": "lb", "position": {"x": 0, "y": 0}, "data": {"label": "LB", "componentType": "load_balancer", "config": {"algorithm": "round_robin"}}},
+	    {"id": "api", "position": {"x": 0, "y": 100}, "data": {"label": "API", "componentType": "service", "config": {"replicas": 3}}},
+	    {"id": "db", "position": {"x": 50, "y": 250}, "data": {"label": "DB", "componentType": "postgresql", "config": {"replicas": 1}}}
+	  ], "edges": [
+	    {"id": "e1", "source": "lb", "target": "api"},
+	    {"id": "e2", "source": "api", "target": "db", "data": {"protocol": "TCP"}}
+	  ]}`
+	theirs := `{"version": "1.0", "metadata": {"name": "shop"}, "nodes": [
+	    {"id": "lb", "position": {"x": 0, "y": 0}, "data": {"label": "LB", "componentType": "load_balancer", "config": {"algorithm": "least_connections"}}},
+	    {"id": "api", "position": {"x": 0, "y": 100}, "data": {"label": "API", "componentType": "service", "config": {"replicas": 2}}},
+	    {"id": "db", "position": {"x": 0, "y": 200}, "data": {"label": "DB", "componentType": "postgresql", "config": {"replicas": 1}}},
+	    {"id": "cache", "position": {"x": 100, "y": 200}, "data": {"label": "Cache", "componentType": "redis"}}
+	  ], "edges": [
+	    {"id": "e1", "source": "lb", "target": "api"},
+	    {"id": "e2", "source": "api", "target": "db", "data": {"protocol": "TCP"}},
+	    {"id": "e3", "source": "api", "target": "cache"}
+	  ]}`
+
+	res, err := Merge([]byte(base), []byte(ours), []byte(theirs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Conflicts) != 0 {
+		t.Fatalf("conflicts = %+v", res.Conflicts)
+	}
+
+	d, err := Compare([]byte(theirs), res.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Relative to theirs, only ours' edits remain.
+	if len(d.Nodes.Added)+len(d.Nodes.Removed) != 0 || len(d.Nodes.Changed) != 2 {
+		t.Fatalf("merged vs theirs = %+v", d.Nodes)
+	}
+	if len(d.Edges.Added)+len(d.Edges.Removed)+len(d.Edges.Changed) != 0 {
+		t.Errorf("merged edges differ from theirs: %+v", d.Edges)
+	}
+}
+
+func TestMergeConflicts(t *testing.T) {
+	// Both scale the API differently; ours deletes the DB theirs edited.
+	ours := `{"version": "1.0", "nodes": [
+	    {"id": "lb", "position": {"x": 0, "y": 0}, "data": {"label": "LB", "componentType": "load_balancer", "config": {"algorithm": "round_robin"}}},
+	    {"id": "api", "position": {"x": 0, "y": 100}, "data": {"label": "API", "componentType": "service", "config": {"replicas": 5}}}
+	  ], "edges": [{"id": "e1", "source": "lb", "target": "api"}]}`
+	theirs := `{"version": "1.0", "nodes": [
+	    {"id": "lb", "position": {"x": 0, "y": 0}, "data": {"label": "LB", "componentType": "load_balancer", "config": {"algorithm": "round_robin"}}},
+	    {"id": "api", "position": {"x": 0, "y": 100}, "data": {"label": "API", "componentType": "service", "config": {"replicas": 8}}},
+	    {"id": "db", "position": {"x": 0, "y": 200}, "data": {"label": "DB", "componentType": "postgresql", "config": {"replicas": 3}}}
+	  ], "edges": [
+	    {"id": "e1", "source": "lb", "target": "api"},
+	    {"id": "e2", "source": "api", "target": "db", "data": {"protocol": "TCP"}}
+	  ]}`
+
+	res, err := Merge([]byte(base), []byte(ours), []byte(theirs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := map[string]Conflict{}
+	for _, c := range res.Conflicts {
+		kinds[c.Kind] = c
+	}
+	if c, ok := kinds[BothModified]; !ok || c.ID != "api" || c.Path != "/data/config/replicas" {
+		t.Errorf("conflicts = %+v, want replicas conflict on api", res.Conflicts)
+	}
+	if c, ok := kinds[DeleteModify]; !ok || c.ID != "db" {
+		t.Errorf("conflicts = %+v, want delete/modify on db", res.Conflicts)
+	}
+
+	var merged struct {
+		Nodes []struct {
+			ID   string `json:"id"`
+			Data struct {
+				Config map[string]any `json:"config"`
+			} `json:"data"`
+		} `json:"nodes"`
+		Edges []json.RawMessage `json:"edges"`
+	}
+	if err := json.Unmarshal(res.Data, &merged); err != nil {
+		t.Fatal(err)
+	}
+	if len(merged.Nodes) != 2 || merged.Nodes[1].Data.Config["replicas"] != 5.0 {
+		t.Errorf("merged nodes = %+v, want ours to win", merged.Nodes)
+	}
+	if len(merged.Edges) != 1 {
+		t.Errorf("merged edges = %d, want 1", len(merged.Edges))
+	}
+}
@@ -0,0 +1,189 @@
+package archdiff
+
+// uiState are node and edge keys the canvas rewrites on every interaction;
+// they are not part of the design and never show up in a diff.
+var uiState = map[string]bool{
+	"id":       true,
+	"selected": true,
+	"dragging": true,
+	"resizing": true,
+	"measured": true,
+}
+
+// Change is one added, removed or changed leaf value. Path is a JSON
+// pointer relative to the node, edge or metadata object.
+type Change struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"`
+	Old  any    `json:"old,omitempty"`
+	New  any    `json:"new,omitempty"`
+}
+
+type NodeRef struct {
+	ID            string `json:"id"`
+	Label         string `json:"label"`
+	ComponentType string `json:"component_type"`
+}
+
+// NodeChange is a node present in both documents with different content.
+// ID is the node in a; OtherID is set when b uses a different ID and the
+// node was matched by label.
+type NodeChange struct {
+	NodeRef
+	OtherID   string   `json:"other_id,omitempty"`
+	MatchedBy string   `json:"matched_by"`
+	Changes   []Change `json:"changes"`
+}
+
+type EdgeRef struct {
+	ID     string `json:"id"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// EdgeChange is an edge present in both documents with different content.
+// Edges are matched by ID, then by their endpoints.
+type EdgeChange struct {
+	EdgeRef
+	OtherID   string   `json:"other_id,omitempty"`
+	MatchedBy string   `json:"matched_by"`
+	Changes   []Change `json:"changes"`
+}
+
+type NodeDiff struct {
+	Added   []NodeRef    `json:"added"`
+	Removed []NodeRef    `json:"removed"`
+	Changed []NodeChange `json:"changed"`
+}
+
+type EdgeDiff struct {
+	Added   []EdgeRef    `json:"added"`
+	Removed []EdgeRef    `json:"removed"`
+	Changed []EdgeChange `json:"changed"`
+}
+
+// Diff is the semantic difference from document a to document b.
+type Diff struct {
+	Metadata []Change `json:"metadata"`
+	Nodes    NodeDiff `json:"nodes"`
+	Edges    EdgeDiff `json:"edges"`
+}
+
+// Empty reports whether the documents are equivalent.
+func (d *Diff) Empty() bool {
+	return len(d.Metadata) == 0 &&
+		len(d.Nodes.Added)+len(d.Nodes.Removed)+len(d.Nodes.Changed) == 0 &&
+		len(d.Edges.Added)+len(d.Edges.Removed)+len(d.Edges.Changed) == 0
+}
+
+// Compare returns what changed from a to b. Nodes are matched by ID and
+// then by label within a component type, so a node recreated with a new ID
+// shows up as changed rather than removed and added.
+func Compare(a, b []byte) (*Diff, error) {
+	da, err := decode(a)
+	if err != nil {
+		return nil, err
+	}
+	db, err := decode(b)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Diff{
+		Metadata: changes(da.Metadata, db.Metadata, nil),
+		Nodes:    NodeDiff{Added: []NodeRef{}, Removed: []NodeRef{}, Changed: []NodeChange{}},
+		Edges:    EdgeDiff{Added: []EdgeRef{}, Removed: []EdgeRef{}, Changed: []EdgeChange{}},
+	}
+
+	nodeIDs, nodeBy := match(da.Nodes, db.Nodes, nodeKey, "label")
+	matchedA := make(map[string]bool)
+	for _, n := range db.Nodes {
+		aID, ok := nodeIDs[n.ID]
+		if !ok {
+			d.Nodes.Added = append(d.Nodes.Added, nodeRef(n))
+			continue
+		}
+		matchedA[aID] = true
+		an := findElement(da.Nodes, aID)
+		bn := rename(n, aID, nodeIDs, "parentId")
+		if cs := changes(an.V, bn.V, uiState); len(cs) > 0 {
+			c := NodeChange{NodeRef: nodeRef(an), MatchedBy: nodeBy[n.ID], Changes: cs}
+			if n.ID != aID {
+				c.OtherID = n.ID
+			}
+			d.Nodes.Changed = append(d.Nodes.Changed, c)
+		}
+	}
+	for _, n := range da.Nodes {
+		if !matchedA[n.ID] {
+			d.Nodes.Removed = append(d.Nodes.Removed, nodeRef(n))
+		}
+	}
+
+	// Compare edges with b's endpoints renamed into a's node IDs.
+	bEdges := make([]element, len(db.Edges))
+	for i, e := range db.Edges {
+		bEdges[i] = rename(e, e.ID, nodeIDs, "source", "target")
+	}
+	edgeIDs, edgeBy := match(da.Edges, bEdges, edgeKey, "endpoints")
+	matchedA = make(map[string]bool)
+	for i, e := range bEdges {
+		aID, ok := edgeIDs[e.ID]
+		if !ok {
+			d.Edges.Added = append(d.Edges.Added, edgeRef(db.Edges[i]))
+			continue
+		}
+		matchedA[aID] = true
+		ae := findElement(da.Edges, aID)
+		if cs := changes(ae.V, e.V, uiState); len(cs) > 0 {
+			c := EdgeChange{EdgeRef: edgeRef(ae), MatchedBy: edgeBy[e.ID], Changes: cs}
+			if e.ID != aID {
+				c.OtherID = e.ID
+			}
+			d.Edges.Changed = append(d.Edges.Changed, c)
+		}
+	}
+	for _, e := range da.Edges {
+		if !matchedA[e.ID] {
+			d.Edges.Removed = append(d.Edges.Removed, edgeRef(e))
+		}
+	}
+	return d, nil
+}
+
+// changes lists leaf differences between two objects in path order.
+func changes(a, b map[string]any, skip map[string]bool) []Change {
+	fa, fb := flatten(a, skip), flatten(b, skip)
+	out := []Change{}
+	for _, path := range sortedPaths(fa, fb) {
+		va, inA := fa[path]
+		vb, inB := fb[path]
+		switch {
+		case inA && !inB:
+			out = append(out, Change{Path: path, Kind: "removed", Old: va})
+		case !inA && inB:
+			out = append(out, Change{Path: path, Kind: "added", New: vb})
+		case !equal(va, vb):
+			out = append(out, Change{Path: path, Kind: "changed", Old: va, New: vb})
+		}
+	}
+	return out
+}
+
+func findElement(list []element, id string) element {
+	for _, e := range list {
+		if e.ID == id {
+			return e
+		}
+	}
+	return element{}
+}
+
+func nodeRef(e element) NodeRef {
+	data, _ := e.V["data"].(map[string]any)
+	return NodeRef{ID: e.ID, Label: str(data, "label"), ComponentType: str(data, "componentType")}
+}
+
+func edgeRef(e element) EdgeRef {
+	return EdgeRef{ID: e.ID, Source: str(e.V, "source"), Target: str(e.V, "target")}
+}
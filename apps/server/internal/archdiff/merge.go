@@ -0,0 +1,263 @@
+package archdiff
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Conflict kinds reported by Merge.
+const (
+	// BothModified: ours and theirs changed the same value differently.
+	BothModified = "both_modified"
+	// BothAdded: ours and theirs added the same element with different values.
+	BothAdded = "both_added"
+	// ModifyDelete: ours changed an element theirs deleted.
+	ModifyDelete = "modify_delete"
+	// DeleteModify: ours deleted an element theirs changed.
+	DeleteModify = "delete_modify"
+	// DanglingEdge: the merged edge points at a node that no longer exists.
+	DanglingEdge = "dangling_edge"
+	// DanglingParent: the merged node's container no longer exists.
+	DanglingParent = "dangling_parent"
+)
+
+// Conflict is a change Merge could not apply cleanly. The merged document
+// keeps the ours side; Base, Ours and Theirs show the competing values.
+type Conflict struct {
+	Kind    string `json:"kind"`
+	Element string `json:"element"`
+	ID      string `json:"id,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Base    any    `json:"base,omitempty"`
+	Ours    any    `json:"ours,omitempty"`
+	Theirs  any    `json:"theirs,omitempty"`
+}
+
+// Result is the outcome of a three-way merge.
+type Result struct {
+	Data      json.RawMessage `json:"data"`
+	Conflicts []Conflict      `json:"conflicts"`
+}
+
+// Merge applies the changes from base to theirs on top of ours. Nodes and
+// edges are matched across the three documents like in Compare; IDs in the
+// result are ours where the element exists there. Non-conflicting changes
+// are merged per leaf value, so ours moving a node and theirs resizing it
+// both land. Conflicts resolve to ours and are listed in the result.
+func Merge(base, ours, theirs []byte) (*Result, error) {
+	db, err := decode(base)
+	if err != nil {
+		return nil, fmt.Errorf("base: %w", err)
+	}
+	do, err := decode(ours)
+	if err != nil {
+		return nil, fmt.Errorf("ours: %w", err)
+	}
+	dt, err := decode(theirs)
+	if err != nil {
+		return nil, fmt.Errorf("theirs: %w", err)
+	}
+	m := &merger{conflicts: []Conflict{}}
+
+	nodeIDs := unify(db.Nodes, do.Nodes, dt.Nodes, nodeKey)
+	bn := renameAll(db.Nodes, nodeIDs.base, nodeIDs.base, "parentId")
+	on := renameAll(do.Nodes, nodeIDs.ours, nodeIDs.ours, "parentId")
+	tn := renameAll(dt.Nodes, nodeIDs.theirs, nodeIDs.theirs, "parentId")
+	nodes := m.elements("node", bn, on, tn)
+
+	be := renameAll(db.Edges, nil, nodeIDs.base, "source", "target")
+	oe := renameAll(do.Edges, nil, nodeIDs.ours, "source", "target")
+	te := renameAll(dt.Edges, nil, nodeIDs.theirs, "source", "target")
+	edgeIDs := unify(be, oe, te, edgeKey)
+	edges := m.elements("edge",
+		renameAll(be, edgeIDs.base, nil),
+		renameAll(oe, edgeIDs.ours, nil),
+		renameAll(te, edgeIDs.theirs, nil),
+	)
+
+	exists := make(map[string]bool, len(nodes))
+	for _, n := range nodes {
+		exists[n.ID] = true
+	}
+	for _, n := range nodes {
+		if p := str(n.V, "parentId"); p != "" && !exists[p] {
+			delete(n.V, "parentId")
+			m.conflicts = append(m.conflicts, Conflict{Kind: DanglingParent, Element: "node", ID: n.ID, Path: "/parentId", Ours: p})
+		}
+	}
+	kept := edges[:0]
+	for _, e := range edges {
+		if !exists[str(e.V, "source")] || !exists[str(e.V, "target")] {
+			m.conflicts = append(m.conflicts, Conflict{Kind: DanglingEdge, Element: "edge", ID: e.ID})
+			continue
+		}
+		kept = append(kept, e)
+	}
+
+	root := make(map[string]any, len(do.Root))
+	for k, v := range do.Root {
+		root[k] = v
+	}
+	root["metadata"] = m.fields("metadata", "", db.Metadata, do.Metadata, dt.Metadata, BothModified)
+	root["nodes"] = values(nodes)
+	root["edges"] = values(kept)
+
+	data, err := json.Marshal(root)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Data: data, Conflicts: m.conflicts}, nil
+}
+
+// idSpace maps the IDs of each side to the IDs used in the merged result.
+type idSpace struct {
+	base, ours, theirs map[string]string
+}
+
+// unify matches ours and theirs against base, and new elements of theirs
+// against new elements of ours, then names every element after its ours
+// counterpart when there is one.
+func unify(base, ours, theirs []element, key func(element) string) idSpace {
+	oursToBase, _ := match(base, ours, key, "key")
+	theirsToBase, _ := match(base, theirs, key, "key")
+
+	s := idSpace{base: map[string]string{}, ours: map[string]string{}, theirs: map[string]string{}}
+	for _, e := range base {
+		s.base[e.ID] = e.ID
+	}
+	for _, e := range ours {
+		s.ours[e.ID] = e.ID
+		if b, ok := oursToBase[e.ID]; ok {
+			s.base[b] = e.ID
+		}
+	}
+
+	var oursNew, theirsNew []element
+	for _, e := range ours {
+		if _, ok := oursToBase[e.ID]; !ok {
+			oursNew = append(oursNew, e)
+		}
+	}
+	for _, e := range theirs {
+		if _, ok := theirsToBase[e.ID]; !ok {
+			theirsNew = append(theirsNew, e)
+		}
+	}
+	newToOurs, _ := match(oursNew, theirsNew, key, "key")
+
+	for _, e := range theirs {
+		switch b, ok := theirsToBase[e.ID]; {
+		case ok:
+			s.theirs[e.ID] = s.base[b]
+		case newToOurs[e.ID] != "":
+			s.theirs[e.ID] = newToOurs[e.ID]
+		default:
+			s.theirs[e.ID] = e.ID
+		}
+	}
+	return s
+}
+
+// renameAll renames elements through ids (nil keeps them) and their node
+// references in refs through nodeIDs.
+func renameAll(list []element, ids, nodeIDs map[string]string, refs ...string) []element {
+	out := make([]element, len(list))
+	for i, e := range list {
+		id := e.ID
+		if to, ok := ids[id]; ok {
+			id = to
+		}
+		out[i] = rename(e, id, nodeIDs, refs...)
+	}
+	return out
+}
+
+type merger struct {
+	conflicts []Conflict
+}
+
+// elements merges one element list. The result keeps ours order followed
+// by elements only theirs added.
+func (m *merger) elements(kind string, base, ours, theirs []element) []element {
+	bi, ti := index(base), index(theirs)
+	oi := index(ours)
+	var out []element
+
+	for _, o := range ours {
+		b, inBase := bi[o.ID]
+		t, inTheirs := ti[o.ID]
+		switch {
+		case inTheirs && inBase:
+			out = append(out, element{ID: o.ID, V: m.fields(kind, o.ID, b.V, o.V, t.V, BothModified)})
+		case inTheirs:
+			out = append(out, element{ID: o.ID, V: m.fields(kind, o.ID, nil, o.V, t.V, BothAdded)})
+		case inBase:
+			if !equal(o.V, b.V) {
+				m.conflicts = append(m.conflicts, Conflict{Kind: ModifyDelete, Element: kind, ID: o.ID, Base: b.V, Ours: o.V})
+				out = append(out, o)
+			}
+		default:
+			out = append(out, o)
+		}
+	}
+
+	for _, t := range theirs {
+		if _, inOurs := oi[t.ID]; inOurs {
+			continue
+		}
+		b, inBase := bi[t.ID]
+		switch {
+		case !inBase:
+			out = append(out, t)
+		case !equal(t.V, b.V):
+			m.conflicts = append(m.conflicts, Conflict{Kind: DeleteModify, Element: kind, ID: t.ID, Base: b.V, Theirs: t.V})
+		}
+	}
+	return out
+}
+
+// fields merges three versions of one object leaf by leaf.
+func (m *merger) fields(kind, id string, base, ours, theirs map[string]any, conflictKind string) map[string]any {
+	fb, fo, ft := flatten(base, nil), flatten(ours, nil), flatten(theirs, nil)
+	out := make(map[string]any)
+	for _, path := range sortedPaths(fb, fo, ft) {
+		vb, inB := fb[path]
+		vo, inO := fo[path]
+		vt, inT := ft[path]
+		same := func(v1 any, in1 bool, v2 any, in2 bool) bool {
+			return in1 == in2 && (!in1 || equal(v1, v2))
+		}
+		switch {
+		case same(vo, inO, vt, inT), same(vt, inT, vb, inB):
+			if inO {
+				out[path] = vo
+			}
+		case same(vo, inO, vb, inB):
+			if inT {
+				out[path] = vt
+			}
+		default:
+			m.conflicts = append(m.conflicts, Conflict{Kind: conflictKind, Element: kind, ID: id, Path: path, Base: vb, Ours: vo, Theirs: vt})
+			if inO {
+				out[path] = vo
+			}
+		}
+	}
+	return unflatten(out)
+}
+
+func index(list []element) map[string]element {
+	m := make(map[string]element, len(list))
+	for _, e := range list {
+		m[e.ID] = e
+	}
+	return m
+}
+
+func values(list []element) []any {
+	out := make([]any, len(list))
+	for i, e := range list {
+		out[i] = e.V
+	}
+	return out
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+)
+
+// The canonicalization example from RFC 6376, section 3.4.5.
+func TestRelaxedCanonicalization(t *testing.T) {
+	if got := relaxedHeader("A", " X"); got != "a:X" {
+		t.Errorf("relaxedHeader(A) = %q", got)
+	}
+	if got := relaxedHeader("B ", " Y\t\r\n\tZ  "); got != "b:Y Z" {
+		t.Errorf("relaxedHeader(B) = %q", got)
+	}
+	if got := string(relaxedBody([]byte(" C \r\nD \t E\r\n\r\n\r\n"))); got != " C\r\nD E\r\n" {
+		t.Errorf("relaxedBody = %q", got)
+	}
+	if got := string(relaxedBody([]byte("\r\n\r\n"))); got != "" {
+		t.Errorf("relaxedBody of an empty body = %q", got)
+	}
+}
+
+func TestNewDKIMSigner(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := NewDKIMSigner("example.com", "mail", pemPKCS8(t, edKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer.algo != "ed25519-sha256" {
+		t.Errorf("algo = %q", signer.algo)
+	}
+
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+	signer, err = NewDKIMSigner("example.com", "mail", pkcs1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer.algo != "rsa-sha256" {
+		t.Errorf("algo = %q", signer.algo)
+	}
+	if _, err := NewDKIMSigner("example.com", "mail", []byte("not a key")); err == nil {
+		t.Error("expected an error for input without a PEM block")
+	}
+}
+
+func pemPKCS8(t *testing.T, key any) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
@@ -4,77 +4,130 @@ import (
 	"bytes"
 	"crypto/rand"
 	"crypto/tls"
-	_ "embed"
+	"errors"
 	"fmt"
-	"html/template"
+	"io"
 	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
 	"net/smtp"
+	"net/textproto"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/system-design-sandbox/server/internal/config"
 )
 
-//go:embed assets/login_email.html
-var loginEmailHTML string
-
-var loginEmailTmpl = template.Must(template.New("login_email").Parse(loginEmailHTML))
+// Message is a rendered email ready for delivery.
+type Message struct {
+	Template string // one of the Template constants, recorded in the outbox
+	To       string
+	Subject  string
+	// Text is the text/plain alternative to HTML; empty sends HTML only.
+	Text string
+	HTML string
+	// Unsubscribe is the one-click unsubscribe URL of non-transactional
+	// mail, sent as List-Unsubscribe; empty for transactional mail.
+	Unsubscribe string
+	// ExpiresAt is when the message stops being worth delivering, e.g. when
+	// the login code in it expires. Zero means never.
+	ExpiresAt time.Time
+}
 
-// EmailSender sends login emails.
+// EmailSender delivers rendered messages.
 type EmailSender interface {
-	SendLoginEmail(to, token, code, publicURL string) error
+	// Send delivers msg and returns the Message-ID it was sent with.
+	Send(msg Message) (string, error)
 }
 
-// NewEmailSender returns an SMTP sender if configured, otherwise a console fallback.
-func NewEmailSender(cfg config.SMTPConfig) EmailSender {
+// NewEmailSender returns an SMTP sender if configured, otherwise a console
+// fallback. The SMTP sender signs mail when DKIM is configured.
+func NewEmailSender(cfg config.SMTPConfig) (EmailSender, error) {
 	if cfg.Host == "" {
 		slog.Info("email: SMTP_HOST not set, using console fallback")
-		return &consoleSender{}
+		return &consoleSender{}, nil
+	}
+	s := &smtpSender{cfg: cfg}
+	if cfg.DKIM.Selector != "" {
+		signer, err := NewDKIMSigner(cfg.DKIM.Domain, cfg.DKIM.Selector, cfg.DKIM.Key)
+		if err != nil {
+			return nil, err
+		}
+		s.dkim = signer
+		slog.Info("email: DKIM signing enabled", "domain", signer.Domain, "selector", signer.Selector)
+	}
+	return s, nil
+}
+
+// bounceError is a permanent rejection of the recipient or message.
+type bounceError struct{ err error }
+
+func (e *bounceError) Error() string { return e.err.Error() }
+func (e *bounceError) Unwrap() error { return e.err }
+
+// IsHardBounce reports whether err is a 5xx SMTP reply to the recipient or
+// the message, which retrying will not fix.
+func IsHardBounce(err error) bool {
+	var b *bounceError
+	return errors.As(err, &b)
+}
+
+// Rejected marks a permanent (5xx) SMTP reply as a hard bounce; other
+// errors are returned unchanged.
+func Rejected(err error) error {
+	var te *textproto.Error
+	if errors.As(err, &te) && te.Code >= 500 {
+		return &bounceError{err: err}
 	}
-	return &smtpSender{cfg: cfg}
+	return err
 }
 
 // --- Console sender (fallback) ---
 
 type consoleSender struct{}
 
-func (s *consoleSender) SendLoginEmail(to, token, code, publicURL string) error {
-	link := publicURL + "/auth/verify?token=" + token
-	slog.Debug("login email (console)",
-		"to", to,
-		"code", code,
-		"link", link,
+// Send logs the message instead of delivering it; the text part carries
+// the login link and code for local development.
+func (s *consoleSender) Send(msg Message) (string, error) {
+	messageID := generateMessageID("")
+	slog.Debug("email (console)",
+		"template", msg.Template,
+		"to", msg.To,
+		"subject", msg.Subject,
+		"message_id", messageID,
+		"text", msg.Text,
 	)
-	return nil
+	return messageID, nil
 }
 
 // --- SMTP sender ---
 
 type smtpSender struct {
-	cfg config.SMTPConfig
+	cfg  config.SMTPConfig
+	dkim *DKIMSigner // nil sends unsigned mail
 }
 
-func (s *smtpSender) SendLoginEmail(to, token, code, publicURL string) error {
-	link := publicURL + "/auth/verify?token=" + token
+// oneLine keeps user-supplied names from starting new header lines.
+var oneLine = strings.NewReplacer("\r", " ", "\n", " ")
 
-	subject := "System Design Sandbox - Login Code: " + code
-	body := buildEmailHTML(code, link)
-	messageID := generateMessageID(s.cfg.From)
+// Send delivers one email over the configured transport. 5xx replies to
+// the recipient or message come back as hard bounces (IsHardBounce).
+func (s *smtpSender) Send(m Message) (string, error) {
+	messageID := generateMessageID(envelopeAddress(s.cfg.From))
+	to := m.To
 
-	msg := "From: " + s.cfg.From + "\r\n" +
-		"To: " + to + "\r\n" +
-		"Subject: " + subject + "\r\n" +
-		"Message-ID: " + messageID + "\r\n" +
-		"Date: " + time.Now().UTC().Format(time.RFC1123Z) + "\r\n" +
-		"MIME-Version: 1.0\r\n" +
-		"Content-Type: text/html; charset=UTF-8\r\n" +
-		"\r\n" + body
+	msg, err := buildMIME(s.cfg.From, messageID, m, time.Now(), s.dkim)
+	if err != nil {
+		return "", err
+	}
 
 	addr := fmt.Sprintf("%s:%d", s.cfg.Host, s.cfg.Port)
 
-	slog.Debug("sending login email", "to", to, "message_id", messageID)
+	slog.Debug("sending email", "to", to, "message_id", messageID)
 
-	var err error
 	switch strings.ToLower(s.cfg.TLS) {
 	case "tls":
 		err = s.sendTLS(addr, to, msg)
@@ -86,12 +139,146 @@ func (s *smtpSender) SendLoginEmail(to, token, code, publicURL string) error {
 
 	if err != nil {
 		slog.Error("email send failed", "to", to, "error", err)
+		return "", err
+	}
+	slog.Debug("email sent", "to", to, "message_id", messageID)
+	return messageID, nil
+}
+
+// buildMIME renders m as an RFC 5322 message, signed when dkim is set.
+// With a text part the body is multipart/alternative, plain text first so
+// clients prefer the HTML.
+func buildMIME(from, messageID string, m Message, now time.Time, dkim *DKIMSigner) (string, error) {
+	headers := []headerField{
+		{"From", formatAddress(from)},
+		{"To", formatAddress(m.To)},
+		{"Subject", encodeHeader("Subject", m.Subject)},
+		{"Date", now.UTC().Format(time.RFC1123Z)},
+		{"Message-ID", messageID},
+		{"MIME-Version", "1.0"},
+	}
+	if m.Unsubscribe != "" {
+		// One-click unsubscribe (RFC 8058): the mail client POSTs to the URL.
+		headers = append(headers,
+			headerField{"List-Unsubscribe", "<" + oneLine.Replace(m.Unsubscribe) + ">"},
+			headerField{"List-Unsubscribe-Post", "List-Unsubscribe=One-Click"},
+		)
+	}
+
+	var body bytes.Buffer
+	if m.Text == "" {
+		headers = append(headers,
+			headerField{"Content-Type", "text/html; charset=UTF-8"},
+			headerField{"Content-Transfer-Encoding", "quoted-printable"},
+		)
+		if err := writeQuotedPrintable(&body, m.HTML); err != nil {
+			return "", err
+		}
 	} else {
-		slog.Debug("email sent", "to", to, "message_id", messageID)
+		mw := multipart.NewWriter(&body)
+		for _, part := range []struct{ contentType, content string }{
+			{"text/plain; charset=UTF-8", m.Text},
+			{"text/html; charset=UTF-8", m.HTML},
+		} {
+			w, err := mw.CreatePart(textproto.MIMEHeader{
+				"Content-Type":              {part.contentType},
+				"Content-Transfer-Encoding": {"quoted-printable"},
+			})
+			if err != nil {
+				return "", err
+			}
+			if err := writeQuotedPrintable(w, part.content); err != nil {
+				return "", err
+			}
+		}
+		if err := mw.Close(); err != nil {
+			return "", err
+		}
+		headers = append(headers, headerField{"Content-Type", "multipart/alternative; boundary=\"" + mw.Boundary() + "\""})
 	}
-	return err
+
+	if dkim != nil {
+		sig, err := dkim.Sign(headers, body.Bytes(), now)
+		if err != nil {
+			return "", err
+		}
+		headers = append([]headerField{sig}, headers...)
+	}
+
+	var b strings.Builder
+	for _, h := range headers {
+		b.WriteString(h.name + ": " + h.value + "\r\n")
+	}
+	b.WriteString("\r\n")
+	b.Write(body.Bytes())
+	return b.String(), nil
+}
+
+// formatAddress renders an address header, RFC 2047-encoding a non-ASCII
+// display name. Unparseable input is kept on one line as is.
+func formatAddress(s string) string {
+	addr, err := mail.ParseAddress(s)
+	if err != nil {
+		return oneLine.Replace(s)
+	}
+	return addr.String()
+}
+
+// envelopeAddress is the bare address for MAIL FROM and RCPT TO.
+func envelopeAddress(s string) string {
+	if addr, err := mail.ParseAddress(s); err == nil {
+		return addr.Address
+	}
+	return s
 }
 
+// encodeHeader renders an unstructured header value: RFC 2047
+// encoded-words when it is not plain ASCII, folded to stay within 78
+// characters per line where it has spaces to fold at.
+func encodeHeader(name, value string) string {
+	value = oneLine.Replace(value)
+	for _, r := range value {
+		if r >= utf8.RuneSelf || (r < ' ' && r != '\t') {
+			value = mime.BEncoding.Encode("UTF-8", value)
+			break
+		}
+	}
+	return foldHeader(len(name)+2, value)
+}
+
+// foldHeader breaks value at spaces so lines stay within 78 characters;
+// used is what the line already holds before value. A first word that
+// does not fit, such as a long encoded-word, starts on the next line.
+func foldHeader(used int, value string) string {
+	words := strings.Split(value, " ")
+	var b strings.Builder
+	lineLen := used
+	for i, w := range words {
+		if lineLen+len(w)+1 > 78 && lineLen > 1 {
+			b.WriteString("\r\n ")
+			lineLen = 1
+		} else if i > 0 {
+			b.WriteByte(' ')
+			lineLen++
+		}
+		b.WriteString(w)
+		lineLen += len(w)
+	}
+	return b.String()
+}
+
+// writeQuotedPrintable encodes content with CRLF line breaks, keeping lines
+// within the SMTP limit whatever the template produced.
+func writeQuotedPrintable(w io.Writer, content string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qp, crlf.Replace(content)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+var crlf = strings.NewReplacer("\r\n", "\r\n", "\n", "\r\n")
+
 // generateMessageID creates an RFC 2822 compliant Message-ID using the sender domain.
 func generateMessageID(from string) string {
 	b := make([]byte, 16)
@@ -110,8 +297,26 @@ func (s *smtpSender) auth() smtp.Auth {
 	return smtp.PlainAuth("", s.cfg.User, s.cfg.Password, s.cfg.Host)
 }
 
+// sendPlain upgrades to TLS when the server offers STARTTLS, like
+// smtp.SendMail, but keeps the reply codes needed to spot bounces.
 func (s *smtpSender) sendPlain(addr, to, msg string) error {
-	return smtp.SendMail(addr, s.auth(), s.cfg.From, []string{to}, []byte(msg))
+	c, err := smtp.Dial(addr)
+	if err != nil {
+		return fmt.Errorf("smtp dial: %w", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: s.cfg.Host}); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if a := s.auth(); a != nil {
+		if err := c.Auth(a); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	return s.sendViaClient(c, to, msg)
 }
 
 func (s *smtpSender) sendSTARTTLS(addr, to, msg string) error {
@@ -155,37 +360,21 @@ func (s *smtpSender) sendTLS(addr, to, msg string) error {
 }
 
 func (s *smtpSender) sendViaClient(c *smtp.Client, to, msg string) error {
-	if err := c.Mail(s.cfg.From); err != nil {
+	if err := c.Mail(envelopeAddress(s.cfg.From)); err != nil {
 		return err
 	}
-	if err := c.Rcpt(to); err != nil {
-		return err
+	if err := c.Rcpt(envelopeAddress(to)); err != nil {
+		return Rejected(err)
 	}
 	w, err := c.Data()
 	if err != nil {
-		return err
+		return Rejected(err)
 	}
 	if _, err := w.Write([]byte(msg)); err != nil {
 		return err
 	}
 	if err := w.Close(); err != nil {
-		return err
+		return Rejected(err)
 	}
 	return c.Quit()
 }
-
-type emailData struct {
-	Code      string
-	Link      string
-	ExpiresAt string
-}
-
-func buildEmailHTML(code, link string) string {
-	expiresAt := time.Now().UTC().Add(authTokenTTL).Format("15:04 UTC")
-	var buf bytes.Buffer
-	if err := loginEmailTmpl.Execute(&buf, emailData{Code: code, Link: link, ExpiresAt: expiresAt}); err != nil {
-		slog.Error("email: template render failed", "error", err)
-		return ""
-	}
-	return buf.String()
-}
@@ -0,0 +1,501 @@
+package auth
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"net/url"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Email locales. DefaultLocale is used when the recipient's is unknown or
+// unsupported.
+const (
+	LocaleEN      = "en"
+	LocaleRU      = "ru"
+	DefaultLocale = LocaleEN
+)
+
+// Locales lists the locales every email template is written in.
+var Locales = []string{LocaleEN, LocaleRU}
+
+// ValidLocale reports whether emails can be rendered in locale.
+func ValidLocale(locale string) bool {
+	for _, l := range Locales {
+		if l == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchLocale picks the first supported language of an Accept-Language
+// header, ignoring quality weights, or DefaultLocale.
+func MatchLocale(acceptLanguage string) string {
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if ValidLocale(lang) {
+			return lang
+		}
+	}
+	return DefaultLocale
+}
+
+// Email templates.
+const (
+	TemplateLogin             = "login"
+	TemplateWorkspaceInvite   = "workspace_invite"
+	TemplateCommentMention    = "comment_mention"
+	TemplateNewDevice         = "new_device"
+	TemplateDataExport        = "data_export"
+	TemplateAccountDeletion   = "account_deletion"
+	TemplateEmailChange       = "email_change"
+	TemplateEmailChangeNotice = "email_change_notice"
+)
+
+// OptionalEmails lists the templates a user may unsubscribe from; the rest
+// are transactional and always sent.
+var OptionalEmails = []string{TemplateCommentMention, TemplateNewDevice}
+
+// ValidOptionalEmail reports whether users may unsubscribe from template.
+func ValidOptionalEmail(template string) bool {
+	for _, t := range OptionalEmails {
+		if t == template {
+			return true
+		}
+	}
+	return false
+}
+
+// UnsubscribeLink is the one-click unsubscribe endpoint for list, keyed by
+// the recipient's unsubscribe token.
+func UnsubscribeLink(publicURL, token, list string) string {
+	return publicURL + "/api/v1/email/unsubscribe?" + url.Values{"token": {token}, "list": {list}}.Encode()
+}
+
+// RevokeSessionLink is the "this wasn't me" endpoint that signs out the
+// session a sign-in alert was sent for.
+func RevokeSessionLink(publicURL, token string) string {
+	return publicURL + "/api/v1/auth/revoke?" + url.Values{"token": {token}}.Encode()
+}
+
+// EmailChangeCancelLink is the endpoint that cancels an email change, or
+// reverts it once confirmed, from the notice sent to the old address.
+func EmailChangeCancelLink(publicURL, token string) string {
+	return publicURL + "/api/v1/auth/email-change/cancel?" + url.Values{"token": {token}}.Encode()
+}
+
+//go:embed templates
+var templateFS embed.FS
+
+// Registry holds email templates by name. For every locale a template has
+// <locale>/<name>.txt, defining the "subject" and "text" parts with
+// text/template, and <locale>/<name>.html, the HTML part.
+type Registry struct {
+	fsys      fs.FS
+	templates map[string]*emailTemplate
+}
+
+type emailTemplate struct {
+	text   map[string]*texttemplate.Template
+	html   map[string]*htmltemplate.Template
+	sample any
+}
+
+// NewRegistry returns an empty registry reading templates from fsys.
+func NewRegistry(fsys fs.FS) *Registry {
+	return &Registry{fsys: fsys, templates: map[string]*emailTemplate{}}
+}
+
+// Register parses the template name in every locale. sample is example
+// data used for previews.
+func (r *Registry) Register(name string, sample any) error {
+	t := &emailTemplate{
+		text:   make(map[string]*texttemplate.Template, len(Locales)),
+		html:   make(map[string]*htmltemplate.Template, len(Locales)),
+		sample: sample,
+	}
+	for _, locale := range Locales {
+		base := locale + "/" + name
+		text, err := texttemplate.ParseFS(r.fsys, base+".txt")
+		if err != nil {
+			return fmt.Errorf("email template %s: %w", base, err)
+		}
+		for _, part := range []string{"subject", "text"} {
+			if text.Lookup(part) == nil {
+				return fmt.Errorf("email template %s.txt: no %q part", base, part)
+			}
+		}
+		html, err := htmltemplate.ParseFS(r.fsys, base+".html")
+		if err != nil {
+			return fmt.Errorf("email template %s: %w", base, err)
+		}
+		t.text[locale], t.html[locale] = text, html
+	}
+	r.templates[name] = t
+	return nil
+}
+
+// Names lists the registered templates in order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.templates))
+	for name := range r.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render renders the template name for to in locale, falling back to
+// DefaultLocale.
+func (r *Registry) Render(name, locale, to string, data any) (Message, error) {
+	t, ok := r.templates[name]
+	if !ok {
+		return Message{}, fmt.Errorf("email template %q is not registered", name)
+	}
+	if !ValidLocale(locale) {
+		locale = DefaultLocale
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := t.text[locale].ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("email template %s/%s: %w", locale, name, err)
+	}
+	if err := t.text[locale].ExecuteTemplate(&text, "text", data); err != nil {
+		return Message{}, fmt.Errorf("email template %s/%s: %w", locale, name, err)
+	}
+	if err := t.html[locale].Execute(&html, data); err != nil {
+		return Message{}, fmt.Errorf("email template %s/%s: %w", locale, name, err)
+	}
+	return Message{
+		Template: name,
+		To:       to,
+		Subject:  oneLine.Replace(strings.TrimSpace(subject.String())),
+		Text:     strings.TrimLeft(text.String(), "\n"),
+		HTML:     html.String(),
+	}, nil
+}
+
+// Preview renders the template name with its sample data.
+func (r *Registry) Preview(name, locale string) (Message, error) {
+	t, ok := r.templates[name]
+	if !ok {
+		return Message{}, fmt.Errorf("email template %q is not registered", name)
+	}
+	return r.Render(name, locale, "user@example.com", t.sample)
+}
+
+// Templates holds the built-in emails.
+var Templates = builtinTemplates()
+
+func builtinTemplates() *Registry {
+	sub, err := fs.Sub(templateFS, "templates")
+	if err != nil {
+		panic(err)
+	}
+	r := NewRegistry(sub)
+	expires := time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC)
+	for name, sample := range map[string]any{
+		TemplateLogin: loginData{
+			Code: "ABC-DEF", Link: "https://example.com/auth/verify?token=sample",
+			ExpiresAt: expires.Format("15:04 UTC"), Minutes: int(authTokenTTL / time.Minute),
+		},
+		TemplateWorkspaceInvite: workspaceInviteData{
+			Workspace: "Platform", Inviter: "Ann", Role: "editor",
+			Link: "https://example.com/?invite=sample", ExpiresAt: expires.Format(emailTimeLayout),
+		},
+		TemplateCommentMention: commentMentionData{
+			Author: "Ann", Architecture: "Chat backend",
+			Excerpt:     "@you could the cache sit in front of the database here?",
+			Link:        "https://example.com/?architecture=sample&thread=sample",
+			Unsubscribe: "https://example.com/api/v1/email/unsubscribe?list=comment_mention&token=sample",
+		},
+		TemplateNewDevice: newDeviceData{
+			Device: "Firefox on Linux", IP: "203.0.113.7", Location: "Berlin, Germany",
+			Time: expires.Format(emailTimeLayout), Link: "https://example.com/?settings=sessions",
+			RevokeLink:  "https://example.com/api/v1/auth/revoke?token=sample",
+			Unsubscribe: "https://example.com/api/v1/email/unsubscribe?list=new_device&token=sample",
+		},
+		TemplateDataExport: dataExportData{
+			Link:         "https://example.com/api/v1/exports/sample",
+			SessionsLink: "https://example.com/?settings=sessions",
+			ExpiresAt:    expires.Format(emailTimeLayout),
+		},
+		TemplateAccountDeletion: accountDeletionData{
+			DeleteAt: expires.Format(emailDateLayout),
+			Link:     "https://example.com/",
+		},
+		TemplateEmailChange: emailChangeData{
+			Email: "new@example.com", Code: "ABC-DEF",
+			ExpiresAt: expires.Format("15:04 UTC"), Minutes: int(emailChangeTTL / time.Minute),
+		},
+		TemplateEmailChangeNotice: emailChangeNoticeData{
+			NewEmail:   "new@example.com",
+			CancelLink: "https://example.com/api/v1/auth/email-change/cancel?token=sample",
+			ExpiresAt:  expires.Format(emailTimeLayout),
+		},
+	} {
+		if err := r.Register(name, sample); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+const (
+	emailTimeLayout = "2006-01-02 15:04 UTC"
+	emailDateLayout = "2006-01-02"
+)
+
+// --- Built-in emails ---
+
+type loginData struct {
+	Code      string
+	Link      string
+	ExpiresAt string
+	Minutes   int
+}
+
+// LoginEmail renders the login code and magic link email. It expires with
+// the code.
+func LoginEmail(to, locale, token, code, publicURL string) (Message, error) {
+	expiresAt := time.Now().Add(authTokenTTL)
+	m, err := Templates.Render(TemplateLogin, locale, to, loginData{
+		Code:      code,
+		Link:      publicURL + "/auth/verify?token=" + token,
+		ExpiresAt: expiresAt.UTC().Format("15:04 UTC"),
+		Minutes:   int(authTokenTTL / time.Minute),
+	})
+	m.ExpiresAt = expiresAt
+	return m, err
+}
+
+// WorkspaceInvite describes an invitation email. Token is the raw invite
+// token; only its hash is stored.
+type WorkspaceInvite struct {
+	Workspace string
+	Inviter   string
+	Role      string
+	Token     string
+	ExpiresAt time.Time
+}
+
+type workspaceInviteData struct {
+	Workspace string
+	Inviter   string
+	Role      string
+	Link      string
+	ExpiresAt string
+}
+
+// WorkspaceInviteEmail renders a workspace invitation. It expires with the
+// invite.
+func WorkspaceInviteEmail(to, locale string, invite WorkspaceInvite, publicURL string) (Message, error) {
+	m, err := Templates.Render(TemplateWorkspaceInvite, locale, to, workspaceInviteData{
+		Workspace: invite.Workspace,
+		Inviter:   invite.Inviter,
+		Role:      invite.Role,
+		Link:      workspaceInviteLink(publicURL, invite.Token),
+		ExpiresAt: invite.ExpiresAt.UTC().Format(emailTimeLayout),
+	})
+	m.ExpiresAt = invite.ExpiresAt
+	return m, err
+}
+
+// workspaceInviteLink is the web app page that accepts an invitation.
+func workspaceInviteLink(publicURL, token string) string {
+	return publicURL + "/?invite=" + token
+}
+
+// CommentMention describes an email to a user mentioned in a comment.
+type CommentMention struct {
+	Architecture   string
+	ArchitectureID string
+	ThreadID       string
+	Author         string
+	Excerpt        string
+}
+
+type commentMentionData struct {
+	Author       string
+	Architecture string
+	Excerpt      string
+	Link         string
+	Unsubscribe  string
+}
+
+// CommentMentionEmail renders a mention notification. It is optional mail,
+// so it carries an unsubscribe link for the recipient's unsubscribeToken.
+func CommentMentionEmail(to, locale string, mention CommentMention, unsubscribeToken, publicURL string) (Message, error) {
+	unsubscribe := UnsubscribeLink(publicURL, unsubscribeToken, TemplateCommentMention)
+	m, err := Templates.Render(TemplateCommentMention, locale, to, commentMentionData{
+		Author:       mention.Author,
+		Architecture: mention.Architecture,
+		Excerpt:      mention.Excerpt,
+		Link:         commentThreadLink(publicURL, mention),
+		Unsubscribe:  unsubscribe,
+	})
+	m.Unsubscribe = unsubscribe
+	return m, err
+}
+
+// commentThreadLink opens the architecture with the thread selected.
+func commentThreadLink(publicURL string, m CommentMention) string {
+	return publicURL + "/?architecture=" + m.ArchitectureID + "&thread=" + m.ThreadID
+}
+
+// NewDeviceAlert describes a sign-in from a device or country the user has
+// not used before.
+type NewDeviceAlert struct {
+	UserAgent string
+	IP        string
+	Location  string
+	At        time.Time
+	// RevokeToken signs out the new session from the email (CreateRevokeToken).
+	RevokeToken string
+}
+
+type newDeviceData struct {
+	Device      string
+	IP          string
+	Location    string
+	Time        string
+	Link        string
+	RevokeLink  string
+	Unsubscribe string
+}
+
+// NewDeviceAlertEmail renders a new sign-in alert with a link that revokes
+// the session and one to the user's sessions. Users may opt out of it, so
+// it carries an unsubscribe link for the recipient's unsubscribeToken.
+func NewDeviceAlertEmail(to, locale string, alert NewDeviceAlert, unsubscribeToken, publicURL string) (Message, error) {
+	unsubscribe := UnsubscribeLink(publicURL, unsubscribeToken, TemplateNewDevice)
+	m, err := Templates.Render(TemplateNewDevice, locale, to, newDeviceData{
+		Device:      DeviceName(alert.UserAgent),
+		IP:          alert.IP,
+		Location:    alert.Location,
+		Time:        alert.At.UTC().Format(emailTimeLayout),
+		Link:        publicURL + "/?settings=sessions",
+		RevokeLink:  RevokeSessionLink(publicURL, alert.RevokeToken),
+		Unsubscribe: unsubscribe,
+	})
+	m.Unsubscribe = unsubscribe
+	return m, err
+}
+
+type dataExportData struct {
+	Link         string
+	SessionsLink string
+	ExpiresAt    string
+}
+
+// DataExportEmail renders the download link of a finished data export.
+// Token is the raw download token; only its hash is stored. It expires
+// with the export.
+func DataExportEmail(to, locale, token string, expiresAt time.Time, publicURL string) (Message, error) {
+	m, err := Templates.Render(TemplateDataExport, locale, to, dataExportData{
+		Link:         DataExportLink(publicURL, token),
+		SessionsLink: publicURL + "/?settings=sessions",
+		ExpiresAt:    expiresAt.UTC().Format(emailTimeLayout),
+	})
+	m.ExpiresAt = expiresAt
+	return m, err
+}
+
+// DataExportLink is the download endpoint of a data export.
+func DataExportLink(publicURL, token string) string {
+	return publicURL + "/api/v1/exports/" + token
+}
+
+type accountDeletionData struct {
+	DeleteAt string
+	Link     string
+}
+
+// AccountDeletionEmail confirms that the account will be deleted at
+// deleteAt and tells the user how to keep it.
+func AccountDeletionEmail(to, locale string, deleteAt time.Time, publicURL string) (Message, error) {
+	return Templates.Render(TemplateAccountDeletion, locale, to, accountDeletionData{
+		DeleteAt: deleteAt.UTC().Format(emailDateLayout),
+		Link:     publicURL + "/",
+	})
+}
+
+type emailChangeData struct {
+	Email     string
+	Code      string
+	ExpiresAt string
+	Minutes   int
+}
+
+// EmailChangeEmail renders the code that confirms newEmail as the account's
+// address; it is sent to newEmail and expires with the code.
+func EmailChangeEmail(newEmail, locale, code string) (Message, error) {
+	expiresAt := time.Now().Add(emailChangeTTL)
+	m, err := Templates.Render(TemplateEmailChange, locale, newEmail, emailChangeData{
+		Email:     newEmail,
+		Code:      code,
+		ExpiresAt: expiresAt.UTC().Format("15:04 UTC"),
+		Minutes:   int(emailChangeTTL / time.Minute),
+	})
+	m.ExpiresAt = expiresAt
+	return m, err
+}
+
+type emailChangeNoticeData struct {
+	NewEmail   string
+	CancelLink string
+	ExpiresAt  string
+}
+
+// EmailChangeNoticeEmail tells the old address of an account that it is
+// being changed to newEmail, with a link that cancels the change for
+// cancelToken (SaveEmailChange).
+func EmailChangeNoticeEmail(oldEmail, locale, newEmail, cancelToken, publicURL string) (Message, error) {
+	return Templates.Render(TemplateEmailChangeNotice, locale, oldEmail, emailChangeNoticeData{
+		NewEmail:   newEmail,
+		CancelLink: EmailChangeCancelLink(publicURL, cancelToken),
+		ExpiresAt:  time.Now().Add(emailChangeCancelTTL).UTC().Format(emailTimeLayout),
+	})
+}
+
+// DeviceName summarizes a User-Agent as "<browser> on <OS>" for humans.
+func DeviceName(ua string) string {
+	browser := "Unknown browser"
+	for _, b := range []struct{ marker, name string }{
+		// Order matters: Edge and Opera also claim Chrome, Chrome claims Safari.
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"YaBrowser/", "Yandex Browser"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+	} {
+		if strings.Contains(ua, b.marker) {
+			browser = b.name
+			break
+		}
+	}
+	os := "unknown OS"
+	for _, o := range []struct{ marker, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(ua, o.marker) {
+			os = o.name
+			break
+		}
+	}
+	return browser + " on " + os
+}
@@ -0,0 +1,249 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestTemplatesRenderEveryLocale(t *testing.T) {
+	names := Templates.Names()
+	if len(names) != 8 {
+		t.Fatalf("registered templates = %v", names)
+	}
+	for _, name := range names {
+		for _, locale := range Locales {
+			t.Run(locale+"/"+name, func(t *testing.T) {
+				m, err := Templates.Preview(name, locale)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if m.Template != name || m.Subject == "" || m.Text == "" || m.HTML == "" {
+					t.Fatalf("incomplete message: %+v", m)
+				}
+				if strings.ContainsAny(m.Subject, "\r\n") {
+					t.Errorf("subject spans lines: %q", m.Subject)
+				}
+				if strings.Contains(m.Text, "<no value>") || strings.Contains(m.HTML, "<no value>") {
+					t.Error("template refers to a missing field")
+				}
+			})
+		}
+	}
+}
+
+func TestLoginEmail(t *testing.T) {
+	m, err := LoginEmail("user@example.com", LocaleEN, "tok", "ABC-DEF", "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Template != TemplateLogin || m.To != "user@example.com" {
+		t.Errorf("unexpected message: %+v", m)
+	}
+	if !strings.Contains(m.Subject, "ABC-DEF") {
+		t.Error("subject does not contain code")
+	}
+	for _, part := range []string{m.Text, m.HTML} {
+		if !strings.Contains(part, "ABC-DEF") || !strings.Contains(part, "https://example.com/auth/verify?token=tok") {
+			t.Errorf("part lacks code or link: %q", part)
+		}
+		if !strings.Contains(part, "5 minutes") {
+			t.Error("part does not mention expiry time")
+		}
+	}
+	if d := time.Until(m.ExpiresAt); d <= 0 || d > authTokenTTL {
+		t.Errorf("ExpiresAt should be within the token TTL, got %v", d)
+	}
+}
+
+func TestLoginEmailLocale(t *testing.T) {
+	ru, err := LoginEmail("user@example.com", LocaleRU, "tok", "ABC-DEF", "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(ru.Subject, "код для входа") || !strings.Contains(ru.HTML, `lang="ru"`) {
+		t.Errorf("expected Russian email, got subject %q", ru.Subject)
+	}
+
+	fallback, err := LoginEmail("user@example.com", "de", "tok", "ABC-DEF", "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(fallback.Subject, "Login Code") {
+		t.Errorf("unsupported locale should fall back to English, got %q", fallback.Subject)
+	}
+}
+
+func TestWorkspaceInviteEmail(t *testing.T) {
+	invite := WorkspaceInvite{
+		Workspace: "Platform <team>\r\nBcc: x",
+		Inviter:   "Ann",
+		Role:      "editor",
+		Token:     "tok",
+		ExpiresAt: time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC),
+	}
+	m, err := WorkspaceInviteEmail("user@example.com", LocaleEN, invite, "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.ContainsAny(m.Subject, "\r\n") {
+		t.Errorf("subject must stay on one line: %q", m.Subject)
+	}
+	if !m.ExpiresAt.Equal(invite.ExpiresAt) {
+		t.Error("message should expire with the invite")
+	}
+	if !strings.Contains(m.HTML, "Platform &lt;team&gt;") {
+		t.Error("HTML does not contain escaped workspace name")
+	}
+	if !strings.Contains(m.Text, "Platform <team>") {
+		t.Error("text part should not be HTML-escaped")
+	}
+	for _, part := range []string{m.Text, m.HTML} {
+		if !strings.Contains(part, "https://example.com/?invite=tok") || !strings.Contains(part, "2026-01-02 03:04 UTC") {
+			t.Errorf("part lacks link or expiry: %q", part)
+		}
+	}
+
+	ru, err := WorkspaceInviteEmail("user@example.com", LocaleRU, invite, "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(ru.Text, "редактор") || !strings.Contains(ru.HTML, "редактор") {
+		t.Error("role is not translated")
+	}
+}
+
+func TestCommentMentionEmail(t *testing.T) {
+	mention := CommentMention{Architecture: "Chat", ArchitectureID: "a1", ThreadID: "t1", Author: "Ann", Excerpt: "<b>look</b> at the cache"}
+	m, err := CommentMentionEmail("user@example.com", LocaleEN, mention, "u1", "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(m.HTML, "&lt;b&gt;look&lt;/b&gt; at the cache") {
+		t.Error("HTML does not contain escaped excerpt")
+	}
+	if !strings.Contains(m.HTML, "https://example.com/?architecture=a1&amp;thread=t1") {
+		t.Error("HTML does not contain thread link")
+	}
+	if !strings.Contains(m.Text, "https://example.com/?architecture=a1&thread=t1") {
+		t.Error("text does not contain thread link")
+	}
+	want := "https://example.com/api/v1/email/unsubscribe?list=comment_mention&token=u1"
+	if m.Unsubscribe != want {
+		t.Errorf("Unsubscribe = %q, want %q", m.Unsubscribe, want)
+	}
+	if !strings.Contains(m.Text, want) {
+		t.Error("text does not contain unsubscribe link")
+	}
+}
+
+func TestNewDeviceAlertEmail(t *testing.T) {
+	alert := NewDeviceAlert{
+		UserAgent:   "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0",
+		IP:          "203.0.113.7",
+		At:          time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC),
+		RevokeToken: "r1",
+	}
+	m, err := NewDeviceAlertEmail("user@example.com", LocaleEN, alert, "u1", "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(m.Subject, "Firefox on Linux") {
+		t.Errorf("subject = %q", m.Subject)
+	}
+	if !strings.Contains(m.Text, "unknown (203.0.113.7)") {
+		t.Error("text should mark the location unknown")
+	}
+	revoke := "https://example.com/api/v1/auth/revoke?token=r1"
+	if !strings.Contains(m.Text, revoke) || !strings.Contains(m.HTML, revoke) {
+		t.Error("email does not contain the revoke link")
+	}
+	if want := "https://example.com/api/v1/email/unsubscribe?list=new_device&token=u1"; m.Unsubscribe != want {
+		t.Errorf("Unsubscribe = %q, want %q", m.Unsubscribe, want)
+	}
+}
+
+func TestDataExportEmail(t *testing.T) {
+	expires := time.Date(2026, 1, 9, 3, 4, 0, 0, time.UTC)
+	m, err := DataExportEmail("user@example.com", LocaleEN, "t1", expires, "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := "https://example.com/api/v1/exports/t1"
+	if !strings.Contains(m.Text, link) || !strings.Contains(m.HTML, link) {
+		t.Error("email does not contain the download link")
+	}
+	if !strings.Contains(m.Text, "2026-01-09 03:04 UTC") {
+		t.Error("text does not mention the expiry")
+	}
+	if !m.ExpiresAt.Equal(expires) || m.Unsubscribe != "" {
+		t.Errorf("ExpiresAt = %v, Unsubscribe = %q", m.ExpiresAt, m.Unsubscribe)
+	}
+}
+
+func TestEmailChangeEmails(t *testing.T) {
+	m, err := EmailChangeEmail("new@example.com", LocaleRU, "ABC-DEF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.To != "new@example.com" || !strings.Contains(m.Subject, "ABC-DEF") || !strings.Contains(m.HTML, "ABC-DEF") {
+		t.Errorf("code email = %+v", m)
+	}
+	if m.ExpiresAt.IsZero() {
+		t.Error("code email does not expire")
+	}
+
+	m, err = EmailChangeNoticeEmail("old@example.com", LocaleEN, "new@example.com", "t1", "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := "https://example.com/api/v1/auth/email-change/cancel?token=t1"
+	if m.To != "old@example.com" || !strings.Contains(m.Text, link) || !strings.Contains(m.HTML, link) {
+		t.Error("notice does not go to the old address with the cancel link")
+	}
+	if !strings.Contains(m.Text, "new@example.com") {
+		t.Error("notice does not name the new address")
+	}
+}
+
+func TestDeviceName(t *testing.T) {
+	tests := map[string]string{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36 Edg/126.0":                   "Edge on Windows",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1": "Safari on iOS",
+		"Mozilla/5.0 (Linux; Android 14) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Mobile Safari/537.36":                                "Chrome on Android",
+		"curl/8.0": "Unknown browser on unknown OS",
+	}
+	for ua, want := range tests {
+		if got := DeviceName(ua); got != want {
+			t.Errorf("DeviceName(%q) = %q, want %q", ua, got, want)
+		}
+	}
+}
+
+func TestMatchLocale(t *testing.T) {
+	tests := map[string]string{
+		"":                        DefaultLocale,
+		"ru-RU,ru;q=0.9,en;q=0.8": LocaleRU,
+		"de-DE,de;q=0.9,en;q=0.8": LocaleEN,
+		"fr":                      DefaultLocale,
+		" RU ":                    LocaleRU,
+	}
+	for header, want := range tests {
+		if got := MatchLocale(header); got != want {
+			t.Errorf("MatchLocale(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestRegistryRejectsIncompleteTemplate(t *testing.T) {
+	fsys := fstest.MapFS{
+		"en/broken.txt":  {Data: []byte(`{{define "subject"}}Hi{{end}}`)},
+		"en/broken.html": {Data: []byte(`<p>Hi</p>`)},
+		"ru/broken.txt":  {Data: []byte(`{{define "subject"}}Привет{{end}}{{define "text"}}Привет{{end}}`)},
+		"ru/broken.html": {Data: []byte(`<p>Привет</p>`)},
+	}
+	if err := NewRegistry(fsys).Register("broken", nil); err == nil {
+		t.Fatal("expected an error for a template without a text part")
+	}
+}
@@ -1,31 +1,40 @@
 package auth
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/system-design-sandbox/server/internal/config"
 )
 
 func TestNewEmailSender_ConsoleFallback(t *testing.T) {
-	sender := NewEmailSender(config.SMTPConfig{Host: ""})
-	if sender == nil {
+	sender, err := NewEmailSender(config.SMTPConfig{Host: ""})
+	if err != nil || sender == nil {
 		t.Fatal("expected non-nil sender")
 	}
 	// Should be console sender — calling it should not panic
-	err := sender.SendLoginEmail("test@example.com", "token123", "ABC-DEF", "https://example.com")
+	_, err = sender.Send(Message{To: "test@example.com", Subject: "Hi", Text: "code", HTML: "<p>code</p>"})
 	if err != nil {
 		t.Fatalf("console sender should not error: %v", err)
 	}
 }
 
 func TestNewEmailSender_SMTPWhenHostSet(t *testing.T) {
-	sender := NewEmailSender(config.SMTPConfig{
+	sender, err := NewEmailSender(config.SMTPConfig{
 		Host: "smtp.example.com",
 		Port: 587,
 		From: "test@example.com",
 		TLS:  "starttls",
 	})
-	if sender == nil {
+	if err != nil || sender == nil {
 		t.Fatal("expected non-nil sender")
 	}
 	// Should be smtpSender, not consoleSender
@@ -36,26 +45,33 @@ func TestNewEmailSender_SMTPWhenHostSet(t *testing.T) {
 
 func TestConsoleSender_NoError(t *testing.T) {
 	s := &consoleSender{}
-	err := s.SendLoginEmail("user@example.com", "abc123", "XYZ-789", "https://test.com")
+	id, err := s.Send(Message{To: "user@example.com", Subject: "Hi", HTML: "<p>code</p>"})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+	if id == "" {
+		t.Error("expected a Message-ID")
+	}
 }
 
-func TestBuildEmailHTML(t *testing.T) {
-	html := buildEmailHTML("ABC-DEF", "https://example.com/auth/verify?token=xyz")
-
-	if !strContains(html, "ABC-DEF") {
-		t.Error("HTML does not contain code")
-	}
-	if !strContains(html, "https://example.com/auth/verify?token=xyz") {
-		t.Error("HTML does not contain link")
-	}
-	if !strContains(html, "5 minutes") {
-		t.Error("HTML does not mention expiry time")
+func TestIsHardBounce(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"mailbox unavailable", Rejected(&textproto.Error{Code: 550, Msg: "no such user"}), true},
+		{"greylisted", Rejected(&textproto.Error{Code: 451, Msg: "try again later"}), false},
+		{"network", Rejected(errors.New("connection reset")), false},
+		{"wrapped", fmt.Errorf("smtp: %w", Rejected(&textproto.Error{Code: 554, Msg: "rejected"})), true},
+		{"auth failure", fmt.Errorf("smtp auth: %w", &textproto.Error{Code: 535, Msg: "bad credentials"}), false},
 	}
-	if !strContains(html, "System Design Sandbox") {
-		t.Error("HTML does not contain app name")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsHardBounce(tt.err); got != tt.want {
+				t.Errorf("IsHardBounce() = %v, want %v", got, tt.want)
+			}
+		})
 	}
 }
 
@@ -67,3 +83,75 @@ func strContains(s, substr string) bool {
 	}
 	return false
 }
+
+func TestBuildMIMEAlternative(t *testing.T) {
+	m := Message{
+		To:      "user@example.com",
+		Subject: "Hi\r\nBcc: evil@example.com",
+		Text:    "Код: ABC-DEF\nline two",
+		HTML:    "<p>Код: ABC-DEF</p>",
+	}
+	raw, err := buildMIME("noreply@example.com", "<id@example.com>", m, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := msg.Header.Get("Subject"); got != "Hi  Bcc: evil@example.com" {
+		t.Errorf("Subject = %q, want it on one line", got)
+	}
+	if msg.Header.Get("Bcc") != "" {
+		t.Error("subject injected a Bcc header")
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, %v", msg.Header.Get("Content-Type"), err)
+	}
+
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	var types, bodies []string
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		// NextPart decodes quoted-printable.
+		b, err := io.ReadAll(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, p.Header.Get("Content-Type"))
+		bodies = append(bodies, string(b))
+	}
+	if len(types) != 2 || !strings.HasPrefix(types[0], "text/plain") || !strings.HasPrefix(types[1], "text/html") {
+		t.Fatalf("parts = %v, want text/plain then text/html", types)
+	}
+	if bodies[0] != "Код: ABC-DEF\r\nline two" || bodies[1] != m.HTML {
+		t.Errorf("bodies = %q", bodies)
+	}
+	for _, line := range strings.Split(raw, "\r\n") {
+		if len(line) > 998 {
+			t.Fatalf("line longer than 998 octets: %d", len(line))
+		}
+	}
+}
+
+func TestBuildMIMEHTMLOnly(t *testing.T) {
+	raw, err := buildMIME("noreply@example.com", "<id@example.com>", Message{To: "a@example.com", HTML: "<p>hi</p>"}, time.Now(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := msg.Header.Get("Content-Type"); ct != "text/html; charset=UTF-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+}
@@ -3,7 +3,7 @@ package auth
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -11,9 +11,18 @@ import (
 
 const (
 	authTokenTTL    = 5 * time.Minute
-	rateLimitMinTTL = 60 * time.Second
-	rateLimitHrTTL  = 3600 * time.Second
 	maxCodeAttempts = 5
+	// refreshLogInterval is how often a touch that extends a session is
+	// reported as a refresh, so active sessions do not flood session_log.
+	refreshLogInterval = 24 * time.Hour
+	// rotateGrace keeps a replaced session token working for requests that
+	// were already in flight with it.
+	rotateGrace = time.Minute
+	// emailChangeTTL is how long the code confirming a new address lasts.
+	emailChangeTTL = 15 * time.Minute
+	// emailChangeCancelTTL is how long the old address can cancel, or
+	// revert, an email change.
+	emailChangeCancelTTL = 7 * 24 * time.Hour
 )
 
 // AuthTokenData is the data stored in Redis for a pending auth token.
@@ -26,44 +35,63 @@ type AuthTokenData struct {
 // SessionData is the data stored as a Redis hash for an active session.
 // Using HSET allows atomic updates of individual fields (e.g. last_active_at)
 // without read-modify-write cycles.
-// UA is not stored here — it goes only to the session_log table.
+// The full UA is not stored here — it goes only to the session_log table;
+// Device keeps its browser and OS and Network the coarse network of the
+// proxy-reported peer address, for session binding.
 type SessionData struct {
 	UserID       string
 	IP           string
 	Geo          string
 	CountryCode  string
+	Device       string
+	Network      string
 	CreatedAt    string
 	LastActiveAt string
+	RotatedAt    string // when the session token last changed
+
+	// Set when reading a session.
+	ID string
+	// Refreshed is set by ValidateAndTouchSession when it extended the
+	// session and the last refresh is older than refreshLogInterval.
+	Refreshed bool
+	// ReplacedBy is set by ValidateAndTouchSession when the token used was
+	// rotated out within rotateGrace; it is the token to use instead.
+	ReplacedBy string
 }
 
 // RedisAuth provides auth-related Redis operations.
 type RedisAuth struct {
 	rdb              redis.UniversalClient
-	sessionExpiry    time.Duration
+	sessionExpiry    time.Duration // idle timeout, renewed on every touch
 	touchMinInterval time.Duration // skip touch if last update was within this window
-	rateLimitPerMin  int
-	rateLimitPerHr   int
+	// AbsoluteLifetime ends a session that long after sign-in however
+	// active it is; 0 lets active sessions live on.
+	AbsoluteLifetime time.Duration
 }
 
 // NewRedisAuth creates a new RedisAuth instance.
-func NewRedisAuth(rdb redis.UniversalClient, sessionExpiry, touchMinInterval time.Duration, rateLimitPerMin, rateLimitPerHr int) *RedisAuth {
+func NewRedisAuth(rdb redis.UniversalClient, sessionExpiry, touchMinInterval time.Duration) *RedisAuth {
 	return &RedisAuth{
 		rdb:              rdb,
 		sessionExpiry:    sessionExpiry,
 		touchMinInterval: touchMinInterval,
-		rateLimitPerMin:  rateLimitPerMin,
-		rateLimitPerHr:   rateLimitPerHr,
 	}
 }
 
 // --- Auth token operations ---
 
-func authTokenKey(token string) string    { return "auth:" + token }
-func authCodeKey(email, code string) string { return "auth:code:" + email + ":" + code }
-func rateLimitMinKey(email string) string { return "auth:rl:" + email + ":min" }
-func rateLimitHrKey(email string) string  { return "auth:rl:" + email + ":hour" }
+func authTokenKey(token string) string   { return "auth:" + token }
+func authPendingKey(email string) string { return "auth:pending:" + email }
 
-// SaveAuthToken stores the auth token and code index in Redis.
+// Errors from VerifyCode.
+var (
+	ErrCodeNotFound    = errors.New("auth: no pending code for email")
+	ErrCodeMismatch    = errors.New("auth: invalid code")
+	ErrTooManyAttempts = errors.New("auth: too many attempts")
+)
+
+// SaveAuthToken stores the auth token and makes code the pending code for
+// email, replacing any earlier one.
 func (ra *RedisAuth) SaveAuthToken(ctx context.Context, token, code, email string) error {
 	data := AuthTokenData{Code: code, Email: email, Attempts: 0}
 	b, err := json.Marshal(data)
@@ -72,7 +100,7 @@ func (ra *RedisAuth) SaveAuthToken(ctx context.Context, token, code, email strin
 	}
 	pipe := ra.rdb.Pipeline()
 	pipe.Set(ctx, authTokenKey(token), b, authTokenTTL)
-	pipe.Set(ctx, authCodeKey(email, NormalizeCode(code)), token, authTokenTTL)
+	pipe.Set(ctx, authPendingKey(email), token, authTokenTTL)
 	_, err = pipe.Exec(ctx)
 	return err
 }
@@ -90,150 +118,145 @@ func (ra *RedisAuth) GetAuthToken(ctx context.Context, token string) (*AuthToken
 	if err := json.Unmarshal([]byte(val), &data); err != nil {
 		return nil, err
 	}
-	// Clean up the code index
-	ra.rdb.Del(ctx, authCodeKey(data.Email, NormalizeCode(data.Code)))
+	// A pending code left pointing at the used token finds nothing and is
+	// cleaned up by the next VerifyCode.
 	return &data, nil
 }
 
-// GetAuthTokenByCode looks up the token via the code index, verifies attempts, and returns data.
-func (ra *RedisAuth) GetAuthTokenByCode(ctx context.Context, email, code string) (string, *AuthTokenData, error) {
-	normalizedCode := NormalizeCode(code)
-	tokenKey := authCodeKey(email, normalizedCode)
-
-	token, err := ra.rdb.Get(ctx, tokenKey).Result()
-	if err == redis.Nil {
-		return "", nil, nil
-	}
-	if err != nil {
-		return "", nil, err
-	}
-
-	// Get the token data (without deleting)
-	val, err := ra.rdb.Get(ctx, authTokenKey(token)).Result()
-	if err == redis.Nil {
-		return "", nil, nil
-	}
-	if err != nil {
-		return "", nil, err
-	}
-
-	var data AuthTokenData
-	if err := json.Unmarshal([]byte(val), &data); err != nil {
-		return "", nil, err
-	}
-
-	if data.Attempts >= maxCodeAttempts {
-		// Too many attempts — delete everything
-		ra.rdb.Del(ctx, authTokenKey(token), tokenKey)
-		return "", nil, fmt.Errorf("too many attempts")
-	}
-
-	return token, &data, nil
-}
-
-// IncrAttempts increments the attempt counter for a token.
-func (ra *RedisAuth) IncrAttempts(ctx context.Context, token string) error {
-	key := authTokenKey(token)
-	val, err := ra.rdb.Get(ctx, key).Result()
+// verifyCodeScript checks a code against the pending token of an email,
+// counts a wrong guess and consumes the token, all in one step, so parallel
+// guesses cannot race past maxCodeAttempts. The comparison is not constant
+// time; guessing is bounded by that cap and the per-email rate limit.
+//
+// KEYS[1] is the pending key; the token key is derived from it, so this
+// does not run on Redis Cluster. ARGV: normalized code, max attempts.
+// Returns {1, token data} on a match, {2} on a wrong code, {-1} when that
+// was the last attempt and {0} when no code is pending.
+var verifyCodeScript = redis.NewScript(`
+local token = redis.call('GET', KEYS[1])
+if not token then
+	return {0}
+end
+local key = 'auth:' .. token
+local raw = redis.call('GET', key)
+if not raw then
+	redis.call('DEL', KEYS[1])
+	return {0}
+end
+local data = cjson.decode(raw)
+if string.upper((string.gsub(data.code, '%-', ''))) == ARGV[1] then
+	redis.call('DEL', key, KEYS[1])
+	return {1, raw}
+end
+data.attempts = (data.attempts or 0) + 1
+if data.attempts >= tonumber(ARGV[2]) then
+	redis.call('DEL', key, KEYS[1])
+	return {-1}
+end
+redis.call('SET', key, cjson.encode(data), 'KEEPTTL')
+return {2}
+`)
+
+// VerifyCode checks code against the pending login code for email and
+// consumes its token on a match. A wrong code counts as an attempt; the
+// last allowed one invalidates the code. It returns ErrCodeNotFound,
+// ErrCodeMismatch or ErrTooManyAttempts when the code is not accepted.
+func (ra *RedisAuth) VerifyCode(ctx context.Context, email, code string) (*AuthTokenData, error) {
+	res, err := verifyCodeScript.Run(ctx, ra.rdb, []string{authPendingKey(email)}, NormalizeCode(code), maxCodeAttempts).Slice()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	var data AuthTokenData
-	if err := json.Unmarshal([]byte(val), &data); err != nil {
-		return err
+	if err := codeResult(res, &data); err != nil {
+		return nil, err
 	}
-	data.Attempts++
-	b, _ := json.Marshal(data)
-	return ra.rdb.Set(ctx, key, b, redis.KeepTTL).Err()
+	return &data, nil
 }
 
-// DeleteAuthToken removes both the token and its code index.
-func (ra *RedisAuth) DeleteAuthToken(ctx context.Context, token string) error {
-	val, err := ra.rdb.Get(ctx, authTokenKey(token)).Result()
-	if err == redis.Nil {
-		return nil
-	}
-	if err != nil {
-		return err
-	}
-	var data AuthTokenData
-	if err := json.Unmarshal([]byte(val), &data); err != nil {
-		return err
+// codeResult decodes the reply of a code-checking script into data, or
+// returns the error for a code that was not accepted.
+func codeResult(res []any, data any) error {
+	status, _ := res[0].(int64)
+	switch status {
+	case 1:
+		raw, _ := res[1].(string)
+		return json.Unmarshal([]byte(raw), data)
+	case 2:
+		return ErrCodeMismatch
+	case -1:
+		return ErrTooManyAttempts
+	default:
+		return ErrCodeNotFound
 	}
-	pipe := ra.rdb.Pipeline()
-	pipe.Del(ctx, authTokenKey(token))
-	pipe.Del(ctx, authCodeKey(data.Email, NormalizeCode(data.Code)))
-	_, err = pipe.Exec(ctx)
-	return err
-}
-
-// --- Rate limiting ---
-
-// RateLimitError is returned when the rate limit is exceeded.
-type RateLimitError struct {
-	RetryAfter int // seconds until the limit resets
 }
 
-func (e *RateLimitError) Error() string {
-	return fmt.Sprintf("rate limit exceeded, retry after %d seconds", e.RetryAfter)
-}
-
-// CheckRateLimit returns a *RateLimitError if the email has exceeded rate limits.
-func (ra *RedisAuth) CheckRateLimit(ctx context.Context, email string) error {
-	minKey := rateLimitMinKey(email)
-	hrKey := rateLimitHrKey(email)
-
-	minCount, err := ra.rdb.Get(ctx, minKey).Int()
-	if err != nil && err != redis.Nil {
-		return err
-	}
-	if minCount >= ra.rateLimitPerMin {
-		ttl, _ := ra.rdb.TTL(ctx, minKey).Result()
-		retryAfter := int(ttl.Seconds())
-		if retryAfter <= 0 {
-			retryAfter = int(rateLimitMinTTL.Seconds())
-		}
-		return &RateLimitError{RetryAfter: retryAfter}
-	}
-
-	hrCount, err := ra.rdb.Get(ctx, hrKey).Int()
-	if err != nil && err != redis.Nil {
-		return err
-	}
-	if hrCount >= ra.rateLimitPerHr {
-		ttl, _ := ra.rdb.TTL(ctx, hrKey).Result()
-		retryAfter := int(ttl.Seconds())
-		if retryAfter <= 0 {
-			retryAfter = int(rateLimitHrTTL.Seconds())
-		}
-		return &RateLimitError{RetryAfter: retryAfter}
-	}
-
-	pipe := ra.rdb.Pipeline()
-	pipe.Incr(ctx, minKey)
-	pipe.Expire(ctx, minKey, rateLimitMinTTL)
-	pipe.Incr(ctx, hrKey)
-	pipe.Expire(ctx, hrKey, rateLimitHrTTL)
-	_, err = pipe.Exec(ctx)
-	return err
+// discardCodeScript deletes the pending code of an email and its token.
+// KEYS[1] is the pending key, as in verifyCodeScript.
+var discardCodeScript = redis.NewScript(`
+local token = redis.call('GET', KEYS[1])
+if token then
+	redis.call('DEL', 'auth:' .. token)
+end
+return redis.call('DEL', KEYS[1])
+`)
+
+// DiscardPendingCode invalidates the login code and magic link last sent
+// to email, e.g. once the address no longer belongs to the account.
+func (ra *RedisAuth) DiscardPendingCode(ctx context.Context, email string) error {
+	return discardCodeScript.Run(ctx, ra.rdb, []string{authPendingKey(email)}).Err()
 }
 
 // --- Session operations (Redis hashes) ---
+//
+// The cookie holds a session token, not the session ID: st:<token> points
+// at the session, whose hash names its current token. Rotating the token
+// leaves the session ID, which logs and revoke links refer to, unchanged.
+// Sessions from before tokens have none and are found by the ID in the
+// cookie until their first rotation.
 
 const (
 	fUserID       = "uid"
 	fIP           = "ip"
 	fGeo          = "geo"
 	fCountryCode  = "cc"
+	fDevice       = "dev"
+	fNetwork      = "net"
 	fCreatedAt    = "cat"
 	fLastActiveAt = "lat"
+	fRefreshedAt  = "rat"
+	fRotatedAt    = "rtd"
+	fToken        = "tok"
 )
 
 func sessionKey(sessionID string) string   { return "s:" + sessionID }
+func sessionTokenKey(token string) string  { return "st:" + token }
 func userSessionsKey(userID string) string { return "su:" + userID }
 
-// CreateSession creates a new session as a Redis hash.
-func (ra *RedisAuth) CreateSession(ctx context.Context, sessionID string, data SessionData) error {
+// sessionTTL is how long a session touched at now may stay idle: the idle
+// timeout, cut short by the absolute lifetime.
+func (ra *RedisAuth) sessionTTL(createdAt string, now time.Time) time.Duration {
+	ttl := ra.sessionExpiry
+	if ra.AbsoluteLifetime <= 0 {
+		return ttl
+	}
+	created, err := time.Parse(time.RFC3339, createdAt)
+	if err != nil {
+		return ttl
+	}
+	if left := created.Add(ra.AbsoluteLifetime).Sub(now); left < ttl {
+		ttl = left
+	}
+	return ttl
+}
+
+// CreateSession creates a new session as a Redis hash and returns the
+// token for its cookie.
+func (ra *RedisAuth) CreateSession(ctx context.Context, sessionID string, data SessionData) (string, error) {
+	token, err := GenerateToken()
+	if err != nil {
+		return "", err
+	}
+	ttl := ra.sessionTTL(data.CreatedAt, time.Now())
 	key := sessionKey(sessionID)
 	pipe := ra.rdb.Pipeline()
 	pipe.HSet(ctx, key, map[string]interface{}{
@@ -241,14 +264,38 @@ func (ra *RedisAuth) CreateSession(ctx context.Context, sessionID string, data S
 		fIP:           data.IP,
 		fGeo:          data.Geo,
 		fCountryCode:  data.CountryCode,
+		fDevice:       data.Device,
+		fNetwork:      data.Network,
 		fCreatedAt:    data.CreatedAt,
 		fLastActiveAt: data.LastActiveAt,
+		fRefreshedAt:  data.CreatedAt,
+		fRotatedAt:    data.CreatedAt,
+		fToken:        token,
 	})
-	pipe.Expire(ctx, key, ra.sessionExpiry)
+	pipe.Expire(ctx, key, ttl)
+	pipe.Set(ctx, sessionTokenKey(token), sessionID, ttl)
 	pipe.SAdd(ctx, userSessionsKey(data.UserID), sessionID)
 	pipe.Expire(ctx, userSessionsKey(data.UserID), ra.sessionExpiry)
-	_, err := pipe.Exec(ctx)
-	return err
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// sessionFromHash reads a session hash as returned by HGETALL.
+func sessionFromHash(sessionID string, m map[string]string) *SessionData {
+	return &SessionData{
+		ID:           sessionID,
+		UserID:       m[fUserID],
+		IP:           m[fIP],
+		Geo:          m[fGeo],
+		CountryCode:  m[fCountryCode],
+		Device:       m[fDevice],
+		Network:      m[fNetwork],
+		CreatedAt:    m[fCreatedAt],
+		LastActiveAt: m[fLastActiveAt],
+		RotatedAt:    m[fRotatedAt],
+	}
 }
 
 // GetSession retrieves session data from a Redis hash.
@@ -260,14 +307,7 @@ func (ra *RedisAuth) GetSession(ctx context.Context, sessionID string) (*Session
 	if len(m) == 0 {
 		return nil, nil
 	}
-	return &SessionData{
-		UserID:       m[fUserID],
-		IP:           m[fIP],
-		Geo:          m[fGeo],
-		CountryCode:  m[fCountryCode],
-		CreatedAt:    m[fCreatedAt],
-		LastActiveAt: m[fLastActiveAt],
-	}, nil
+	return sessionFromHash(sessionID, m), nil
 }
 
 // ListUserSessions returns all active session IDs for a user.
@@ -336,39 +376,56 @@ func (ra *RedisAuth) ListUserSessionsFull(ctx context.Context, userID, currentSe
 	return sessions, nil
 }
 
-// DeleteSession removes a session from Redis.
+// DeleteSession removes a session and its token from Redis.
 func (ra *RedisAuth) DeleteSession(ctx context.Context, sessionID, userID string) error {
+	token, err := ra.rdb.HGet(ctx, sessionKey(sessionID), fToken).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
 	pipe := ra.rdb.Pipeline()
 	pipe.Del(ctx, sessionKey(sessionID))
+	if token != "" {
+		pipe.Del(ctx, sessionTokenKey(token))
+	}
 	pipe.SRem(ctx, userSessionsKey(userID), sessionID)
-	_, err := pipe.Exec(ctx)
+	_, err = pipe.Exec(ctx)
 	return err
 }
 
-// DeleteOtherSessions removes all sessions except the current one.
-func (ra *RedisAuth) DeleteOtherSessions(ctx context.Context, currentSessionID, userID string) (int, error) {
+// DeleteOtherSessions removes all sessions except the current one and
+// returns the IDs it removed.
+func (ra *RedisAuth) DeleteOtherSessions(ctx context.Context, currentSessionID, userID string) ([]string, error) {
 	sessions, err := ra.ListUserSessions(ctx, userID)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	count := 0
+	var deleted []string
 	for _, sid := range sessions {
 		if sid == currentSessionID {
 			continue
 		}
 		if err := ra.DeleteSession(ctx, sid, userID); err != nil {
-			return count, err
+			return deleted, err
 		}
-		count++
+		deleted = append(deleted, sid)
 	}
-	return count, nil
+	return deleted, nil
 }
 
-// ValidateAndTouchSession retrieves session data and, if the last touch is
-// older than touchMinInterval, updates last_active_at and extends the TTL.
-// This throttling reduces Redis writes on high-frequency API calls.
-// Returns nil if the session does not exist or has expired.
-func (ra *RedisAuth) ValidateAndTouchSession(ctx context.Context, sessionID string) (*SessionData, error) {
+// ValidateAndTouchSession looks up the session of a cookie token and, if
+// the last touch is older than touchMinInterval, updates last_active_at
+// and extends the TTL. This throttling reduces Redis writes on
+// high-frequency API calls. Returns nil if the session does not exist, has
+// expired or outlived AbsoluteLifetime, or token is not its token.
+func (ra *RedisAuth) ValidateAndTouchSession(ctx context.Context, token string) (*SessionData, error) {
+	sessionID, err := ra.rdb.Get(ctx, sessionTokenKey(token)).Result()
+	viaToken := err == nil
+	if err == redis.Nil {
+		sessionID = token
+	} else if err != nil {
+		return nil, err
+	}
+
 	key := sessionKey(sessionID)
 	m, err := ra.rdb.HGetAll(ctx, key).Result()
 	if err != nil {
@@ -378,33 +435,273 @@ func (ra *RedisAuth) ValidateAndTouchSession(ctx context.Context, sessionID stri
 		return nil, nil
 	}
 
-	latStr := m[fLastActiveAt]
+	sess := sessionFromHash(sessionID, m)
+	switch current := m[fToken]; {
+	case viaToken && current == token:
+	case viaToken && current != "":
+		sess.ReplacedBy = current
+	case !viaToken && current == "":
+		// A session from before tokens, found by its ID.
+	default:
+		return nil, nil
+	}
+
 	now := time.Now().UTC()
+	if ra.AbsoluteLifetime > 0 {
+		if created, err := time.Parse(time.RFC3339, sess.CreatedAt); err == nil && now.Sub(created) >= ra.AbsoluteLifetime {
+			if err := ra.DeleteSession(ctx, sessionID, sess.UserID); err != nil {
+				return nil, err
+			}
+			return nil, nil
+		}
+	}
 
 	// Only write to Redis if the last touch is old enough.
 	needsTouch := true
-	if ra.touchMinInterval > 0 && latStr != "" {
-		if lastTouch, err := time.Parse(time.RFC3339, latStr); err == nil {
+	if ra.touchMinInterval > 0 && sess.LastActiveAt != "" {
+		if lastTouch, err := time.Parse(time.RFC3339, sess.LastActiveAt); err == nil {
 			needsTouch = now.Sub(lastTouch) >= ra.touchMinInterval
 		}
 	}
 
 	if needsTouch {
-		latStr = now.Format(time.RFC3339)
+		sess.LastActiveAt = now.Format(time.RFC3339)
+		fields := []any{fLastActiveAt, sess.LastActiveAt}
+		// Sessions created before refreshes were tracked count from creation.
+		ratStr := m[fRefreshedAt]
+		if ratStr == "" {
+			ratStr = sess.CreatedAt
+		}
+		if lastRefresh, err := time.Parse(time.RFC3339, ratStr); err != nil || now.Sub(lastRefresh) >= refreshLogInterval {
+			sess.Refreshed = true
+			fields = append(fields, fRefreshedAt, sess.LastActiveAt)
+		}
+		ttl := ra.sessionTTL(sess.CreatedAt, now)
 		pipe := ra.rdb.Pipeline()
-		pipe.HSet(ctx, key, fLastActiveAt, latStr)
-		pipe.Expire(ctx, key, ra.sessionExpiry)
+		pipe.HSet(ctx, key, fields...)
+		pipe.Expire(ctx, key, ttl)
+		if current := m[fToken]; current != "" {
+			pipe.Expire(ctx, sessionTokenKey(current), ttl)
+		}
+		// Keep the user's session index alive as long as its sessions.
+		pipe.Expire(ctx, userSessionsKey(sess.UserID), ra.sessionExpiry)
 		if _, err := pipe.Exec(ctx); err != nil {
 			return nil, err
 		}
 	}
 
-	return &SessionData{
-		UserID:       m[fUserID],
-		IP:           m[fIP],
-		Geo:          m[fGeo],
-		CountryCode:  m[fCountryCode],
-		CreatedAt:    m[fCreatedAt],
-		LastActiveAt: latStr,
-	}, nil
+	return sess, nil
+}
+
+// rotateTokenScript replaces the token of a session unless another request
+// replaced it first. The old token keeps pointing at the session for
+// rotateGrace. KEYS: session, new token, old token. ARGV: session ID, new
+// token, now, TTL and grace in seconds, old token. Returns 1 if rotated.
+var rotateTokenScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+local current = redis.call('HGET', KEYS[1], 'tok') or ARGV[1]
+if current ~= ARGV[6] then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'tok', ARGV[2], 'rtd', ARGV[3])
+redis.call('SET', KEYS[2], ARGV[1], 'EX', ARGV[4])
+redis.call('SET', KEYS[3], ARGV[1], 'EX', ARGV[5])
+return 1
+`)
+
+// RotateSession gives the session a new token in place of oldToken and
+// returns it, or "" when the session is gone or oldToken was already
+// replaced by a concurrent request.
+func (ra *RedisAuth) RotateSession(ctx context.Context, sess *SessionData, oldToken string) (string, error) {
+	token, err := GenerateToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	ttl := ra.sessionTTL(sess.CreatedAt, now)
+	if ttl < time.Second {
+		return "", nil
+	}
+	n, err := rotateTokenScript.Run(ctx, ra.rdb,
+		[]string{sessionKey(sess.ID), sessionTokenKey(token), sessionTokenKey(oldToken)},
+		sess.ID, token, now.Format(time.RFC3339), int(ttl.Seconds()), int(rotateGrace.Seconds()), oldToken,
+	).Int()
+	if err != nil || n == 0 {
+		return "", err
+	}
+	return token, nil
+}
+
+// SessionsExist reports which of sessionIDs are still live in Redis.
+func (ra *RedisAuth) SessionsExist(ctx context.Context, sessionIDs []string) (map[string]bool, error) {
+	if len(sessionIDs) == 0 {
+		return map[string]bool{}, nil
+	}
+	pipe := ra.rdb.Pipeline()
+	cmds := make([]*redis.IntCmd, len(sessionIDs))
+	for i, sid := range sessionIDs {
+		cmds[i] = pipe.Exists(ctx, sessionKey(sid))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	live := make(map[string]bool, len(sessionIDs))
+	for i, sid := range sessionIDs {
+		live[sid] = cmds[i].Val() > 0
+	}
+	return live, nil
+}
+
+// --- Revoke links ---
+
+// revokeKey holds the session a "this wasn't me" link signs out.
+func revokeKey(token string) string { return "auth:revoke:" + token }
+
+// RevokeTokenData is the session a revoke token belongs to.
+type RevokeTokenData struct {
+	SessionID string `json:"sid"`
+	UserID    string `json:"uid"`
+}
+
+// CreateRevokeToken returns a token that signs out the session once. It
+// lives as long as a session can, since the link is no use after that.
+func (ra *RedisAuth) CreateRevokeToken(ctx context.Context, sessionID, userID string) (string, error) {
+	token, err := GenerateToken()
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(RevokeTokenData{SessionID: sessionID, UserID: userID})
+	if err != nil {
+		return "", err
+	}
+	if err := ra.rdb.Set(ctx, revokeKey(token), b, ra.sessionExpiry).Err(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ConsumeRevokeToken retrieves and deletes the revoke token data (GETDEL).
+// Returns nil if the token does not exist or has expired.
+func (ra *RedisAuth) ConsumeRevokeToken(ctx context.Context, token string) (*RevokeTokenData, error) {
+	val, err := ra.rdb.GetDel(ctx, revokeKey(token)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var data RevokeTokenData
+	if err := json.Unmarshal([]byte(val), &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// --- Email change ---
+//
+// A change waits under the user for the code sent to the new address. The
+// old address gets a cancel token, which outlives the code so that it can
+// also revert a change that was confirmed.
+
+func emailChangeKey(userID string) string      { return "auth:email_change:" + userID }
+func emailChangeCancelKey(token string) string { return "auth:email_cancel:" + token }
+
+// EmailChangeData is a pending email change of a user.
+type EmailChangeData struct {
+	Email    string `json:"email"` // the new address
+	OldEmail string `json:"old_email"`
+	Code     string `json:"code"`
+	Attempts int    `json:"attempts"`
+}
+
+// EmailChangeCancel is the change a cancel token undoes.
+type EmailChangeCancel struct {
+	UserID   string `json:"uid"`
+	OldEmail string `json:"old_email"`
+	NewEmail string `json:"new_email"`
+}
+
+// verifyEmailChangeScript checks a code against a pending email change
+// like verifyCodeScript does for logins. KEYS[1] is the change key; ARGV
+// and replies are those of verifyCodeScript.
+var verifyEmailChangeScript = redis.NewScript(`
+local raw = redis.call('GET', KEYS[1])
+if not raw then
+	return {0}
+end
+local data = cjson.decode(raw)
+if string.upper((string.gsub(data.code, '%-', ''))) == ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	return {1, raw}
+end
+data.attempts = (data.attempts or 0) + 1
+if data.attempts >= tonumber(ARGV[2]) then
+	redis.call('DEL', KEYS[1])
+	return {-1}
+end
+redis.call('SET', KEYS[1], cjson.encode(data), 'KEEPTTL')
+return {2}
+`)
+
+// SaveEmailChange makes code the pending confirmation of userID's change
+// from oldEmail to newEmail, replacing any earlier change, and returns the
+// token that cancels it.
+func (ra *RedisAuth) SaveEmailChange(ctx context.Context, userID, oldEmail, newEmail, code string) (string, error) {
+	token, err := GenerateToken()
+	if err != nil {
+		return "", err
+	}
+	change, err := json.Marshal(EmailChangeData{Email: newEmail, OldEmail: oldEmail, Code: code})
+	if err != nil {
+		return "", err
+	}
+	cancel, err := json.Marshal(EmailChangeCancel{UserID: userID, OldEmail: oldEmail, NewEmail: newEmail})
+	if err != nil {
+		return "", err
+	}
+	pipe := ra.rdb.Pipeline()
+	pipe.Set(ctx, emailChangeKey(userID), change, emailChangeTTL)
+	pipe.Set(ctx, emailChangeCancelKey(token), cancel, emailChangeCancelTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// VerifyEmailChange checks code against the pending email change of
+// userID and consumes the change on a match. Wrong codes count as in
+// VerifyCode, and so do the errors.
+func (ra *RedisAuth) VerifyEmailChange(ctx context.Context, userID, code string) (*EmailChangeData, error) {
+	res, err := verifyEmailChangeScript.Run(ctx, ra.rdb, []string{emailChangeKey(userID)}, NormalizeCode(code), maxCodeAttempts).Slice()
+	if err != nil {
+		return nil, err
+	}
+	var data EmailChangeData
+	if err := codeResult(res, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// ConsumeEmailChangeCancel retrieves and deletes a cancel token and drops
+// the user's pending email change, if any. Returns nil if the token does
+// not exist or has expired.
+func (ra *RedisAuth) ConsumeEmailChangeCancel(ctx context.Context, token string) (*EmailChangeCancel, error) {
+	val, err := ra.rdb.GetDel(ctx, emailChangeCancelKey(token)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var data EmailChangeCancel
+	if err := json.Unmarshal([]byte(val), &data); err != nil {
+		return nil, err
+	}
+	if err := ra.rdb.Del(ctx, emailChangeKey(data.UserID)).Err(); err != nil {
+		return nil, err
+	}
+	return &data, nil
 }
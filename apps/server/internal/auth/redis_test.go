@@ -0,0 +1,350 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// setupTestRedisAuth uses DB 14 of the Redis instance at localhost:6379,
+// apart from other packages testing in parallel, and skips the test when
+// it is not running.
+func setupTestRedisAuth(t *testing.T) *RedisAuth {
+	t.Helper()
+	rdb := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 14})
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		t.Skip("Redis not available, skipping auth integration test")
+	}
+	t.Cleanup(func() {
+		rdb.FlushDB(context.Background())
+		_ = rdb.Close()
+	})
+	return NewRedisAuth(rdb, time.Hour, 0)
+}
+
+func TestVerifyCode(t *testing.T) {
+	ra := setupTestRedisAuth(t)
+	ctx := context.Background()
+	if err := ra.SaveAuthToken(ctx, "tok", "ABC-DEF", "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ra.VerifyCode(ctx, "a@example.com", "XYZ-XYZ"); !errors.Is(err, ErrCodeMismatch) {
+		t.Fatalf("wrong code: %v", err)
+	}
+	data, err := ra.VerifyCode(ctx, "a@example.com", "abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Email != "a@example.com" || data.Attempts != 1 {
+		t.Errorf("data = %+v", data)
+	}
+	// The code and its magic link are used up.
+	if _, err := ra.VerifyCode(ctx, "a@example.com", "ABC-DEF"); !errors.Is(err, ErrCodeNotFound) {
+		t.Errorf("reused code: %v", err)
+	}
+	if d, err := ra.GetAuthToken(ctx, "tok"); err != nil || d != nil {
+		t.Errorf("magic link still valid: %+v, %v", d, err)
+	}
+}
+
+func TestVerifyCodeLatestCodeWins(t *testing.T) {
+	ra := setupTestRedisAuth(t)
+	ctx := context.Background()
+	_ = ra.SaveAuthToken(ctx, "old", "AAA-AAA", "a@example.com")
+	_ = ra.SaveAuthToken(ctx, "new", "BBB-BBB", "a@example.com")
+
+	if _, err := ra.VerifyCode(ctx, "a@example.com", "AAA-AAA"); !errors.Is(err, ErrCodeMismatch) {
+		t.Errorf("superseded code: %v", err)
+	}
+	if _, err := ra.VerifyCode(ctx, "a@example.com", "BBB-BBB"); err != nil {
+		t.Errorf("latest code: %v", err)
+	}
+}
+
+func TestVerifyCodeConcurrentGuesses(t *testing.T) {
+	ra := setupTestRedisAuth(t)
+	ctx := context.Background()
+	if err := ra.SaveAuthToken(ctx, "tok", "ABC-DEF", "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	const guesses = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, guesses)
+	for i := 0; i < guesses; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := ra.VerifyCode(ctx, "a@example.com", "XYZ-XYZ")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	counts := map[error]int{}
+	for err := range errs {
+		counts[err]++
+	}
+	if counts[ErrCodeMismatch] != maxCodeAttempts-1 || counts[ErrTooManyAttempts] != 1 || counts[ErrCodeNotFound] != guesses-maxCodeAttempts {
+		t.Fatalf("results = %v; want %d mismatches, 1 lockout, the rest not found", counts, maxCodeAttempts-1)
+	}
+	if _, err := ra.VerifyCode(ctx, "a@example.com", "ABC-DEF"); !errors.Is(err, ErrCodeNotFound) {
+		t.Errorf("the right code still works after the lockout: %v", err)
+	}
+}
+
+func TestVerifyCodeConcurrentRightCode(t *testing.T) {
+	ra := setupTestRedisAuth(t)
+	ctx := context.Background()
+	if err := ra.SaveAuthToken(ctx, "tok", "ABC-DEF", "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	const requests = 20
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ok := 0
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ra.VerifyCode(ctx, "a@example.com", "ABC-DEF"); err == nil {
+				mu.Lock()
+				ok++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if ok != 1 {
+		t.Fatalf("%d requests signed in with one code, want 1", ok)
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	ra := setupTestRedisAuth(t)
+	ctx := context.Background()
+	token, err := ra.CreateRevokeToken(ctx, "sess", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ra.ConsumeRevokeToken(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data == nil || data.SessionID != "sess" || data.UserID != "user" {
+		t.Fatalf("data = %+v", data)
+	}
+	if data, err := ra.ConsumeRevokeToken(ctx, token); err != nil || data != nil {
+		t.Errorf("token reused: %+v, %v", data, err)
+	}
+}
+
+func TestValidateAndTouchSessionRefresh(t *testing.T) {
+	ra := setupTestRedisAuth(t)
+	ctx := context.Background()
+	old := time.Now().Add(-2 * refreshLogInterval).UTC().Format(time.RFC3339)
+	token, err := ra.CreateSession(ctx, "old", SessionData{UserID: "u", CreatedAt: old, LastActiveAt: old})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := ra.ValidateAndTouchSession(ctx, token)
+	if err != nil || sess == nil || !sess.Refreshed {
+		t.Fatalf("first touch after a day: %+v, %v", sess, err)
+	}
+	// The next touch is within refreshLogInterval of the logged refresh.
+	if sess, err := ra.ValidateAndTouchSession(ctx, token); err != nil || sess.Refreshed {
+		t.Errorf("second touch: %+v, %v", sess, err)
+	}
+
+	live, err := ra.SessionsExist(ctx, []string{"old", "gone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !live["old"] || live["gone"] {
+		t.Errorf("SessionsExist = %v", live)
+	}
+}
+
+func TestRotateSession(t *testing.T) {
+	ra := setupTestRedisAuth(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Format(time.RFC3339)
+	oldToken, err := ra.CreateSession(ctx, "sid", SessionData{UserID: "u", CreatedAt: now, LastActiveAt: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := ra.ValidateAndTouchSession(ctx, oldToken)
+	if err != nil || sess == nil || sess.ID != "sid" {
+		t.Fatalf("session = %+v, %v", sess, err)
+	}
+
+	newToken, err := ra.RotateSession(ctx, sess, oldToken)
+	if err != nil || newToken == "" || newToken == oldToken {
+		t.Fatalf("RotateSession = %q, %v", newToken, err)
+	}
+	// A second rotation from the same old token loses the race.
+	if again, err := ra.RotateSession(ctx, sess, oldToken); err != nil || again != "" {
+		t.Errorf("second rotation = %q, %v", again, err)
+	}
+
+	got, err := ra.ValidateAndTouchSession(ctx, newToken)
+	if err != nil || got == nil || got.ID != "sid" || got.ReplacedBy != "" {
+		t.Errorf("new token: %+v, %v", got, err)
+	}
+	// The old token still works for a moment and names its replacement.
+	got, err = ra.ValidateAndTouchSession(ctx, oldToken)
+	if err != nil || got == nil || got.ReplacedBy != newToken {
+		t.Errorf("old token: %+v, %v", got, err)
+	}
+
+	if err := ra.DeleteSession(ctx, "sid", "u"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ra.ValidateAndTouchSession(ctx, newToken); err != nil || got != nil {
+		t.Errorf("deleted session: %+v, %v", got, err)
+	}
+}
+
+func TestLegacySessionRotates(t *testing.T) {
+	ra := setupTestRedisAuth(t)
+	ctx := context.Background()
+	// Sessions from before tokens are a hash without one, found by ID.
+	now := time.Now().UTC().Format(time.RFC3339)
+	ra.rdb.HSet(ctx, sessionKey("legacy"), fUserID, "u", fCreatedAt, now, fLastActiveAt, now)
+
+	sess, err := ra.ValidateAndTouchSession(ctx, "legacy")
+	if err != nil || sess == nil {
+		t.Fatalf("legacy session: %+v, %v", sess, err)
+	}
+	token, err := ra.RotateSession(ctx, sess, "legacy")
+	if err != nil || token == "" {
+		t.Fatalf("RotateSession = %q, %v", token, err)
+	}
+	if got, err := ra.ValidateAndTouchSession(ctx, token); err != nil || got == nil || got.ID != "legacy" {
+		t.Errorf("rotated token: %+v, %v", got, err)
+	}
+	if got, err := ra.ValidateAndTouchSession(ctx, "legacy"); err != nil || got == nil || got.ReplacedBy != token {
+		t.Errorf("legacy ID in grace: %+v, %v", got, err)
+	}
+}
+
+func TestAbsoluteLifetime(t *testing.T) {
+	ra := setupTestRedisAuth(t)
+	ra.AbsoluteLifetime = 24 * time.Hour
+	ctx := context.Background()
+	created := time.Now().Add(-25 * time.Hour).UTC().Format(time.RFC3339)
+	token, err := ra.CreateSession(ctx, "long", SessionData{UserID: "u", CreatedAt: created, LastActiveAt: created})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess, err := ra.ValidateAndTouchSession(ctx, token); err != nil || sess != nil {
+		t.Errorf("session past its lifetime: %+v, %v", sess, err)
+	}
+}
+
+func TestSessionTTL(t *testing.T) {
+	ra := &RedisAuth{sessionExpiry: 7 * 24 * time.Hour}
+	now := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	if got := ra.sessionTTL("2026-01-01T00:00:00Z", now); got != 7*24*time.Hour {
+		t.Errorf("no absolute lifetime: %v", got)
+	}
+	ra.AbsoluteLifetime = 10 * 24 * time.Hour
+	if got := ra.sessionTTL("2026-01-01T00:00:00Z", now); got != 24*time.Hour {
+		t.Errorf("ttl = %v, want the day left of the lifetime", got)
+	}
+}
+
+func TestEmailChange(t *testing.T) {
+	ra := setupTestRedisAuth(t)
+	ctx := context.Background()
+	if _, err := ra.SaveEmailChange(ctx, "user", "old@example.com", "first@example.com", "AAA-AAA"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ra.SaveEmailChange(ctx, "user", "old@example.com", "new@example.com", "ABC-DEF"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ra.VerifyEmailChange(ctx, "user", "AAA-AAA"); !errors.Is(err, ErrCodeMismatch) {
+		t.Fatalf("replaced code: %v", err)
+	}
+	if _, err := ra.VerifyEmailChange(ctx, "other", "ABC-DEF"); !errors.Is(err, ErrCodeNotFound) {
+		t.Fatalf("other user's change: %v", err)
+	}
+	data, err := ra.VerifyEmailChange(ctx, "user", "abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Email != "new@example.com" || data.OldEmail != "old@example.com" || data.Attempts != 1 {
+		t.Errorf("data = %+v", data)
+	}
+	if _, err := ra.VerifyEmailChange(ctx, "user", "ABC-DEF"); !errors.Is(err, ErrCodeNotFound) {
+		t.Errorf("reused code: %v", err)
+	}
+}
+
+func TestEmailChangeTooManyAttempts(t *testing.T) {
+	ra := setupTestRedisAuth(t)
+	ctx := context.Background()
+	_, _ = ra.SaveEmailChange(ctx, "user", "old@example.com", "new@example.com", "ABC-DEF")
+	for i := 1; i < maxCodeAttempts; i++ {
+		if _, err := ra.VerifyEmailChange(ctx, "user", "XYZ-XYZ"); !errors.Is(err, ErrCodeMismatch) {
+			t.Fatalf("attempt %d: %v", i, err)
+		}
+	}
+	if _, err := ra.VerifyEmailChange(ctx, "user", "XYZ-XYZ"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("last attempt: %v", err)
+	}
+	if _, err := ra.VerifyEmailChange(ctx, "user", "ABC-DEF"); !errors.Is(err, ErrCodeNotFound) {
+		t.Errorf("code still valid: %v", err)
+	}
+}
+
+func TestEmailChangeCancel(t *testing.T) {
+	ra := setupTestRedisAuth(t)
+	ctx := context.Background()
+	token, err := ra.SaveEmailChange(ctx, "user", "old@example.com", "new@example.com", "ABC-DEF")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ra.ConsumeEmailChangeCancel(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data == nil || data.UserID != "user" || data.OldEmail != "old@example.com" || data.NewEmail != "new@example.com" {
+		t.Fatalf("data = %+v", data)
+	}
+	if _, err := ra.VerifyEmailChange(ctx, "user", "ABC-DEF"); !errors.Is(err, ErrCodeNotFound) {
+		t.Errorf("cancelled change still pending: %v", err)
+	}
+	if data, err := ra.ConsumeEmailChangeCancel(ctx, token); err != nil || data != nil {
+		t.Errorf("token reused: %+v, %v", data, err)
+	}
+}
+
+func TestDiscardPendingCode(t *testing.T) {
+	ra := setupTestRedisAuth(t)
+	ctx := context.Background()
+	_ = ra.SaveAuthToken(ctx, "tok", "ABC-DEF", "a@example.com")
+
+	if err := ra.DiscardPendingCode(ctx, "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ra.VerifyCode(ctx, "a@example.com", "ABC-DEF"); !errors.Is(err, ErrCodeNotFound) {
+		t.Errorf("code still valid: %v", err)
+	}
+	if d, err := ra.GetAuthToken(ctx, "tok"); err != nil || d != nil {
+		t.Errorf("magic link still valid: %+v, %v", d, err)
+	}
+}
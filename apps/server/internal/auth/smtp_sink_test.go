@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"mime"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/system-design-sandbox/server/internal/config"
+)
+
+// smtpSink is a minimal SMTP server that records what it is sent.
+type smtpSink struct {
+	ln        net.Listener
+	rcptReply string // reply to RCPT TO; empty accepts
+	mailFrom  chan string
+	messages  chan string
+}
+
+func newSMTPSink(t *testing.T, rcptReply string) *smtpSink {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpSink{ln: ln, rcptReply: rcptReply, mailFrom: make(chan string, 1), messages: make(chan string, 1)}
+	t.Cleanup(func() { _ = ln.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *smtpSink) config(from string, dkim config.DKIMConfig) config.SMTPConfig {
+	addr := s.ln.Addr().(*net.TCPAddr)
+	return config.SMTPConfig{Host: addr.IP.String(), Port: addr.Port, From: from, TLS: "none", DKIM: dkim}
+}
+
+func (s *smtpSink) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(textproto.NewConn(conn))
+	}
+}
+
+func (s *smtpSink) handle(c *textproto.Conn) {
+	defer func() { _ = c.Close() }()
+	_ = c.PrintfLine("220 sink ESMTP")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO":
+			_ = c.PrintfLine("250 sink")
+		case "MAIL":
+			s.mailFrom <- line
+			_ = c.PrintfLine("250 OK")
+		case "RCPT":
+			if s.rcptReply != "" {
+				_ = c.PrintfLine("%s", s.rcptReply)
+				continue
+			}
+			_ = c.PrintfLine("250 OK")
+		case "DATA":
+			_ = c.PrintfLine("354 go ahead")
+			var msg strings.Builder
+			for {
+				l, err := c.ReadLine()
+				if err != nil {
+					return
+				}
+				if l == "." {
+					break
+				}
+				msg.WriteString(strings.TrimPrefix(l, ".") + "\r\n")
+			}
+			s.messages <- msg.String()
+			_ = c.PrintfLine("250 OK queued")
+		case "QUIT":
+			_ = c.PrintfLine("221 bye")
+			return
+		default:
+			_ = c.PrintfLine("250 OK")
+		}
+	}
+}
+
+func TestSMTPSenderSignsAndEncodes(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		key  any
+		pub  crypto.PublicKey
+	}{
+		{"rsa", rsaKey, &rsaKey.PublicKey},
+		{"ed25519", edKey, edPub},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sink := newSMTPSink(t, "")
+			sender, err := NewEmailSender(sink.config(`"Песочница" <noreply@example.com>`, config.DKIMConfig{
+				Domain: "example.com", Selector: "mail", Key: pemPKCS8(t, tc.key),
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			subject := "Алиса упомянула вас в обсуждении архитектуры «Чат с кешем перед базой данных»"
+			messageID, err := sender.Send(Message{
+				To:          "user@example.com",
+				Subject:     subject,
+				Text:        "Привет\n",
+				HTML:        "<p>Привет</p>",
+				Unsubscribe: "https://example.com/api/v1/email/unsubscribe?list=comment_mention&token=t",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if from := <-sink.mailFrom; from != "MAIL FROM:<noreply@example.com>" {
+				t.Errorf("envelope sender = %q", from)
+			}
+			raw := <-sink.messages
+
+			msg, err := mail.ReadMessage(strings.NewReader(raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); err != nil || got != subject {
+				t.Errorf("Subject = %q, %v", got, err)
+			}
+			if from, err := mail.ParseAddress(msg.Header.Get("From")); err != nil || from.Name != "Песочница" {
+				t.Errorf("From = %q, %v", msg.Header.Get("From"), err)
+			}
+			if msg.Header.Get("Message-ID") != messageID || !strings.HasSuffix(messageID, "@example.com>") {
+				t.Errorf("Message-ID = %q, Send returned %q", msg.Header.Get("Message-ID"), messageID)
+			}
+			if got := msg.Header.Get("List-Unsubscribe"); got != "<https://example.com/api/v1/email/unsubscribe?list=comment_mention&token=t>" {
+				t.Errorf("List-Unsubscribe = %q", got)
+			}
+			if msg.Header.Get("List-Unsubscribe-Post") != "List-Unsubscribe=One-Click" {
+				t.Error("missing List-Unsubscribe-Post")
+			}
+			for _, line := range strings.Split(raw, "\r\n") {
+				if strings.HasPrefix(line, "Subject:") && len(line) > 78 {
+					t.Errorf("Subject line is %d characters, want at most 78", len(line))
+				}
+			}
+
+			verifyDKIM(t, raw, tc.pub)
+		})
+	}
+}
+
+func TestSMTPSenderHardBounce(t *testing.T) {
+	sink := newSMTPSink(t, "550 5.1.1 no such user")
+	sender, err := NewEmailSender(sink.config("noreply@example.com", config.DKIMConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sender.Send(Message{To: "gone@example.com", Subject: "Hi", HTML: "<p>hi</p>"})
+	if !IsHardBounce(err) {
+		t.Fatalf("expected a hard bounce, got %v", err)
+	}
+}
+
+// verifyDKIM checks the DKIM-Signature of raw against pub as a receiving
+// server would.
+func verifyDKIM(t *testing.T, raw string, pub crypto.PublicKey) {
+	t.Helper()
+	head, body, ok := strings.Cut(raw, "\r\n\r\n")
+	if !ok {
+		t.Fatal("message has no body")
+	}
+	var headers []headerField
+	for _, line := range strings.Split(head, "\r\n") {
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			headers[len(headers)-1].value += "\r\n" + line
+			continue
+		}
+		name, value, _ := strings.Cut(line, ":")
+		headers = append(headers, headerField{name, value})
+	}
+	if headers[0].name != "DKIM-Signature" {
+		t.Fatalf("first header is %q, want DKIM-Signature", headers[0].name)
+	}
+	sigValue := headers[0].value
+
+	tags := map[string]string{}
+	for _, tag := range strings.Split(strings.Join(strings.Fields(sigValue), ""), ";") {
+		k, v, _ := strings.Cut(tag, "=")
+		tags[k] = v
+	}
+	if tags["d"] != "example.com" || tags["s"] != "mail" || tags["c"] != "relaxed/relaxed" {
+		t.Errorf("unexpected tags %v", tags)
+	}
+
+	bodyHash := sha256.Sum256(relaxedBody([]byte(body)))
+	if tags["bh"] != base64.StdEncoding.EncodeToString(bodyHash[:]) {
+		t.Fatal("body hash does not match")
+	}
+
+	h := sha256.New()
+	for _, name := range strings.Split(tags["h"], ":") {
+		found := false
+		for _, f := range headers[1:] {
+			if strings.EqualFold(f.name, name) {
+				h.Write([]byte(relaxedHeader(f.name, f.value) + "\r\n"))
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Fatalf("signed header %q is missing", name)
+		}
+	}
+	i := strings.LastIndex(sigValue, "b=")
+	h.Write([]byte(relaxedHeader("DKIM-Signature", sigValue[:i+len("b=")])))
+	digest := h.Sum(nil)
+
+	sig, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest, sig)
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, digest, sig) {
+			err = errors.New("ed25519: invalid signature")
+		}
+	}
+	if err != nil {
+		t.Fatalf("signature does not verify: %v", err)
+	}
+	if !strings.Contains(tags["h"], "list-unsubscribe") {
+		t.Error("List-Unsubscribe is not signed")
+	}
+}
@@ -3,6 +3,7 @@ package auth
 import (
 	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"strings"
@@ -39,6 +40,13 @@ func GenerateSessionID() (string, error) {
 	return hex.EncodeToString(b), nil
 }
 
+// HashToken is what the database keeps of a token sent by email, so that
+// a leaked table does not give out working links.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 // TimingSafeEqual performs a constant-time string comparison.
 func TimingSafeEqual(a, b string) bool {
 	return hmac.Equal([]byte(a), []byte(b))
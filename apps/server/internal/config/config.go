@@ -24,19 +24,48 @@ type Config struct {
 	ReferralFieldEnabled bool
 	GeoIP                GeoIPConfig
 	SessionLogEnabled    bool
+	TrashRetention       time.Duration          // how long deleted architectures stay restorable
+	AccountDeletionGrace time.Duration          // how long a deleted account can still be kept by signing in
+	Quotas               map[string]QuotaConfig // keyed by users.plan
+	SessionLogRetention  time.Duration          // 0 keeps session_log forever
+	JobsConcurrency      int
 }
 
+// QuotaConfig limits what a user on one plan may store and post. Zero
+// means unlimited.
+type QuotaConfig struct {
+	Architectures int
+	StorageBytes  int64 // total gzipped architecture data
+	ResultsPerDay int
+}
+
+// RateLimitConfig holds sliding-window request limits; 0 disables a limit.
 type RateLimitConfig struct {
-	PerMinute int
-	PerHour   int
+	PerMinute       int // login codes sent to one email address
+	PerHour         int
+	IPPerMinute     int // auth requests from one IP
+	IPPerHour       int
+	GlobalPerMinute int // auth requests from everyone together
+	WritesPerMinute int // write requests per user, or per IP when signed out
 }
 
 type SessionConfig struct {
-	Expiry           time.Duration
+	Expiry           time.Duration // idle timeout: an unused session expires after this
+	AbsoluteLifetime time.Duration // sessions end this long after sign-in; 0 never
+	RotateInterval   time.Duration // how often session tokens change; 0 only on privilege changes
+	Binding          SessionBinding
 	TouchMinInterval time.Duration // minimum interval between session touch writes
 	MetricsTick      time.Duration // how often the metrics collector scans Redis
 }
 
+// SessionBinding ties a session to where it signed in from. A request
+// breaking a binding ends the session and the user signs in again.
+type SessionBinding struct {
+	IP        bool // same /24 (IPv4) or /48 (IPv6) network
+	Country   bool // same GeoIP country
+	UserAgent bool // same browser and OS
+}
+
 type SMTPConfig struct {
 	Host     string
 	Port     int
@@ -44,6 +73,15 @@ type SMTPConfig struct {
 	Password string
 	TLS      string // "none", "starttls", "tls"
 	From     string
+	DKIM     DKIMConfig
+}
+
+// DKIMConfig signs outgoing mail when Selector is set. Key is a PEM RSA or
+// Ed25519 private key.
+type DKIMConfig struct {
+	Domain   string
+	Selector string
+	Key      []byte
 }
 
 type RedisConfig struct {
@@ -94,7 +132,13 @@ func Load() (*Config, error) {
 	}
 
 	sessionExpiry := 7 * 24 * time.Hour
-	if v := os.Getenv("SESSION_EXPIRY"); v != "" {
+	if v := os.Getenv("SESSION_IDLE_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("SESSION_IDLE_TIMEOUT must be a valid duration: %w", err)
+		}
+		sessionExpiry = d
+	} else if v := os.Getenv("SESSION_EXPIRY"); v != "" {
 		d, err := time.ParseDuration(v)
 		if err != nil {
 			return nil, fmt.Errorf("SESSION_EXPIRY must be a valid duration: %w", err)
@@ -126,6 +170,29 @@ func Load() (*Config, error) {
 		touchMinInterval = d
 	}
 
+	sessionAbsolute := 30 * 24 * time.Hour
+	if v := os.Getenv("SESSION_ABSOLUTE_LIFETIME"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("SESSION_ABSOLUTE_LIFETIME must be a valid duration: %w", err)
+		}
+		sessionAbsolute = d
+	}
+
+	sessionRotate := 24 * time.Hour
+	if v := os.Getenv("SESSION_ROTATE_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("SESSION_ROTATE_INTERVAL must be a valid duration: %w", err)
+		}
+		sessionRotate = d
+	}
+
+	sessionBinding, err := loadSessionBinding(os.Getenv("SESSION_BINDING"))
+	if err != nil {
+		return nil, err
+	}
+
 	publicURL := os.Getenv("PUBLIC_URL")
 	if publicURL == "" {
 		return nil, fmt.Errorf("PUBLIC_URL environment variable is required")
@@ -149,21 +216,57 @@ func Load() (*Config, error) {
 		sessionLogEnabled = b
 	}
 
-	rlPerMinute := 5
-	if v := os.Getenv("RATE_LIMIT_PER_MINUTE"); v != "" {
+	trashRetention := 30 * 24 * time.Hour
+	if v := os.Getenv("TRASH_RETENTION_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("TRASH_RETENTION_DAYS must be an integer: %w", err)
+		}
+		trashRetention = time.Duration(n) * 24 * time.Hour
+	}
+
+	accountDeletionGrace := 30 * 24 * time.Hour
+	if v := os.Getenv("ACCOUNT_DELETION_GRACE_DAYS"); v != "" {
 		n, err := strconv.Atoi(v)
 		if err != nil {
-			return nil, fmt.Errorf("RATE_LIMIT_PER_MINUTE must be an integer: %w", err)
+			return nil, fmt.Errorf("ACCOUNT_DELETION_GRACE_DAYS must be an integer: %w", err)
 		}
-		rlPerMinute = n
+		accountDeletionGrace = time.Duration(n) * 24 * time.Hour
 	}
-	rlPerHour := 20
-	if v := os.Getenv("RATE_LIMIT_PER_HOUR"); v != "" {
+
+	sessionLogRetention := 180 * 24 * time.Hour
+	if v := os.Getenv("SESSION_LOG_RETENTION_DAYS"); v != "" {
 		n, err := strconv.Atoi(v)
 		if err != nil {
-			return nil, fmt.Errorf("RATE_LIMIT_PER_HOUR must be an integer: %w", err)
+			return nil, fmt.Errorf("SESSION_LOG_RETENTION_DAYS must be an integer: %w", err)
 		}
-		rlPerHour = n
+		sessionLogRetention = time.Duration(n) * 24 * time.Hour
+	}
+
+	jobsConcurrency := 4
+	if v := os.Getenv("JOBS_CONCURRENCY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("JOBS_CONCURRENCY must be an integer: %w", err)
+		}
+		jobsConcurrency = n
+	}
+
+	quotas := map[string]QuotaConfig{}
+	for plan, def := range map[string]QuotaConfig{
+		"free": {Architectures: 50, StorageBytes: 50 << 20, ResultsPerDay: 200},
+		"pro":  {Architectures: 1000, StorageBytes: 2 << 30, ResultsPerDay: 5000},
+	} {
+		q, err := loadQuota(strings.ToUpper(plan), def)
+		if err != nil {
+			return nil, err
+		}
+		quotas[plan] = q
+	}
+
+	rateLimit, err := loadRateLimit()
+	if err != nil {
+		return nil, err
 	}
 
 	smtpPort := 587
@@ -180,12 +283,22 @@ func Load() (*Config, error) {
 		smtpTLS = "starttls"
 	}
 
+	dkim, err := loadDKIM(os.Getenv("SMTP_FROM"))
+	if err != nil {
+		return nil, err
+	}
+
 	return &Config{
 		DatabaseURL:          dbURL,
 		ServerPort:           port,
 		PublicURL:            publicURL,
 		ReferralFieldEnabled: referralFieldEnabled,
 		SessionLogEnabled:    sessionLogEnabled,
+		TrashRetention:       trashRetention,
+		AccountDeletionGrace: accountDeletionGrace,
+		Quotas:               quotas,
+		SessionLogRetention:  sessionLogRetention,
+		JobsConcurrency:      jobsConcurrency,
 		GeoIP: GeoIPConfig{
 			GRPCAddr: os.Getenv("GEOIP_GRPC_ADDR"),
 			RESTURL:  os.Getenv("GEOIP_REST_URL"),
@@ -201,6 +314,9 @@ func Load() (*Config, error) {
 		},
 		Session: SessionConfig{
 			Expiry:           sessionExpiry,
+			AbsoluteLifetime: sessionAbsolute,
+			RotateInterval:   sessionRotate,
+			Binding:          sessionBinding,
 			TouchMinInterval: touchMinInterval,
 			MetricsTick:      metricsTick,
 		},
@@ -211,14 +327,128 @@ func Load() (*Config, error) {
 			Password: os.Getenv("SMTP_PASSWORD"),
 			TLS:      smtpTLS,
 			From:     os.Getenv("SMTP_FROM"),
+			DKIM:     dkim,
 		},
-		RateLimit: RateLimitConfig{
-			PerMinute: rlPerMinute,
-			PerHour:   rlPerHour,
-		},
+		RateLimit: rateLimit,
 	}, nil
 }
 
+// loadSessionBinding parses SESSION_BINDING, a comma-separated list of
+// ip, country and ua; empty binds nothing.
+func loadSessionBinding(v string) (SessionBinding, error) {
+	var b SessionBinding
+	for _, part := range strings.Split(v, ",") {
+		switch strings.TrimSpace(strings.ToLower(part)) {
+		case "":
+		case "ip":
+			b.IP = true
+		case "country":
+			b.Country = true
+		case "ua":
+			b.UserAgent = true
+		default:
+			return b, fmt.Errorf("SESSION_BINDING: unknown binding %q (want ip, country or ua)", part)
+		}
+	}
+	return b, nil
+}
+
+// loadQuota overrides def with QUOTA_<PLAN>_ARCHITECTURES,
+// QUOTA_<PLAN>_STORAGE_MB and QUOTA_<PLAN>_RESULTS_PER_DAY.
+func loadQuota(plan string, def QuotaConfig) (QuotaConfig, error) {
+	q := def
+	if v := os.Getenv("QUOTA_" + plan + "_ARCHITECTURES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return q, fmt.Errorf("QUOTA_%s_ARCHITECTURES must be an integer: %w", plan, err)
+		}
+		q.Architectures = n
+	}
+	if v := os.Getenv("QUOTA_" + plan + "_STORAGE_MB"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return q, fmt.Errorf("QUOTA_%s_STORAGE_MB must be an integer: %w", plan, err)
+		}
+		q.StorageBytes = n << 20
+	}
+	if v := os.Getenv("QUOTA_" + plan + "_RESULTS_PER_DAY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return q, fmt.Errorf("QUOTA_%s_RESULTS_PER_DAY must be an integer: %w", plan, err)
+		}
+		q.ResultsPerDay = n
+	}
+	return q, nil
+}
+
+// loadRateLimit reads the RATE_LIMIT_* variables.
+func loadRateLimit() (RateLimitConfig, error) {
+	rl := RateLimitConfig{
+		PerMinute:       5,
+		PerHour:         20,
+		IPPerMinute:     20,
+		IPPerHour:       100,
+		GlobalPerMinute: 600,
+		WritesPerMinute: 120,
+	}
+	for _, v := range []struct {
+		env string
+		dst *int
+	}{
+		{"RATE_LIMIT_PER_MINUTE", &rl.PerMinute},
+		{"RATE_LIMIT_PER_HOUR", &rl.PerHour},
+		{"RATE_LIMIT_IP_PER_MINUTE", &rl.IPPerMinute},
+		{"RATE_LIMIT_IP_PER_HOUR", &rl.IPPerHour},
+		{"RATE_LIMIT_GLOBAL_PER_MINUTE", &rl.GlobalPerMinute},
+		{"RATE_LIMIT_WRITES_PER_MINUTE", &rl.WritesPerMinute},
+	} {
+		s := os.Getenv(v.env)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return rl, fmt.Errorf("%s must be an integer: %w", v.env, err)
+		}
+		*v.dst = n
+	}
+	return rl, nil
+}
+
+// loadDKIM reads SMTP_DKIM_SELECTOR, SMTP_DKIM_DOMAIN (defaulting to the
+// domain of from) and the key from SMTP_DKIM_KEY_FILE or SMTP_DKIM_KEY.
+func loadDKIM(from string) (DKIMConfig, error) {
+	d := DKIMConfig{
+		Selector: os.Getenv("SMTP_DKIM_SELECTOR"),
+		Domain:   os.Getenv("SMTP_DKIM_DOMAIN"),
+	}
+	if d.Selector == "" {
+		return d, nil
+	}
+	if d.Domain == "" {
+		addr := strings.TrimSuffix(from, ">")
+		if i := strings.LastIndex(addr, "@"); i >= 0 {
+			d.Domain = addr[i+1:]
+		}
+	}
+	if d.Domain == "" {
+		return d, fmt.Errorf("SMTP_DKIM_DOMAIN is required when SMTP_FROM has no domain")
+	}
+	if path := os.Getenv("SMTP_DKIM_KEY_FILE"); path != "" {
+		key, err := os.ReadFile(path)
+		if err != nil {
+			return d, fmt.Errorf("SMTP_DKIM_KEY_FILE: %w", err)
+		}
+		d.Key = key
+	} else {
+		d.Key = []byte(os.Getenv("SMTP_DKIM_KEY"))
+	}
+	if len(d.Key) == 0 {
+		return d, fmt.Errorf("SMTP_DKIM_KEY_FILE or SMTP_DKIM_KEY is required when SMTP_DKIM_SELECTOR is set")
+	}
+	return d, nil
+}
+
 func getEnvOrDefault(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -0,0 +1,323 @@
+// Package cost estimates the monthly cloud cost of an architecture from a
+// versioned pricing catalog. The catalog mirrors the browser pricing models
+// in packages/component-library/src/pricing/pricing.ts so server-side
+// estimates match what the cost panel shows.
+package cost
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/system-design-sandbox/server/internal/archdata"
+)
+
+//go:embed catalog/*.json
+var catalogFS embed.FS
+
+// Catalog is one version of the pricing catalog.
+type Catalog struct {
+	Version       string            `json:"version"`
+	Currency      string            `json:"currency"`
+	HoursPerMonth float64           `json:"hours_per_month"`
+	DefaultRegion string            `json:"default_region"`
+	Regions       map[string]Region `json:"regions"`
+	Components    map[string]*Model `json:"components"`
+}
+
+// Region scales list prices relative to the default region.
+type Region struct {
+	Name       string  `json:"name"`
+	Multiplier float64 `json:"multiplier"`
+}
+
+// Model prices one component type with a formula over its config.
+type Model struct {
+	Formula string         `json:"formula"`
+	Vars    map[string]Var `json:"vars"`
+
+	expr expr
+}
+
+// Var binds a formula variable to a config key. A numeric config value is
+// used as-is (zero counts as missing, like `||` in pricing.ts). With Map,
+// the config value is looked up as a string instead. Missing values come
+// from Fallback (another variable) or Default.
+type Var struct {
+	Key      string             `json:"key,omitempty"`
+	Default  float64            `json:"default,omitempty"`
+	Map      map[string]float64 `json:"map,omitempty"`
+	Fallback string             `json:"fallback,omitempty"`
+}
+
+var (
+	loadOnce sync.Once
+	catalogs map[string]*Catalog
+	latest   string
+	loadErr  error
+)
+
+func loadAll() {
+	catalogs = make(map[string]*Catalog)
+	entries, err := catalogFS.ReadDir("catalog")
+	if err != nil {
+		loadErr = err
+		return
+	}
+	for _, e := range entries {
+		raw, err := catalogFS.ReadFile(path.Join("catalog", e.Name()))
+		if err != nil {
+			loadErr = err
+			return
+		}
+		c, err := Parse(raw)
+		if err != nil {
+			loadErr = fmt.Errorf("cost: %s: %w", e.Name(), err)
+			return
+		}
+		catalogs[c.Version] = c
+		if latest == "" || versionLess(latest, c.Version) {
+			latest = c.Version
+		}
+	}
+}
+
+// versionLess orders "v1" < "v2" < "v10".
+func versionLess(a, b string) bool {
+	na, errA := strconv.Atoi(strings.TrimPrefix(a, "v"))
+	nb, errB := strconv.Atoi(strings.TrimPrefix(b, "v"))
+	if errA == nil && errB == nil {
+		return na < nb
+	}
+	return a < b
+}
+
+// Load returns the embedded catalog with the given version, or the latest
+// one when version is empty.
+func Load(version string) (*Catalog, error) {
+	loadOnce.Do(loadAll)
+	if loadErr != nil {
+		return nil, loadErr
+	}
+	if version == "" {
+		version = latest
+	}
+	c, ok := catalogs[version]
+	if !ok {
+		return nil, fmt.Errorf("cost: unknown catalog version %q", version)
+	}
+	return c, nil
+}
+
+// Parse decodes a catalog file and compiles its formulas.
+func Parse(raw []byte) (*Catalog, error) {
+	var c Catalog
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, err
+	}
+	if c.Version == "" {
+		return nil, fmt.Errorf("missing version")
+	}
+	if _, ok := c.Regions[c.DefaultRegion]; !ok {
+		return nil, fmt.Errorf("default region %q is not in regions", c.DefaultRegion)
+	}
+	for typ, m := range c.Components {
+		e, idents, err := parseFormula(m.Formula)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", typ, err)
+		}
+		for id := range idents {
+			if _, ok := m.Vars[id]; !ok && id != "hours" {
+				return nil, fmt.Errorf("%s: formula uses undefined variable %s", typ, id)
+			}
+		}
+		for name, v := range m.Vars {
+			if v.Fallback != "" {
+				if _, ok := m.Vars[v.Fallback]; !ok || v.Fallback == name {
+					return nil, fmt.Errorf("%s: variable %s falls back to unknown %s", typ, name, v.Fallback)
+				}
+			}
+		}
+		m.expr = e
+	}
+	return &c, nil
+}
+
+// Monthly prices a single component config in the default region. It
+// returns false when the catalog has no model for the type.
+func (c *Catalog) Monthly(componentType string, config map[string]any) (float64, bool) {
+	m, ok := c.Components[componentType]
+	if !ok {
+		return 0, false
+	}
+	vars := map[string]float64{"hours": c.HoursPerMonth}
+	for name := range m.Vars {
+		vars[name] = m.resolve(name, config, 0)
+	}
+	return roundCents(m.expr.eval(vars)), true
+}
+
+func (m *Model) resolve(name string, config map[string]any, depth int) float64 {
+	v := m.Vars[name]
+	key := v.Key
+	if key == "" {
+		key = name
+	}
+	raw, present := config[key]
+	if v.Map != nil {
+		if present {
+			if price, ok := v.Map[fmt.Sprint(raw)]; ok {
+				return price
+			}
+		}
+	} else if f, ok := toFloat(raw); ok && f != 0 {
+		return f
+	}
+	if v.Fallback != "" && depth < len(m.Vars) {
+		return m.resolve(v.Fallback, config, depth+1)
+	}
+	return v.Default
+}
+
+func toFloat(v any) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case int:
+		return float64(x), true
+	case string:
+		f, err := strconv.ParseFloat(x, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// ComponentCost is the monthly cost of one canvas node.
+type ComponentCost struct {
+	NodeID        string  `json:"node_id"`
+	Label         string  `json:"label"`
+	ComponentType string  `json:"component_type"`
+	Category      string  `json:"category"`
+	Region        string  `json:"region"`
+	Monthly       float64 `json:"monthly"`
+}
+
+// Estimate is the monthly cost breakdown of an architecture.
+type Estimate struct {
+	CatalogVersion string             `json:"catalog_version"`
+	Currency       string             `json:"currency"`
+	MonthlyTotal   float64            `json:"monthly_total"`
+	Components     []ComponentCost    `json:"components"`
+	ByCategory     map[string]float64 `json:"by_category"`
+	ByRegion       map[string]float64 `json:"by_region"`
+	// Unpriced lists component types on the canvas with no pricing model.
+	Unpriced []string `json:"unpriced,omitempty"`
+	// UnknownRegions were priced at the default region's rates.
+	UnknownRegions []string `json:"unknown_regions,omitempty"`
+}
+
+// Estimate prices every component of s. A component's region comes from
+// its own "region" config, then the enclosing datacenter, then
+// defaultRegion (the catalog default when empty).
+func (c *Catalog) Estimate(s *archdata.Schema, defaultRegion string) *Estimate {
+	if defaultRegion == "" {
+		defaultRegion = c.DefaultRegion
+	}
+	est := &Estimate{
+		CatalogVersion: c.Version,
+		Currency:       c.Currency,
+		Components:     []ComponentCost{},
+		ByCategory:     map[string]float64{},
+		ByRegion:       map[string]float64{},
+	}
+	unpriced := map[string]bool{}
+	unknown := map[string]bool{}
+
+	for i := range s.Nodes {
+		n := &s.Nodes[i]
+		if archdata.IsContainer(n.Data.ComponentType) || n.Data.ComponentType == "" {
+			continue
+		}
+		monthly, ok := c.Monthly(n.Data.ComponentType, n.Data.Config)
+		if !ok {
+			unpriced[n.Data.ComponentType] = true
+			continue
+		}
+		region := nodeRegion(s, n, defaultRegion)
+		mult := 1.0
+		if r, ok := c.Regions[region]; ok {
+			mult = r.Multiplier
+		} else {
+			unknown[region] = true
+		}
+		monthly = roundCents(monthly * mult)
+
+		cc := ComponentCost{
+			NodeID:        n.ID,
+			Label:         n.Data.Label,
+			ComponentType: n.Data.ComponentType,
+			Category:      n.CategoryName(),
+			Region:        region,
+			Monthly:       monthly,
+		}
+		est.Components = append(est.Components, cc)
+		est.MonthlyTotal += monthly
+		est.ByCategory[cc.Category] += monthly
+		est.ByRegion[region] += monthly
+	}
+
+	est.MonthlyTotal = roundCents(est.MonthlyTotal)
+	for k, v := range est.ByCategory {
+		est.ByCategory[k] = roundCents(v)
+	}
+	for k, v := range est.ByRegion {
+		est.ByRegion[k] = roundCents(v)
+	}
+	sort.SliceStable(est.Components, func(i, j int) bool { return est.Components[i].Monthly > est.Components[j].Monthly })
+	est.Unpriced = sortedKeys(unpriced)
+	est.UnknownRegions = sortedKeys(unknown)
+	return est
+}
+
+func nodeRegion(s *archdata.Schema, n *archdata.Node, def string) string {
+	if r := n.String("region", ""); r != "" {
+		return r
+	}
+	seen := map[string]bool{n.ID: true}
+	for id := n.ParentID; id != "" && !seen[id]; {
+		seen[id] = true
+		p := s.NodeByID(id)
+		if p == nil {
+			break
+		}
+		if p.Data.ComponentType == "datacenter" {
+			if r := p.String("region", ""); r != "" {
+				return r
+			}
+		}
+		id = p.ParentID
+	}
+	return def
+}
+
+func sortedKeys(m map[string]bool) []string {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,112 @@
+package cost
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/system-design-sandbox/server/internal/archdata"
+)
+
+func latestCatalog(t *testing.T) *Catalog {
+	t.Helper()
+	c, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return c
+}
+
+// Expected values are computed with pricing.ts for the same configs.
+func TestMonthlyMatchesPricingTS(t *testing.T) {
+	c := latestCatalog(t)
+	tests := []struct {
+		typ    string
+		config map[string]any
+		want   float64
+	}{
+		{"service", nil, 525.6},
+		{"service", map[string]any{"replicas": 2, "cpu_cores": 1, "memory_gb": 2}, 87.6},
+		{"postgresql", map[string]any{"storage_gb": 50, "read_replicas": 2}, 605.75},
+		{"redis", map[string]any{"memory_gb": 1, "mode": "cluster"}, 297.84},
+		{"kafka", map[string]any{"brokers": 0}, 459.9},
+		{"rabbitmq", map[string]any{"ha_mode": false}, 102.2},
+		{"rabbitmq", map[string]any{"nodes": 5}, 511},
+		{"s3", map[string]any{"storage_class": "glacier", "replicas": 2}, 10.4},
+		{"network_disk", map[string]any{"disk_type": "io2"}, 1102.5},
+		{"api_gateway", nil, 136080},
+	}
+	for _, tc := range tests {
+		got, ok := c.Monthly(tc.typ, tc.config)
+		if !ok {
+			t.Fatalf("%s: no pricing model", tc.typ)
+		}
+		if got != tc.want {
+			t.Errorf("%s %v: got %v, want %v", tc.typ, tc.config, got, tc.want)
+		}
+	}
+	if _, ok := c.Monthly("web_client", nil); ok {
+		t.Error("web_client should have no pricing model")
+	}
+}
+
+func TestEstimateBreakdown(t *testing.T) {
+	s := &archdata.Schema{Nodes: []archdata.Node{
+		{ID: "dc", Data: archdata.NodeData{ComponentType: "datacenter", Config: map[string]any{"region": "eu-central-1"}}},
+		{ID: "api", ParentID: "dc", Data: archdata.NodeData{Label: "API", ComponentType: "service", Config: map[string]any{"replicas": 1, "cpu_cores": 1, "memory_gb": 2}}},
+		{ID: "lb", Data: archdata.NodeData{Label: "LB", ComponentType: "load_balancer"}},
+		{ID: "web", Data: archdata.NodeData{Label: "Web", ComponentType: "web_client"}},
+		{ID: "far", Data: archdata.NodeData{Label: "Far", ComponentType: "load_balancer", Config: map[string]any{"region": "mars-1"}}},
+	}}
+
+	est := latestCatalog(t).Estimate(s, "")
+
+	// API: 1 * (0.048 + 0.012) * 730 = 43.8, * 1.12 in Frankfurt.
+	want := map[string]float64{"api": 49.06, "lb": 18, "far": 18}
+	for _, cc := range est.Components {
+		if cc.Monthly != want[cc.NodeID] {
+			t.Errorf("%s: got %v, want %v", cc.NodeID, cc.Monthly, want[cc.NodeID])
+		}
+	}
+	if len(est.Components) != 3 {
+		t.Fatalf("expected 3 priced components, got %+v", est.Components)
+	}
+	if est.MonthlyTotal != 85.06 {
+		t.Errorf("total = %v, want 85.06", est.MonthlyTotal)
+	}
+	if est.ByCategory["network"] != 36 || est.ByCategory["compute"] != 49.06 {
+		t.Errorf("unexpected category breakdown %v", est.ByCategory)
+	}
+	if est.ByRegion["eu-central-1"] != 49.06 || est.ByRegion["us-east-1"] != 18 {
+		t.Errorf("unexpected region breakdown %v", est.ByRegion)
+	}
+	if strings.Join(est.UnknownRegions, ",") != "mars-1" {
+		t.Errorf("unknown regions = %v", est.UnknownRegions)
+	}
+	if strings.Join(est.Unpriced, ",") != "web_client" {
+		t.Errorf("unpriced = %v", est.Unpriced)
+	}
+}
+
+func TestParseRejectsBadFormulas(t *testing.T) {
+	base := `{"version": "t", "default_region": "r", "regions": {"r": {"multiplier": 1}}, "components": {"x": %s}}`
+	for _, model := range []string{
+		`{"formula": "a * "}`,
+		`{"formula": "a * 2"}`,
+		`{"formula": "pow(a, 2)", "vars": {"a": {}}}`,
+		`{"formula": "a", "vars": {"a": {"fallback": "b"}}}`,
+	} {
+		if _, err := Parse([]byte(strings.Replace(base, "%s", model, 1))); err == nil {
+			t.Errorf("expected an error for %s", model)
+		}
+	}
+}
+
+func TestFormulaPrecedence(t *testing.T) {
+	e, _, err := parseFormula("2 + 3 * (4 - 1) / 3 - -1 + max(gt(5, 4), 0.5)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := e.eval(nil); got != 7 {
+		t.Errorf("got %v, want 7", got)
+	}
+}
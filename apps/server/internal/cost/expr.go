@@ -0,0 +1,213 @@
+package cost
+
+import (
+	"fmt"
+	"strconv"
+	"unicode"
+)
+
+// expr is a parsed pricing formula. The grammar covers what pricing.ts
+// needs: numbers, variables, + - * /, parentheses and the functions
+// min(a, b), max(a, b) and gt(a, b) (1 when a > b, else 0).
+type expr interface {
+	eval(vars map[string]float64) float64
+}
+
+type num float64
+
+type ident string
+
+type binary struct {
+	op   byte
+	l, r expr
+}
+
+type call struct {
+	fn   string
+	args []expr
+}
+
+type neg struct{ x expr }
+
+func (n num) eval(map[string]float64) float64        { return float64(n) }
+func (i ident) eval(vars map[string]float64) float64 { return vars[string(i)] }
+func (n neg) eval(vars map[string]float64) float64   { return -n.x.eval(vars) }
+
+func (b binary) eval(vars map[string]float64) float64 {
+	l, r := b.l.eval(vars), b.r.eval(vars)
+	switch b.op {
+	case '+':
+		return l + r
+	case '-':
+		return l - r
+	case '*':
+		return l * r
+	default:
+		if r == 0 {
+			return 0
+		}
+		return l / r
+	}
+}
+
+func (c call) eval(vars map[string]float64) float64 {
+	a, b := c.args[0].eval(vars), c.args[1].eval(vars)
+	switch c.fn {
+	case "min":
+		return min(a, b)
+	case "max":
+		return max(a, b)
+	default: // gt
+		if a > b {
+			return 1
+		}
+		return 0
+	}
+}
+
+var functions = map[string]bool{"min": true, "max": true, "gt": true}
+
+type parser struct {
+	src    string
+	pos    int
+	idents map[string]bool
+}
+
+// parseFormula parses src and returns the expression and the identifiers it uses.
+func parseFormula(src string) (expr, map[string]bool, error) {
+	p := &parser{src: src, idents: map[string]bool{}}
+	e, err := p.sum()
+	if err != nil {
+		return nil, nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.src) {
+		return nil, nil, fmt.Errorf("unexpected %q at %d", p.src[p.pos:], p.pos)
+	}
+	return e, p.idents, nil
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *parser) peek() byte {
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *parser) sum() (expr, error) {
+	l, err := p.product()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == '+' || op == '-'; op = p.peek() {
+		p.pos++
+		r, err := p.product()
+		if err != nil {
+			return nil, err
+		}
+		l = binary{op, l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) product() (expr, error) {
+	l, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == '*' || op == '/'; op = p.peek() {
+		p.pos++
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l = binary{op, l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) unary() (expr, error) {
+	if p.peek() == '-' {
+		p.pos++
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return neg{x}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (expr, error) {
+	c := p.peek()
+	switch {
+	case c == '(':
+		p.pos++
+		e, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing ) at %d", p.pos)
+		}
+		p.pos++
+		return e, nil
+
+	case c >= '0' && c <= '9' || c == '.':
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q", p.src[start:p.pos])
+		}
+		return num(v), nil
+
+	case c == '_' || unicode.IsLetter(rune(c)):
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || unicode.IsLetter(rune(p.src[p.pos])) || unicode.IsDigit(rune(p.src[p.pos]))) {
+			p.pos++
+		}
+		name := p.src[start:p.pos]
+		if p.peek() != '(' {
+			p.idents[name] = true
+			return ident(name), nil
+		}
+		if !functions[name] {
+			return nil, fmt.Errorf("unknown function %s", name)
+		}
+		p.pos++
+		var args []expr
+		for {
+			a, err := p.sum()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, a)
+			if p.peek() != ',' {
+				break
+			}
+			p.pos++
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing ) after %s arguments", name)
+		}
+		p.pos++
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%s takes 2 arguments, got %d", name, len(args))
+		}
+		return call{name, args}, nil
+	}
+	if c == 0 {
+		return nil, fmt.Errorf("unexpected end of formula")
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", c, p.pos)
+}
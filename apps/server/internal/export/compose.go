@@ -0,0 +1,116 @@
+package export
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// compose renders docker-compose.yml plus nginx configs for load balancers.
+func (p *plan) compose(project string) []File {
+	services := ymap{}
+	volumes := ymap{}
+	var files []File
+	lbPort := 8080
+
+	for _, c := range p.components {
+		var svc ymap
+		switch c.kind {
+		case kindService:
+			svc = ymap{{"image", c.node.String("image", serviceImage)}}
+			if env := connectionEnv(c); len(env) > 0 {
+				svc = append(svc, kv{"environment", env})
+			}
+			svc = append(svc, kv{"deploy", ymap{
+				{"replicas", c.replicas},
+				{"resources", ymap{{"limits", ymap{
+					{"cpus", strconv.FormatFloat(cpuLimit(c.node), 'f', -1, 64)},
+					{"memory", memoryQuantity(memoryGB(c.node), "m")},
+				}}}},
+			}})
+			p.note("Services run the %s placeholder image; set an `image` key in the component config to use your own.", serviceImage)
+
+		case kindPostgres:
+			svc = ymap{
+				{"image", "postgres:16-alpine"},
+				{"environment", ymap{{"POSTGRES_USER", "app"}, {"POSTGRES_PASSWORD", "app"}, {"POSTGRES_DB", "app"}}},
+				{"volumes", ylist{c.name + "-data:/var/lib/postgresql/data"}},
+			}
+			volumes = append(volumes, kv{c.name + "-data", ymap{}})
+			if c.node.Number("read_replicas", 0) > 0 {
+				p.note("PostgreSQL read replicas are not generated; each database runs as a single primary.")
+			}
+
+		case kindRedis:
+			svc = ymap{
+				{"image", "redis:7-alpine"},
+				{"command", ylist{"redis-server", "--maxmemory", memoryQuantity(memoryGB(c.node), "mb"), "--maxmemory-policy", "allkeys-lru"}},
+			}
+			if mode := c.node.String("mode", "standalone"); mode != "standalone" {
+				p.note("Redis %s mode is approximated by a single standalone instance.", mode)
+			}
+
+		case kindKafka:
+			svc = ymap{
+				{"image", "bitnami/kafka:3.7"},
+				{"environment", kafkaEnv(c.name)},
+			}
+			if c.node.Number("brokers", 3) > 1 {
+				p.note("Kafka clusters are approximated by a single KRaft broker in docker-compose.")
+			}
+
+		case kindLoadBalancer:
+			conf := "nginx/" + c.name + ".conf"
+			files = append(files, File{Name: conf, Data: []byte(nginxConfig(c))})
+			svc = ymap{
+				{"image", "nginx:1.27-alpine"},
+				{"ports", ylist{fmt.Sprintf("%d:80", lbPort)}},
+				{"volumes", ylist{"./" + conf + ":/etc/nginx/conf.d/default.conf:ro"}},
+			}
+			lbPort++
+			if c.replicas > 1 {
+				p.note("Load balancer replicas collapse to one nginx container per load balancer in docker-compose.")
+			}
+		}
+
+		if deps := dependsOn(c); len(deps) > 0 {
+			svc = append(svc, kv{"depends_on", deps})
+		}
+		services = append(services, kv{c.name, svc})
+	}
+
+	doc := ymap{{"name", project}, {"services", services}}
+	if len(volumes) > 0 {
+		doc = append(doc, kv{"volumes", volumes})
+	}
+	return append([]File{{Name: "docker-compose.yml", Data: marshalYAML(doc)}}, files...)
+}
+
+func dependsOn(c *component) ylist {
+	var deps ylist
+	for _, t := range c.targets {
+		deps = append(deps, t.name)
+	}
+	return deps
+}
+
+func kafkaEnv(name string) ymap {
+	return ymap{
+		{"KAFKA_CFG_NODE_ID", "0"},
+		{"KAFKA_CFG_PROCESS_ROLES", "controller,broker"},
+		{"KAFKA_CFG_LISTENERS", "PLAINTEXT://:9092,CONTROLLER://:9093"},
+		{"KAFKA_CFG_ADVERTISED_LISTENERS", "PLAINTEXT://" + name + ":9092"},
+		{"KAFKA_CFG_CONTROLLER_QUORUM_VOTERS", "0@" + name + ":9093"},
+		{"KAFKA_CFG_CONTROLLER_LISTENER_NAMES", "CONTROLLER"},
+		{"KAFKA_CFG_LISTENER_SECURITY_PROTOCOL_MAP", "CONTROLLER:PLAINTEXT,PLAINTEXT:PLAINTEXT"},
+	}
+}
+
+// memoryQuantity formats gigabytes in megabytes with the given unit suffix
+// ("m" for compose, "mb" for redis, "Mi" for Kubernetes).
+func memoryQuantity(gb float64, unit string) string {
+	mb := int(gb * 1024)
+	if mb < 64 {
+		mb = 64
+	}
+	return strconv.Itoa(mb) + unit
+}
@@ -0,0 +1,110 @@
+// Package export turns a stored architecture into deployable artifacts
+// (docker-compose, Kubernetes manifests, Terraform) packaged as a zip bundle.
+package export
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/system-design-sandbox/server/internal/archdata"
+)
+
+// File is a single generated artifact inside a bundle.
+type File struct {
+	Name string
+	Data []byte
+}
+
+// Bundle is a set of generated files ready to be zipped.
+type Bundle struct {
+	Files []File
+}
+
+// Infrastructure generates docker-compose.yml, Kubernetes manifests and a
+// README describing what was approximated.
+func Infrastructure(s *archdata.Schema, name string) *Bundle {
+	p := newPlan(s)
+	project := dnsName(name, "architecture")
+
+	b := &Bundle{}
+	b.Files = append(b.Files, p.compose(project)...)
+	b.Files = append(b.Files, p.kubernetes()...)
+	b.Files = append([]File{{Name: "README.md", Data: []byte(p.readme(name, infrastructureUsage))}}, b.Files...)
+	return b
+}
+
+// WriteZip writes all bundle files under a top-level directory named dir.
+func (b *Bundle) WriteZip(w io.Writer, dir string) error {
+	zw := zip.NewWriter(w)
+	modified := time.Now().UTC()
+	for _, f := range b.Files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     dir + "/" + f.Name,
+			Method:   zip.Deflate,
+			Modified: modified,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(f.Data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// File returns the generated file with the given name, or nil.
+func (b *Bundle) File(name string) *File {
+	for i := range b.Files {
+		if b.Files[i].Name == name {
+			return &b.Files[i]
+		}
+	}
+	return nil
+}
+
+const infrastructureUsage = "## Usage\n\n" +
+	"```sh\n" +
+	"docker compose up -d            # local containers\n" +
+	"kubectl apply -k k8s/           # or any Kubernetes cluster (kind, minikube, k3d)\n" +
+	"```\n"
+
+func (p *plan) readme(name, usage string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", name)
+	b.WriteString("Generated by System Design Sandbox from a saved architecture.\n")
+	b.WriteString("Review the output before using it beyond a local sandbox.\n\n")
+	b.WriteString(usage)
+
+	b.WriteString("\n## Components\n\n")
+	if len(p.components) == 0 {
+		b.WriteString("No deployable components were found on the canvas.\n")
+	}
+	for _, c := range p.components {
+		fmt.Fprintf(&b, "- `%s` — %s (%s), replicas: %d\n", c.name, c.node.Data.Label, c.node.Data.ComponentType, c.replicas)
+	}
+
+	if len(p.skipped) > 0 {
+		b.WriteString("\n## Not generated\n\n")
+		b.WriteString("These canvas components have no deployable equivalent and were skipped:\n\n")
+		for _, s := range p.skipped {
+			fmt.Fprintf(&b, "- %s\n", s)
+		}
+	}
+
+	if len(p.notes) > 0 {
+		b.WriteString("\n## Approximations\n\n")
+		for _, n := range p.notes {
+			fmt.Fprintf(&b, "- %s\n", n)
+		}
+	}
+	return b.String()
+}
+
+// FileName returns a filesystem-safe base name for downloads.
+func FileName(name string) string {
+	return dnsName(name, "architecture")
+}
@@ -0,0 +1,213 @@
+package export
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// kubernetes renders one manifest file per component and a kustomization
+// that applies them all.
+func (p *plan) kubernetes() []File {
+	var files []File
+	var resources ylist
+
+	for _, c := range p.components {
+		var docs []ymap
+		switch c.kind {
+		case kindService:
+			docs = append(docs,
+				deployment(c, c.node.String("image", serviceImage), 80, connectionEnv(c), serviceResources(c)),
+				clusterService(c, "ClusterIP", 80),
+				hpa(c),
+			)
+		case kindPostgres:
+			docs = append(docs,
+				statefulSet(c, "postgres:16-alpine", 5432, ymap{{"POSTGRES_USER", "app"}, {"POSTGRES_PASSWORD", "app"}, {"POSTGRES_DB", "app"}, {"PGDATA", "/var/lib/postgresql/data/pgdata"}}, nil, "/var/lib/postgresql/data", storageGB(c.node)),
+				headlessService(c, 5432),
+			)
+			p.note("Database credentials are inlined for local use; move them to Secrets before deploying anywhere shared.")
+		case kindRedis:
+			args := ylist{"--maxmemory", memoryQuantity(memoryGB(c.node), "mb"), "--maxmemory-policy", "allkeys-lru"}
+			docs = append(docs,
+				statefulSet(c, "redis:7-alpine", 6379, nil, args, "/data", 1),
+				headlessService(c, 6379),
+			)
+		case kindKafka:
+			docs = append(docs,
+				statefulSet(c, "bitnami/kafka:3.7", 9092, kafkaEnv(c.name), nil, "/bitnami/kafka", 10),
+				headlessService(c, 9092),
+			)
+			if c.node.Number("brokers", 3) > 1 {
+				p.note("Kafka StatefulSets run a single KRaft broker; scaling out needs per-pod node IDs and a controller quorum.")
+			}
+		case kindLoadBalancer:
+			docs = append(docs,
+				configMap(c.name+"-nginx", ymap{{"default.conf", nginxConfig(c)}}),
+				deployment(c, "nginx:1.27-alpine", 80, nil, nil),
+				clusterService(c, "LoadBalancer", 80),
+			)
+			p.note("Load balancers become an nginx Deployment behind a Service of type LoadBalancer; on local clusters use `kubectl port-forward` or MetalLB.")
+		}
+
+		name := "k8s/" + c.name + ".yaml"
+		files = append(files, File{Name: name, Data: marshalYAMLDocs(docs...)})
+		resources = append(resources, c.name+".yaml")
+	}
+
+	kustomization := ymap{
+		{"apiVersion", "kustomize.config.k8s.io/v1beta1"},
+		{"kind", "Kustomization"},
+		{"resources", resources},
+	}
+	return append(files, File{Name: "k8s/kustomization.yaml", Data: marshalYAML(kustomization)})
+}
+
+func labels(c *component) ymap {
+	return ymap{{"app.kubernetes.io/name", c.name}}
+}
+
+func metadata(c *component) ymap {
+	return ymap{{"name", c.name}, {"labels", labels(c)}}
+}
+
+func container(c *component, image string, port int, env ymap, args ylist) ymap {
+	ct := ymap{
+		{"name", c.name},
+		{"image", image},
+	}
+	if len(args) > 0 {
+		ct = append(ct, kv{"args", args})
+	}
+	ct = append(ct, kv{"ports", ylist{ymap{{"containerPort", port}}}})
+	if len(env) > 0 {
+		var list ylist
+		for _, e := range env {
+			list = append(list, ymap{{"name", e.Key}, {"value", e.Value}})
+		}
+		ct = append(ct, kv{"env", list})
+	}
+	return ct
+}
+
+func serviceResources(c *component) ymap {
+	cpu := strconv.FormatFloat(cpuLimit(c.node), 'f', -1, 64)
+	mem := memoryQuantity(memoryGB(c.node), "Mi")
+	return ymap{
+		{"requests", ymap{{"cpu", cpu}, {"memory", mem}}},
+		{"limits", ymap{{"memory", mem}}},
+	}
+}
+
+func deployment(c *component, image string, port int, env ymap, resources ymap) ymap {
+	ct := container(c, image, port, env, nil)
+	if c.kind == kindLoadBalancer {
+		ct = append(ct, kv{"volumeMounts", ylist{ymap{{"name", "nginx-conf"}, {"mountPath", "/etc/nginx/conf.d"}}}})
+	}
+	if len(resources) > 0 {
+		ct = append(ct, kv{"resources", resources})
+	}
+	podSpec := ymap{{"containers", ylist{ct}}}
+	if c.kind == kindLoadBalancer {
+		podSpec = append(podSpec, kv{"volumes", ylist{ymap{{"name", "nginx-conf"}, {"configMap", ymap{{"name", c.name + "-nginx"}}}}}})
+	}
+	return ymap{
+		{"apiVersion", "apps/v1"},
+		{"kind", "Deployment"},
+		{"metadata", metadata(c)},
+		{"spec", ymap{
+			{"replicas", c.replicas},
+			{"selector", ymap{{"matchLabels", labels(c)}}},
+			{"template", ymap{
+				{"metadata", ymap{{"labels", labels(c)}}},
+				{"spec", podSpec},
+			}},
+		}},
+	}
+}
+
+func statefulSet(c *component, image string, port int, env ymap, args ylist, mountPath string, storageGi float64) ymap {
+	ct := container(c, image, port, env, args)
+	ct = append(ct, kv{"volumeMounts", ylist{ymap{{"name", "data"}, {"mountPath", mountPath}}}})
+	return ymap{
+		{"apiVersion", "apps/v1"},
+		{"kind", "StatefulSet"},
+		{"metadata", metadata(c)},
+		{"spec", ymap{
+			{"serviceName", c.name},
+			{"replicas", 1},
+			{"selector", ymap{{"matchLabels", labels(c)}}},
+			{"template", ymap{
+				{"metadata", ymap{{"labels", labels(c)}}},
+				{"spec", ymap{{"containers", ylist{ct}}}},
+			}},
+			{"volumeClaimTemplates", ylist{ymap{
+				{"metadata", ymap{{"name", "data"}}},
+				{"spec", ymap{
+					{"accessModes", ylist{"ReadWriteOnce"}},
+					{"resources", ymap{{"requests", ymap{{"storage", fmt.Sprintf("%.0fGi", storageGi)}}}}},
+				}},
+			}}},
+		}},
+	}
+}
+
+func clusterService(c *component, typ string, port int) ymap {
+	return ymap{
+		{"apiVersion", "v1"},
+		{"kind", "Service"},
+		{"metadata", metadata(c)},
+		{"spec", ymap{
+			{"type", typ},
+			{"selector", labels(c)},
+			{"ports", ylist{ymap{{"port", port}, {"targetPort", port}}}},
+		}},
+	}
+}
+
+func headlessService(c *component, port int) ymap {
+	return ymap{
+		{"apiVersion", "v1"},
+		{"kind", "Service"},
+		{"metadata", metadata(c)},
+		{"spec", ymap{
+			{"clusterIP", "None"},
+			{"selector", labels(c)},
+			{"ports", ylist{ymap{{"port", port}, {"targetPort", port}}}},
+		}},
+	}
+}
+
+// hpa keeps the canvas replica count as the floor and allows doubling under load
+// unless the component config sets max_replicas.
+func hpa(c *component) ymap {
+	maxReplicas := int(c.node.Number("max_replicas", float64(c.replicas*2)))
+	if maxReplicas < c.replicas {
+		maxReplicas = c.replicas
+	}
+	return ymap{
+		{"apiVersion", "autoscaling/v2"},
+		{"kind", "HorizontalPodAutoscaler"},
+		{"metadata", metadata(c)},
+		{"spec", ymap{
+			{"scaleTargetRef", ymap{{"apiVersion", "apps/v1"}, {"kind", "Deployment"}, {"name", c.name}}},
+			{"minReplicas", c.replicas},
+			{"maxReplicas", maxReplicas},
+			{"metrics", ylist{ymap{
+				{"type", "Resource"},
+				{"resource", ymap{
+					{"name", "cpu"},
+					{"target", ymap{{"type", "Utilization"}, {"averageUtilization", 70}}},
+				}},
+			}}},
+		}},
+	}
+}
+
+func configMap(name string, data ymap) ymap {
+	return ymap{
+		{"apiVersion", "v1"},
+		{"kind", "ConfigMap"},
+		{"metadata", ymap{{"name", name}}},
+		{"data", data},
+	}
+}
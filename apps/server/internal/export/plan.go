@@ -0,0 +1,169 @@
+package export
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/system-design-sandbox/server/internal/archdata"
+)
+
+// kind is a deployable component class. Everything else on the canvas
+// (clients, observability, containers, ...) is reported as skipped.
+type kind int
+
+const (
+	kindService kind = iota
+	kindPostgres
+	kindRedis
+	kindKafka
+	kindLoadBalancer
+)
+
+var deployableKinds = map[string]kind{
+	"service":       kindService,
+	"worker":        kindService,
+	"postgresql":    kindPostgres,
+	"redis":         kindRedis,
+	"kafka":         kindKafka,
+	"load_balancer": kindLoadBalancer,
+}
+
+type component struct {
+	node     *archdata.Node
+	name     string
+	kind     kind
+	replicas int
+	// targets are deployable components this one sends traffic to.
+	targets []*component
+}
+
+type plan struct {
+	components []*component
+	skipped    []string
+	notes      []string
+}
+
+var nonDNS = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// dnsName converts a canvas label into an RFC 1123 label usable as a
+// compose service, Kubernetes object and Terraform resource name.
+func dnsName(label, fallback string) string {
+	s := strings.Trim(nonDNS.ReplaceAllString(strings.ToLower(label), "-"), "-")
+	if s == "" {
+		s = strings.Trim(nonDNS.ReplaceAllString(strings.ToLower(fallback), "-"), "-")
+	}
+	if s == "" {
+		s = "component"
+	}
+	if s[0] >= '0' && s[0] <= '9' {
+		s = "c-" + s
+	}
+	if len(s) > 40 {
+		s = strings.TrimRight(s[:40], "-")
+	}
+	return s
+}
+
+func newPlan(s *archdata.Schema) *plan {
+	p := &plan{}
+	byID := make(map[string]*component)
+	used := make(map[string]bool)
+
+	for i := range s.Nodes {
+		n := &s.Nodes[i]
+		k, ok := deployableKinds[n.Data.ComponentType]
+		if !ok {
+			if n.Data.ComponentType != "" && !archdata.IsContainer(n.Data.ComponentType) {
+				p.skipped = append(p.skipped, fmt.Sprintf("%s (%s)", n.Data.Label, n.Data.ComponentType))
+			}
+			continue
+		}
+		base := dnsName(n.Data.Label, n.Data.ComponentType)
+		name := base
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s-%d", base, i)
+		}
+		used[name] = true
+		c := &component{node: n, name: name, kind: k, replicas: n.Replicas()}
+		byID[n.ID] = c
+		p.components = append(p.components, c)
+	}
+
+	for _, e := range s.Edges {
+		src, dst := byID[e.Source], byID[e.Target]
+		if src == nil || dst == nil || src == dst {
+			continue
+		}
+		src.targets = append(src.targets, dst)
+	}
+	for _, c := range p.components {
+		sort.Slice(c.targets, func(i, j int) bool { return c.targets[i].name < c.targets[j].name })
+	}
+	sort.Strings(p.skipped)
+	return p
+}
+
+func (p *plan) note(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	for _, n := range p.notes {
+		if n == msg {
+			return
+		}
+	}
+	p.notes = append(p.notes, msg)
+}
+
+// envName turns a component name into an environment variable prefix.
+func envName(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// connectionEnv returns the environment variables a service gets for each
+// backing component it is wired to on the canvas.
+func connectionEnv(c *component) ymap {
+	env := ymap{}
+	for _, t := range c.targets {
+		prefix := envName(t.name)
+		switch t.kind {
+		case kindPostgres:
+			env = append(env, kv{prefix + "_URL", fmt.Sprintf("postgres://app:app@%s:5432/app?sslmode=disable", t.name)})
+		case kindRedis:
+			env = append(env, kv{prefix + "_URL", fmt.Sprintf("redis://%s:6379/0", t.name)})
+		case kindKafka:
+			env = append(env, kv{prefix + "_BROKERS", t.name + ":9092"})
+		case kindService, kindLoadBalancer:
+			env = append(env, kv{prefix + "_URL", "http://" + t.name})
+		}
+	}
+	return env
+}
+
+// cpuLimit and memoryLimit read the canvas sizing; defaults match the
+// component library (4 cores, 8 GB per service instance).
+func cpuLimit(n *archdata.Node) float64  { return n.Number("cpu_cores", 4) }
+func memoryGB(n *archdata.Node) float64  { return n.Number("memory_gb", 8) }
+func storageGB(n *archdata.Node) float64 { return n.Number("storage_gb", 100) }
+
+const serviceImage = "traefik/whoami:v1.10"
+
+// nginxConfig proxies a load balancer to its targets using the configured algorithm.
+func nginxConfig(c *component) string {
+	var b strings.Builder
+	b.WriteString("upstream backend {\n")
+	switch c.node.String("algorithm", "round_robin") {
+	case "least_conn":
+		b.WriteString("    least_conn;\n")
+	case "ip_hash":
+		b.WriteString("    ip_hash;\n")
+	}
+	if len(c.targets) == 0 {
+		b.WriteString("    server 127.0.0.1:65535 down;\n")
+	}
+	for _, t := range c.targets {
+		fmt.Fprintf(&b, "    server %s:80;\n", t.name)
+	}
+	b.WriteString("}\n\nserver {\n    listen 80;\n    location / {\n        proxy_pass http://backend;\n        proxy_set_header Host $host;\n    }\n}\n")
+	return b.String()
+}
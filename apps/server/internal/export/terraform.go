@@ -0,0 +1,361 @@
+package export
+
+import (
+	"embed"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/system-design-sandbox/server/internal/archdata"
+)
+
+//go:embed terraform
+var terraformModules embed.FS
+
+// tier maps a capacity requirement to the smallest instance that covers it.
+type tier struct {
+	limit float64
+	class string
+}
+
+// Instance tiers follow the price points in component-library pricing.ts so
+// that the generated infrastructure costs roughly what the canvas estimates:
+// a PostgreSQL primary is ~$200/month (db.m6g.large with storage), a Kafka
+// broker is $0.21/h (kafka.m5.large) and Redis is priced per GB of memory.
+var (
+	rdsTiers = []tier{ // queries per second per instance
+		{1000, "db.t4g.medium"},
+		{5000, "db.m6g.large"},
+		{10000, "db.m6g.xlarge"},
+		{20000, "db.m6g.2xlarge"},
+		{40000, "db.m6g.4xlarge"},
+		{math.Inf(1), "db.m6g.8xlarge"},
+	}
+	elasticacheTiers = []tier{ // usable memory in GB
+		{1.37, "cache.t4g.small"},
+		{3.09, "cache.t4g.medium"},
+		{6.38, "cache.m6g.large"},
+		{13.07, "cache.r6g.large"},
+		{26.32, "cache.r6g.xlarge"},
+		{52.82, "cache.r6g.2xlarge"},
+		{105.81, "cache.r6g.4xlarge"},
+		{209.55, "cache.r6g.8xlarge"},
+		{math.Inf(1), "cache.r6g.16xlarge"},
+	}
+	mskTiers = []tier{ // messages per second per broker
+		{100000, "kafka.m5.large"},
+		{200000, "kafka.m5.xlarge"},
+		{400000, "kafka.m5.2xlarge"},
+		{math.Inf(1), "kafka.m5.4xlarge"},
+	}
+)
+
+func pickTier(tiers []tier, need float64) string {
+	for _, t := range tiers {
+		if need <= t.limit {
+			return t.class
+		}
+	}
+	return tiers[len(tiers)-1].class
+}
+
+// tfInput is one module argument exposed as a root variable so it can be
+// tuned without touching the generated modules.
+type tfInput struct {
+	arg         string
+	typ         string
+	description string
+	value       any
+}
+
+// tfModule is a module call in the root main.tf.
+type tfModule struct {
+	c       *component
+	source  string
+	subnets string
+	inputs  []tfInput
+	outputs []string
+}
+
+// Terraform generates an AWS Terraform module tree: root main.tf,
+// variables.tf and outputs.tf plus one reusable module per resource type.
+// Generation is plain file rendering; nothing talks to AWS.
+func Terraform(s *archdata.Schema, name string) *Bundle {
+	p := newPlan(s)
+	project := dnsName(name, "architecture")
+
+	var mods []tfModule
+	var provisioned []*component
+	for _, c := range p.components {
+		if m, ok := p.terraformModule(c); ok {
+			mods = append(mods, m)
+			provisioned = append(provisioned, c)
+		}
+	}
+	p.components = provisioned
+	sort.Strings(p.skipped)
+
+	b := &Bundle{}
+	b.Files = append(b.Files,
+		File{Name: "README.md", Data: []byte(p.readme(name, terraformUsage))},
+		File{Name: "versions.tf", Data: []byte(terraformVersions)},
+		File{Name: "main.tf", Data: []byte(terraformMain(mods))},
+		File{Name: "variables.tf", Data: []byte(terraformVariables(project, mods))},
+		File{Name: "outputs.tf", Data: []byte(terraformOutputs(mods))},
+		File{Name: "terraform.tfvars.example", Data: []byte(terraformTfvarsExample)},
+	)
+	b.Files = append(b.Files, moduleFiles(mods)...)
+	return b
+}
+
+func (p *plan) terraformModule(c *component) (tfModule, bool) {
+	label := c.node.Data.Label
+	switch c.kind {
+	case kindPostgres:
+		rps := c.node.Number("max_rps_per_instance", 5000)
+		return tfModule{c: c, source: "rds", subnets: "private", outputs: []string{"endpoint", "replica_endpoints", "master_user_secret_arn"}, inputs: []tfInput{
+			{"instance_class", "string", fmt.Sprintf("%s: RDS instance class sized for %s queries/sec per instance.", label, formatNumber(rps)), pickTier(rdsTiers, rps)},
+			{"allocated_storage", "number", label + ": storage in GB.", int(storageGB(c.node))},
+			{"multi_az", "bool", label + ": standby in a second AZ (replicas > 1 on the canvas).", c.replicas > 1},
+			{"read_replica_count", "number", label + ": read replicas.", int(c.node.Number("read_replicas", 0))},
+		}}, true
+
+	case kindRedis:
+		rps := c.node.Number("max_rps_per_instance", 100000)
+		nodeType := pickTier(elasticacheTiers, memoryGB(c.node))
+		if rps > 100000 && strings.HasPrefix(nodeType, "cache.t4g.") {
+			// Burstable nodes cannot sustain the throughput the canvas asks for.
+			nodeType = "cache.m6g.large"
+		}
+		// Node counts mirror pricing.ts: cluster = 3 shards x 2, sentinel = 1 + 2 replicas.
+		shards, replicasPerShard := 1, 0
+		switch c.node.String("mode", "standalone") {
+		case "cluster":
+			shards, replicasPerShard = max(3, c.replicas), 1
+			p.note("Redis cluster mode becomes an ElastiCache replication group with %d shards and one replica each.", shards)
+		case "sentinel":
+			replicasPerShard = 2
+			p.note("Redis sentinel mode becomes a single-shard replication group with automatic failover.")
+		}
+		return tfModule{c: c, source: "elasticache", subnets: "private", outputs: []string{"endpoint"}, inputs: []tfInput{
+			{"node_type", "string", fmt.Sprintf("%s: node type for %s GB of memory.", label, formatNumber(memoryGB(c.node))), nodeType},
+			{"shards", "number", label + ": node groups (shards).", shards},
+			{"replicas_per_shard", "number", label + ": replicas in each shard.", replicasPerShard},
+		}}, true
+
+	case kindKafka:
+		rps := c.node.Number("max_rps_per_broker", 100000)
+		brokers := int(c.node.Number("brokers", 3))
+		if brokers%3 != 0 {
+			p.note("MSK rounds the broker count up to a multiple of the subnet count; %s asks for %d brokers.", label, brokers)
+		}
+		return tfModule{c: c, source: "msk", subnets: "private", outputs: []string{"bootstrap_brokers_tls"}, inputs: []tfInput{
+			{"broker_count", "number", label + ": brokers (rounded up to a multiple of the subnet count).", brokers},
+			{"broker_instance_type", "string", fmt.Sprintf("%s: broker instance type for %s messages/sec per broker.", label, formatNumber(rps)), pickTier(mskTiers, rps)},
+			{"volume_size_gb", "number", label + ": EBS volume per broker in GB.", int(c.node.Number("storage_gb", 100))},
+			{"partitions", "number", label + ": default partitions for new topics.", int(c.node.Number("partitions", 12))},
+			{"replication_factor", "number", label + ": default replication factor.", int(c.node.Number("replication_factor", 3))},
+			{"retention_hours", "number", label + ": log retention in hours.", int(c.node.Number("retention_hours", 168))},
+		}}, true
+
+	case kindLoadBalancer:
+		algorithm, sticky := "round_robin", false
+		switch c.node.String("algorithm", "round_robin") {
+		case "least_conn":
+			algorithm = "least_outstanding_requests"
+		case "ip_hash":
+			sticky = true
+			p.note("ALB has no IP hashing; %s uses cookie stickiness instead.", label)
+		}
+		expected := expectedRPS(c)
+		p.note("Load balancer targets are not registered; attach your service tasks or instances to the `target_group_arn` output.")
+		return tfModule{c: c, source: "alb", subnets: "public", outputs: []string{"dns_name", "target_group_arn"}, inputs: []tfInput{
+			{"algorithm", "string", label + ": target group load balancing algorithm.", algorithm},
+			{"sticky_sessions", "bool", label + ": cookie stickiness.", sticky},
+			{"expected_rps", "number", label + ": designed request rate; an alarm fires above it.", int(expected)},
+		}}, true
+
+	default:
+		p.skipped = append(p.skipped, fmt.Sprintf("%s (%s) — compute is not provisioned; use the IaC export or your own ECS/EKS setup", label, c.node.Data.ComponentType))
+		return tfModule{}, false
+	}
+}
+
+// expectedRPS is the traffic a load balancer's backends can serve together,
+// or the load balancer's own capacity when nothing is wired behind it.
+func expectedRPS(c *component) float64 {
+	var total float64
+	for _, t := range c.targets {
+		def := 2000.0
+		if t.node.Data.ComponentType == "worker" {
+			def = 200
+		}
+		total += float64(t.replicas) * t.node.Number("max_rps_per_instance", def)
+	}
+	if total == 0 {
+		total = float64(c.replicas) * c.node.Number("max_rps_per_instance", 50000)
+	}
+	return total
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// tfIdent turns a component name into a Terraform identifier.
+func tfIdent(name string) string {
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+// hclExpr is a value written verbatim rather than quoted.
+type hclExpr string
+
+// hclValue renders a Go value as an HCL literal.
+func hclValue(v any) string {
+	switch x := v.(type) {
+	case string:
+		s := strconv.Quote(x)
+		s = strings.ReplaceAll(s, "${", "$${")
+		return strings.ReplaceAll(s, "%{", "%%{")
+	case bool:
+		return strconv.FormatBool(x)
+	case int:
+		return strconv.Itoa(x)
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// writeAttrs writes attributes with their equals signs aligned, as terraform fmt does.
+func writeAttrs(b *strings.Builder, indent string, attrs [][2]string) {
+	width := 0
+	for _, a := range attrs {
+		width = max(width, len(a[0]))
+	}
+	for _, a := range attrs {
+		fmt.Fprintf(b, "%s%-*s = %s\n", indent, width, a[0], a[1])
+	}
+}
+
+func terraformMain(mods []tfModule) string {
+	var b strings.Builder
+	b.WriteString("provider \"aws\" {\n  region = var.region\n\n  default_tags {\n    tags = {\n      Project = var.name_prefix\n    }\n  }\n}\n")
+	for _, m := range mods {
+		id := tfIdent(m.c.name)
+		fmt.Fprintf(&b, "\nmodule %q {\n  source = \"./modules/%s\"\n\n", id, m.source)
+		common := [][2]string{
+			{"name", fmt.Sprintf(`"${var.name_prefix}-%s"`, m.c.name)},
+			{"vpc_id", "var.vpc_id"},
+			{"subnet_ids", "var." + m.subnets + "_subnet_ids"},
+		}
+		if m.source != "alb" {
+			common = append(common, [2]string{"allowed_cidr_blocks", "var.allowed_cidr_blocks"})
+		}
+		writeAttrs(&b, "  ", common)
+		b.WriteString("\n")
+		var sizing [][2]string
+		for _, in := range m.inputs {
+			sizing = append(sizing, [2]string{in.arg, "var." + id + "_" + in.arg})
+		}
+		writeAttrs(&b, "  ", sizing)
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+func terraformVariables(project string, mods []tfModule) string {
+	var b strings.Builder
+	writeVariable(&b, "region", "AWS region.", "string", "us-east-1")
+	writeVariable(&b, "name_prefix", "Prefix for all resource names.", "string", project)
+	writeVariable(&b, "vpc_id", "VPC to deploy into.", "string", nil)
+	writeVariable(&b, "private_subnet_ids", "Private subnets in distinct AZs for data stores.", "list(string)", nil)
+	writeVariable(&b, "public_subnet_ids", "Public subnets in distinct AZs for load balancers.", "list(string)", nil)
+	writeVariable(&b, "allowed_cidr_blocks", "CIDR blocks allowed to reach data stores.", "list(string)", hclExpr(`["10.0.0.0/8"]`))
+	for _, m := range mods {
+		for _, in := range m.inputs {
+			writeVariable(&b, tfIdent(m.c.name)+"_"+in.arg, in.description, in.typ, in.value)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func writeVariable(b *strings.Builder, name, description, typ string, def any) {
+	fmt.Fprintf(b, "variable %q {\n", name)
+	attrs := [][2]string{{"description", hclValue(description)}, {"type", typ}}
+	switch d := def.(type) {
+	case nil:
+	case hclExpr:
+		attrs = append(attrs, [2]string{"default", string(d)})
+	default:
+		attrs = append(attrs, [2]string{"default", hclValue(d)})
+	}
+	writeAttrs(b, "  ", attrs)
+	b.WriteString("}\n\n")
+}
+
+func terraformOutputs(mods []tfModule) string {
+	var b strings.Builder
+	for i, m := range mods {
+		id := tfIdent(m.c.name)
+		for j, out := range m.outputs {
+			if i > 0 || j > 0 {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "output %q {\n  value = module.%s.%s\n}\n", id+"_"+out, id, out)
+		}
+	}
+	return b.String()
+}
+
+// moduleFiles copies the embedded modules that the root configuration uses.
+func moduleFiles(mods []tfModule) []File {
+	used := make(map[string]bool)
+	var sources []string
+	for _, m := range mods {
+		if !used[m.source] {
+			used[m.source] = true
+			sources = append(sources, m.source)
+		}
+	}
+	sort.Strings(sources)
+
+	var files []File
+	for _, src := range sources {
+		for _, f := range []string{"main.tf", "variables.tf", "outputs.tf"} {
+			data, err := terraformModules.ReadFile("terraform/" + src + "/" + f)
+			if err != nil {
+				panic(err) // embedded at build time
+			}
+			files = append(files, File{Name: "modules/" + src + "/" + f, Data: data})
+		}
+	}
+	return files
+}
+
+const terraformVersions = `terraform {
+  required_version = ">= 1.5"
+
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 5.0"
+    }
+  }
+}
+`
+
+const terraformTfvarsExample = `vpc_id             = "vpc-0123456789abcdef0"
+private_subnet_ids = ["subnet-aaaa", "subnet-bbbb", "subnet-cccc"]
+public_subnet_ids  = ["subnet-dddd", "subnet-eeee", "subnet-ffff"]
+`
+
+const terraformUsage = "## Usage\n\n" +
+	"```sh\n" +
+	"cp terraform.tfvars.example terraform.tfvars   # point at your VPC and subnets\n" +
+	"terraform init\n" +
+	"terraform plan\n" +
+	"```\n\n" +
+	"Instance sizes are derived from the canvas (replicas, per-instance RPS, memory)\n" +
+	"and exposed as variables in `variables.tf`; override them in `terraform.tfvars`.\n"
@@ -0,0 +1,80 @@
+locals {
+  # ALB and target group names are limited to 32 characters.
+  identifier = trim(substr(var.name, 0, 32), "-")
+}
+
+resource "aws_security_group" "this" {
+  name_prefix = "${local.identifier}-"
+  vpc_id      = var.vpc_id
+
+  ingress {
+    from_port   = 80
+    to_port     = 80
+    protocol    = "tcp"
+    cidr_blocks = ["0.0.0.0/0"]
+  }
+
+  egress {
+    from_port   = 0
+    to_port     = 0
+    protocol    = "-1"
+    cidr_blocks = ["0.0.0.0/0"]
+  }
+}
+
+resource "aws_lb" "this" {
+  name               = local.identifier
+  load_balancer_type = "application"
+  subnets            = var.subnet_ids
+  security_groups    = [aws_security_group.this.id]
+}
+
+resource "aws_lb_target_group" "this" {
+  name                          = local.identifier
+  port                          = 80
+  protocol                      = "HTTP"
+  target_type                   = "ip"
+  vpc_id                        = var.vpc_id
+  load_balancing_algorithm_type = var.algorithm
+
+  health_check {
+    path = var.health_check_path
+  }
+
+  dynamic "stickiness" {
+    for_each = var.sticky_sessions ? [1] : []
+    content {
+      type = "lb_cookie"
+    }
+  }
+}
+
+resource "aws_lb_listener" "http" {
+  load_balancer_arn = aws_lb.this.arn
+  port              = 80
+  protocol          = "HTTP"
+
+  default_action {
+    type             = "forward"
+    target_group_arn = aws_lb_target_group.this.arn
+  }
+}
+
+# Fires when traffic exceeds what the designed backends can serve.
+resource "aws_cloudwatch_metric_alarm" "capacity" {
+  count = var.expected_rps > 0 ? 1 : 0
+
+  alarm_name          = "${local.identifier}-over-capacity"
+  alarm_description   = "Request rate is above the ${var.expected_rps} RPS the architecture was designed for."
+  namespace           = "AWS/ApplicationELB"
+  metric_name         = "RequestCount"
+  statistic           = "Sum"
+  period              = 60
+  evaluation_periods  = 3
+  threshold           = var.expected_rps * 60
+  comparison_operator = "GreaterThanThreshold"
+
+  dimensions = {
+    LoadBalancer = aws_lb.this.arn_suffix
+  }
+}
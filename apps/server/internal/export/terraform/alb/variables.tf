@@ -0,0 +1,28 @@
+variable "name" {
+  type = string
+}
+
+variable "vpc_id" {
+  type = string
+}
+
+variable "subnet_ids" {
+  type = list(string)
+}
+
+variable "algorithm" {
+  type = string
+}
+
+variable "sticky_sessions" {
+  type = bool
+}
+
+variable "health_check_path" {
+  type    = string
+  default = "/"
+}
+
+variable "expected_rps" {
+  type = number
+}
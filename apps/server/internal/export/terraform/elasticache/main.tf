@@ -0,0 +1,39 @@
+locals {
+  identifier      = trim(substr(var.name, 0, 40), "-")
+  cluster_enabled = var.shards > 1
+}
+
+resource "aws_elasticache_subnet_group" "this" {
+  name       = local.identifier
+  subnet_ids = var.subnet_ids
+}
+
+resource "aws_security_group" "this" {
+  name_prefix = "${local.identifier}-"
+  vpc_id      = var.vpc_id
+
+  ingress {
+    from_port   = 6379
+    to_port     = 6379
+    protocol    = "tcp"
+    cidr_blocks = var.allowed_cidr_blocks
+  }
+}
+
+resource "aws_elasticache_replication_group" "this" {
+  replication_group_id       = local.identifier
+  description                = "Redis for ${var.name}"
+  engine                     = "redis"
+  engine_version             = var.engine_version
+  node_type                  = var.node_type
+  cluster_mode               = local.cluster_enabled ? "enabled" : "disabled"
+  parameter_group_name       = local.cluster_enabled ? "default.redis7.cluster.on" : "default.redis7"
+  num_node_groups            = var.shards
+  replicas_per_node_group    = var.replicas_per_shard
+  automatic_failover_enabled = local.cluster_enabled || var.replicas_per_shard > 0
+  multi_az_enabled           = var.replicas_per_shard > 0
+  subnet_group_name          = aws_elasticache_subnet_group.this.name
+  security_group_ids         = [aws_security_group.this.id]
+  at_rest_encryption_enabled = true
+  transit_encryption_enabled = true
+}
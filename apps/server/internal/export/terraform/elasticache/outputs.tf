@@ -0,0 +1,3 @@
+output "endpoint" {
+  value = local.cluster_enabled ? aws_elasticache_replication_group.this.configuration_endpoint_address : aws_elasticache_replication_group.this.primary_endpoint_address
+}
@@ -0,0 +1,32 @@
+variable "name" {
+  type = string
+}
+
+variable "vpc_id" {
+  type = string
+}
+
+variable "subnet_ids" {
+  type = list(string)
+}
+
+variable "allowed_cidr_blocks" {
+  type = list(string)
+}
+
+variable "engine_version" {
+  type    = string
+  default = "7.1"
+}
+
+variable "node_type" {
+  type = string
+}
+
+variable "shards" {
+  type = number
+}
+
+variable "replicas_per_shard" {
+  type = number
+}
@@ -0,0 +1,60 @@
+locals {
+  # MSK requires the broker count to be a multiple of the client subnets (AZs).
+  azs                = length(var.subnet_ids)
+  broker_count       = ceil(var.broker_count / local.azs) * local.azs
+  replication_factor = min(var.replication_factor, local.broker_count)
+}
+
+resource "aws_security_group" "this" {
+  name_prefix = "${var.name}-"
+  vpc_id      = var.vpc_id
+
+  ingress {
+    from_port   = 9092
+    to_port     = 9098
+    protocol    = "tcp"
+    cidr_blocks = var.allowed_cidr_blocks
+  }
+}
+
+resource "aws_msk_configuration" "this" {
+  name           = var.name
+  kafka_versions = [var.kafka_version]
+
+  server_properties = <<-EOT
+    auto.create.topics.enable=true
+    default.replication.factor=${local.replication_factor}
+    min.insync.replicas=${max(1, local.replication_factor - 1)}
+    num.partitions=${var.partitions}
+    log.retention.hours=${var.retention_hours}
+  EOT
+}
+
+resource "aws_msk_cluster" "this" {
+  cluster_name           = var.name
+  kafka_version          = var.kafka_version
+  number_of_broker_nodes = local.broker_count
+
+  broker_node_group_info {
+    instance_type   = var.broker_instance_type
+    client_subnets  = var.subnet_ids
+    security_groups = [aws_security_group.this.id]
+
+    storage_info {
+      ebs_storage_info {
+        volume_size = var.volume_size_gb
+      }
+    }
+  }
+
+  configuration_info {
+    arn      = aws_msk_configuration.this.arn
+    revision = aws_msk_configuration.this.latest_revision
+  }
+
+  encryption_info {
+    encryption_in_transit {
+      client_broker = "TLS"
+    }
+  }
+}
@@ -0,0 +1,3 @@
+output "bootstrap_brokers_tls" {
+  value = aws_msk_cluster.this.bootstrap_brokers_tls
+}
@@ -0,0 +1,44 @@
+variable "name" {
+  type = string
+}
+
+variable "vpc_id" {
+  type = string
+}
+
+variable "subnet_ids" {
+  type = list(string)
+}
+
+variable "allowed_cidr_blocks" {
+  type = list(string)
+}
+
+variable "kafka_version" {
+  type    = string
+  default = "3.6.0"
+}
+
+variable "broker_count" {
+  type = number
+}
+
+variable "broker_instance_type" {
+  type = string
+}
+
+variable "volume_size_gb" {
+  type = number
+}
+
+variable "partitions" {
+  type = number
+}
+
+variable "replication_factor" {
+  type = number
+}
+
+variable "retention_hours" {
+  type = number
+}
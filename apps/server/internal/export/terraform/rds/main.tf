@@ -0,0 +1,48 @@
+locals {
+  identifier = trim(substr(var.name, 0, 50), "-")
+}
+
+resource "aws_db_subnet_group" "this" {
+  name       = local.identifier
+  subnet_ids = var.subnet_ids
+}
+
+resource "aws_security_group" "this" {
+  name_prefix = "${local.identifier}-"
+  vpc_id      = var.vpc_id
+
+  ingress {
+    from_port   = 5432
+    to_port     = 5432
+    protocol    = "tcp"
+    cidr_blocks = var.allowed_cidr_blocks
+  }
+}
+
+resource "aws_db_instance" "primary" {
+  identifier                  = local.identifier
+  engine                      = "postgres"
+  engine_version              = var.engine_version
+  instance_class              = var.instance_class
+  allocated_storage           = var.allocated_storage
+  storage_type                = "gp3"
+  multi_az                    = var.multi_az
+  db_name                     = "app"
+  username                    = "app"
+  manage_master_user_password = true
+  db_subnet_group_name        = aws_db_subnet_group.this.name
+  vpc_security_group_ids      = [aws_security_group.this.id]
+  backup_retention_period     = 7
+  skip_final_snapshot         = true
+}
+
+resource "aws_db_instance" "replica" {
+  count = var.read_replica_count
+
+  identifier             = "${local.identifier}-replica-${count.index + 1}"
+  replicate_source_db    = aws_db_instance.primary.identifier
+  instance_class         = var.instance_class
+  storage_type           = "gp3"
+  vpc_security_group_ids = [aws_security_group.this.id]
+  skip_final_snapshot    = true
+}
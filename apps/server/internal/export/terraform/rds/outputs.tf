@@ -0,0 +1,11 @@
+output "endpoint" {
+  value = aws_db_instance.primary.endpoint
+}
+
+output "replica_endpoints" {
+  value = aws_db_instance.replica[*].endpoint
+}
+
+output "master_user_secret_arn" {
+  value = aws_db_instance.primary.master_user_secret[0].secret_arn
+}
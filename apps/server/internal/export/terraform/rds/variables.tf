@@ -0,0 +1,36 @@
+variable "name" {
+  type = string
+}
+
+variable "vpc_id" {
+  type = string
+}
+
+variable "subnet_ids" {
+  type = list(string)
+}
+
+variable "allowed_cidr_blocks" {
+  type = list(string)
+}
+
+variable "engine_version" {
+  type    = string
+  default = "16"
+}
+
+variable "instance_class" {
+  type = string
+}
+
+variable "allocated_storage" {
+  type = number
+}
+
+variable "multi_az" {
+  type = bool
+}
+
+variable "read_replica_count" {
+  type = number
+}
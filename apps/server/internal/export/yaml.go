@@ -0,0 +1,150 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ymap is an ordered YAML mapping. Manifests are easier to review when keys
+// keep the conventional order (apiVersion, kind, metadata, spec).
+type ymap []kv
+
+type kv struct {
+	Key   string
+	Value any
+}
+
+// ylist is a YAML sequence.
+type ylist []any
+
+var plainScalar = regexp.MustCompile(`^[A-Za-z0-9_./@-][A-Za-z0-9_./:@ -]*$`)
+
+// sexagesimal matches values like "8080:80" that YAML 1.1 reads as base-60 numbers.
+var sexagesimal = regexp.MustCompile(`^[0-9][0-9_:.]*$`)
+
+// ambiguousScalars are plain strings that YAML 1.1 parsers would read as
+// booleans or null.
+var ambiguousScalars = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true,
+	"y": true, "n": true, "null": true, "~": true,
+}
+
+func marshalYAML(v any) []byte {
+	var buf bytes.Buffer
+	writeYAML(&buf, v, 0)
+	return buf.Bytes()
+}
+
+// marshalYAMLDocs joins several documents with "---" separators.
+func marshalYAMLDocs(docs ...ymap) []byte {
+	var buf bytes.Buffer
+	for i, d := range docs {
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		writeYAML(&buf, d, 0)
+	}
+	return buf.Bytes()
+}
+
+func writeYAML(buf *bytes.Buffer, v any, indent int) {
+	pad := strings.Repeat("  ", indent)
+	switch x := v.(type) {
+	case ymap:
+		if len(x) == 0 {
+			buf.WriteString(pad + "{}\n")
+			return
+		}
+		for _, e := range x {
+			writeEntry(buf, pad+e.Key+":", e.Value, indent)
+		}
+	case ylist:
+		if len(x) == 0 {
+			buf.WriteString(pad + "[]\n")
+			return
+		}
+		for _, item := range x {
+			if m, ok := item.(ymap); ok && len(m) > 0 {
+				// First key shares the line with the dash.
+				writeEntry(buf, pad+"- "+m[0].Key+":", m[0].Value, indent+1)
+				for _, e := range m[1:] {
+					writeEntry(buf, pad+"  "+e.Key+":", e.Value, indent+1)
+				}
+				continue
+			}
+			writeEntry(buf, pad+"-", item, indent+1)
+		}
+	default:
+		buf.WriteString(pad + scalar(v) + "\n")
+	}
+}
+
+func writeEntry(buf *bytes.Buffer, prefix string, v any, indent int) {
+	switch x := v.(type) {
+	case ymap:
+		if len(x) == 0 {
+			buf.WriteString(prefix + " {}\n")
+			return
+		}
+		buf.WriteString(prefix + "\n")
+		writeYAML(buf, x, indent+1)
+	case ylist:
+		if len(x) == 0 {
+			buf.WriteString(prefix + " []\n")
+			return
+		}
+		buf.WriteString(prefix + "\n")
+		writeYAML(buf, x, indent+1)
+	case string:
+		if !strings.Contains(x, "\n") {
+			buf.WriteString(prefix + " " + scalar(x) + "\n")
+			return
+		}
+		// Multi-line strings (config files) read best as literal blocks.
+		body := strings.Repeat("  ", indent+1)
+		buf.WriteString(prefix + " |\n")
+		for _, line := range strings.Split(strings.TrimRight(x, "\n"), "\n") {
+			if line == "" {
+				buf.WriteString("\n")
+				continue
+			}
+			buf.WriteString(body + line + "\n")
+		}
+	default:
+		buf.WriteString(prefix + " " + scalar(v) + "\n")
+	}
+}
+
+func scalar(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(x)
+	case int:
+		return strconv.Itoa(x)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case string:
+		return quoteIfNeeded(x)
+	default:
+		return quoteIfNeeded(fmt.Sprint(x))
+	}
+}
+
+func quoteIfNeeded(s string) string {
+	if s == "" || !plainScalar.MatchString(s) || ambiguousScalars[strings.ToLower(s)] ||
+		strings.HasSuffix(s, " ") || strings.Contains(s, ": ") || strings.HasSuffix(s, ":") {
+		return strconv.Quote(s)
+	}
+	if sexagesimal.MatchString(s) {
+		return strconv.Quote(s)
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/system-design-sandbox/server/internal/auth"
+	"github.com/system-design-sandbox/server/internal/config"
+	"github.com/system-design-sandbox/server/internal/geoip"
+	"github.com/system-design-sandbox/server/internal/jobs"
+	"github.com/system-design-sandbox/server/internal/ratelimit"
+	"github.com/system-design-sandbox/server/internal/storage"
+)
+
+// AccountHandler serves email changes, data exports and account deletion.
+type AccountHandler struct {
+	Store     *storage.Storage
+	RedisAuth *auth.RedisAuth
+	Config    *config.Config
+	GeoIP     *geoip.Client
+	// Limiter holds the per-email rate limits moved along with an email
+	// change; nil when rate limiting is off.
+	Limiter *ratelimit.Limiter
+}
+
+// Export handles POST /api/v1/users/me/export. The zip is built in the
+// background and its download link emailed to the user.
+func (h *AccountHandler) Export(w http.ResponseWriter, r *http.Request) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+	uid, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	export, err := jobs.QueueUserExport(r.Context(), h.Store, uid)
+	if errors.Is(err, storage.ErrExportPending) {
+		writeError(w, http.StatusConflict, "export_pending", "an export is already being prepared")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "failed to queue export")
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, export)
+}
+
+// DownloadExport handles GET /api/v1/exports/{token}, the link emailed
+// when an export is ready. The token is the only credential, so that the
+// link works from a mail client without the session cookie.
+func (h *AccountHandler) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	if !validToken(token) {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid export link")
+		return
+	}
+
+	export, err := h.Store.GetUserExportByToken(r.Context(), auth.HashToken(token))
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "not_found", "export not found or expired")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "failed to get export")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="sdsandbox-export-%s.zip"`, export.CreatedAt.Time.UTC().Format("2006-01-02")))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	_, _ = w.Write(export.Data)
+}
+
+type deleteAccountRequest struct {
+	// Email must repeat the account's address to confirm the deletion.
+	Email string `json:"email"`
+}
+
+// Delete handles DELETE /api/v1/users/me. It signs out every session and
+// schedules the account to be purged after Config.AccountDeletionGrace;
+// signing in again before then keeps it.
+func (h *AccountHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if h.RedisAuth == nil {
+		writeError(w, http.StatusServiceUnavailable, "auth_unavailable", "authentication is unavailable")
+		return
+	}
+
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+	uid, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	var req deleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid request body")
+		return
+	}
+
+	user, err := h.Store.GetUser(r.Context(), uid)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "not_found", "user not found")
+		return
+	}
+	if !strings.EqualFold(strings.TrimSpace(req.Email), user.Email) {
+		writeError(w, http.StatusBadRequest, "confirmation_required", "email must match the account's email address")
+		return
+	}
+
+	user, err = h.Store.ScheduleUserDeletion(r.Context(), uid)
+	if errors.Is(err, storage.ErrLastOwner) {
+		writeError(w, http.StatusConflict, "last_owner", "hand over ownership of your shared workspaces first")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "failed to delete account")
+		return
+	}
+
+	revoked, err := h.RedisAuth.DeleteOtherSessions(r.Context(), "", authUser.UserID)
+	logSessionEvent(r, h.Store, h.Config, h.GeoIP, authUser.UserID, "revoke", revoked...)
+	if err != nil {
+		slog.Error("account: revoke sessions failed", "user_id", authUser.UserID, "error", err)
+	}
+	clearSessionCookie(w, h.Config)
+
+	deleteAt := user.DeletedAt.Time.Add(h.Config.AccountDeletionGrace)
+	if msg, err := auth.AccountDeletionEmail(user.Email, user.Locale, deleteAt, h.Config.PublicURL); err != nil {
+		slog.Error("account: render deletion email failed", "error", err)
+	} else if err := jobs.QueueEmail(r.Context(), h.Store, msg); err != nil {
+		slog.Error("account: queue deletion email failed", "user_id", authUser.UserID, "error", err)
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]time.Time{"delete_at": deleteAt})
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/system-design-sandbox/server/internal/auth"
+)
+
+func TestAccountRequiresAuth(t *testing.T) {
+	h := &AccountHandler{RedisAuth: &auth.RedisAuth{}}
+
+	for name, call := range map[string]func(http.ResponseWriter, *http.Request){
+		"export": h.Export,
+		"delete": h.Delete,
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			call(w, httptest.NewRequest(http.MethodPost, "/api/v1/users/me", nil))
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+			}
+		})
+	}
+}
+
+func TestDeleteAccountValidation(t *testing.T) {
+	h := &AccountHandler{RedisAuth: &auth.RedisAuth{}}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodDelete, "/api/v1/users/me", strings.NewReader("{"))
+	h.Delete(w, withAuthUser(r, testUserID))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestDownloadExportInvalidToken(t *testing.T) {
+	h := &AccountHandler{}
+	for _, token := range []string{"short", strings.Repeat("z", 64)} {
+		w := httptest.NewRecorder()
+		h.DownloadExport(w, withURLParam(httptest.NewRequest(http.MethodGet, "/api/v1/exports/"+token, nil), "token", token))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("token %q: status = %d, want %d", token, w.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/system-design-sandbox/server/internal/auth"
+	"github.com/system-design-sandbox/server/internal/model"
+	"github.com/system-design-sandbox/server/internal/storage"
+)
+
+const (
+	defaultJobsLimit = 50
+	maxJobsLimit     = 200
+)
+
+type AdminHandler struct {
+	Store *storage.Storage
+}
+
+// Jobs handles GET /api/v1/admin/jobs?status=&kind=&limit= — the newest
+// background jobs, e.g. status=dead for the dead-letter queue.
+func (h *AdminHandler) Jobs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	status := q.Get("status")
+	switch status {
+	case "", model.JobPending, model.JobRunning, model.JobDone, model.JobDead:
+	default:
+		writeError(w, http.StatusBadRequest, "bad_request", "status must be pending, running, done or dead")
+		return
+	}
+	limit, ok := adminListLimit(w, r)
+	if !ok {
+		return
+	}
+
+	jobs, err := h.Store.ListJobs(r.Context(), status, q.Get("kind"), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "failed to list jobs")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+// Emails handles GET /api/v1/admin/emails?status=&to=&limit= — the newest
+// outbox emails with their delivery status and Message-ID.
+func (h *AdminHandler) Emails(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	status := q.Get("status")
+	switch status {
+	case "", model.EmailPending, model.EmailSent, model.EmailFailed, model.EmailBounced, model.EmailExpired:
+	default:
+		writeError(w, http.StatusBadRequest, "bad_request", "status must be pending, sent, failed, bounced or expired")
+		return
+	}
+	limit, ok := adminListLimit(w, r)
+	if !ok {
+		return
+	}
+
+	emails, err := h.Store.ListEmails(r.Context(), status, q.Get("to"), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "failed to list emails")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, emails)
+}
+
+// ArchitectureUpgrades handles GET /api/v1/admin/architectures/upgrade-failures?limit=
+// — architectures whose data could not be upgraded to the current format
+// version, with the error of the last attempt.
+func (h *AdminHandler) ArchitectureUpgrades(w http.ResponseWriter, r *http.Request) {
+	limit, ok := adminListLimit(w, r)
+	if !ok {
+		return
+	}
+
+	failed, err := h.Store.ListFailedArchitectureUpgrades(r.Context(), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "failed to list architecture upgrade failures")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, failed)
+}
+
+type emailPreview struct {
+	Template string `json:"template"`
+	Locale   string `json:"locale"`
+	Subject  string `json:"subject"`
+	Text     string `json:"text"`
+	HTML     string `json:"html"`
+}
+
+// PreviewEmail handles GET /api/v1/admin/emails/preview/{template}?locale=&format=
+// — renders a template with sample data. format=html or format=text serves
+// that part alone for viewing in the browser; the default is JSON with
+// every part.
+func (h *AdminHandler) PreviewEmail(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	locale := q.Get("locale")
+	if locale == "" {
+		locale = auth.DefaultLocale
+	}
+	if !auth.ValidLocale(locale) {
+		writeError(w, http.StatusBadRequest, "bad_request", "locale must be en or ru")
+		return
+	}
+	format := q.Get("format")
+	switch format {
+	case "", "json", "html", "text":
+	default:
+		writeError(w, http.StatusBadRequest, "bad_request", "format must be json, html or text")
+		return
+	}
+
+	name := chi.URLParam(r, "template")
+	if !slices.Contains(auth.Templates.Names(), name) {
+		writeErrorDetails(w, http.StatusNotFound, "not_found", "email template not found", auth.Templates.Names())
+		return
+	}
+	msg, err := auth.Templates.Preview(name, locale)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "failed to render email template")
+		return
+	}
+
+	switch format {
+	case "html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		// Emails carry only inline styles; nothing in a preview may load or run.
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+		_, _ = w.Write([]byte(msg.HTML))
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("Subject: " + msg.Subject + "\n\n" + msg.Text))
+	default:
+		writeJSON(w, http.StatusOK, emailPreview{
+			Template: name,
+			Locale:   locale,
+			Subject:  msg.Subject,
+			Text:     msg.Text,
+			HTML:     msg.HTML,
+		})
+	}
+}
+
+// adminListLimit reads the limit query parameter of admin listings.
+func adminListLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return defaultJobsLimit, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > maxJobsLimit {
+		writeError(w, http.StatusBadRequest, "bad_request", "limit must be between 1 and 200")
+		return 0, false
+	}
+	return n, true
+}
+
+// RetryJob handles POST /api/v1/admin/jobs/{id}/retry — requeues a dead
+// job with fresh attempts.
+func (h *AdminHandler) RetryJob(w http.ResponseWriter, r *http.Request) {
+	id, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid id")
+		return
+	}
+
+	job, err := h.Store.RetryJob(r.Context(), id)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "dead job not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to retry job")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, job)
+}
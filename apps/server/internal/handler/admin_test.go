@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminHandlerValidation(t *testing.T) {
+	h := &AdminHandler{}
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		run     func(http.ResponseWriter, *http.Request)
+	}{
+		{
+			name:    "jobs bad status",
+			request: httptest.NewRequest(http.MethodGet, "/admin/jobs?status=lost", nil),
+			run:     h.Jobs,
+		},
+		{
+			name:    "jobs limit too large",
+			request: httptest.NewRequest(http.MethodGet, "/admin/jobs?limit=1000", nil),
+			run:     h.Jobs,
+		},
+		{
+			name:    "emails bad status",
+			request: httptest.NewRequest(http.MethodGet, "/admin/emails?status=lost", nil),
+			run:     h.Emails,
+		},
+		{
+			name:    "emails bad limit",
+			request: httptest.NewRequest(http.MethodGet, "/admin/emails?limit=0", nil),
+			run:     h.Emails,
+		},
+		{
+			name:    "architecture upgrades bad limit",
+			request: httptest.NewRequest(http.MethodGet, "/admin/architectures/upgrade-failures?limit=x", nil),
+			run:     h.ArchitectureUpgrades,
+		},
+		{
+			name:    "preview bad locale",
+			request: withURLParam(httptest.NewRequest(http.MethodGet, "/admin/emails/preview/login?locale=de", nil), "template", "login"),
+			run:     h.PreviewEmail,
+		},
+		{
+			name:    "preview bad format",
+			request: withURLParam(httptest.NewRequest(http.MethodGet, "/admin/emails/preview/login?format=pdf", nil), "template", "login"),
+			run:     h.PreviewEmail,
+		},
+		{
+			name:    "retry bad id",
+			request: withURLParam(httptest.NewRequest(http.MethodPost, "/admin/jobs/x/retry", nil), "id", "x"),
+			run:     h.RetryJob,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tc.run(w, withAuthUser(tc.request, testUserID))
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestAdminPreviewEmail(t *testing.T) {
+	h := &AdminHandler{}
+
+	w := httptest.NewRecorder()
+	h.PreviewEmail(w, withURLParam(httptest.NewRequest(http.MethodGet, "/admin/emails/preview/login?locale=ru", nil), "template", "login"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	var preview emailPreview
+	if err := json.NewDecoder(w.Body).Decode(&preview); err != nil {
+		t.Fatal(err)
+	}
+	if preview.Locale != "ru" || !strings.Contains(preview.Subject, "код для входа") || preview.Text == "" || preview.HTML == "" {
+		t.Fatalf("unexpected preview: %+v", preview)
+	}
+
+	w = httptest.NewRecorder()
+	h.PreviewEmail(w, withURLParam(httptest.NewRequest(http.MethodGet, "/admin/emails/preview/new_device?format=html", nil), "template", "new_device"))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("status = %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if w.Header().Get("Content-Security-Policy") == "" {
+		t.Error("HTML preview should be sandboxed by CSP")
+	}
+
+	w = httptest.NewRecorder()
+	h.PreviewEmail(w, withURLParam(httptest.NewRequest(http.MethodGet, "/admin/emails/preview/nope", nil), "template", "nope"))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/system-design-sandbox/server/internal/analysis"
+	"github.com/system-design-sandbox/server/internal/storage"
+)
+
+// Analysis handles GET /api/v1/architectures/{id}/analysis — the static
+// analyzer report, ranked by severity. When the architecture belongs to a
+// scenario that requires a clean report, the outcome is included.
+func (h *ArchitectureHandler) Analysis(w http.ResponseWriter, r *http.Request) {
+	arch, schema, ok := h.loadSchema(w, r)
+	if !ok {
+		return
+	}
+
+	report := analysis.Analyze(schema, nil)
+	if arch.ScenarioID != nil {
+		if req := scenarioRequirement(r.Context(), h.Store, *arch.ScenarioID); req != nil {
+			req.Check(report)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// scenarioRequirement loads the analysis requirement of a scenario, or nil
+// when the scenario has none or cannot be read.
+func scenarioRequirement(ctx context.Context, store *storage.Storage, scenarioID string) *analysis.Requirement {
+	sc, err := store.GetScenario(ctx, scenarioID)
+	if err != nil {
+		return nil
+	}
+	req, err := analysis.RequirementFromScenario(sc.Config)
+	if err != nil {
+		slog.Warn("analysis: invalid scenario requirement", "scenario_id", scenarioID, "error", err)
+		return nil
+	}
+	return req
+}
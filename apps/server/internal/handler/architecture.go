@@ -2,21 +2,68 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/archdata"
+	"github.com/system-design-sandbox/server/internal/model"
 	"github.com/system-design-sandbox/server/internal/storage"
 )
 
+// requestOverhead is the body allowance on top of the data limit for
+// name, description and tags.
+const requestOverhead = 64 << 10
+
 type ArchitectureHandler struct {
 	Store *storage.Storage
+	// Limits bound saved architecture data; zero means archdata.DefaultLimits.
+	Limits archdata.Limits
+}
+
+func (h *ArchitectureHandler) limits() archdata.Limits {
+	if h.Limits == (archdata.Limits{}) {
+		return archdata.DefaultLimits
+	}
+	return h.Limits
+}
+
+// decodeArchitectureRequest reads a create or update body, answering 413
+// when it exceeds the data limit and 400 when it is not JSON.
+func (h *ArchitectureHandler) decodeArchitectureRequest(w http.ResponseWriter, r *http.Request, v any) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, h.limits().MaxBytes+requestOverhead)
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "payload_too_large", "request body too large")
+			return false
+		}
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid request body")
+		return false
+	}
+	return true
+}
+
+// validateData rejects data that does not match the export format with a
+// 422 listing every violation as a JSON pointer.
+func (h *ArchitectureHandler) validateData(w http.ResponseWriter, data json.RawMessage) bool {
+	if errs := archdata.Validate(data, h.limits()); errs != nil {
+		writeErrorDetails(w, http.StatusUnprocessableEntity, "invalid_architecture", "architecture data does not match the export format", errs)
+		return false
+	}
+	return true
 }
 
 type createArchitectureRequest struct {
 	Name        string          `json:"name"`
 	Description string          `json:"description"`
 	ScenarioID  *string         `json:"scenario_id,omitempty"`
+	FolderID    *string         `json:"folder_id,omitempty"`
+	WorkspaceID *string         `json:"workspace_id,omitempty"`
 	Data        json.RawMessage `json:"data"`
 	IsPublic    bool            `json:"is_public"`
 	Tags        []string        `json:"tags"`
@@ -38,8 +85,7 @@ func (h *ArchitectureHandler) Create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req createArchitectureRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "bad_request", "invalid request body")
+	if !h.decodeArchitectureRequest(w, r, &req) {
 		return
 	}
 
@@ -49,8 +95,39 @@ func (h *ArchitectureHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	arch, err := h.Store.CreateArchitecture(r.Context(), userID, req.Name, req.Description, req.ScenarioID, req.Data, req.IsPublic, req.Tags)
+	if !h.validateData(w, req.Data) {
+		return
+	}
+
+	var folderID, workspaceID *pgtype.UUID
+	if req.WorkspaceID != nil {
+		wid, err := parseUUID(*req.WorkspaceID)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "bad_request", "invalid workspace_id")
+			return
+		}
+		if _, ok := workspaceWithRole(w, r, h.Store, wid, userID, model.RoleEditor); !ok {
+			return
+		}
+		workspaceID = &wid
+	}
+	if req.FolderID != nil {
+		folder, ok := h.accessibleFolder(w, r, userID, *req.FolderID)
+		if !ok {
+			return
+		}
+		if !folder.InWorkspace(workspaceID) {
+			writeError(w, http.StatusConflict, "folder_mismatch", "the folder belongs to another workspace")
+			return
+		}
+		folderID = &folder.ID
+	}
+
+	arch, err := h.Store.CreateArchitecture(r.Context(), userID, req.Name, req.Description, req.ScenarioID, folderID, workspaceID, req.Data, req.IsPublic, req.Tags)
 	if err != nil {
+		if writeQuotaError(w, err) {
+			return
+		}
 		writeError(w, http.StatusInternalServerError, "internal", "failed to create architecture")
 		return
 	}
@@ -90,6 +167,14 @@ func (h *ArchitectureHandler) Get(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, arch)
 }
 
+// maxListLimit caps the page size of architecture listings.
+const maxListLimit = 100
+
+// ListMine handles GET /api/v1/architectures/mine. Query parameters:
+// q (full-text search), scenario_id, folder_id (or "root" for unfiled),
+// tag (repeatable, all must match), sort (updated, created, name,
+// relevance), limit and cursor. Without limit every match is returned;
+// with it the next page is linked via the Link header.
 func (h *ArchitectureHandler) ListMine(w http.ResponseWriter, r *http.Request) {
 	authUser, ok := GetAuthUser(r.Context())
 	if !ok {
@@ -103,12 +188,71 @@ func (h *ArchitectureHandler) ListMine(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	archs, err := h.Store.ListArchitecturesByUser(r.Context(), userID)
+	filter, ok := parseArchitectureFilter(w, r)
+	if !ok || !parseFolderFilter(w, r, &filter) {
+		return
+	}
+	writeArchitectureList(w, r, h.Store, userID, filter)
+}
+
+// parseFolderFilter reads the folder_id query parameter of listings that
+// span folders: a folder ID, or "root" for unfiled architectures.
+func parseFolderFilter(w http.ResponseWriter, r *http.Request, filter *storage.ArchitectureFilter) bool {
+	if v := r.URL.Query().Get("folder_id"); v == "root" {
+		filter.Unfiled = true
+	} else if v != "" {
+		folderID, err := parseUUID(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "bad_request", "invalid folder_id")
+			return false
+		}
+		filter.FolderID = &folderID
+	}
+	return true
+}
+
+// parseArchitectureFilter reads the search, filter, sort and paging query
+// parameters shared by architecture listings.
+func parseArchitectureFilter(w http.ResponseWriter, r *http.Request) (storage.ArchitectureFilter, bool) {
+	q := r.URL.Query()
+	filter := storage.ArchitectureFilter{
+		Query:  strings.TrimSpace(q.Get("q")),
+		Tags:   q["tag"],
+		Sort:   q.Get("sort"),
+		Cursor: q.Get("cursor"),
+	}
+	if v := q.Get("scenario_id"); v != "" {
+		filter.ScenarioID = &v
+	}
+	if !storage.ValidArchitectureSort(filter.Sort, filter.Query != "") {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid sort")
+		return filter, false
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxListLimit {
+			writeError(w, http.StatusBadRequest, "bad_request", "limit must be between 1 and "+strconv.Itoa(maxListLimit))
+			return filter, false
+		}
+		filter.Limit = n
+	}
+	return filter, true
+}
+
+func writeArchitectureList(w http.ResponseWriter, r *http.Request, store *storage.Storage, userID pgtype.UUID, filter storage.ArchitectureFilter) {
+	archs, next, err := store.ListArchitecturesByUser(r.Context(), userID, filter)
 	if err != nil {
+		if errors.Is(err, storage.ErrInvalidCursor) {
+			writeError(w, http.StatusBadRequest, "bad_request", "invalid cursor")
+			return
+		}
 		writeError(w, http.StatusInternalServerError, "internal", "failed to list architectures")
 		return
 	}
 
+	if next != "" {
+		setNextLink(w, r, next)
+	}
 	writeJSON(w, http.StatusOK, archs)
 }
 
@@ -132,13 +276,22 @@ func (h *ArchitectureHandler) Update(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req updateArchitectureRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "bad_request", "invalid request body")
+	if !h.decodeArchitectureRequest(w, r, &req) {
+		return
+	}
+	if !h.validateData(w, req.Data) {
 		return
 	}
 
 	arch, err := h.Store.UpdateArchitectureForUser(r.Context(), id, userID, req.Name, req.Description, req.Data, req.IsPublic, req.Tags)
 	if err != nil {
+		if writeQuotaError(w, err) {
+			return
+		}
+		if errors.Is(err, storage.ErrForbidden) {
+			writeError(w, http.StatusForbidden, "forbidden", "your workspace role does not allow editing this architecture")
+			return
+		}
 		if err == pgx.ErrNoRows {
 			writeError(w, http.StatusNotFound, "not_found", "architecture not found")
 			return
@@ -150,6 +303,8 @@ func (h *ArchitectureHandler) Update(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, arch)
 }
 
+// Delete handles DELETE /api/v1/architectures/{id} — moves the
+// architecture to the trash, from where it can be restored until purged.
 func (h *ArchitectureHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	authUser, ok := GetAuthUser(r.Context())
 	if !ok {
@@ -170,6 +325,10 @@ func (h *ArchitectureHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.Store.DeleteArchitectureForUser(r.Context(), id, userID); err != nil {
+		if errors.Is(err, storage.ErrForbidden) {
+			writeError(w, http.StatusForbidden, "forbidden", "only workspace admins can delete this architecture")
+			return
+		}
 		if err == pgx.ErrNoRows {
 			writeError(w, http.StatusNotFound, "not_found", "architecture not found")
 			return
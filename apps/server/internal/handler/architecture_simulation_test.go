@@ -65,6 +65,11 @@ func TestArchitectureHandlerRequiresAuth(t *testing.T) {
 			request: withURLParam(httptest.NewRequest(http.MethodDelete, "/architectures/id", nil), "id", "0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a"),
 			run:     h.Delete,
 		},
+		{
+			name:    "analysis",
+			request: withURLParam(httptest.NewRequest(http.MethodGet, "/architectures/id/analysis", nil), "id", "0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a"),
+			run:     h.Analysis,
+		},
 	}
 
 	for _, tc := range tests {
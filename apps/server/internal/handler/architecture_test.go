@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/system-design-sandbox/server/internal/archdata"
+)
+
+const testUserID = "0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1b"
+
+func TestArchitectureCreateRejectsInvalidData(t *testing.T) {
+	h := &ArchitectureHandler{}
+	body := `{"name":"n","data":{"version":"1.0","nodes":[],"edges":[{"id":"e","source":"a","target":"b"}]}}`
+	req := withAuthUser(httptest.NewRequest(http.MethodPost, "/architectures", bytes.NewBufferString(body)), testUserID)
+	w := httptest.NewRecorder()
+
+	h.Create(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	var resp struct {
+		Code    string                     `json:"code"`
+		Details []archdata.ValidationError `json:"details"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Code != "invalid_architecture" {
+		t.Errorf("code = %q, want invalid_architecture", resp.Code)
+	}
+	paths := map[string]bool{}
+	for _, d := range resp.Details {
+		paths[d.Path] = true
+	}
+	if !paths["/edges/0/source"] || !paths["/edges/0/target"] {
+		t.Errorf("details = %+v, want dangling source and target", resp.Details)
+	}
+}
+
+func TestArchitectureUpdateRejectsNullData(t *testing.T) {
+	h := &ArchitectureHandler{}
+	req := httptest.NewRequest(http.MethodPut, "/architectures/id", bytes.NewBufferString(`{"name":"n","data":null}`))
+	req = withAuthUser(withURLParam(req, "id", "0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a"), testUserID)
+	w := httptest.NewRecorder()
+
+	h.Update(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestArchitectureCreateRejectsOversizedBody(t *testing.T) {
+	h := &ArchitectureHandler{Limits: archdata.Limits{MaxBytes: 1024, MaxNodes: 10, MaxEdges: 10}}
+	body := `{"name":"n","description":"` + strings.Repeat("x", requestOverhead+2048) + `","data":{}}`
+	req := withAuthUser(httptest.NewRequest(http.MethodPost, "/architectures", bytes.NewBufferString(body)), testUserID)
+	w := httptest.NewRecorder()
+
+	h.Create(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+	if resp := decodeErrorResponse(t, w.Body); resp.Code != "payload_too_large" {
+		t.Errorf("code = %q, want payload_too_large", resp.Code)
+	}
+}
+
+func TestArchitectureDiffRejectsInvalidIDs(t *testing.T) {
+	h := &ArchitectureHandler{}
+	req := withAuthUser(httptest.NewRequest(http.MethodGet, "/architectures/diff?a=nope&b=", nil), testUserID)
+	w := httptest.NewRecorder()
+
+	h.Diff(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestArchitectureListMineRejectsBadParams(t *testing.T) {
+	h := &ArchitectureHandler{}
+	for _, query := range []string{"sort=relevance", "sort=size", "limit=0", "limit=101", "limit=x"} {
+		req := withAuthUser(httptest.NewRequest(http.MethodGet, "/architectures/mine?"+query, nil), testUserID)
+		w := httptest.NewRecorder()
+
+		h.ListMine(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+	}
+}
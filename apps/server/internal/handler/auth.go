@@ -2,17 +2,19 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/system-design-sandbox/server/internal/auth"
 	"github.com/system-design-sandbox/server/internal/config"
 	"github.com/system-design-sandbox/server/internal/geoip"
+	"github.com/system-design-sandbox/server/internal/jobs"
 	"github.com/system-design-sandbox/server/internal/model"
 	"github.com/system-design-sandbox/server/internal/storage"
 )
@@ -20,7 +22,6 @@ import (
 type AuthHandler struct {
 	Store     *storage.Storage
 	RedisAuth *auth.RedisAuth
-	Email     auth.EmailSender
 	Config    *config.Config
 	GeoIP     *geoip.Client
 }
@@ -71,30 +72,17 @@ func (h *AuthHandler) SendCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check rate limit
-	if err := h.RedisAuth.CheckRateLimit(r.Context(), req.Email); err != nil {
-		if rlErr, ok := err.(*auth.RateLimitError); ok {
-			slog.Warn("send-code: rate limited", "email", req.Email, "retry_after", rlErr.RetryAfter)
-			writeJSON(w, http.StatusTooManyRequests, map[string]interface{}{
-				"error":       "Too many requests. Please try again later.",
-				"code":        "rate_limited",
-				"retry_after": rlErr.RetryAfter,
-			})
-		} else {
-			slog.Error("send-code: rate limit check failed", "email", req.Email, "error", err)
-			writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
-		}
-		return
-	}
-
 	// Check if user exists
-	_, err := h.Store.GetUserByEmail(r.Context(), req.Email)
+	user, err := h.Store.GetUserByEmail(r.Context(), req.Email)
 	if err != nil {
-		// User doesn't exist — create with pending status
-		_, err = h.Store.CreateUserWithStatus(r.Context(), req.Email, req.Email, "pending_verification")
+		// User doesn't exist — create with pending status, emailed in the
+		// browser's language
+		locale := auth.MatchLocale(r.Header.Get("Accept-Language"))
+		user, err = h.Store.CreateUserWithStatus(r.Context(), req.Email, req.Email, "pending_verification", locale)
 		if err != nil {
 			// Race condition: concurrent INSERT may fail on unique constraint — re-read
-			_, retryErr := h.Store.GetUserByEmail(r.Context(), req.Email)
+			var retryErr error
+			user, retryErr = h.Store.GetUserByEmail(r.Context(), req.Email)
 			if retryErr != nil {
 				slog.Error("send-code: create user failed", "error", err)
 				writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
@@ -104,7 +92,7 @@ func (h *AuthHandler) SendCode(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	h.sendAuthEmail(r, req.Email)
+	h.sendAuthEmail(r, req.Email, user.Locale)
 	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
 }
 
@@ -166,26 +154,24 @@ func (h *AuthHandler) VerifyCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, data, err := h.RedisAuth.GetAuthTokenByCode(r.Context(), req.Email, req.Code)
-	if err != nil {
+	// Checks the code, counts a wrong guess and consumes the token atomically
+	data, err := h.RedisAuth.VerifyCode(r.Context(), req.Email, req.Code)
+	switch {
+	case errors.Is(err, auth.ErrTooManyAttempts):
 		writeError(w, http.StatusBadRequest, "too_many_attempts", "too many attempts, request a new code")
 		return
-	}
-	if data == nil {
+	case errors.Is(err, auth.ErrCodeMismatch):
+		writeError(w, http.StatusBadRequest, "invalid_code", "invalid code")
+		return
+	case errors.Is(err, auth.ErrCodeNotFound):
 		writeError(w, http.StatusBadRequest, "invalid_code", "invalid or expired code")
 		return
-	}
-
-	// Timing-safe compare the normalized codes
-	if !auth.TimingSafeEqual(auth.NormalizeCode(data.Code), auth.NormalizeCode(req.Code)) {
-		_ = h.RedisAuth.IncrAttempts(r.Context(), token)
-		writeError(w, http.StatusBadRequest, "invalid_code", "invalid code")
+	case err != nil:
+		slog.Error("verify-code: check failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal", "failed to verify code")
 		return
 	}
 
-	// Delete the token now that it's verified
-	_ = h.RedisAuth.DeleteAuthToken(r.Context(), token)
-
 	h.completeVerification(w, r, data.Email)
 }
 
@@ -203,26 +189,15 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	}
 
 	_ = h.RedisAuth.DeleteSession(r.Context(), authUser.SessionID, authUser.UserID)
+	logSessionEvent(r, h.Store, h.Config, h.GeoIP, authUser.UserID, "logout", authUser.SessionID)
 
-	if h.Config.SessionLogEnabled {
-		var uid pgtype.UUID
-		_ = uid.Scan(authUser.UserID)
-		_ = h.Store.CreateSessionLog(r.Context(), model.SessionLogEntry{
-			UserID:    uid,
-			SessionID: authUser.SessionID,
-			Action:    "logout",
-			IP:        clientIP(r),
-			UserAgent: r.UserAgent(),
-		})
-	}
-
-	h.clearSessionCookie(w)
+	clearSessionCookie(w, h.Config)
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // --- Helpers ---
 
-func (h *AuthHandler) sendAuthEmail(r *http.Request, email string) {
+func (h *AuthHandler) sendAuthEmail(r *http.Request, email, locale string) {
 	token, err := auth.GenerateToken()
 	if err != nil {
 		slog.Error("auth: generate token failed", "error", err)
@@ -239,12 +214,18 @@ func (h *AuthHandler) sendAuthEmail(r *http.Request, email string) {
 		return
 	}
 
-	slog.Info("auth: sending login email", "email", email)
-	if err := h.Email.SendLoginEmail(email, token, code, h.Config.PublicURL); err != nil {
-		slog.Error("auth: send email failed", "email", email, "error", err)
-	} else {
-		slog.Info("auth: login email sent", "email", email)
+	// Delivery happens in the background so a slow SMTP server never holds
+	// up the request; the email expires along with the code.
+	msg, err := auth.LoginEmail(email, locale, token, code, h.Config.PublicURL)
+	if err != nil {
+		slog.Error("auth: render login email failed", "error", err)
+		return
+	}
+	if err := jobs.QueueEmail(r.Context(), h.Store, msg); err != nil {
+		slog.Error("auth: queue login email failed", "email", email, "error", err)
+		return
 	}
+	slog.Info("auth: login email queued", "email", email)
 }
 
 func (h *AuthHandler) completeVerification(w http.ResponseWriter, r *http.Request, email string) {
@@ -276,6 +257,16 @@ func (h *AuthHandler) completeVerification(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// Signing in during the deletion grace period keeps the account.
+	if user.DeletedAt != nil {
+		if err := h.Store.CancelUserDeletion(r.Context(), user.ID); err != nil {
+			writeError(w, http.StatusInternalServerError, "internal", "failed to restore account")
+			return
+		}
+		user.DeletedAt = nil
+		slog.Info("auth: account deletion cancelled by sign-in", "user_id", user.ID)
+	}
+
 	// Create session
 	sessionID, err := auth.GenerateSessionID()
 	if err != nil {
@@ -288,24 +279,29 @@ func (h *AuthHandler) completeVerification(w http.ResponseWriter, r *http.Reques
 		user.ID.Bytes[8:10], user.ID.Bytes[10:16])
 
 	ip := clientIP(r)
-	now := time.Now().UTC().Format(time.RFC3339)
+	createdAt := time.Now().UTC()
+	now := createdAt.Format(time.RFC3339)
 	geo := h.GeoIP.Lookup(r.Context(), ip)
 	sessData := auth.SessionData{
 		UserID:       userIDStr,
 		IP:           ip,
 		Geo:          geo.Formatted,
 		CountryCode:  geo.CountryCode,
+		Device:       auth.DeviceName(r.UserAgent()),
+		Network:      ipNetwork(peerIP(r)),
 		CreatedAt:    now,
 		LastActiveAt: now,
 	}
 
-	if err := h.RedisAuth.CreateSession(r.Context(), sessionID, sessData); err != nil {
+	token, err := h.RedisAuth.CreateSession(r.Context(), sessionID, sessData)
+	if err != nil {
 		writeError(w, http.StatusInternalServerError, "internal", "failed to create session")
 		return
 	}
 
-	// Log login
+	// Log login, after checking it against the earlier ones
 	if h.Config.SessionLogEnabled {
+		h.alertNewSignIn(r, user, sessionID, userIDStr, geo)
 		_ = h.Store.CreateSessionLog(r.Context(), model.SessionLogEntry{
 			UserID:      user.ID,
 			SessionID:   sessionID,
@@ -317,7 +313,7 @@ func (h *AuthHandler) completeVerification(w http.ResponseWriter, r *http.Reques
 		})
 	}
 
-	h.setSessionCookie(w, sessionID)
+	setSessionCookie(w, h.Config, token, createdAt)
 
 	// If this is from htmx (verify page), return success HTML with auto-redirect
 	if r.Header.Get("HX-Request") == "true" {
@@ -333,28 +329,61 @@ func (h *AuthHandler) completeVerification(w http.ResponseWriter, r *http.Reques
 	writeJSON(w, http.StatusOK, authResponse{User: user})
 }
 
-func (h *AuthHandler) setSessionCookie(w http.ResponseWriter, sessionID string) {
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
-		Value:    sessionID,
-		Path:     "/",
-		MaxAge:   int(h.Config.Session.Expiry.Seconds()),
-		HttpOnly: true,
-		Secure:   strings.HasPrefix(h.Config.PublicURL, "https"),
-		SameSite: http.SameSiteStrictMode,
-	})
+// alertNewSignIn emails the user when they sign in from a device or
+// country the session log has not seen for them, with a link that signs
+// the new session out. A failure is logged and never fails the sign-in.
+func (h *AuthHandler) alertNewSignIn(r *http.Request, user model.User, sessionID, userID string, geo geoip.Result) {
+	if slices.Contains(user.EmailOptOut, auth.TemplateNewDevice) {
+		return
+	}
+	known, err := h.Store.ListKnownLogins(r.Context(), user.ID)
+	if err != nil {
+		slog.Error("auth: list known logins failed", "user_id", userID, "error", err)
+		return
+	}
+	if !isNewSignIn(known, r.UserAgent(), geo.CountryCode) {
+		return
+	}
+
+	token, err := h.RedisAuth.CreateRevokeToken(r.Context(), sessionID, userID)
+	if err != nil {
+		slog.Error("auth: create revoke token failed", "user_id", userID, "error", err)
+		return
+	}
+	msg, err := auth.NewDeviceAlertEmail(user.Email, user.Locale, auth.NewDeviceAlert{
+		UserAgent:   r.UserAgent(),
+		IP:          clientIP(r),
+		Location:    geo.Formatted,
+		At:          time.Now(),
+		RevokeToken: token,
+	}, formatUUID(user.UnsubscribeToken), h.Config.PublicURL)
+	if err == nil {
+		err = jobs.QueueEmail(r.Context(), h.Store, msg)
+	}
+	if err != nil {
+		slog.Error("auth: queue sign-in alert failed", "user_id", userID, "error", err)
+	}
 }
 
-func (h *AuthHandler) clearSessionCookie(w http.ResponseWriter) {
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1,
-		HttpOnly: true,
-		Secure:   strings.HasPrefix(h.Config.PublicURL, "https"),
-		SameSite: http.SameSiteStrictMode,
-	})
+// isNewSignIn reports whether a sign-in with ua from country differs from
+// every known one in browser and OS or in country. The first sign-in is
+// never new: there is nothing to compare it with. An unknown country only
+// counts as new through the device.
+func isNewSignIn(known []model.SessionLogEntry, ua, country string) bool {
+	if len(known) == 0 {
+		return false
+	}
+	device := auth.DeviceName(ua)
+	newDevice, newCountry := true, country != ""
+	for _, k := range known {
+		if auth.DeviceName(k.UserAgent) == device {
+			newDevice = false
+		}
+		if k.CountryCode == country {
+			newCountry = false
+		}
+	}
+	return newDevice || newCountry
 }
 
 func clientIP(r *http.Request) string {
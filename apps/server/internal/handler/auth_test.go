@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/system-design-sandbox/server/internal/config"
+	"github.com/system-design-sandbox/server/internal/model"
 )
 
 func TestClientIP(t *testing.T) {
@@ -114,6 +115,40 @@ func TestVerifyPageRendersHTML(t *testing.T) {
 	}
 }
 
+func TestIsNewSignIn(t *testing.T) {
+	const (
+		firefoxLinux = "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+		firefoxNewer = "Mozilla/5.0 (X11; Linux x86_64; rv:130.0) Gecko/20100101 Firefox/130.0"
+		chromeMac    = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36"
+	)
+	known := []model.SessionLogEntry{
+		{UserAgent: firefoxLinux, CountryCode: "DE"},
+		{UserAgent: firefoxLinux, CountryCode: ""},
+	}
+
+	tests := []struct {
+		name    string
+		known   []model.SessionLogEntry
+		ua      string
+		country string
+		want    bool
+	}{
+		{"first sign-in", nil, chromeMac, "US", false},
+		{"same device and country", known, firefoxLinux, "DE", false},
+		{"browser update", known, firefoxNewer, "DE", false},
+		{"new device", known, chromeMac, "DE", true},
+		{"new country", known, firefoxLinux, "US", true},
+		{"unknown country", known, firefoxLinux, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isNewSignIn(tt.known, tt.ua, tt.country); got != tt.want {
+				t.Errorf("isNewSignIn() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(s) > 0 && containsStr(s, substr))
 }
@@ -0,0 +1,432 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/archdata"
+	"github.com/system-design-sandbox/server/internal/auth"
+	"github.com/system-design-sandbox/server/internal/config"
+	"github.com/system-design-sandbox/server/internal/jobs"
+	"github.com/system-design-sandbox/server/internal/model"
+	"github.com/system-design-sandbox/server/internal/storage"
+)
+
+const (
+	// maxCommentLength caps a comment body, in characters.
+	maxCommentLength = 10000
+	// maxMentions caps the users notified by one comment.
+	maxMentions = 20
+	// mentionExcerptLength is how much of the comment a mention email quotes.
+	mentionExcerptLength = 280
+)
+
+type CommentHandler struct {
+	Store  *storage.Storage
+	Config *config.Config
+}
+
+type commentRequest struct {
+	Body       string   `json:"body"`
+	AnchorType *string  `json:"anchor_type,omitempty"`
+	AnchorID   *string  `json:"anchor_id,omitempty"`
+	Mentions   []string `json:"mentions,omitempty"`
+}
+
+// decodeCommentRequest reads a comment body and the IDs of the users it
+// mentions.
+func decodeCommentRequest(w http.ResponseWriter, r *http.Request) (commentRequest, []pgtype.UUID, bool) {
+	var req commentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid request body")
+		return req, nil, false
+	}
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Body == "" {
+		writeError(w, http.StatusBadRequest, "bad_request", "body is required")
+		return req, nil, false
+	}
+	if utf8.RuneCountInString(req.Body) > maxCommentLength {
+		writeError(w, http.StatusBadRequest, "bad_request", "body is too long")
+		return req, nil, false
+	}
+	if len(req.Mentions) > maxMentions {
+		writeError(w, http.StatusBadRequest, "bad_request", "too many mentions")
+		return req, nil, false
+	}
+	mentions := make([]pgtype.UUID, 0, len(req.Mentions))
+	for _, raw := range req.Mentions {
+		id, err := parseUUID(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "bad_request", "invalid mention")
+			return req, nil, false
+		}
+		mentions = append(mentions, id)
+	}
+	return req, mentions, true
+}
+
+// Threads handles GET /api/v1/architectures/{id}/threads?status=open|resolved.
+// Without status every thread is listed. Threads anchored to a node or
+// edge that is no longer in the architecture are marked orphaned.
+func (h *CommentHandler) Threads(w http.ResponseWriter, r *http.Request) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	id, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid id")
+		return
+	}
+
+	userID, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+		return
+	}
+
+	var resolved *bool
+	switch r.URL.Query().Get("status") {
+	case "", "all":
+	case "open":
+		resolved = new(bool)
+	case "resolved":
+		resolved = new(bool)
+		*resolved = true
+	default:
+		writeError(w, http.StatusBadRequest, "bad_request", "status must be open, resolved or all")
+		return
+	}
+
+	arch, ok := h.architecture(w, r, id, userID)
+	if !ok {
+		return
+	}
+
+	threads, err := h.Store.ListCommentThreadsForUser(r.Context(), id, userID, resolved)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "failed to list threads")
+		return
+	}
+	if threads == nil {
+		threads = []model.CommentThread{}
+	}
+	markOrphaned(threads, arch.RawData)
+
+	writeJSON(w, http.StatusOK, threads)
+}
+
+// CreateThread handles POST /api/v1/architectures/{id}/threads. Any user
+// who can read the architecture may comment; anchor_type and anchor_id
+// must name a node or edge in its current data.
+func (h *CommentHandler) CreateThread(w http.ResponseWriter, r *http.Request) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	id, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid id")
+		return
+	}
+
+	userID, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+		return
+	}
+
+	req, mentions, ok := decodeCommentRequest(w, r)
+	if !ok {
+		return
+	}
+	if (req.AnchorType == nil) != (req.AnchorID == nil) {
+		writeError(w, http.StatusBadRequest, "bad_request", "anchor_type and anchor_id go together")
+		return
+	}
+	if req.AnchorType != nil && *req.AnchorType != model.AnchorNode && *req.AnchorType != model.AnchorEdge {
+		writeError(w, http.StatusBadRequest, "bad_request", "anchor_type must be node or edge")
+		return
+	}
+
+	arch, ok := h.architecture(w, r, id, userID)
+	if !ok {
+		return
+	}
+	if req.AnchorType != nil && anchorMissing(arch.RawData, *req.AnchorType, *req.AnchorID) {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_anchor", *req.AnchorType+" not found in the architecture")
+		return
+	}
+
+	thread, err := h.Store.CreateCommentThreadForUser(r.Context(), id, userID, req.AnchorType, req.AnchorID, req.Body)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "architecture not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to create thread")
+		return
+	}
+
+	h.notifyMentions(r, arch, thread.ID, userID, req.Body, mentions)
+	writeJSON(w, http.StatusCreated, thread)
+}
+
+// Reply handles POST /api/v1/threads/{id}/comments.
+func (h *CommentHandler) Reply(w http.ResponseWriter, r *http.Request) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	id, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid id")
+		return
+	}
+
+	userID, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+		return
+	}
+
+	req, mentions, ok := decodeCommentRequest(w, r)
+	if !ok {
+		return
+	}
+
+	comment, thread, err := h.Store.AddCommentForUser(r.Context(), id, userID, req.Body)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "thread not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to add comment")
+		return
+	}
+
+	if len(mentions) > 0 {
+		if arch, err := h.Store.GetArchitectureForUser(r.Context(), thread.ArchitectureID, userID); err == nil {
+			h.notifyMentions(r, arch, thread.ID, userID, req.Body, mentions)
+		} else {
+			slog.Error("comments: load architecture for mentions failed", "error", err)
+		}
+	}
+	writeJSON(w, http.StatusCreated, comment)
+}
+
+// Resolve handles POST /api/v1/threads/{id}/resolve.
+func (h *CommentHandler) Resolve(w http.ResponseWriter, r *http.Request) {
+	h.setResolved(w, r, true)
+}
+
+// Reopen handles POST /api/v1/threads/{id}/reopen.
+func (h *CommentHandler) Reopen(w http.ResponseWriter, r *http.Request) {
+	h.setResolved(w, r, false)
+}
+
+func (h *CommentHandler) setResolved(w http.ResponseWriter, r *http.Request, resolved bool) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	id, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid id")
+		return
+	}
+
+	userID, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+		return
+	}
+
+	thread, err := h.Store.SetCommentThreadResolvedForUser(r.Context(), id, userID, resolved)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "thread not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to update thread")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, thread)
+}
+
+// UpdateComment handles PUT /api/v1/comments/{id}; authors only.
+func (h *CommentHandler) UpdateComment(w http.ResponseWriter, r *http.Request) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	id, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid id")
+		return
+	}
+
+	userID, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+		return
+	}
+
+	req, _, ok := decodeCommentRequest(w, r)
+	if !ok {
+		return
+	}
+
+	comment, err := h.Store.UpdateCommentForUser(r.Context(), id, userID, req.Body)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "comment not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to update comment")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, comment)
+}
+
+// DeleteComment handles DELETE /api/v1/comments/{id}; authors only.
+// Deleting the last comment of a thread deletes the thread.
+func (h *CommentHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	id, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid id")
+		return
+	}
+
+	userID, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+		return
+	}
+
+	if err := h.Store.DeleteCommentForUser(r.Context(), id, userID); err != nil {
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "comment not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to delete comment")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *CommentHandler) architecture(w http.ResponseWriter, r *http.Request, id, userID pgtype.UUID) (model.Architecture, bool) {
+	arch, err := h.Store.GetArchitectureForUser(r.Context(), id, userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "architecture not found")
+			return model.Architecture{}, false
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to get architecture")
+		return model.Architecture{}, false
+	}
+	return arch, true
+}
+
+// notifyMentions queues emails to the mentioned users who can read the
+// architecture, except the author and those who unsubscribed from
+// mentions. Failures are logged; the comment is already saved.
+func (h *CommentHandler) notifyMentions(r *http.Request, arch model.Architecture, threadID, authorID pgtype.UUID, body string, mentions []pgtype.UUID) {
+	if len(mentions) == 0 {
+		return
+	}
+	users, err := h.Store.MentionableUsers(r.Context(), arch.ID, mentions)
+	if err != nil {
+		slog.Error("comments: resolve mentions failed", "error", err)
+		return
+	}
+
+	author := "Someone"
+	if u, err := h.Store.GetUser(r.Context(), authorID); err == nil {
+		author = u.Name
+		if u.DisplayName != nil && *u.DisplayName != "" {
+			author = *u.DisplayName
+		}
+	}
+
+	mention := auth.CommentMention{
+		Architecture:   arch.Name,
+		ArchitectureID: formatUUID(arch.ID),
+		ThreadID:       formatUUID(threadID),
+		Author:         author,
+		Excerpt:        excerpt(body, mentionExcerptLength),
+	}
+	for _, u := range users {
+		if u.ID == authorID || slices.Contains(u.EmailOptOut, auth.TemplateCommentMention) {
+			continue
+		}
+		msg, err := auth.CommentMentionEmail(u.Email, u.Locale, mention, formatUUID(u.UnsubscribeToken), h.Config.PublicURL)
+		if err == nil {
+			err = jobs.QueueEmail(r.Context(), h.Store, msg)
+		}
+		if err != nil {
+			slog.Error("comments: queue mention email failed", "email", u.Email, "error", err)
+		}
+	}
+}
+
+// markOrphaned flags threads whose anchor is gone from data.
+func markOrphaned(threads []model.CommentThread, data json.RawMessage) {
+	s, err := archdata.Parse(data)
+	for i, t := range threads {
+		if t.AnchorType != nil && t.AnchorID != nil {
+			threads[i].Orphaned = err != nil || !hasAnchor(s, *t.AnchorType, *t.AnchorID)
+		}
+	}
+}
+
+// anchorMissing reports whether data lacks the node or edge an anchor
+// points at. Unparseable data counts as missing.
+func anchorMissing(data json.RawMessage, anchorType, anchorID string) bool {
+	s, err := archdata.Parse(data)
+	return err != nil || !hasAnchor(s, anchorType, anchorID)
+}
+
+func hasAnchor(s *archdata.Schema, anchorType, anchorID string) bool {
+	if anchorType == model.AnchorEdge {
+		return s.HasEdge(anchorID)
+	}
+	return s.HasNode(anchorID)
+}
+
+// excerpt shortens s to at most n characters, marking the cut with an
+// ellipsis.
+func excerpt(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:n-1])) + "…"
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/system-design-sandbox/server/internal/model"
+)
+
+func TestCommentHandlerValidation(t *testing.T) {
+	h := &CommentHandler{}
+	const archID = "0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a"
+	post := func(target, body string) *http.Request {
+		return withURLParam(httptest.NewRequest(http.MethodPost, target, bytes.NewBufferString(body)), "id", archID)
+	}
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		run     func(http.ResponseWriter, *http.Request)
+	}{
+		{
+			name:    "list with bad status",
+			request: withURLParam(httptest.NewRequest(http.MethodGet, "/architectures/id/threads?status=stale", nil), "id", archID),
+			run:     h.Threads,
+		},
+		{
+			name:    "thread without body",
+			request: post("/architectures/id/threads", `{"body":"   "}`),
+			run:     h.CreateThread,
+		},
+		{
+			name:    "thread with anchor id only",
+			request: post("/architectures/id/threads", `{"body":"hi","anchor_id":"n1"}`),
+			run:     h.CreateThread,
+		},
+		{
+			name:    "thread with unknown anchor type",
+			request: post("/architectures/id/threads", `{"body":"hi","anchor_type":"group","anchor_id":"n1"}`),
+			run:     h.CreateThread,
+		},
+		{
+			name:    "reply with bad mention",
+			request: post("/threads/id/comments", `{"body":"hi","mentions":["bob"]}`),
+			run:     h.Reply,
+		},
+		{
+			name:    "reply too long",
+			request: post("/threads/id/comments", `{"body":"`+strings.Repeat("x", maxCommentLength+1)+`"}`),
+			run:     h.Reply,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tc.run(w, withAuthUser(tc.request, testUserID))
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestMarkOrphaned(t *testing.T) {
+	data := json.RawMessage(`{"version":"1.0","nodes":[{"id":"api","position":{"x":0,"y":0},"data":{"label":"API","componentType":"service"}}],"edges":[{"id":"e1","source":"api","target":"api"}]}`)
+	anchor := func(typ, id string) model.CommentThread {
+		return model.CommentThread{AnchorType: &typ, AnchorID: &id}
+	}
+	threads := []model.CommentThread{
+		{},
+		anchor(model.AnchorNode, "api"),
+		anchor(model.AnchorNode, "db"),
+		anchor(model.AnchorEdge, "e1"),
+		anchor(model.AnchorEdge, "api"),
+	}
+
+	markOrphaned(threads, data)
+
+	want := []bool{false, false, true, false, true}
+	for i, th := range threads {
+		if th.Orphaned != want[i] {
+			t.Errorf("thread %d orphaned = %v, want %v", i, th.Orphaned, want[i])
+		}
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	if got := excerpt("short", 10); got != "short" {
+		t.Errorf("excerpt() = %q", got)
+	}
+	if got := excerpt("привет мир", 7); got != "привет…" {
+		t.Errorf("excerpt() = %q, want %q", got, "привет…")
+	}
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/system-design-sandbox/server/internal/archdata"
+	"github.com/system-design-sandbox/server/internal/cost"
+)
+
+// Cost handles GET /api/v1/architectures/{id}/cost — the monthly cost
+// breakdown per component, category and region. Optional query params:
+// region (for components outside a datacenter with a region) and catalog
+// (pricing catalog version, latest by default).
+func (h *ArchitectureHandler) Cost(w http.ResponseWriter, r *http.Request) {
+	_, schema, ok := h.loadSchema(w, r)
+	if !ok {
+		return
+	}
+
+	catalog, err := cost.Load(r.URL.Query().Get("catalog"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "unknown pricing catalog version")
+		return
+	}
+	region := r.URL.Query().Get("region")
+	if _, known := catalog.Regions[region]; region != "" && !known {
+		writeError(w, http.StatusBadRequest, "bad_request", "unknown region")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, catalog.Estimate(schema, region))
+}
+
+// estimateCost prices an architecture with the latest catalog.
+func estimateCost(schema *archdata.Schema, region string) (*cost.Estimate, error) {
+	catalog, err := cost.Load("")
+	if err != nil {
+		return nil, err
+	}
+	return catalog.Estimate(schema, region), nil
+}
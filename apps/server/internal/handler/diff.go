@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/archdata"
+	"github.com/system-design-sandbox/server/internal/archdiff"
+	"github.com/system-design-sandbox/server/internal/model"
+	"github.com/system-design-sandbox/server/internal/storage"
+)
+
+// Diff handles GET /api/v1/architectures/diff?a=&b= — the semantic
+// difference from architecture a to architecture b.
+func (h *ArchitectureHandler) Diff(w http.ResponseWriter, r *http.Request) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	userID, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+		return
+	}
+
+	a, ok := h.loadOwned(w, r, userID, r.URL.Query().Get("a"))
+	if !ok {
+		return
+	}
+	b, ok := h.loadOwned(w, r, userID, r.URL.Query().Get("b"))
+	if !ok {
+		return
+	}
+
+	d, err := archdiff.Compare(a.RawData, b.RawData)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_data", "architecture data cannot be parsed")
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+type mergeRequest struct {
+	BaseID  string `json:"base_id"`
+	OtherID string `json:"other_id"`
+	Apply   bool   `json:"apply"`
+}
+
+type mergeResponse struct {
+	Data      json.RawMessage     `json:"data"`
+	Conflicts []archdiff.Conflict `json:"conflicts"`
+	Applied   bool                `json:"applied"`
+	Result    *model.Architecture `json:"architecture,omitempty"`
+}
+
+// Merge handles POST /api/v1/architectures/{id}/merge — a three-way merge
+// of other_id into {id} against their common ancestor base_id. Without
+// apply it only previews the result. With apply the merged data is saved
+// to {id}, unless there are conflicts (409).
+func (h *ArchitectureHandler) Merge(w http.ResponseWriter, r *http.Request) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	userID, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+		return
+	}
+
+	var req mergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid request body")
+		return
+	}
+
+	ours, ok := h.loadOwned(w, r, userID, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+	base, ok := h.loadOwned(w, r, userID, req.BaseID)
+	if !ok {
+		return
+	}
+	theirs, ok := h.loadOwned(w, r, userID, req.OtherID)
+	if !ok {
+		return
+	}
+
+	res, err := archdiff.Merge(base.RawData, ours.RawData, theirs.RawData)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_data", "architecture data cannot be parsed")
+		return
+	}
+	resp := mergeResponse{Data: res.Data, Conflicts: res.Conflicts}
+	if !req.Apply {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	if len(res.Conflicts) > 0 {
+		writeErrorDetails(w, http.StatusConflict, "merge_conflict", "merge has conflicts", res.Conflicts)
+		return
+	}
+	if errs := archdata.Validate(res.Data, h.limits()); errs != nil {
+		writeErrorDetails(w, http.StatusUnprocessableEntity, "invalid_architecture", "merged data does not match the export format", errs)
+		return
+	}
+
+	arch, err := h.Store.UpdateArchitectureForUser(r.Context(), ours.ID, userID, ours.Name, ours.Description, res.Data, ours.IsPublic, ours.Tags)
+	if err != nil {
+		if writeQuotaError(w, err) {
+			return
+		}
+		if errors.Is(err, storage.ErrForbidden) {
+			writeError(w, http.StatusForbidden, "forbidden", "your workspace role does not allow editing this architecture")
+			return
+		}
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "architecture not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to update architecture")
+		return
+	}
+	resp.Applied = true
+	resp.Result = &arch
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// loadOwned fetches an architecture the user can read by its raw ID, writing
+// the error response when the ID is invalid or the architecture is missing.
+func (h *ArchitectureHandler) loadOwned(w http.ResponseWriter, r *http.Request, userID pgtype.UUID, rawID string) (model.Architecture, bool) {
+	id, err := parseUUID(rawID)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid id")
+		return model.Architecture{}, false
+	}
+
+	arch, err := h.Store.GetArchitectureForUser(r.Context(), id, userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "architecture not found")
+			return model.Architecture{}, false
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to get architecture")
+		return model.Architecture{}, false
+	}
+	return arch, true
+}
@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/system-design-sandbox/server/internal/auth"
+	"github.com/system-design-sandbox/server/internal/jobs"
+	"github.com/system-design-sandbox/server/internal/storage"
+)
+
+//go:embed templates/email_change.html
+var emailChangeFS embed.FS
+
+var emailChangeTmpl = template.Must(template.ParseFS(emailChangeFS, "templates/email_change.html"))
+
+type changeEmailRequest struct {
+	Email string `json:"email"`
+}
+
+type confirmEmailChangeRequest struct {
+	Code string `json:"code"`
+}
+
+// ChangeEmail handles POST /api/v1/users/me/email. It emails a code to the
+// new address and a notice with a cancel link to the current one; the
+// address changes once ConfirmEmailChange gets the code.
+func (h *AccountHandler) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	if h.RedisAuth == nil {
+		writeError(w, http.StatusServiceUnavailable, "auth_unavailable", "authentication is unavailable")
+		return
+	}
+
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+	uid, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	var req changeEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid request body")
+		return
+	}
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	if req.Email == "" || !strings.Contains(req.Email, "@") {
+		writeError(w, http.StatusBadRequest, "invalid_email", "a valid email address is required")
+		return
+	}
+
+	user, err := h.Store.GetUser(r.Context(), uid)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "not_found", "user not found")
+		return
+	}
+	if req.Email == user.Email {
+		writeError(w, http.StatusBadRequest, "same_email", "this is already the account's email address")
+		return
+	}
+	if _, err := h.Store.GetUserByEmail(r.Context(), req.Email); err == nil {
+		writeError(w, http.StatusConflict, "email_taken", "another account uses this email address")
+		return
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusInternalServerError, "internal", "failed to check email address")
+		return
+	}
+
+	code, err := auth.GenerateCode()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "failed to generate code")
+		return
+	}
+	cancelToken, err := h.RedisAuth.SaveEmailChange(r.Context(), authUser.UserID, user.Email, req.Email, code)
+	if err != nil {
+		slog.Error("account: save email change failed", "user_id", authUser.UserID, "error", err)
+		writeError(w, http.StatusInternalServerError, "internal", "failed to start email change")
+		return
+	}
+
+	codeMsg, err := auth.EmailChangeEmail(req.Email, user.Locale, code)
+	if err != nil {
+		slog.Error("account: render email change code failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal", "failed to send code")
+		return
+	}
+	if err := jobs.QueueEmail(r.Context(), h.Store, codeMsg); err != nil {
+		slog.Error("account: queue email change code failed", "user_id", authUser.UserID, "error", err)
+		writeError(w, http.StatusInternalServerError, "internal", "failed to send code")
+		return
+	}
+	if msg, err := auth.EmailChangeNoticeEmail(user.Email, user.Locale, req.Email, cancelToken, h.Config.PublicURL); err != nil {
+		slog.Error("account: render email change notice failed", "error", err)
+	} else if err := jobs.QueueEmail(r.Context(), h.Store, msg); err != nil {
+		slog.Error("account: queue email change notice failed", "user_id", authUser.UserID, "error", err)
+	}
+
+	slog.Info("account: email change requested", "user_id", authUser.UserID)
+	writeJSON(w, http.StatusAccepted, map[string]string{"email": req.Email})
+}
+
+// ConfirmEmailChange handles POST /api/v1/users/me/email/confirm with the
+// code sent to the new address. The change is made only if the account
+// still has the address it had when the change was requested.
+func (h *AccountHandler) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	if h.RedisAuth == nil {
+		writeError(w, http.StatusServiceUnavailable, "auth_unavailable", "authentication is unavailable")
+		return
+	}
+
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+	uid, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	var req confirmEmailChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid request body")
+		return
+	}
+	if req.Code == "" {
+		writeError(w, http.StatusBadRequest, "bad_request", "code is required")
+		return
+	}
+
+	change, err := h.RedisAuth.VerifyEmailChange(r.Context(), authUser.UserID, req.Code)
+	switch {
+	case errors.Is(err, auth.ErrTooManyAttempts):
+		writeError(w, http.StatusBadRequest, "too_many_attempts", "too many attempts, request a new code")
+		return
+	case errors.Is(err, auth.ErrCodeMismatch):
+		writeError(w, http.StatusBadRequest, "invalid_code", "invalid code")
+		return
+	case errors.Is(err, auth.ErrCodeNotFound):
+		writeError(w, http.StatusBadRequest, "invalid_code", "invalid or expired code")
+		return
+	case err != nil:
+		slog.Error("account: check email change code failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal", "failed to verify code")
+		return
+	}
+
+	user, err := h.Store.ChangeUserEmail(r.Context(), uid, change.OldEmail, change.Email)
+	switch {
+	case errors.Is(err, storage.ErrEmailTaken):
+		writeError(w, http.StatusConflict, "email_taken", "another account uses this email address")
+		return
+	case errors.Is(err, pgx.ErrNoRows):
+		writeError(w, http.StatusConflict, "email_changed", "the account's email address has changed since the code was sent")
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "internal", "failed to change email address")
+		return
+	}
+
+	h.moveEmailState(r.Context(), change.OldEmail, change.Email)
+	rotateSessionToken(w, r, h.RedisAuth, h.Config)
+	slog.Info("account: email changed", "user_id", authUser.UserID)
+	writeJSON(w, http.StatusOK, user)
+}
+
+type emailChangePageData struct {
+	Done      bool
+	Reverted  bool
+	Error     string
+	PublicURL string
+}
+
+// CancelEmailChangeLink handles /api/v1/auth/email-change/cancel?token=,
+// the link in the notice sent to the old address. GET shows a confirmation
+// page, since mail scanners follow links; POST drops the pending change,
+// moves the account back to the old address if the change was already
+// confirmed, and signs out every session.
+func (h *AccountHandler) CancelEmailChangeLink(w http.ResponseWriter, r *http.Request) {
+	data := emailChangePageData{PublicURL: h.Config.PublicURL}
+	status := http.StatusOK
+	const failed = "Something went wrong. Please try again later."
+
+	token := r.URL.Query().Get("token")
+	switch {
+	case !validToken(token):
+		status, data.Error = http.StatusBadRequest, "This link is invalid."
+	case h.RedisAuth == nil:
+		status, data.Error = http.StatusServiceUnavailable, failed
+	case r.Method == http.MethodPost:
+		ct, err := h.RedisAuth.ConsumeEmailChangeCancel(r.Context(), token)
+		if err != nil {
+			slog.Error("account: consume email change cancel token failed", "error", err)
+			status, data.Error = http.StatusInternalServerError, failed
+			break
+		}
+		if ct == nil {
+			status, data.Error = http.StatusNotFound, "This link has expired or has already been used."
+			break
+		}
+		uid, err := parseUUID(ct.UserID)
+		if err != nil {
+			status, data.Error = http.StatusBadRequest, "This link is invalid."
+			break
+		}
+
+		_, err = h.Store.ChangeUserEmail(r.Context(), uid, ct.NewEmail, ct.OldEmail)
+		switch {
+		case err == nil:
+			h.moveEmailState(r.Context(), ct.NewEmail, ct.OldEmail)
+			data.Reverted = true
+		case errors.Is(err, pgx.ErrNoRows):
+			// Not confirmed, or changed again since: nothing to undo.
+		case errors.Is(err, storage.ErrEmailTaken):
+			status, data.Error = http.StatusConflict, "The change was cancelled, but this address now belongs to another account, so it could not be restored."
+		default:
+			slog.Error("account: revert email change failed", "user_id", ct.UserID, "error", err)
+			status, data.Error = http.StatusInternalServerError, failed
+		}
+
+		revoked, err := h.RedisAuth.DeleteOtherSessions(r.Context(), "", ct.UserID)
+		logSessionEvent(r, h.Store, h.Config, h.GeoIP, ct.UserID, "revoke", revoked...)
+		if err != nil {
+			slog.Error("account: revoke sessions failed", "user_id", ct.UserID, "error", err)
+			status, data.Error = http.StatusInternalServerError, failed
+		}
+		slog.Info("account: email change cancelled from notice", "user_id", ct.UserID, "reverted", data.Reverted)
+		data.Done = data.Error == ""
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := emailChangeTmpl.Execute(w, data); err != nil {
+		slog.Error("account: render email change page failed", "error", err)
+	}
+}
+
+// moveEmailState follows an address change in Redis: the login code sent
+// to the old address stops working and its rate limits carry over to the
+// new one, so a change neither resets them nor leaves them behind.
+func (h *AccountHandler) moveEmailState(ctx context.Context, from, to string) {
+	if err := h.RedisAuth.DiscardPendingCode(ctx, from); err != nil {
+		slog.Error("account: discard login code failed", "error", err)
+	}
+	if err := rekeyEmailRateLimits(ctx, h.Limiter, h.Config.RateLimit, from, to); err != nil {
+		slog.Error("account: move rate limits failed", "error", err)
+	}
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/system-design-sandbox/server/internal/auth"
+	"github.com/system-design-sandbox/server/internal/config"
+)
+
+func TestEmailChangeRequiresAuth(t *testing.T) {
+	h := &AccountHandler{RedisAuth: &auth.RedisAuth{}}
+
+	for name, call := range map[string]func(http.ResponseWriter, *http.Request){
+		"change":  h.ChangeEmail,
+		"confirm": h.ConfirmEmailChange,
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			call(w, httptest.NewRequest(http.MethodPost, "/api/v1/users/me/email", nil))
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+			}
+		})
+	}
+}
+
+func TestEmailChangeValidation(t *testing.T) {
+	h := &AccountHandler{RedisAuth: &auth.RedisAuth{}}
+
+	tests := []struct {
+		name string
+		call func(http.ResponseWriter, *http.Request)
+		body string
+		code string
+	}{
+		{"change: invalid body", h.ChangeEmail, "{", "bad_request"},
+		{"change: missing email", h.ChangeEmail, `{"email": "  "}`, "invalid_email"},
+		{"change: not an email", h.ChangeEmail, `{"email": "example.com"}`, "invalid_email"},
+		{"confirm: invalid body", h.ConfirmEmailChange, "{", "bad_request"},
+		{"confirm: missing code", h.ConfirmEmailChange, `{}`, "bad_request"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/users/me/email", strings.NewReader(tt.body))
+			tt.call(w, withAuthUser(r, testUserID))
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+			var resp errorResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Code != tt.code {
+				t.Errorf("code = %q, want %q", resp.Code, tt.code)
+			}
+		})
+	}
+}
+
+func TestCancelEmailChangeLink(t *testing.T) {
+	h := &AccountHandler{Config: &config.Config{PublicURL: "https://example.com"}, RedisAuth: &auth.RedisAuth{}}
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		w := httptest.NewRecorder()
+		h.CancelEmailChangeLink(w, httptest.NewRequest(method, "/api/v1/auth/email-change/cancel?token=abc", nil))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "link is invalid") {
+			t.Errorf("%s with a bad token: status = %d", method, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.CancelEmailChangeLink(w, httptest.NewRequest(http.MethodGet, "/api/v1/auth/email-change/cancel?token="+strings.Repeat("ab", 32), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if w.Header().Get("Referrer-Policy") != "no-referrer" {
+		t.Error("token could leak through the Referer header")
+	}
+	if body := w.Body.String(); !strings.Contains(body, `<form method="post">`) {
+		t.Errorf("expected a confirmation form, got %s", body)
+	}
+}
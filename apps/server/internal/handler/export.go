@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/system-design-sandbox/server/internal/archdata"
+	"github.com/system-design-sandbox/server/internal/export"
+	"github.com/system-design-sandbox/server/internal/model"
+)
+
+// ExportIaC handles GET /api/v1/architectures/{id}/export/iac — a zip with
+// docker-compose.yml, Kubernetes manifests and a README.
+func (h *ArchitectureHandler) ExportIaC(w http.ResponseWriter, r *http.Request) {
+	arch, schema, ok := h.loadSchema(w, r)
+	if !ok {
+		return
+	}
+
+	writeBundle(w, export.Infrastructure(schema, arch.Name), arch.Name, "iac")
+}
+
+// ExportTerraform handles GET /api/v1/architectures/{id}/export/terraform — a
+// zip with an AWS Terraform module tree sized from the canvas.
+func (h *ArchitectureHandler) ExportTerraform(w http.ResponseWriter, r *http.Request) {
+	arch, schema, ok := h.loadSchema(w, r)
+	if !ok {
+		return
+	}
+
+	writeBundle(w, export.Terraform(schema, arch.Name), arch.Name, "terraform")
+}
+
+// loadSchema fetches the caller's architecture and parses its data.
+// It writes the error response itself and reports whether to continue.
+func (h *ArchitectureHandler) loadSchema(w http.ResponseWriter, r *http.Request) (model.Architecture, *archdata.Schema, bool) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return model.Architecture{}, nil, false
+	}
+
+	id, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid id")
+		return model.Architecture{}, nil, false
+	}
+
+	userID, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+		return model.Architecture{}, nil, false
+	}
+
+	arch, err := h.Store.GetArchitectureForUser(r.Context(), id, userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "architecture not found")
+			return model.Architecture{}, nil, false
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to get architecture")
+		return model.Architecture{}, nil, false
+	}
+
+	schema, err := archdata.Parse(arch.RawData)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_data", "architecture data cannot be parsed")
+		return model.Architecture{}, nil, false
+	}
+	return arch, schema, true
+}
+
+func writeBundle(w http.ResponseWriter, b *export.Bundle, name, suffix string) {
+	dir := export.FileName(name) + "-" + suffix
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+dir+`.zip"`)
+	if err := b.WriteZip(w, dir); err != nil {
+		slog.Error("export: write zip failed", "error", err)
+	}
+}
@@ -0,0 +1,356 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/model"
+	"github.com/system-design-sandbox/server/internal/storage"
+)
+
+type FolderHandler struct {
+	Store *storage.Storage
+}
+
+type folderRequest struct {
+	Name     string  `json:"name"`
+	ParentID *string `json:"parent_id"`
+	// WorkspaceID is only read on create; folders stay in their space.
+	WorkspaceID *string `json:"workspace_id"`
+}
+
+// decodeFolderRequest reads a folder body and resolves parent_id (null or
+// absent for top level).
+func decodeFolderRequest(w http.ResponseWriter, r *http.Request) (folderRequest, *pgtype.UUID, bool) {
+	var req folderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid request body")
+		return req, nil, false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "bad_request", "name is required")
+		return req, nil, false
+	}
+	if req.ParentID == nil {
+		return req, nil, true
+	}
+	parentID, err := parseUUID(*req.ParentID)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid parent_id")
+		return req, nil, false
+	}
+	return req, &parentID, true
+}
+
+// folderWorkspace resolves an optional workspace ID for folders and checks
+// that the user holds at least role min in it. nil means personal folders.
+func folderWorkspace(w http.ResponseWriter, r *http.Request, store *storage.Storage, userID pgtype.UUID, raw *string, min string) (*pgtype.UUID, bool) {
+	if raw == nil || *raw == "" {
+		return nil, true
+	}
+	id, err := parseUUID(*raw)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid workspace_id")
+		return nil, false
+	}
+	if _, ok := workspaceWithRole(w, r, store, id, userID, min); !ok {
+		return nil, false
+	}
+	return &id, true
+}
+
+// List handles GET /api/v1/folders?workspace_id= — every personal folder
+// of the user, or every folder of the workspace, as a flat list; parent_id
+// links them into a tree.
+func (h *FolderHandler) List(w http.ResponseWriter, r *http.Request) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	userID, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+		return
+	}
+
+	var rawWorkspace *string
+	if v := r.URL.Query().Get("workspace_id"); v != "" {
+		rawWorkspace = &v
+	}
+	workspaceID, ok := folderWorkspace(w, r, h.Store, userID, rawWorkspace, model.RoleViewer)
+	if !ok {
+		return
+	}
+
+	folders, err := h.Store.ListFoldersByUser(r.Context(), userID, workspaceID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "failed to list folders")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, folders)
+}
+
+// Create handles POST /api/v1/folders. With workspace_id the folder belongs
+// to that workspace, where the user must be an editor or above.
+func (h *FolderHandler) Create(w http.ResponseWriter, r *http.Request) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	userID, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+		return
+	}
+
+	req, parentID, ok := decodeFolderRequest(w, r)
+	if !ok {
+		return
+	}
+	workspaceID, ok := folderWorkspace(w, r, h.Store, userID, req.WorkspaceID, model.RoleEditor)
+	if !ok {
+		return
+	}
+
+	folder, err := h.Store.CreateFolderForUser(r.Context(), userID, workspaceID, parentID, req.Name)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "parent folder not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to create folder")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, folder)
+}
+
+// Update handles PUT /api/v1/folders/{id} — rename and move. parent_id
+// null moves the folder to the top level.
+func (h *FolderHandler) Update(w http.ResponseWriter, r *http.Request) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	id, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid id")
+		return
+	}
+
+	userID, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+		return
+	}
+
+	req, parentID, ok := decodeFolderRequest(w, r)
+	if !ok {
+		return
+	}
+
+	folder, err := h.Store.UpdateFolderForUser(r.Context(), id, userID, req.Name, parentID)
+	if err != nil {
+		if errors.Is(err, storage.ErrForbidden) {
+			writeError(w, http.StatusForbidden, "forbidden", "requires the editor role in this workspace")
+			return
+		}
+		if errors.Is(err, storage.ErrFolderCycle) {
+			writeError(w, http.StatusConflict, "folder_cycle", "a folder cannot be moved into itself or its subfolders")
+			return
+		}
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "folder not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to update folder")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, folder)
+}
+
+// Delete handles DELETE /api/v1/folders/{id}?mode=reparent|cascade. The
+// default reparent moves subfolders and architectures to the parent
+// folder; cascade moves them to the trash. In a workspace, cascading needs
+// the admin role, like deleting architectures.
+func (h *FolderHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	id, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid id")
+		return
+	}
+
+	userID, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+		return
+	}
+
+	var cascade bool
+	switch r.URL.Query().Get("mode") {
+	case "", "reparent":
+	case "cascade":
+		cascade = true
+	default:
+		writeError(w, http.StatusBadRequest, "bad_request", "mode must be reparent or cascade")
+		return
+	}
+
+	if err := h.Store.DeleteFolderForUser(r.Context(), id, userID, cascade); err != nil {
+		if errors.Is(err, storage.ErrForbidden) {
+			writeError(w, http.StatusForbidden, "forbidden", "requires the editor role in this workspace, or admin to cascade")
+			return
+		}
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "folder not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to delete folder")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Architectures handles GET /api/v1/folders/{id}/architectures with the
+// same query parameters as GET /architectures/mine.
+func (h *FolderHandler) Architectures(w http.ResponseWriter, r *http.Request) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	id, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid id")
+		return
+	}
+
+	userID, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+		return
+	}
+
+	filter, ok := parseArchitectureFilter(w, r)
+	if !ok {
+		return
+	}
+
+	folder, err := h.Store.GetFolderForUser(r.Context(), id, userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "folder not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to get folder")
+		return
+	}
+
+	filter.FolderID = &id
+	filter.WorkspaceID = folder.WorkspaceID
+	writeArchitectureList(w, r, h.Store, userID, filter)
+}
+
+type moveArchitectureRequest struct {
+	FolderID *string `json:"folder_id"`
+}
+
+// Move handles PUT /api/v1/architectures/{id}/folder. folder_id null moves
+// the architecture out of any folder; otherwise the folder must be in the
+// architecture's workspace, or personal for a personal architecture.
+func (h *ArchitectureHandler) Move(w http.ResponseWriter, r *http.Request) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	id, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid id")
+		return
+	}
+
+	userID, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+		return
+	}
+
+	var req moveArchitectureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid request body")
+		return
+	}
+
+	var folderID *pgtype.UUID
+	if req.FolderID != nil {
+		folder, ok := h.accessibleFolder(w, r, userID, *req.FolderID)
+		if !ok {
+			return
+		}
+		folderID = &folder.ID
+	}
+
+	arch, err := h.Store.MoveArchitectureForUser(r.Context(), id, userID, folderID)
+	if err != nil {
+		if errors.Is(err, storage.ErrFolderMismatch) {
+			writeError(w, http.StatusConflict, "folder_mismatch", "the folder belongs to another workspace")
+			return
+		}
+		if errors.Is(err, storage.ErrForbidden) {
+			writeError(w, http.StatusForbidden, "forbidden", "requires the editor role in this workspace")
+			return
+		}
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "architecture not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to move architecture")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, arch)
+}
+
+// accessibleFolder parses a folder ID from a request body and loads the
+// folder, which must be the user's or in one of their workspaces.
+func (h *ArchitectureHandler) accessibleFolder(w http.ResponseWriter, r *http.Request, userID pgtype.UUID, rawID string) (model.Folder, bool) {
+	id, err := parseUUID(rawID)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid folder_id")
+		return model.Folder{}, false
+	}
+	folder, err := h.Store.GetFolderForUser(r.Context(), id, userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "folder not found")
+			return model.Folder{}, false
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to get folder")
+		return model.Folder{}, false
+	}
+	return folder, true
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFolderHandlerValidation(t *testing.T) {
+	h := &FolderHandler{}
+	const folderID = "0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a"
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		run     func(http.ResponseWriter, *http.Request)
+	}{
+		{
+			name:    "create without name",
+			request: httptest.NewRequest(http.MethodPost, "/folders", bytes.NewBufferString(`{"name":"  "}`)),
+			run:     h.Create,
+		},
+		{
+			name:    "create with bad parent",
+			request: httptest.NewRequest(http.MethodPost, "/folders", bytes.NewBufferString(`{"name":"infra","parent_id":"nope"}`)),
+			run:     h.Create,
+		},
+		{
+			name:    "update bad id",
+			request: withURLParam(httptest.NewRequest(http.MethodPut, "/folders/x", bytes.NewBufferString(`{"name":"a"}`)), "id", "x"),
+			run:     h.Update,
+		},
+		{
+			name:    "delete bad mode",
+			request: withURLParam(httptest.NewRequest(http.MethodDelete, "/folders/id?mode=shred", nil), "id", folderID),
+			run:     h.Delete,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tc.run(w, withAuthUser(tc.request, testUserID))
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/system-design-sandbox/server/internal/auth"
+	"github.com/system-design-sandbox/server/internal/model"
+	"github.com/system-design-sandbox/server/internal/storage"
 )
 
 type contextKey string
@@ -15,6 +18,13 @@ const authUserKey contextKey = "authUser"
 type AuthUser struct {
 	UserID    string
 	SessionID string
+	// Refreshed is set when this request extended the session and it
+	// should be logged as a refresh (see LogSessionRefresh).
+	Refreshed bool
+	// Token is the session token from the cookie and Session what Redis
+	// holds for it; SecureSession uses them to bind and rotate sessions.
+	Token   string
+	Session *auth.SessionData
 }
 
 // RequireAuth returns a chi middleware that validates session cookies via Redis.
@@ -40,7 +50,10 @@ func RequireAuth(redisAuth *auth.RedisAuth) func(http.Handler) http.Handler {
 
 			ctx := context.WithValue(r.Context(), authUserKey, AuthUser{
 				UserID:    sess.UserID,
-				SessionID: cookie.Value,
+				SessionID: sess.ID,
+				Refreshed: sess.Refreshed,
+				Token:     cookie.Value,
+				Session:   sess,
 			})
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
@@ -52,3 +65,36 @@ func GetAuthUser(ctx context.Context) (AuthUser, bool) {
 	u, ok := ctx.Value(authUserKey).(AuthUser)
 	return u, ok
 }
+
+// RequireAdmin lets only site admins through. It must run after
+// RequireAuth.
+func RequireAdmin(store *storage.Storage) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authUser, ok := GetAuthUser(r.Context())
+			if !ok {
+				writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+				return
+			}
+			userID, err := parseUUID(authUser.UserID)
+			if err != nil {
+				writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+				return
+			}
+			user, err := store.GetUser(r.Context(), userID)
+			if err != nil {
+				if err == pgx.ErrNoRows {
+					writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+					return
+				}
+				writeError(w, http.StatusInternalServerError, "internal", "failed to get user")
+				return
+			}
+			if user.Role != model.UserRoleAdmin {
+				writeError(w, http.StatusForbidden, "forbidden", "admin access required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -29,7 +29,7 @@ func setupTestRedisAuth(t *testing.T) *auth.RedisAuth {
 		rdb.FlushDB(context.Background())
 		_ = rdb.Close()
 	})
-	return auth.NewRedisAuth(rdb, 7*24*time.Hour, 20*time.Second, 100, 100)
+	return auth.NewRedisAuth(rdb, 7*24*time.Hour, 20*time.Second)
 }
 
 func TestRequireAuth(t *testing.T) {
@@ -39,7 +39,7 @@ func TestRequireAuth(t *testing.T) {
 	sessionID := "test-session-id-123"
 
 	// Create a valid session in Redis
-	err := ra.CreateSession(context.Background(), sessionID, auth.SessionData{
+	token, err := ra.CreateSession(context.Background(), sessionID, auth.SessionData{
 		UserID:       userID,
 		IP:           "127.0.0.1",
 		CreatedAt:    time.Now().UTC().Format(time.RFC3339),
@@ -59,7 +59,7 @@ func TestRequireAuth(t *testing.T) {
 
 	t.Run("valid session cookie passes", func(t *testing.T) {
 		req := httptest.NewRequest("GET", "/test", nil)
-		req.AddCookie(&http.Cookie{Name: "session_id", Value: sessionID})
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: token})
 		w := httptest.NewRecorder()
 
 		protected.ServeHTTP(w, req)
@@ -111,6 +111,18 @@ func TestRequireAuth(t *testing.T) {
 		}
 	})
 
+	t.Run("session ID in place of its token returns 401", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: sessionID})
+		w := httptest.NewRecorder()
+
+		protected.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %d", w.Code)
+		}
+	})
+
 	t.Run("empty cookie value returns 401", func(t *testing.T) {
 		req := httptest.NewRequest("GET", "/test", nil)
 		req.AddCookie(&http.Cookie{Name: "session_id", Value: ""})
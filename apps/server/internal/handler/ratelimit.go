@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/system-design-sandbox/server/internal/config"
+	"github.com/system-design-sandbox/server/internal/ratelimit"
+)
+
+// maxRateLimitBody is how much of a request body is read to find the email
+// address it is limited by.
+const maxRateLimitBody = 64 << 10
+
+// authRateLimits limits the public auth endpoints per IP and globally, plus
+// any extra buckets, e.g. per email address. All buckets are checked in one
+// step so a request rejected by one is not counted against the others.
+func authRateLimits(l *ratelimit.Limiter, cfg config.RateLimitConfig, extra ...ratelimit.Bucket) func(http.Handler) http.Handler {
+	buckets := append([]ratelimit.Bucket{
+		{Name: "ip", Key: rateLimitIP, Rules: []ratelimit.Rule{
+			{Limit: cfg.IPPerMinute, Window: time.Minute},
+			{Limit: cfg.IPPerHour, Window: time.Hour},
+		}},
+		ratelimit.Global(ratelimit.Rule{Limit: cfg.GlobalPerMinute, Window: time.Minute}),
+	}, extra...)
+	return l.Middleware("auth", buckets...)
+}
+
+// emailRateLimit is a bucket per email address in the JSON request body.
+func emailRateLimit(name string, cfg config.RateLimitConfig) ratelimit.Bucket {
+	return ratelimit.Bucket{Name: name, Key: emailFromBody, Rules: []ratelimit.Rule{
+		{Limit: cfg.PerMinute, Window: time.Minute},
+		{Limit: cfg.PerHour, Window: time.Hour},
+	}}
+}
+
+// rekeyEmailRateLimits moves the per-email auth limits counted for address
+// from to address to.
+func rekeyEmailRateLimits(ctx context.Context, l *ratelimit.Limiter, cfg config.RateLimitConfig, from, to string) error {
+	for _, name := range []string{"email", "verify"} {
+		if err := l.Rekey(ctx, "auth", emailRateLimit(name, cfg), from, to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRateLimits limits write requests per signed-in user, or per IP for
+// anonymous ones. Reads pass unlimited.
+func writeRateLimits(l *ratelimit.Limiter, cfg config.RateLimitConfig) func(http.Handler) http.Handler {
+	return l.Middleware("write", ratelimit.Bucket{
+		Name: "writer",
+		Key: func(r *http.Request) string {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				return ""
+			}
+			if u, ok := GetAuthUser(r.Context()); ok {
+				return "user:" + u.UserID
+			}
+			return "ip:" + rateLimitIP(r)
+		},
+		Rules: []ratelimit.Rule{{Limit: cfg.WritesPerMinute, Window: time.Minute}},
+	})
+}
+
+// rateLimitIP is the client address requests are limited by. It trusts
+// X-Real-IP, which nginx overwrites with the peer address, rather than
+// headers clients can set. IPv6 clients usually hold a whole /64, so they
+// are limited by that.
+func rateLimitIP(r *http.Request) string {
+	ip := peerIP(r)
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	if addr.Is6() && !addr.Is4In6() {
+		prefix, _ := addr.Prefix(64)
+		return prefix.String()
+	}
+	return addr.Unmap().String()
+}
+
+// peerIP is the address nginx received the request from (X-Real-IP), or
+// the connection's when there is no proxy in front.
+func peerIP(r *http.Request) string {
+	if ip := r.Header.Get("X-Real-IP"); ip != "" {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// emailFromBody returns the normalized "email" field of a JSON request
+// body, leaving the body intact for the handler.
+func emailFromBody(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+	b, err := io.ReadAll(io.LimitReader(r.Body, maxRateLimitBody))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(b), r.Body), r.Body}
+	if err != nil {
+		return ""
+	}
+	var body struct {
+		Email string `json:"email"`
+	}
+	if json.Unmarshal(b, &body) != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(body.Email))
+}
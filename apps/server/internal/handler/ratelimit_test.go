@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRateLimitIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		realIP     string
+		want       string
+	}{
+		{name: "X-Real-IP from nginx", remoteAddr: "10.0.0.1:1234", realIP: "203.0.113.7", want: "203.0.113.7"},
+		{name: "RemoteAddr without proxy", remoteAddr: "203.0.113.7:1234", want: "203.0.113.7"},
+		{name: "IPv6 limited by /64", remoteAddr: "[2001:db8:1:2:aaaa::1]:1234", want: "2001:db8:1:2::/64"},
+		{name: "IPv4-mapped IPv6", remoteAddr: "[::ffff:203.0.113.7]:1234", want: "203.0.113.7"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			// Clients can set these; only X-Real-IP is overwritten by nginx.
+			req.Header.Set("X-Forwarded-For", "198.51.100.1")
+			req.Header.Set("True-Client-IP", "198.51.100.2")
+			if tc.realIP != "" {
+				req.Header.Set("X-Real-IP", tc.realIP)
+			}
+			if got := rateLimitIP(req); got != tc.want {
+				t.Errorf("rateLimitIP() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEmailFromBodyKeepsBody(t *testing.T) {
+	body := `{"email":" User@Example.com ","code":"ABC-DEF"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/verify-code", strings.NewReader(body))
+
+	if got := emailFromBody(req); got != "user@example.com" {
+		t.Errorf("emailFromBody() = %q", got)
+	}
+	rest, err := io.ReadAll(req.Body)
+	if err != nil || string(rest) != body {
+		t.Errorf("handler would read %q, %v", rest, err)
+	}
+
+	bad := httptest.NewRequest(http.MethodPost, "/api/v1/auth/send-code", strings.NewReader("not json"))
+	if got := emailFromBody(bad); got != "" {
+		t.Errorf("emailFromBody(invalid) = %q, want empty", got)
+	}
+}
@@ -40,3 +40,14 @@ func TestWriteError(t *testing.T) {
 		t.Errorf("code = %q, want %q", body.Code, "bad_input")
 	}
 }
+
+func TestSetNextLink(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/architectures/mine?q=kafka&limit=20&cursor=old", nil)
+	setNextLink(w, r, "abc")
+
+	want := `</api/v1/architectures/mine?cursor=abc&limit=20&q=kafka>; rel="next"`
+	if got := w.Header().Get("Link"); got != want {
+		t.Errorf("Link = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"embed"
+	"encoding/hex"
+	"html/template"
+	"log/slog"
+	"net/http"
+)
+
+//go:embed templates/revoke.html
+var revokeFS embed.FS
+
+var revokeTmpl = template.Must(template.ParseFS(revokeFS, "templates/revoke.html"))
+
+type revokePageData struct {
+	Done      bool
+	Error     string
+	PublicURL string
+}
+
+// RevokeSessionLink handles /api/v1/auth/revoke?token= — the "this wasn't
+// me" link in sign-in alerts. GET shows a confirmation page, since mail
+// scanners follow links; POST signs out the session the alert was for.
+func (h *AuthHandler) RevokeSessionLink(w http.ResponseWriter, r *http.Request) {
+	data := revokePageData{PublicURL: h.Config.PublicURL}
+	status := http.StatusOK
+
+	token := r.URL.Query().Get("token")
+	switch {
+	case !validToken(token):
+		status, data.Error = http.StatusBadRequest, "This link is invalid."
+	case h.RedisAuth == nil:
+		status, data.Error = http.StatusServiceUnavailable, "Something went wrong. Please try again later."
+	case r.Method == http.MethodPost:
+		rt, err := h.RedisAuth.ConsumeRevokeToken(r.Context(), token)
+		switch {
+		case err != nil:
+			slog.Error("auth: consume revoke token failed", "error", err)
+			status, data.Error = http.StatusInternalServerError, "Something went wrong. Please try again later."
+		case rt == nil:
+			status, data.Error = http.StatusNotFound, "This link has expired or has already been used."
+		default:
+			if err := h.RedisAuth.DeleteSession(r.Context(), rt.SessionID, rt.UserID); err != nil {
+				slog.Error("auth: revoke session failed", "error", err)
+				status, data.Error = http.StatusInternalServerError, "Something went wrong. Please try again later."
+				break
+			}
+			logSessionEvent(r, h.Store, h.Config, h.GeoIP, rt.UserID, "revoke", rt.SessionID)
+			slog.Info("auth: session revoked from sign-in alert", "user_id", rt.UserID)
+			data.Done = true
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := revokeTmpl.Execute(w, data); err != nil {
+		slog.Error("auth: render revoke page failed", "error", err)
+	}
+}
+
+// validToken reports whether s looks like a token from GenerateToken.
+func validToken(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
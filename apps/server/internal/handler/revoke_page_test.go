@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/system-design-sandbox/server/internal/auth"
+	"github.com/system-design-sandbox/server/internal/config"
+)
+
+func TestRevokeSessionLinkRejectsBadTokens(t *testing.T) {
+	h := &AuthHandler{Config: &config.Config{PublicURL: "https://example.com"}}
+
+	for name, target := range map[string]string{
+		"missing token": "/api/v1/auth/revoke",
+		"short token":   "/api/v1/auth/revoke?token=abc",
+		"not hex":       "/api/v1/auth/revoke?token=" + strings.Repeat("z", 64),
+	} {
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			t.Run(name+"/"+method, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				h.RevokeSessionLink(w, httptest.NewRequest(method, target, nil))
+				if w.Code != http.StatusBadRequest {
+					t.Fatalf("status = %d, want 400", w.Code)
+				}
+				if !strings.Contains(w.Body.String(), "link is invalid") {
+					t.Error("page does not explain the error")
+				}
+			})
+		}
+	}
+}
+
+func TestRevokeSessionLinkAsksForConfirmation(t *testing.T) {
+	h := &AuthHandler{Config: &config.Config{PublicURL: "https://example.com"}, RedisAuth: &auth.RedisAuth{}}
+	w := httptest.NewRecorder()
+	h.RevokeSessionLink(w, httptest.NewRequest(http.MethodGet, "/api/v1/auth/revoke?token="+strings.Repeat("ab", 32), nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if w.Header().Get("Referrer-Policy") != "no-referrer" {
+		t.Error("token could leak through the Referer header")
+	}
+	if body := w.Body.String(); !strings.Contains(body, `<form method="post">`) {
+		t.Errorf("expected a confirmation form, got %s", body)
+	}
+}
@@ -13,10 +13,13 @@ import (
 	"github.com/system-design-sandbox/server/internal/config"
 	"github.com/system-design-sandbox/server/internal/geoip"
 	"github.com/system-design-sandbox/server/internal/metrics"
+	"github.com/system-design-sandbox/server/internal/ratelimit"
 	"github.com/system-design-sandbox/server/internal/storage"
 )
 
-func NewRouter(cfg *config.Config, store *storage.Storage, redisAuth *auth.RedisAuth, emailSender auth.EmailSender, geo *geoip.Client, collector *metrics.Collector, hub *metrics.Hub) *chi.Mux {
+// NewRouter wires the HTTP API. limiter may be nil, which disables rate
+// limiting.
+func NewRouter(cfg *config.Config, store *storage.Storage, redisAuth *auth.RedisAuth, limiter *ratelimit.Limiter, geo *geoip.Client, collector *metrics.Collector, hub *metrics.Hub) *chi.Mux {
 	r := chi.NewRouter()
 
 	// Middleware safe for all routes including WebSocket.
@@ -57,25 +60,45 @@ func NewRouter(cfg *config.Config, store *storage.Storage, redisAuth *auth.Redis
 		ah := &ArchitectureHandler{Store: store}
 		sh := &ScenarioHandler{Store: store}
 		simh := &SimulationHandler{Store: store}
-		authH := &AuthHandler{Store: store, RedisAuth: redisAuth, Email: emailSender, Config: cfg, GeoIP: geo}
-		sessH := &SessionHandler{Store: store, RedisAuth: redisAuth, Config: cfg}
+		fh := &FolderHandler{Store: store}
+		workspaceH := &WorkspaceHandler{Store: store, RedisAuth: redisAuth, Config: cfg}
+		commentH := &CommentHandler{Store: store, Config: cfg}
+		authH := &AuthHandler{Store: store, RedisAuth: redisAuth, Config: cfg, GeoIP: geo}
+		sessH := &SessionHandler{Store: store, RedisAuth: redisAuth, Config: cfg, GeoIP: geo}
+		shareH := &ShareHandler{Store: store, Config: cfg}
+		adminH := &AdminHandler{Store: store}
+		emailH := &EmailHandler{Store: store, Config: cfg}
+		accountH := &AccountHandler{Store: store, RedisAuth: redisAuth, Config: cfg, GeoIP: geo, Limiter: limiter}
+		writeLimit := writeRateLimits(limiter, cfg.RateLimit)
 
 		// Verify page (server-rendered HTML with htmx)
 		r.Get("/auth/verify", authH.VerifyPage)
 
+		// Share links (server-rendered OG pages and static snapshots)
+		r.Get("/s/{slug}", shareH.Page)
+		r.Get("/s/{slug}/snapshot", shareH.Snapshot)
+		r.Get("/s/{slug}/thumbnail.svg", shareH.Thumbnail)
+
 		r.Route("/api/v1", func(r chi.Router) {
-			// Public auth endpoints (no middleware)
+			// Public auth endpoints, rate limited per IP, globally and per
+			// email address
 			r.Route("/auth", func(r chi.Router) {
-				r.Post("/send-code", authH.SendCode)
+				r.With(authRateLimits(limiter, cfg.RateLimit, emailRateLimit("email", cfg.RateLimit))).Post("/send-code", authH.SendCode)
 				r.Get("/config", authH.AuthConfig)
-				r.Post("/verify", authH.Verify)
-				r.Post("/verify-code", authH.VerifyCode)
+				r.With(authRateLimits(limiter, cfg.RateLimit)).Post("/verify", authH.Verify)
+				r.With(authRateLimits(limiter, cfg.RateLimit, emailRateLimit("verify", cfg.RateLimit))).Post("/verify-code", authH.VerifyCode)
+				// "This wasn't me" links in sign-in alerts
+				r.Get("/revoke", authH.RevokeSessionLink)
+				r.With(authRateLimits(limiter, cfg.RateLimit)).Post("/revoke", authH.RevokeSessionLink)
+				// Cancel links in the notice of an email change
+				r.Get("/email-change/cancel", accountH.CancelEmailChangeLink)
+				r.With(authRateLimits(limiter, cfg.RateLimit)).Post("/email-change/cancel", accountH.CancelEmailChangeLink)
 			})
 
 			// Existing public endpoints
 			r.Route("/users", func(r chi.Router) {
 				r.Get("/", uh.List)
-				r.Post("/", uh.Create)
+				r.With(writeLimit).Post("/", uh.Create)
 				r.Get("/{id}", uh.Get)
 				r.Get("/{id}/public", uh.GetPublic)
 			})
@@ -87,27 +110,93 @@ func NewRouter(cfg *config.Config, store *storage.Storage, redisAuth *auth.Redis
 
 			r.Get("/simulations/leaderboard/{scenarioID}", simh.Leaderboard)
 
+			r.Get("/shared/{slug}", shareH.Get)
+
+			// Data export downloads, keyed by the token emailed with the link
+			r.Get("/exports/{token}", accountH.DownloadExport)
+
+			// Unsubscribe links in emails, keyed by the user's unsubscribe token
+			r.Get("/email/unsubscribe", emailH.Unsubscribe)
+			r.With(writeLimit).Post("/email/unsubscribe", emailH.Unsubscribe)
+
 			// Protected endpoints
 			r.Group(func(r chi.Router) {
 				r.Use(RequireAuth(redisAuth))
+				r.Use(SecureSession(redisAuth, store, cfg, geo))
+				r.Use(LogSessionRefresh(store, cfg, geo))
+				r.Use(writeLimit)
 
 				r.Post("/auth/logout", authH.Logout)
 
 				r.Route("/auth/sessions", func(r chi.Router) {
 					r.Get("/", sessH.ListSessions)
+					r.Get("/history", sessH.SessionHistory)
 					r.Delete("/{sessionID}", sessH.RevokeSession)
 					r.Post("/revoke-others", sessH.RevokeOtherSessions)
 				})
 
 				r.Get("/users/me", uh.Me)
 				r.Patch("/users/me", uh.UpdateMe)
+				r.Delete("/users/me", accountH.Delete)
+				r.Post("/users/me/export", accountH.Export)
+				r.With(authRateLimits(limiter, cfg.RateLimit, emailRateLimit("email", cfg.RateLimit))).Post("/users/me/email", accountH.ChangeEmail)
+				r.With(authRateLimits(limiter, cfg.RateLimit)).Post("/users/me/email/confirm", accountH.ConfirmEmailChange)
+				r.Get("/users/me/usage", uh.Usage)
 
 				r.Route("/architectures", func(r chi.Router) {
 					r.Get("/mine", ah.ListMine)
+					r.Get("/diff", ah.Diff)
+					r.Get("/trash", ah.Trash)
+					r.Delete("/trash/{id}", ah.Purge)
 					r.Post("/", ah.Create)
 					r.Get("/{id}", ah.Get)
 					r.Put("/{id}", ah.Update)
 					r.Delete("/{id}", ah.Delete)
+					r.Post("/{id}/restore", ah.Restore)
+					r.Get("/{id}/export/iac", ah.ExportIaC)
+					r.Get("/{id}/export/terraform", ah.ExportTerraform)
+					r.Get("/{id}/analysis", ah.Analysis)
+					r.Get("/{id}/cost", ah.Cost)
+					r.Post("/{id}/merge", ah.Merge)
+					r.Put("/{id}/folder", ah.Move)
+					r.Put("/{id}/workspace", ah.MoveToWorkspace)
+					r.Get("/{id}/threads", commentH.Threads)
+					r.Post("/{id}/threads", commentH.CreateThread)
+				})
+
+				r.Route("/threads", func(r chi.Router) {
+					r.Post("/{id}/comments", commentH.Reply)
+					r.Post("/{id}/resolve", commentH.Resolve)
+					r.Post("/{id}/reopen", commentH.Reopen)
+				})
+
+				r.Route("/comments", func(r chi.Router) {
+					r.Put("/{id}", commentH.UpdateComment)
+					r.Delete("/{id}", commentH.DeleteComment)
+				})
+
+				r.Route("/workspaces", func(r chi.Router) {
+					r.Get("/", workspaceH.List)
+					r.Post("/", workspaceH.Create)
+					r.Post("/invites/{token}/accept", workspaceH.AcceptInvite)
+					r.Get("/{id}", workspaceH.Get)
+					r.Put("/{id}", workspaceH.Update)
+					r.Delete("/{id}", workspaceH.Delete)
+					r.Get("/{id}/members", workspaceH.Members)
+					r.Put("/{id}/members/{userID}", workspaceH.UpdateMember)
+					r.Delete("/{id}/members/{userID}", workspaceH.RemoveMember)
+					r.Get("/{id}/invites", workspaceH.Invites)
+					r.Post("/{id}/invites", workspaceH.Invite)
+					r.Delete("/{id}/invites/{inviteID}", workspaceH.RevokeInvite)
+					r.Get("/{id}/architectures", workspaceH.Architectures)
+				})
+
+				r.Route("/folders", func(r chi.Router) {
+					r.Get("/", fh.List)
+					r.Post("/", fh.Create)
+					r.Put("/{id}", fh.Update)
+					r.Delete("/{id}", fh.Delete)
+					r.Get("/{id}/architectures", fh.Architectures)
 				})
 
 				r.Route("/simulations", func(r chi.Router) {
@@ -115,6 +204,15 @@ func NewRouter(cfg *config.Config, store *storage.Storage, redisAuth *auth.Redis
 					r.Get("/{id}", simh.Get)
 					r.Get("/architecture/{architectureID}", simh.ListByArchitecture)
 				})
+
+				r.Route("/admin", func(r chi.Router) {
+					r.Use(RequireAdmin(store))
+					r.Get("/jobs", adminH.Jobs)
+					r.Post("/jobs/{id}/retry", adminH.RetryJob)
+					r.Get("/emails", adminH.Emails)
+					r.Get("/emails/preview/{template}", adminH.PreviewEmail)
+					r.Get("/architectures/upgrade-failures", adminH.ArchitectureUpgrades)
+				})
 			})
 		})
 	}) // end r.Group (HTTP routes)
@@ -27,9 +27,52 @@ func TestPrivateArchitectureAndSimulationRoutesRequireAuth(t *testing.T) {
 		method string
 		target string
 	}{
+		{name: "usage", method: http.MethodGet, target: "/api/v1/users/me/usage"},
+		{name: "delete account", method: http.MethodDelete, target: "/api/v1/users/me"},
+		{name: "export data", method: http.MethodPost, target: "/api/v1/users/me/export"},
+		{name: "change email", method: http.MethodPost, target: "/api/v1/users/me/email"},
+		{name: "confirm email change", method: http.MethodPost, target: "/api/v1/users/me/email/confirm"},
+		{name: "session history", method: http.MethodGet, target: "/api/v1/auth/sessions/history"},
+		{name: "admin jobs", method: http.MethodGet, target: "/api/v1/admin/jobs"},
+		{name: "admin emails", method: http.MethodGet, target: "/api/v1/admin/emails"},
+		{name: "admin email preview", method: http.MethodGet, target: "/api/v1/admin/emails/preview/login"},
+		{name: "admin architecture upgrades", method: http.MethodGet, target: "/api/v1/admin/architectures/upgrade-failures"},
+		{name: "admin retry job", method: http.MethodPost, target: "/api/v1/admin/jobs/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a/retry"},
 		{name: "list mine", method: http.MethodGet, target: "/api/v1/architectures/mine"},
 		{name: "create architecture", method: http.MethodPost, target: "/api/v1/architectures/"},
 		{name: "get architecture", method: http.MethodGet, target: "/api/v1/architectures/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a"},
+		{name: "export iac", method: http.MethodGet, target: "/api/v1/architectures/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a/export/iac"},
+		{name: "export terraform", method: http.MethodGet, target: "/api/v1/architectures/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a/export/terraform"},
+		{name: "analysis", method: http.MethodGet, target: "/api/v1/architectures/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a/analysis"},
+		{name: "cost", method: http.MethodGet, target: "/api/v1/architectures/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a/cost"},
+		{name: "diff", method: http.MethodGet, target: "/api/v1/architectures/diff?a=0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a&b=0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1b"},
+		{name: "merge", method: http.MethodPost, target: "/api/v1/architectures/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a/merge"},
+		{name: "move architecture", method: http.MethodPut, target: "/api/v1/architectures/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a/folder"},
+		{name: "list trash", method: http.MethodGet, target: "/api/v1/architectures/trash"},
+		{name: "restore architecture", method: http.MethodPost, target: "/api/v1/architectures/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a/restore"},
+		{name: "purge architecture", method: http.MethodDelete, target: "/api/v1/architectures/trash/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a"},
+		{name: "list folders", method: http.MethodGet, target: "/api/v1/folders/"},
+		{name: "create folder", method: http.MethodPost, target: "/api/v1/folders/"},
+		{name: "update folder", method: http.MethodPut, target: "/api/v1/folders/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a"},
+		{name: "delete folder", method: http.MethodDelete, target: "/api/v1/folders/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a"},
+		{name: "folder architectures", method: http.MethodGet, target: "/api/v1/folders/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a/architectures"},
+		{name: "move architecture to workspace", method: http.MethodPut, target: "/api/v1/architectures/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a/workspace"},
+		{name: "list workspaces", method: http.MethodGet, target: "/api/v1/workspaces/"},
+		{name: "create workspace", method: http.MethodPost, target: "/api/v1/workspaces/"},
+		{name: "get workspace", method: http.MethodGet, target: "/api/v1/workspaces/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a"},
+		{name: "delete workspace", method: http.MethodDelete, target: "/api/v1/workspaces/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a"},
+		{name: "workspace members", method: http.MethodGet, target: "/api/v1/workspaces/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a/members"},
+		{name: "update workspace member", method: http.MethodPut, target: "/api/v1/workspaces/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a/members/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1b"},
+		{name: "invite to workspace", method: http.MethodPost, target: "/api/v1/workspaces/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a/invites"},
+		{name: "accept workspace invite", method: http.MethodPost, target: "/api/v1/workspaces/invites/token/accept"},
+		{name: "workspace architectures", method: http.MethodGet, target: "/api/v1/workspaces/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a/architectures"},
+		{name: "list threads", method: http.MethodGet, target: "/api/v1/architectures/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a/threads"},
+		{name: "create thread", method: http.MethodPost, target: "/api/v1/architectures/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a/threads"},
+		{name: "reply to thread", method: http.MethodPost, target: "/api/v1/threads/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a/comments"},
+		{name: "resolve thread", method: http.MethodPost, target: "/api/v1/threads/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a/resolve"},
+		{name: "reopen thread", method: http.MethodPost, target: "/api/v1/threads/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a/reopen"},
+		{name: "update comment", method: http.MethodPut, target: "/api/v1/comments/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a"},
+		{name: "delete comment", method: http.MethodDelete, target: "/api/v1/comments/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a"},
 		{name: "create simulation", method: http.MethodPost, target: "/api/v1/simulations/"},
 		{name: "list simulation results", method: http.MethodGet, target: "/api/v1/simulations/architecture/0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a"},
 	}
@@ -1,14 +1,15 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"sort"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/system-design-sandbox/server/internal/auth"
 	"github.com/system-design-sandbox/server/internal/config"
+	"github.com/system-design-sandbox/server/internal/geoip"
 	"github.com/system-design-sandbox/server/internal/model"
 	"github.com/system-design-sandbox/server/internal/storage"
 )
@@ -17,6 +18,7 @@ type SessionHandler struct {
 	Store     *storage.Storage
 	RedisAuth *auth.RedisAuth
 	Config    *config.Config
+	GeoIP     *geoip.Client
 }
 
 type sessionInfo struct {
@@ -92,6 +94,50 @@ func (h *SessionHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, sessionsResponse{Sessions: out, Total: total})
 }
 
+// SessionHistory handles GET /api/v1/auth/sessions/history — the user's
+// session log, newest first. Query parameters: limit (1-100, default 50)
+// and cursor, taken from the rel="next" Link header of the previous page.
+func (h *SessionHandler) SessionHistory(w http.ResponseWriter, r *http.Request) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+	userID, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+		return
+	}
+
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxListLimit {
+			writeError(w, http.StatusBadRequest, "bad_request", "limit must be between 1 and "+strconv.Itoa(maxListLimit))
+			return
+		}
+		limit = n
+	}
+
+	entries, next, err := h.Store.ListSessionLogs(r.Context(), userID, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidCursor) {
+			writeError(w, http.StatusBadRequest, "bad_request", "invalid cursor")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to list session history")
+		return
+	}
+
+	if next != "" {
+		setNextLink(w, r, next)
+	}
+	if entries == nil {
+		entries = []model.SessionLogEntry{}
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
 // RevokeSession handles DELETE /api/v1/auth/sessions/{sessionID}
 func (h *SessionHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
 	if h.RedisAuth == nil {
@@ -119,18 +165,7 @@ func (h *SessionHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
 	}
 
 	_ = h.RedisAuth.DeleteSession(r.Context(), targetSessionID, authUser.UserID)
-
-	if h.Config.SessionLogEnabled {
-		var uid pgtype.UUID
-		_ = uid.Scan(authUser.UserID)
-		_ = h.Store.CreateSessionLog(r.Context(), model.SessionLogEntry{
-			UserID:    uid,
-			SessionID: targetSessionID,
-			Action:    "revoke",
-			IP:        clientIP(r),
-			UserAgent: r.UserAgent(),
-		})
-	}
+	logSessionEvent(r, h.Store, h.Config, h.GeoIP, authUser.UserID, "revoke", targetSessionID)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -148,11 +183,15 @@ func (h *SessionHandler) RevokeOtherSessions(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	count, err := h.RedisAuth.DeleteOtherSessions(r.Context(), authUser.SessionID, authUser.UserID)
+	revoked, err := h.RedisAuth.DeleteOtherSessions(r.Context(), authUser.SessionID, authUser.UserID)
+	// Log the sessions that are gone even if a later one failed.
+	logSessionEvent(r, h.Store, h.Config, h.GeoIP, authUser.UserID, "revoke", revoked...)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "internal", "failed to revoke sessions")
 		return
 	}
+	// A copy of this session's token taken along with the others goes too.
+	rotateSessionToken(w, r, h.RedisAuth, h.Config)
 
-	writeJSON(w, http.StatusOK, map[string]int{"revoked": count})
+	writeJSON(w, http.StatusOK, map[string]int{"revoked": len(revoked)})
 }
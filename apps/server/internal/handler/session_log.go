@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/config"
+	"github.com/system-design-sandbox/server/internal/geoip"
+	"github.com/system-design-sandbox/server/internal/model"
+	"github.com/system-design-sandbox/server/internal/storage"
+)
+
+// logSessionEvent records a logout, refresh or revoke of each of the
+// sessions in session_log when it is enabled, with the location of the
+// request's IP so that listing the history needs no lookups. The log is an
+// audit trail only, so a failure is reported and does not fail the request.
+func logSessionEvent(r *http.Request, store *storage.Storage, cfg *config.Config, geo *geoip.Client, userID, action string, sessionIDs ...string) {
+	if !cfg.SessionLogEnabled || len(sessionIDs) == 0 {
+		return
+	}
+	var uid pgtype.UUID
+	_ = uid.Scan(userID)
+	ip := clientIP(r)
+	loc := geo.Lookup(r.Context(), ip)
+	for _, sid := range sessionIDs {
+		err := store.CreateSessionLog(r.Context(), model.SessionLogEntry{
+			UserID:      uid,
+			SessionID:   sid,
+			Action:      action,
+			IP:          ip,
+			UserAgent:   r.UserAgent(),
+			Geo:         loc.Formatted,
+			CountryCode: loc.CountryCode,
+		})
+		if err != nil {
+			slog.Warn("session log: write failed", "action", action, "user_id", userID, "error", err)
+		}
+	}
+}
+
+// LogSessionRefresh records a refresh for requests on which RequireAuth
+// extended the session. It must run after RequireAuth.
+func LogSessionRefresh(store *storage.Storage, cfg *config.Config, geo *geoip.Client) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if authUser, ok := GetAuthUser(r.Context()); ok && authUser.Refreshed {
+				logSessionEvent(r, store, cfg, geo, authUser.UserID, "refresh", authUser.SessionID)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/system-design-sandbox/server/internal/auth"
+	"github.com/system-design-sandbox/server/internal/config"
+	"github.com/system-design-sandbox/server/internal/geoip"
+	"github.com/system-design-sandbox/server/internal/storage"
+)
+
+// setSessionCookie sets the session token cookie. It lasts as long as the
+// session created at createdAt can: its absolute lifetime if there is
+// one, otherwise the idle timeout, which every rotation starts over.
+func setSessionCookie(w http.ResponseWriter, cfg *config.Config, token string, createdAt time.Time) {
+	maxAge := cfg.Session.Expiry
+	if cfg.Session.AbsoluteLifetime > 0 {
+		maxAge = time.Until(createdAt.Add(cfg.Session.AbsoluteLifetime))
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session_id",
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(maxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(cfg.PublicURL, "https"),
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func clearSessionCookie(w http.ResponseWriter, cfg *config.Config) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session_id",
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(cfg.PublicURL, "https"),
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// SecureSession enforces the deployment's session binding and rotates
+// session tokens every Session.RotateInterval. It must run after
+// RequireAuth. A request that no longer matches what its session is bound
+// to ends the session: the user signs in with a new code to carry on.
+func SecureSession(redisAuth *auth.RedisAuth, store *storage.Storage, cfg *config.Config, geo *geoip.Client) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authUser, ok := GetAuthUser(r.Context())
+			if !ok || authUser.Session == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			sess := authUser.Session
+
+			if reason := bindingMismatch(r, cfg.Session.Binding, sess, geo); reason != "" {
+				slog.Warn("auth: session binding mismatch", "user_id", sess.UserID, "binding", reason)
+				if err := redisAuth.DeleteSession(r.Context(), sess.ID, sess.UserID); err != nil {
+					slog.Error("auth: delete mismatched session failed", "error", err)
+				}
+				logSessionEvent(r, store, cfg, geo, sess.UserID, "revoke", sess.ID)
+				clearSessionCookie(w, cfg)
+				writeError(w, http.StatusUnauthorized, "reverify_required", "sign in again to confirm it is you")
+				return
+			}
+
+			switch {
+			case sess.ReplacedBy != "":
+				// Another request rotated the token; catch this client up.
+				setSessionCookie(w, cfg, sess.ReplacedBy, parseSessionTime(sess.CreatedAt))
+			case cfg.Session.RotateInterval > 0 && rotationDue(sess, cfg.Session.RotateInterval):
+				rotateSessionToken(w, r, redisAuth, cfg)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rotateSessionToken gives the request's session a new token and cookie.
+// Handlers call it after changing what the user may do, so that a token
+// copied before the change does not carry it. Failures keep the old token.
+func rotateSessionToken(w http.ResponseWriter, r *http.Request, redisAuth *auth.RedisAuth, cfg *config.Config) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok || authUser.Session == nil || redisAuth == nil {
+		return
+	}
+	token, err := redisAuth.RotateSession(r.Context(), authUser.Session, authUser.Token)
+	if err != nil {
+		slog.Error("auth: rotate session failed", "user_id", authUser.UserID, "error", err)
+		return
+	}
+	if token != "" {
+		setSessionCookie(w, cfg, token, parseSessionTime(authUser.Session.CreatedAt))
+	}
+}
+
+// rotationDue reports whether the session token is older than interval.
+// Sessions from before rotation count from sign-in.
+func rotationDue(sess *auth.SessionData, interval time.Duration) bool {
+	at := sess.RotatedAt
+	if at == "" {
+		at = sess.CreatedAt
+	}
+	return time.Since(parseSessionTime(at)) >= interval
+}
+
+func parseSessionTime(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339, s)
+	return t
+}
+
+// bindingMismatch names the first binding the request breaks, or returns
+// "" if it keeps to all of them. Sessions created before a binding was
+// turned on, which lack the data to check, are let through.
+func bindingMismatch(r *http.Request, b config.SessionBinding, sess *auth.SessionData, geo *geoip.Client) string {
+	ip := peerIP(r)
+	network := ipNetwork(ip)
+	if b.UserAgent && sess.Device != "" && auth.DeviceName(r.UserAgent()) != sess.Device {
+		return "user_agent"
+	}
+	if b.IP && sess.Network != "" && network != sess.Network {
+		return "ip"
+	}
+	// The sign-in network is in the sign-in country; look others up.
+	if b.Country && sess.CountryCode != "" && network != sess.Network {
+		if cc := geo.Lookup(r.Context(), ip).CountryCode; cc != "" && cc != sess.CountryCode {
+			return "country"
+		}
+	}
+	return ""
+}
+
+// ipNetwork coarsens an address to its /24 (IPv4) or /48 (IPv6) network,
+// so that the same provider reassigning addresses does not break binding.
+func ipNetwork(s string) string {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return s
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
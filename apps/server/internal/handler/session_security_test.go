@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/system-design-sandbox/server/internal/auth"
+	"github.com/system-design-sandbox/server/internal/config"
+)
+
+func TestIPNetwork(t *testing.T) {
+	tests := map[string]string{
+		"203.0.113.7":          "203.0.113.0",
+		"203.0.113.250":        "203.0.113.0",
+		"2001:db8:1:2::1":      "2001:db8:1::",
+		"::ffff:198.51.100.20": "198.51.100.0",
+		"not-an-ip":            "not-an-ip",
+	}
+	for in, want := range tests {
+		if got := ipNetwork(in); got != want {
+			t.Errorf("ipNetwork(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestBindingMismatch(t *testing.T) {
+	const ua = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"
+	sess := &auth.SessionData{
+		Device:      auth.DeviceName(ua),
+		Network:     "203.0.113.0",
+		CountryCode: "DE",
+	}
+	tests := []struct {
+		name    string
+		binding config.SessionBinding
+		ip      string
+		ua      string
+		sess    *auth.SessionData
+		want    string
+	}{
+		{name: "no binding", ip: "198.51.100.1", ua: "curl/8.0", sess: sess},
+		{name: "same network", binding: config.SessionBinding{IP: true, UserAgent: true}, ip: "203.0.113.99", ua: ua, sess: sess},
+		{name: "other network", binding: config.SessionBinding{IP: true}, ip: "198.51.100.1", ua: ua, sess: sess, want: "ip"},
+		{name: "other browser", binding: config.SessionBinding{UserAgent: true}, ip: "203.0.113.7", ua: "curl/8.0", sess: sess, want: "user_agent"},
+		{name: "session from before binding", binding: config.SessionBinding{IP: true, UserAgent: true}, ip: "198.51.100.1", ua: "curl/8.0", sess: &auth.SessionData{}},
+		// Without GeoIP the country is unknown and not held against the request.
+		{name: "unknown country", binding: config.SessionBinding{Country: true}, ip: "198.51.100.1", ua: ua, sess: sess},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tc.ip + ":4000"
+			r.Header.Set("User-Agent", tc.ua)
+			// The client-supplied chain must not decide the network.
+			r.Header.Set("X-Forwarded-For", "203.0.113.1")
+
+			if got := bindingMismatch(r, tc.binding, tc.sess, nil); got != tc.want {
+				t.Errorf("bindingMismatch = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRotationDue(t *testing.T) {
+	ago := func(d time.Duration) string { return time.Now().Add(-d).UTC().Format(time.RFC3339) }
+
+	if rotationDue(&auth.SessionData{CreatedAt: ago(time.Hour)}, 24*time.Hour) {
+		t.Error("new session due for rotation")
+	}
+	if !rotationDue(&auth.SessionData{CreatedAt: ago(25 * time.Hour)}, 24*time.Hour) {
+		t.Error("day-old session not due for rotation")
+	}
+	if rotationDue(&auth.SessionData{CreatedAt: ago(25 * time.Hour), RotatedAt: ago(time.Hour)}, 24*time.Hour) {
+		t.Error("recently rotated session due again")
+	}
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/system-design-sandbox/server/internal/config"
+)
+
+func TestSessionHistoryValidation(t *testing.T) {
+	h := &SessionHandler{}
+
+	for name, target := range map[string]string{
+		"limit zero":     "/api/v1/auth/sessions/history?limit=0",
+		"limit too high": "/api/v1/auth/sessions/history?limit=1000",
+		"limit not int":  "/api/v1/auth/sessions/history?limit=x",
+		"bad cursor":     "/api/v1/auth/sessions/history?cursor=not-a-cursor",
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.SessionHistory(w, withAuthUser(httptest.NewRequest(http.MethodGet, target, nil), testUserID))
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestSessionHistoryRequiresAuth(t *testing.T) {
+	h := &SessionHandler{}
+	w := httptest.NewRecorder()
+	h.SessionHistory(w, httptest.NewRequest(http.MethodGet, "/api/v1/auth/sessions/history", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestLogSessionRefreshDisabled(t *testing.T) {
+	// With the session log off, refreshed sessions pass through untouched
+	// (a nil store would panic if written to).
+	called := false
+	mw := LogSessionRefresh(nil, &config.Config{}, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r = r.WithContext(context.WithValue(r.Context(), authUserKey, AuthUser{UserID: testUserID, SessionID: "s", Refreshed: true}))
+	mw.ServeHTTP(httptest.NewRecorder(), r)
+	if !called {
+		t.Fatal("next handler was not called")
+	}
+}
@@ -0,0 +1,341 @@
+package handler
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/system-design-sandbox/server/internal/archdata"
+	"github.com/system-design-sandbox/server/internal/config"
+	"github.com/system-design-sandbox/server/internal/model"
+	"github.com/system-design-sandbox/server/internal/storage"
+)
+
+//go:embed templates/share.html templates/snapshot.html
+var shareFS embed.FS
+
+var shareFuncs = template.FuncMap{
+	"join": strings.Join,
+	"px":   func(v float64) string { return fmt.Sprintf("%.0fpx", v) },
+	"num":  func(v float64) string { return fmt.Sprintf("%.0f", v) },
+}
+
+var (
+	shareTmpl    = template.Must(template.New("share.html").Funcs(shareFuncs).ParseFS(shareFS, "templates/share.html"))
+	snapshotTmpl = template.Must(template.New("snapshot.html").Funcs(shareFuncs).ParseFS(shareFS, "templates/snapshot.html"))
+)
+
+// ShareHandler serves public share links (/s/{slug}) for architectures marked is_public.
+type ShareHandler struct {
+	Store  *storage.Storage
+	Config *config.Config
+}
+
+type sharePageData struct {
+	Title       string
+	Description string
+	Tags        []string
+	URL         string
+	ImageURL    string
+	AppURL      string
+	SnapshotURL string
+}
+
+type snapshotBox struct {
+	X, Y, W, H    float64
+	Label         string
+	Icon          string
+	ComponentType string
+}
+
+type snapshotEdge struct {
+	Path string
+}
+
+type snapshotPageData struct {
+	Title       string
+	Description string
+	AppURL      string
+	Width       float64
+	Height      float64
+	Containers  []snapshotBox
+	Nodes       []snapshotBox
+	Edges       []snapshotEdge
+}
+
+// Page handles GET /s/{slug} — OG/Twitter meta for link unfurling bots,
+// browsers are redirected to the SPA.
+func (h *ShareHandler) Page(w http.ResponseWriter, r *http.Request) {
+	arch, ok := h.loadPublic(w, r)
+	if !ok {
+		return
+	}
+
+	// Bots and browsers get different responses from the same URL.
+	w.Header().Set("Vary", "User-Agent")
+	if !isLinkPreviewBot(r.UserAgent()) {
+		http.Redirect(w, r, h.appURL(arch.Slug), http.StatusFound)
+		return
+	}
+
+	schema, err := archdata.Parse(arch.RawData)
+	if err != nil {
+		schema = &archdata.Schema{}
+	}
+
+	data := sharePageData{
+		Title:       arch.Name,
+		Description: shareDescription(arch, schema),
+		Tags:        arch.Tags,
+		URL:         h.shareURL(arch.Slug),
+		ImageURL:    h.imageURL(arch.ThumbnailURL),
+		AppURL:      h.appURL(arch.Slug),
+		SnapshotURL: h.shareURL(arch.Slug) + "/snapshot",
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if err := shareTmpl.Execute(w, data); err != nil {
+		slog.Error("share: template render failed", "slug", arch.Slug, "error", err)
+	}
+}
+
+// Snapshot handles GET /s/{slug}/snapshot — a static HTML+CSS rendering of
+// the diagram that works without JavaScript.
+func (h *ShareHandler) Snapshot(w http.ResponseWriter, r *http.Request) {
+	arch, ok := h.loadPublic(w, r)
+	if !ok {
+		return
+	}
+
+	schema, err := archdata.Parse(arch.RawData)
+	if err != nil {
+		http.Error(w, "architecture data is corrupted", http.StatusUnprocessableEntity)
+		return
+	}
+
+	data := buildSnapshot(schema)
+	data.Title = arch.Name
+	data.Description = arch.Description
+	data.AppURL = h.appURL(arch.Slug)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if err := snapshotTmpl.Execute(w, data); err != nil {
+		slog.Error("share: snapshot render failed", "slug", arch.Slug, "error", err)
+	}
+}
+
+// Thumbnail handles GET /s/{slug}/thumbnail.svg — the rendered preview of
+// a public architecture, used as its og:image.
+func (h *ShareHandler) Thumbnail(w http.ResponseWriter, r *http.Request) {
+	svg, err := h.Store.GetPublicThumbnail(r.Context(), chi.URLParam(r, "slug"))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "failed to load thumbnail", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	_, _ = w.Write(svg)
+}
+
+// Get handles GET /api/v1/shared/{slug} — the public architecture JSON used
+// by the SPA to open a share link.
+func (h *ShareHandler) Get(w http.ResponseWriter, r *http.Request) {
+	arch, err := h.Store.GetPublicArchitectureBySlug(r.Context(), chi.URLParam(r, "slug"))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "architecture not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to get architecture")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, arch)
+}
+
+func (h *ShareHandler) loadPublic(w http.ResponseWriter, r *http.Request) (model.Architecture, bool) {
+	arch, err := h.Store.GetPublicArchitectureBySlug(r.Context(), chi.URLParam(r, "slug"))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			http.Error(w, "architecture not found", http.StatusNotFound)
+			return model.Architecture{}, false
+		}
+		http.Error(w, "failed to load architecture", http.StatusInternalServerError)
+		return model.Architecture{}, false
+	}
+	return arch, true
+}
+
+func (h *ShareHandler) shareURL(slug string) string {
+	return strings.TrimRight(h.Config.PublicURL, "/") + "/s/" + url.PathEscape(slug)
+}
+
+func (h *ShareHandler) appURL(slug string) string {
+	return strings.TrimRight(h.Config.PublicURL, "/") + "/?shared=" + url.QueryEscape(slug)
+}
+
+// imageURL makes a stored thumbnail URL absolute; crawlers ignore relative og:image values.
+func (h *ShareHandler) imageURL(thumbnail *string) string {
+	if thumbnail == nil || *thumbnail == "" {
+		return ""
+	}
+	if strings.HasPrefix(*thumbnail, "http://") || strings.HasPrefix(*thumbnail, "https://") {
+		return *thumbnail
+	}
+	return strings.TrimRight(h.Config.PublicURL, "/") + "/" + strings.TrimLeft(*thumbnail, "/")
+}
+
+// shareDescription falls back to a component summary when the author left no description.
+func shareDescription(arch model.Architecture, schema *archdata.Schema) string {
+	if d := strings.TrimSpace(arch.Description); d != "" {
+		return d
+	}
+	counts := make(map[string]int)
+	for _, n := range schema.Nodes {
+		if n.Data.ComponentType != "" && !archdata.IsContainer(n.Data.ComponentType) {
+			counts[n.Data.ComponentType]++
+		}
+	}
+	if len(counts) == 0 {
+		return "System design architecture diagram"
+	}
+	types := make([]string, 0, len(counts))
+	for t := range counts {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if counts[types[i]] != counts[types[j]] {
+			return counts[types[i]] > counts[types[j]]
+		}
+		return types[i] < types[j]
+	})
+	if len(types) > 5 {
+		types = types[:5]
+	}
+	parts := make([]string, len(types))
+	for i, t := range types {
+		parts[i] = fmt.Sprintf("%d× %s", counts[t], t)
+	}
+	return fmt.Sprintf("Architecture with %d components: %s", len(schema.Nodes), strings.Join(parts, ", "))
+}
+
+// isLinkPreviewBot reports whether the request comes from a link unfurler
+// (Slack, Telegram, Twitter, ...) rather than a browser.
+func isLinkPreviewBot(ua string) bool {
+	lower := strings.ToLower(ua)
+	if !strings.Contains(lower, "mozilla/") {
+		return true
+	}
+	for _, marker := range []string{"bot", "crawler", "spider", "facebookexternalhit", "whatsapp", "embedly", "preview"} {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+const snapshotPadding = 40
+
+// buildSnapshot lays out nodes in absolute canvas coordinates. Containers are
+// emitted outermost first so nested boxes stack correctly.
+func buildSnapshot(s *archdata.Schema) snapshotPageData {
+	type placed struct {
+		box       snapshotBox
+		depth     int
+		container bool
+	}
+
+	items := make([]placed, 0, len(s.Nodes))
+	centers := make(map[string][4]float64, len(s.Nodes))
+	minX, minY := 0.0, 0.0
+	maxX, maxY := 0.0, 0.0
+	for i := range s.Nodes {
+		n := &s.Nodes[i]
+		pos := s.AbsolutePosition(n)
+		size := n.Dimensions()
+		if i == 0 || pos.X < minX {
+			minX = pos.X
+		}
+		if i == 0 || pos.Y < minY {
+			minY = pos.Y
+		}
+		if i == 0 || pos.X+size.Width > maxX {
+			maxX = pos.X + size.Width
+		}
+		if i == 0 || pos.Y+size.Height > maxY {
+			maxY = pos.Y + size.Height
+		}
+		items = append(items, placed{
+			box: snapshotBox{
+				X: pos.X, Y: pos.Y, W: size.Width, H: size.Height,
+				Label:         n.Data.Label,
+				Icon:          n.Data.Icon,
+				ComponentType: n.Data.ComponentType,
+			},
+			depth:     nodeDepth(s, n),
+			container: archdata.IsContainer(n.Data.ComponentType),
+		})
+		centers[n.ID] = [4]float64{pos.X, pos.Y, size.Width, size.Height}
+	}
+
+	offX, offY := snapshotPadding-minX, snapshotPadding-minY
+	out := snapshotPageData{
+		Width:  maxX - minX + 2*snapshotPadding,
+		Height: maxY - minY + 2*snapshotPadding,
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].depth < items[j].depth })
+	for _, it := range items {
+		it.box.X += offX
+		it.box.Y += offY
+		if it.container {
+			out.Containers = append(out.Containers, it.box)
+		} else {
+			out.Nodes = append(out.Nodes, it.box)
+		}
+	}
+
+	for _, e := range s.Edges {
+		src, ok1 := centers[e.Source]
+		dst, ok2 := centers[e.Target]
+		if !ok1 || !ok2 {
+			continue
+		}
+		sx, sy := src[0]+src[2]/2+offX, src[1]+src[3]+offY
+		tx, ty := dst[0]+dst[2]/2+offX, dst[1]+offY
+		my := (sy + ty) / 2
+		out.Edges = append(out.Edges, snapshotEdge{
+			Path: fmt.Sprintf("M%.0f %.0f C%.0f %.0f, %.0f %.0f, %.0f %.0f", sx, sy, sx, my, tx, my, tx, ty),
+		})
+	}
+	return out
+}
+
+func nodeDepth(s *archdata.Schema, n *archdata.Node) int {
+	depth := 0
+	seen := map[string]bool{n.ID: true}
+	for parentID := n.ParentID; parentID != "" && !seen[parentID]; depth++ {
+		seen[parentID] = true
+		p := s.NodeByID(parentID)
+		if p == nil {
+			break
+		}
+		parentID = p.ParentID
+	}
+	return depth
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/system-design-sandbox/server/internal/archdata"
+	"github.com/system-design-sandbox/server/internal/config"
+	"github.com/system-design-sandbox/server/internal/model"
+)
+
+func TestIsLinkPreviewBot(t *testing.T) {
+	tests := []struct {
+		ua   string
+		want bool
+	}{
+		{"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", true},
+		{"TelegramBot (like TwitterBot)", true},
+		{"Twitterbot/1.0", true},
+		{"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)", true},
+		{"Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)", true},
+		{"", true},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15", false},
+		{"Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0", false},
+	}
+	for _, tc := range tests {
+		if got := isLinkPreviewBot(tc.ua); got != tc.want {
+			t.Errorf("isLinkPreviewBot(%q) = %v, want %v", tc.ua, got, tc.want)
+		}
+	}
+}
+
+func TestBuildSnapshotResolvesNestedPositions(t *testing.T) {
+	schema, err := archdata.Parse([]byte(`{
+		"version": "1.0",
+		"nodes": [
+			{"id": "dc", "position": {"x": 100, "y": 100}, "data": {"label": "DC", "componentType": "datacenter"}},
+			{"id": "svc", "parentId": "dc", "position": {"x": 20, "y": 30}, "width": 200, "height": 80, "data": {"label": "API", "componentType": "service", "icon": "⚙️"}},
+			{"id": "db", "position": {"x": 600, "y": 500}, "data": {"label": "DB", "componentType": "postgresql"}}
+		],
+		"edges": [{"id": "e1", "source": "svc", "target": "db"}]
+	}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	view := buildSnapshot(schema)
+
+	if len(view.Containers) != 1 || len(view.Nodes) != 2 {
+		t.Fatalf("expected 1 container and 2 nodes, got %d and %d", len(view.Containers), len(view.Nodes))
+	}
+	if view.Containers[0].X != snapshotPadding || view.Containers[0].Y != snapshotPadding {
+		t.Errorf("container should start at padding, got (%v, %v)", view.Containers[0].X, view.Containers[0].Y)
+	}
+	svc := view.Nodes[0]
+	if svc.Label != "API" {
+		svc = view.Nodes[1]
+	}
+	if svc.X != snapshotPadding+20 || svc.Y != snapshotPadding+30 {
+		t.Errorf("nested node should be offset by its parent, got (%v, %v)", svc.X, svc.Y)
+	}
+	if len(view.Edges) != 1 || !strings.HasPrefix(view.Edges[0].Path, "M") {
+		t.Fatalf("expected one edge path, got %+v", view.Edges)
+	}
+	// db: 600+280 wide, 500+90 tall, relative to dc at 100,100
+	if view.Width != 780+2*snapshotPadding || view.Height != 490+2*snapshotPadding {
+		t.Errorf("unexpected canvas size %vx%v", view.Width, view.Height)
+	}
+
+	var buf bytes.Buffer
+	if err := snapshotTmpl.Execute(&buf, view); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	body := buf.String()
+	if strings.Contains(body, "ZgotmplZ") {
+		t.Error("snapshot contains values rejected by html/template")
+	}
+	if strings.Contains(body, "<script") {
+		t.Error("snapshot must not contain scripts")
+	}
+	if !strings.Contains(body, "left:60px;top:70px;width:200px;height:80px;") {
+		t.Error("snapshot does not position the nested node")
+	}
+}
+
+func TestSharePageTemplateEmitsMetaTags(t *testing.T) {
+	thumb := "/thumbs/abc.png"
+	h := &ShareHandler{Config: &config.Config{PublicURL: "https://example.com/"}}
+	arch := model.Architecture{Name: "Chat <backend>", Slug: "abc", Tags: []string{"chat", "kafka"}}
+	data := sharePageData{
+		Title:       arch.Name,
+		Description: shareDescription(arch, &archdata.Schema{}),
+		Tags:        arch.Tags,
+		URL:         h.shareURL(arch.Slug),
+		ImageURL:    h.imageURL(&thumb),
+		AppURL:      h.appURL(arch.Slug),
+	}
+
+	var buf bytes.Buffer
+	if err := shareTmpl.Execute(&buf, data); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	body := buf.String()
+
+	for _, want := range []string{
+		`<meta property="og:title" content="Chat &lt;backend&gt;">`,
+		`<meta property="og:url" content="https://example.com/s/abc">`,
+		`<meta property="og:image" content="https://example.com/thumbs/abc.png">`,
+		`<meta name="twitter:card" content="summary_large_image">`,
+		`<meta property="article:tag" content="kafka">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body does not contain %s", want)
+		}
+	}
+}
+
+func TestShareDescriptionSummarizesComponents(t *testing.T) {
+	schema := &archdata.Schema{Nodes: []archdata.Node{
+		{ID: "1", Data: archdata.NodeData{ComponentType: "service"}},
+		{ID: "2", Data: archdata.NodeData{ComponentType: "service"}},
+		{ID: "3", Data: archdata.NodeData{ComponentType: "redis"}},
+	}}
+
+	got := shareDescription(model.Architecture{}, schema)
+	want := "Architecture with 3 components: 2× service, 1× redis"
+	if got != want {
+		t.Errorf("shareDescription() = %q, want %q", got, want)
+	}
+
+	got = shareDescription(model.Architecture{Description: "  My design "}, schema)
+	if got != "My design" {
+		t.Errorf("explicit description should win, got %q", got)
+	}
+}
@@ -2,11 +2,15 @@ package handler
 
 import (
 	"encoding/json"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
+	"github.com/system-design-sandbox/server/internal/analysis"
+	"github.com/system-design-sandbox/server/internal/archdata"
 	"github.com/system-design-sandbox/server/internal/storage"
 )
 
@@ -48,8 +52,48 @@ func (h *SimulationHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := h.Store.CreateSimulationResultForUser(r.Context(), archID, userID, req.ScenarioID, req.Score, req.Report, req.Metrics, req.DurationSec)
+	arch, err := h.Store.GetArchitectureForUser(r.Context(), archID, userID)
 	if err != nil {
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "architecture not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to get architecture")
+		return
+	}
+	// Stored data that cannot be parsed still gets a result, just without
+	// analysis or cost; the simulation ran in the browser on its own copy.
+	schema, parseErr := archdata.Parse(arch.RawData)
+
+	if req.ScenarioID != nil {
+		if requirement := scenarioRequirement(r.Context(), h.Store, *req.ScenarioID); requirement != nil {
+			if parseErr != nil {
+				writeError(w, http.StatusUnprocessableEntity, "invalid_data", "architecture data cannot be parsed")
+				return
+			}
+			if violations := requirement.Violations(analysis.Analyze(schema, nil)); len(violations) > 0 {
+				writeError(w, http.StatusUnprocessableEntity, "analysis_failed",
+					fmt.Sprintf("scenario requires a clean analysis report; %d blocking findings (first: %s)", len(violations), violations[0].Message))
+				return
+			}
+		}
+	}
+
+	var costJSON json.RawMessage
+	var monthlyCost *float64
+	if parseErr == nil {
+		if est, err := estimateCost(schema, ""); err != nil {
+			slog.Error("simulation: cost estimate failed", "error", err)
+		} else if costJSON, err = json.Marshal(est); err == nil {
+			monthlyCost = &est.MonthlyTotal
+		}
+	}
+
+	result, err := h.Store.CreateSimulationResultForUser(r.Context(), archID, userID, req.ScenarioID, req.Score, req.Report, req.Metrics, req.DurationSec, costJSON, monthlyCost)
+	if err != nil {
+		if writeQuotaError(w, err) {
+			return
+		}
 		if err == pgx.ErrNoRows {
 			writeError(w, http.StatusNotFound, "not_found", "architecture not found")
 			return
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/system-design-sandbox/server/internal/storage"
+)
+
+// Trash handles GET /api/v1/architectures/trash — deleted architectures
+// the user can restore or purge, most recently deleted first.
+func (h *ArchitectureHandler) Trash(w http.ResponseWriter, r *http.Request) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	userID, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+		return
+	}
+
+	items, err := h.Store.ListTrashForUser(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "failed to list trash")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+// Restore handles POST /api/v1/architectures/{id}/restore.
+func (h *ArchitectureHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	id, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid id")
+		return
+	}
+
+	userID, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+		return
+	}
+
+	arch, err := h.Store.RestoreArchitectureForUser(r.Context(), id, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrForbidden) {
+			writeError(w, http.StatusForbidden, "forbidden", "only workspace admins can restore this architecture")
+			return
+		}
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "architecture not found in trash")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to restore architecture")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, arch)
+}
+
+// Purge handles DELETE /api/v1/architectures/trash/{id} — deletes an
+// architecture from the trash for good, with its simulation results.
+func (h *ArchitectureHandler) Purge(w http.ResponseWriter, r *http.Request) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	id, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid id")
+		return
+	}
+
+	userID, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+		return
+	}
+
+	if err := h.Store.PurgeArchitectureForUser(r.Context(), id, userID); err != nil {
+		if errors.Is(err, storage.ErrForbidden) {
+			writeError(w, http.StatusForbidden, "forbidden", "only workspace admins can purge this architecture")
+			return
+		}
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "architecture not found in trash")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to purge architecture")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
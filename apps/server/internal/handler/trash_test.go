@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrashHandlerBadID(t *testing.T) {
+	h := &ArchitectureHandler{}
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		run     func(http.ResponseWriter, *http.Request)
+	}{
+		{
+			name:    "restore",
+			request: withURLParam(httptest.NewRequest(http.MethodPost, "/architectures/x/restore", nil), "id", "x"),
+			run:     h.Restore,
+		},
+		{
+			name:    "purge",
+			request: withURLParam(httptest.NewRequest(http.MethodDelete, "/architectures/trash/x", nil), "id", "x"),
+			run:     h.Purge,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tc.run(w, withAuthUser(tc.request, testUserID))
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
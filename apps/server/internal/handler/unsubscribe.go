@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"embed"
+	"html/template"
+	"log/slog"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/system-design-sandbox/server/internal/auth"
+	"github.com/system-design-sandbox/server/internal/config"
+	"github.com/system-design-sandbox/server/internal/storage"
+)
+
+//go:embed templates/unsubscribe.html
+var unsubscribeFS embed.FS
+
+var unsubscribeTmpl = template.Must(template.ParseFS(unsubscribeFS, "templates/unsubscribe.html"))
+
+// unsubscribeListNames describes each optional email on the unsubscribe page.
+var unsubscribeListNames = map[string]string{
+	auth.TemplateCommentMention: "emails when someone mentions you in a comment",
+	auth.TemplateNewDevice:      "alerts about sign-ins from a new device or country",
+}
+
+type unsubscribePageData struct {
+	ListName  string
+	Done      bool
+	Error     string
+	PublicURL string
+}
+
+type EmailHandler struct {
+	Store  *storage.Storage
+	Config *config.Config
+}
+
+// Unsubscribe handles /api/v1/email/unsubscribe?token=&list= — the link in
+// optional emails. GET shows a confirmation page, since mail scanners
+// follow links; POST unsubscribes, both from that page and as the RFC 8058
+// one-click request mail clients send for List-Unsubscribe.
+func (h *EmailHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	data := unsubscribePageData{PublicURL: h.Config.PublicURL}
+	status := http.StatusOK
+
+	list := q.Get("list")
+	token, err := parseUUID(q.Get("token"))
+	switch {
+	case err != nil || !auth.ValidOptionalEmail(list):
+		status, data.Error = http.StatusBadRequest, "This unsubscribe link is invalid."
+	case r.Method == http.MethodPost:
+		data.ListName = unsubscribeListNames[list]
+		if _, err := h.Store.UnsubscribeEmail(r.Context(), token, list); err != nil {
+			if err == pgx.ErrNoRows {
+				status, data.Error = http.StatusNotFound, "This unsubscribe link is invalid."
+			} else {
+				slog.Error("email: unsubscribe failed", "list", list, "error", err)
+				status, data.Error = http.StatusInternalServerError, "Something went wrong. Please try again later."
+			}
+		} else {
+			data.Done = true
+		}
+	default:
+		data.ListName = unsubscribeListNames[list]
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := unsubscribeTmpl.Execute(w, data); err != nil {
+		slog.Error("email: render unsubscribe page failed", "error", err)
+	}
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/system-design-sandbox/server/internal/config"
+)
+
+func TestUnsubscribeRejectsBadLinks(t *testing.T) {
+	h := &EmailHandler{Config: &config.Config{PublicURL: "https://example.com"}}
+	const token = "0f8fad5b-d9cb-469f-a165-70867728950e"
+
+	for name, target := range map[string]string{
+		"bad token":          "/api/v1/email/unsubscribe?token=x&list=comment_mention",
+		"missing list":       "/api/v1/email/unsubscribe?token=" + token,
+		"transactional list": "/api/v1/email/unsubscribe?token=" + token + "&list=login",
+	} {
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			t.Run(name+"/"+method, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				h.Unsubscribe(w, httptest.NewRequest(method, target, nil))
+				if w.Code != http.StatusBadRequest {
+					t.Fatalf("status = %d, want 400", w.Code)
+				}
+				if !strings.Contains(w.Body.String(), "link is invalid") {
+					t.Error("page does not explain the error")
+				}
+			})
+		}
+	}
+}
+
+func TestUnsubscribePageAsksForConfirmation(t *testing.T) {
+	h := &EmailHandler{Config: &config.Config{PublicURL: "https://example.com"}}
+	w := httptest.NewRecorder()
+	h.Unsubscribe(w, httptest.NewRequest(http.MethodGet,
+		"/api/v1/email/unsubscribe?token=0f8fad5b-d9cb-469f-a165-70867728950e&list=comment_mention", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `<form method="post">`) || !strings.Contains(body, "mentions you in a comment") {
+		t.Errorf("expected a confirmation form, got %s", body)
+	}
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/system-design-sandbox/server/internal/storage"
+)
+
+type quotaDetails struct {
+	Quota string `json:"quota"`
+	Limit int64  `json:"limit"`
+}
+
+// writeQuotaError writes 403 quota_exceeded when err is a
+// *storage.QuotaError and reports whether it did.
+func writeQuotaError(w http.ResponseWriter, err error) bool {
+	var qe *storage.QuotaError
+	if !errors.As(err, &qe) {
+		return false
+	}
+	writeErrorDetails(w, http.StatusForbidden, "quota_exceeded", "your plan's "+qe.Quota+" quota is used up",
+		quotaDetails{Quota: qe.Quota, Limit: qe.Limit})
+	return true
+}
+
+// Usage handles GET /api/v1/users/me/usage — what the user has stored and
+// posted today, with their plan's limits.
+func (h *UserHandler) Usage(w http.ResponseWriter, r *http.Request) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	userID, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+		return
+	}
+
+	usage, err := h.Store.GetUsage(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "failed to get usage")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, usage)
+}
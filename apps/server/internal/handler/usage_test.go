@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/system-design-sandbox/server/internal/storage"
+)
+
+func TestWriteQuotaError(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := fmt.Errorf("save: %w", &storage.QuotaError{Quota: storage.QuotaStorageBytes, Limit: 1 << 20})
+	if !writeQuotaError(w, err) {
+		t.Fatal("writeQuotaError = false, want true")
+	}
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	var body struct {
+		Code    string       `json:"code"`
+		Details quotaDetails `json:"details"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&body)
+	if body.Code != "quota_exceeded" {
+		t.Errorf("code = %q, want quota_exceeded", body.Code)
+	}
+	if body.Details.Quota != storage.QuotaStorageBytes || body.Details.Limit != 1<<20 {
+		t.Errorf("details = %+v", body.Details)
+	}
+}
+
+func TestWriteQuotaErrorIgnoresOtherErrors(t *testing.T) {
+	w := httptest.NewRecorder()
+	if writeQuotaError(w, errors.New("boom")) {
+		t.Fatal("writeQuotaError = true, want false")
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("body = %q, want empty", w.Body.String())
+	}
+}
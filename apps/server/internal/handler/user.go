@@ -1,14 +1,15 @@
 package handler
 
 import (
-	"crypto/sha256"
 	"encoding/json"
-	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/auth"
+	"github.com/system-design-sandbox/server/internal/model"
 	"github.com/system-design-sandbox/server/internal/storage"
 )
 
@@ -96,6 +97,9 @@ type updateProfileRequest struct {
 	DisplayName     *string `json:"display_name"`
 	GravatarAllowed *bool   `json:"gravatar_allowed"`
 	ReferralSource  *string `json:"referral_source"`
+	Locale          *string `json:"locale"`
+	// EmailOptOut replaces the optional emails the user unsubscribed from.
+	EmailOptOut []string `json:"email_opt_out"`
 }
 
 // UpdateMe handles PATCH /api/v1/users/me — updates the authenticated user's profile.
@@ -112,10 +116,25 @@ func (h *UserHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Locale != nil && !auth.ValidLocale(*req.Locale) {
+		writeError(w, http.StatusBadRequest, "bad_request", "locale must be en or ru")
+		return
+	}
+	for _, list := range req.EmailOptOut {
+		if !auth.ValidOptionalEmail(list) {
+			writeErrorDetails(w, http.StatusBadRequest, "bad_request", "email_opt_out may only list optional emails", auth.OptionalEmails)
+			return
+		}
+	}
+	if req.EmailOptOut != nil {
+		slices.Sort(req.EmailOptOut)
+		req.EmailOptOut = slices.Compact(req.EmailOptOut)
+	}
+
 	var uid pgtype.UUID
 	_ = uid.Scan(authUser.UserID)
 
-	user, err := h.Store.UpdateUserProfile(r.Context(), uid, req.DisplayName, req.GravatarAllowed, req.ReferralSource)
+	user, err := h.Store.UpdateUserProfile(r.Context(), uid, req.DisplayName, req.GravatarAllowed, req.ReferralSource, req.Locale, req.EmailOptOut)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "internal", "failed to update profile")
 		return
@@ -161,6 +180,12 @@ func parseUUID(s string) (pgtype.UUID, error) {
 	return id, err
 }
 
+func formatUUID(id pgtype.UUID) string {
+	v, _ := id.Value()
+	s, _ := v.(string)
+	return s
+}
+
 // MaskEmail masks an email address for display: "user@example.com" → "us**@ex***le.com"
 func MaskEmail(email string) string {
 	parts := strings.SplitN(email, "@", 2)
@@ -188,6 +213,5 @@ func GravatarURL(email string, allowed bool) string {
 	if !allowed {
 		return ""
 	}
-	hash := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
-	return fmt.Sprintf("https://www.gravatar.com/avatar/%x?d=identicon&s=80", hash)
+	return model.GravatarURL(email)
 }
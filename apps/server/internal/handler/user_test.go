@@ -1,7 +1,10 @@
 package handler
 
 import (
+	"encoding/json"
 	"testing"
+
+	"github.com/system-design-sandbox/server/internal/model"
 )
 
 func TestMaskEmail(t *testing.T) {
@@ -51,4 +54,23 @@ func TestGravatarURL(t *testing.T) {
 			t.Errorf("expected same URL for case variants: %q vs %q", a, b)
 		}
 	})
+
+	t.Run("user JSON follows the email", func(t *testing.T) {
+		gravatar := func(u model.User) string {
+			b, _ := json.Marshal(u)
+			var out struct {
+				GravatarURL string `json:"gravatar_url"`
+			}
+			_ = json.Unmarshal(b, &out)
+			return out.GravatarURL
+		}
+		u := model.User{Email: "old@example.com", GravatarAllowed: true}
+		if got := gravatar(u); got != GravatarURL("old@example.com", true) {
+			t.Errorf("gravatar_url = %q", got)
+		}
+		u.Email = "new@example.com"
+		if got := gravatar(u); got != GravatarURL("new@example.com", true) {
+			t.Errorf("gravatar_url does not follow the email: %q", got)
+		}
+	})
 }
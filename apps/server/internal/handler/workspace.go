@@ -0,0 +1,577 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/auth"
+	"github.com/system-design-sandbox/server/internal/config"
+	"github.com/system-design-sandbox/server/internal/jobs"
+	"github.com/system-design-sandbox/server/internal/model"
+	"github.com/system-design-sandbox/server/internal/storage"
+)
+
+// workspaceInviteTTL is how long an emailed invitation stays valid.
+const workspaceInviteTTL = 7 * 24 * time.Hour
+
+type WorkspaceHandler struct {
+	Store     *storage.Storage
+	RedisAuth *auth.RedisAuth
+	Config    *config.Config
+}
+
+// workspaceWithRole fetches a workspace the user belongs to with at least
+// role min: 404 for non-members, 403 for members with a lower role.
+func workspaceWithRole(w http.ResponseWriter, r *http.Request, store *storage.Storage, id, userID pgtype.UUID, min string) (model.Workspace, bool) {
+	ws, err := store.GetWorkspaceForUser(r.Context(), id, userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "workspace not found")
+			return model.Workspace{}, false
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to get workspace")
+		return model.Workspace{}, false
+	}
+	if !model.RoleAtLeast(ws.Role, min) {
+		writeError(w, http.StatusForbidden, "forbidden", "requires the "+min+" role in this workspace")
+		return model.Workspace{}, false
+	}
+	return ws, true
+}
+
+// workspaceRequest reads the authenticated user and the {id} URL parameter,
+// then loads the workspace with at least role min.
+func (h *WorkspaceHandler) workspaceRequest(w http.ResponseWriter, r *http.Request, min string) (model.Workspace, pgtype.UUID, bool) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return model.Workspace{}, pgtype.UUID{}, false
+	}
+
+	id, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid id")
+		return model.Workspace{}, pgtype.UUID{}, false
+	}
+
+	userID, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+		return model.Workspace{}, pgtype.UUID{}, false
+	}
+
+	ws, ok := workspaceWithRole(w, r, h.Store, id, userID, min)
+	return ws, userID, ok
+}
+
+type workspaceRequest struct {
+	Name string `json:"name"`
+}
+
+func decodeWorkspaceName(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req workspaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid request body")
+		return "", false
+	}
+	name := strings.Join(strings.Fields(req.Name), " ")
+	if name == "" {
+		writeError(w, http.StatusBadRequest, "bad_request", "name is required")
+		return "", false
+	}
+	return name, true
+}
+
+// List handles GET /api/v1/workspaces — the user's workspaces with their role.
+func (h *WorkspaceHandler) List(w http.ResponseWriter, r *http.Request) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	userID, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+		return
+	}
+
+	workspaces, err := h.Store.ListWorkspacesByUser(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "failed to list workspaces")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, workspaces)
+}
+
+// Create handles POST /api/v1/workspaces. The creator becomes the owner.
+func (h *WorkspaceHandler) Create(w http.ResponseWriter, r *http.Request) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	userID, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+		return
+	}
+
+	name, ok := decodeWorkspaceName(w, r)
+	if !ok {
+		return
+	}
+
+	ws, err := h.Store.CreateWorkspace(r.Context(), userID, name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "failed to create workspace")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, ws)
+}
+
+func (h *WorkspaceHandler) Get(w http.ResponseWriter, r *http.Request) {
+	ws, _, ok := h.workspaceRequest(w, r, model.RoleViewer)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, ws)
+}
+
+// Update handles PUT /api/v1/workspaces/{id} — rename; admins and owners.
+func (h *WorkspaceHandler) Update(w http.ResponseWriter, r *http.Request) {
+	ws, _, ok := h.workspaceRequest(w, r, model.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	name, ok := decodeWorkspaceName(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.Store.RenameWorkspace(r.Context(), ws.ID, name); err != nil {
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "workspace not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to update workspace")
+		return
+	}
+
+	ws.Name = name
+	writeJSON(w, http.StatusOK, ws)
+}
+
+// Delete handles DELETE /api/v1/workspaces/{id}; owners only. The
+// workspace's architectures go back to the members who created them.
+func (h *WorkspaceHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	ws, _, ok := h.workspaceRequest(w, r, model.RoleOwner)
+	if !ok {
+		return
+	}
+
+	if err := h.Store.DeleteWorkspace(r.Context(), ws.ID); err != nil {
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "workspace not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to delete workspace")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Members handles GET /api/v1/workspaces/{id}/members.
+func (h *WorkspaceHandler) Members(w http.ResponseWriter, r *http.Request) {
+	ws, _, ok := h.workspaceRequest(w, r, model.RoleViewer)
+	if !ok {
+		return
+	}
+
+	members, err := h.Store.ListWorkspaceMembers(r.Context(), ws.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "failed to list members")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, members)
+}
+
+type memberRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// UpdateMember handles PUT /api/v1/workspaces/{id}/members/{userID}.
+// Admins manage editors and viewers; only owners grant or take away the
+// admin and owner roles.
+func (h *WorkspaceHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	ws, _, ok := h.workspaceRequest(w, r, model.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	memberID, err := parseUUID(chi.URLParam(r, "userID"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid user id")
+		return
+	}
+
+	var req memberRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid request body")
+		return
+	}
+	if !model.ValidRole(req.Role) {
+		writeError(w, http.StatusBadRequest, "bad_request", "role must be owner, admin, editor or viewer")
+		return
+	}
+
+	current, ok := h.memberRole(w, r, ws.ID, memberID)
+	if !ok {
+		return
+	}
+	if !canManageRole(ws.Role, current) || !canManageRole(ws.Role, req.Role) {
+		writeError(w, http.StatusForbidden, "forbidden", "only owners can change admin and owner roles")
+		return
+	}
+
+	if err := h.Store.SetWorkspaceMemberRole(r.Context(), ws.ID, memberID, req.Role); err != nil {
+		h.writeMemberError(w, err, "failed to update member")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"role": req.Role})
+}
+
+// RemoveMember handles DELETE /api/v1/workspaces/{id}/members/{userID}.
+// Any member may remove themselves; removing others follows the same rules
+// as changing their role.
+func (h *WorkspaceHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	ws, userID, ok := h.workspaceRequest(w, r, model.RoleViewer)
+	if !ok {
+		return
+	}
+
+	memberID, err := parseUUID(chi.URLParam(r, "userID"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid user id")
+		return
+	}
+
+	if memberID != userID {
+		if !model.RoleAtLeast(ws.Role, model.RoleAdmin) {
+			writeError(w, http.StatusForbidden, "forbidden", "requires the admin role in this workspace")
+			return
+		}
+		current, ok := h.memberRole(w, r, ws.ID, memberID)
+		if !ok {
+			return
+		}
+		if !canManageRole(ws.Role, current) {
+			writeError(w, http.StatusForbidden, "forbidden", "only owners can remove admins and owners")
+			return
+		}
+	}
+
+	if err := h.Store.RemoveWorkspaceMember(r.Context(), ws.ID, memberID); err != nil {
+		h.writeMemberError(w, err, "failed to remove member")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// canManageRole reports whether a member with role actor may assign or
+// revoke target. Owners manage every role, admins editors and viewers.
+func canManageRole(actor, target string) bool {
+	if actor == model.RoleOwner {
+		return true
+	}
+	return model.RoleAtLeast(actor, model.RoleAdmin) && !model.RoleAtLeast(target, model.RoleAdmin)
+}
+
+func (h *WorkspaceHandler) memberRole(w http.ResponseWriter, r *http.Request, workspaceID, memberID pgtype.UUID) (string, bool) {
+	role, err := h.Store.GetWorkspaceMemberRole(r.Context(), workspaceID, memberID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "member not found")
+			return "", false
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to get member")
+		return "", false
+	}
+	return role, true
+}
+
+func (h *WorkspaceHandler) writeMemberError(w http.ResponseWriter, err error, msg string) {
+	if errors.Is(err, storage.ErrLastOwner) {
+		writeError(w, http.StatusConflict, "last_owner", "a workspace must keep at least one owner")
+		return
+	}
+	if err == pgx.ErrNoRows {
+		writeError(w, http.StatusNotFound, "not_found", "member not found")
+		return
+	}
+	writeError(w, http.StatusInternalServerError, "internal", msg)
+}
+
+type inviteRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// Invites handles GET /api/v1/workspaces/{id}/invites — pending invites;
+// admins and owners.
+func (h *WorkspaceHandler) Invites(w http.ResponseWriter, r *http.Request) {
+	ws, _, ok := h.workspaceRequest(w, r, model.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	invites, err := h.Store.ListWorkspaceInvites(r.Context(), ws.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "failed to list invites")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, invites)
+}
+
+// Invite handles POST /api/v1/workspaces/{id}/invites. The invitee gets an
+// email with a single-use link; inviting the same address again replaces
+// the pending invite.
+func (h *WorkspaceHandler) Invite(w http.ResponseWriter, r *http.Request) {
+	ws, userID, ok := h.workspaceRequest(w, r, model.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	var req inviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid request body")
+		return
+	}
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	if req.Email == "" || !strings.Contains(req.Email, "@") {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid email")
+		return
+	}
+	if !model.ValidRole(req.Role) || req.Role == model.RoleOwner {
+		writeError(w, http.StatusBadRequest, "bad_request", "role must be admin, editor or viewer")
+		return
+	}
+	if !canManageRole(ws.Role, req.Role) {
+		writeError(w, http.StatusForbidden, "forbidden", "only owners can invite admins")
+		return
+	}
+
+	token, err := auth.GenerateToken()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "failed to create invite")
+		return
+	}
+	expiresAt := time.Now().Add(workspaceInviteTTL)
+
+	invite, err := h.Store.CreateWorkspaceInvite(r.Context(), ws.ID, userID, req.Email, req.Role, hashInviteToken(token), expiresAt)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "failed to create invite")
+		return
+	}
+
+	// The invitee reads the email in their own language when they already
+	// have an account, otherwise in the inviter's.
+	inviter, locale := "A teammate", auth.DefaultLocale
+	if u, err := h.Store.GetUser(r.Context(), userID); err == nil {
+		inviter, locale = u.Name, u.Locale
+		if u.DisplayName != nil && *u.DisplayName != "" {
+			inviter = *u.DisplayName
+		}
+	}
+	if u, err := h.Store.GetUserByEmail(r.Context(), req.Email); err == nil {
+		locale = u.Locale
+	}
+
+	msg, err := auth.WorkspaceInviteEmail(req.Email, locale, auth.WorkspaceInvite{
+		Workspace: ws.Name,
+		Inviter:   inviter,
+		Role:      req.Role,
+		Token:     token,
+		ExpiresAt: expiresAt,
+	}, h.Config.PublicURL)
+	if err == nil {
+		err = jobs.QueueEmail(r.Context(), h.Store, msg)
+	}
+	if err != nil {
+		slog.Error("workspace: queue invite email failed", "email", req.Email, "error", err)
+		if derr := h.Store.DeleteWorkspaceInvite(r.Context(), ws.ID, invite.ID); derr != nil {
+			slog.Error("workspace: delete unsent invite failed", "error", derr)
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to send the invitation email")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, invite)
+}
+
+// RevokeInvite handles DELETE /api/v1/workspaces/{id}/invites/{inviteID}.
+func (h *WorkspaceHandler) RevokeInvite(w http.ResponseWriter, r *http.Request) {
+	ws, _, ok := h.workspaceRequest(w, r, model.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	inviteID, err := parseUUID(chi.URLParam(r, "inviteID"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid invite id")
+		return
+	}
+
+	if err := h.Store.DeleteWorkspaceInvite(r.Context(), ws.ID, inviteID); err != nil {
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "invite not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to revoke invite")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AcceptInvite handles POST /api/v1/workspaces/invites/{token}/accept. The
+// signed-in user's email must match the invited address.
+func (h *WorkspaceHandler) AcceptInvite(w http.ResponseWriter, r *http.Request) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	userID, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+		return
+	}
+
+	token := chi.URLParam(r, "token")
+	if token == "" {
+		writeError(w, http.StatusBadRequest, "bad_request", "token is required")
+		return
+	}
+
+	ws, err := h.Store.AcceptWorkspaceInvite(r.Context(), hashInviteToken(token), userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "invite not found, expired or sent to another email")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to accept invite")
+		return
+	}
+
+	// Joining grants access to the workspace's architectures.
+	rotateSessionToken(w, r, h.RedisAuth, h.Config)
+
+	writeJSON(w, http.StatusOK, ws)
+}
+
+// Architectures handles GET /api/v1/workspaces/{id}/architectures with the
+// same query parameters as GET /architectures/mine; folder_id names one of
+// the workspace's folders.
+func (h *WorkspaceHandler) Architectures(w http.ResponseWriter, r *http.Request) {
+	ws, userID, ok := h.workspaceRequest(w, r, model.RoleViewer)
+	if !ok {
+		return
+	}
+
+	filter, ok := parseArchitectureFilter(w, r)
+	if !ok || !parseFolderFilter(w, r, &filter) {
+		return
+	}
+
+	filter.WorkspaceID = &ws.ID
+	writeArchitectureList(w, r, h.Store, userID, filter)
+}
+
+// hashInviteToken is what the database keeps of an invite token.
+func hashInviteToken(token string) string {
+	return auth.HashToken(token)
+}
+
+type moveToWorkspaceRequest struct {
+	WorkspaceID *string `json:"workspace_id"`
+}
+
+// MoveToWorkspace handles PUT /api/v1/architectures/{id}/workspace.
+// Moving needs the admin role over the architecture (or owning a personal
+// one) and the editor role in the target workspace. workspace_id null
+// makes it a personal architecture of the caller, within their quotas.
+func (h *ArchitectureHandler) MoveToWorkspace(w http.ResponseWriter, r *http.Request) {
+	authUser, ok := GetAuthUser(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "not authenticated")
+		return
+	}
+
+	id, err := parseUUID(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid id")
+		return
+	}
+
+	userID, err := parseUUID(authUser.UserID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid session user")
+		return
+	}
+
+	var req moveToWorkspaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid request body")
+		return
+	}
+
+	var workspaceID *pgtype.UUID
+	if req.WorkspaceID != nil {
+		wid, err := parseUUID(*req.WorkspaceID)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "bad_request", "invalid workspace_id")
+			return
+		}
+		if _, ok := workspaceWithRole(w, r, h.Store, wid, userID, model.RoleEditor); !ok {
+			return
+		}
+		workspaceID = &wid
+	}
+
+	arch, err := h.Store.MoveArchitectureToWorkspace(r.Context(), id, userID, workspaceID)
+	if err != nil {
+		if writeQuotaError(w, err) {
+			return
+		}
+		if errors.Is(err, storage.ErrForbidden) {
+			writeError(w, http.StatusForbidden, "forbidden", "only workspace admins can move this architecture")
+			return
+		}
+		if err == pgx.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not_found", "architecture not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", "failed to move architecture")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, arch)
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/system-design-sandbox/server/internal/model"
+)
+
+func TestWorkspaceHandlerValidation(t *testing.T) {
+	h := &WorkspaceHandler{}
+	ah := &ArchitectureHandler{}
+	const archID = "0195d5cc-c9de-7ac6-bf7a-b2a2376f5a1a"
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		run     func(http.ResponseWriter, *http.Request)
+	}{
+		{
+			name:    "create without name",
+			request: httptest.NewRequest(http.MethodPost, "/workspaces", bytes.NewBufferString(`{"name":" \n "}`)),
+			run:     h.Create,
+		},
+		{
+			name:    "get bad id",
+			request: withURLParam(httptest.NewRequest(http.MethodGet, "/workspaces/x", nil), "id", "x"),
+			run:     h.Get,
+		},
+		{
+			name:    "invite bad id",
+			request: withURLParam(httptest.NewRequest(http.MethodPost, "/workspaces/x/invites", bytes.NewBufferString(`{"email":"a@b.c","role":"editor"}`)), "id", "x"),
+			run:     h.Invite,
+		},
+		{
+			name:    "architecture in folder with bad workspace",
+			request: httptest.NewRequest(http.MethodPost, "/architectures", bytes.NewBufferString(`{"name":"n","folder_id":"`+archID+`","workspace_id":"nope","data":{"version":"1.0","nodes":[],"edges":[]}}`)),
+			run:     ah.Create,
+		},
+		{
+			name:    "move to bad workspace",
+			request: withURLParam(httptest.NewRequest(http.MethodPut, "/architectures/id/workspace", bytes.NewBufferString(`{"workspace_id":"nope"}`)), "id", archID),
+			run:     ah.MoveToWorkspace,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tc.run(w, withAuthUser(tc.request, testUserID))
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestCanManageRole(t *testing.T) {
+	tests := []struct {
+		actor, target string
+		want          bool
+	}{
+		{model.RoleOwner, model.RoleOwner, true},
+		{model.RoleOwner, model.RoleAdmin, true},
+		{model.RoleAdmin, model.RoleEditor, true},
+		{model.RoleAdmin, model.RoleViewer, true},
+		{model.RoleAdmin, model.RoleAdmin, false},
+		{model.RoleAdmin, model.RoleOwner, false},
+		{model.RoleEditor, model.RoleViewer, false},
+		{model.RoleViewer, model.RoleViewer, false},
+	}
+	for _, tc := range tests {
+		if got := canManageRole(tc.actor, tc.target); got != tc.want {
+			t.Errorf("canManageRole(%q, %q) = %v, want %v", tc.actor, tc.target, got, tc.want)
+		}
+	}
+}
+
+func TestHashInviteToken(t *testing.T) {
+	h := hashInviteToken("token")
+	if len(h) != 64 || h == "token" {
+		t.Fatalf("unexpected hash %q", h)
+	}
+	if hashInviteToken("token") != h {
+		t.Fatal("hash is not deterministic")
+	}
+}
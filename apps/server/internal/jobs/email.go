@@ -0,0 +1,106 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/auth"
+	"github.com/system-design-sandbox/server/internal/model"
+)
+
+// emailMaxAttempts bounds delivery of one email: with Backoff that is
+// about an hour and a half of retries.
+const emailMaxAttempts = 8
+
+// Outbox stores queued emails; *storage.Storage implements it.
+type Outbox interface {
+	EnqueueEmail(ctx context.Context, e model.OutboxEmail, job model.NewJob) (pgtype.UUID, error)
+	StartEmailAttempt(ctx context.Context, id pgtype.UUID) (model.OutboxEmail, error)
+	RecordEmailAttempt(ctx context.Context, id pgtype.UUID, status string, messageID, lastError *string) error
+}
+
+type emailPayload struct {
+	ID pgtype.UUID `json:"id"`
+}
+
+// QueueEmail adds msg to the outbox for delivery in the background.
+func QueueEmail(ctx context.Context, outbox Outbox, msg auth.Message) error {
+	e := model.OutboxEmail{
+		Template:  msg.Template,
+		Recipient: msg.To,
+		Subject:   msg.Subject,
+		Body:      msg.HTML,
+		Text:      msg.Text,
+	}
+	if msg.Unsubscribe != "" {
+		e.UnsubscribeURL = &msg.Unsubscribe
+	}
+	if !msg.ExpiresAt.IsZero() {
+		e.ExpiresAt = pgtype.Timestamptz{Time: msg.ExpiresAt, Valid: true}
+	}
+	_, err := outbox.EnqueueEmail(ctx, e, model.NewJob{Kind: KindEmailSend, MaxAttempts: emailMaxAttempts})
+	return err
+}
+
+// sendEmailHandler delivers the outbox email named by a KindEmailSend job.
+func sendEmailHandler(outbox Outbox, sender auth.EmailSender) HandlerFunc {
+	return func(ctx context.Context, payload json.RawMessage) error {
+		var p emailPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return Permanent(err)
+		}
+		return sendEmail(ctx, outbox, sender, p.ID)
+	}
+}
+
+// sendEmail makes one delivery attempt and records its outcome. Transient
+// failures are returned so the job retries; hard bounces and the last
+// attempt settle the email and dead-letter the job.
+func sendEmail(ctx context.Context, outbox Outbox, sender auth.EmailSender, id pgtype.UUID) error {
+	e, err := outbox.StartEmailAttempt(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Already settled, e.g. sent by a run whose lease ran out.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if e.ExpiresAt.Valid && !time.Now().Before(e.ExpiresAt.Time) {
+		return outbox.RecordEmailAttempt(ctx, id, model.EmailExpired, nil, nil)
+	}
+
+	msg := auth.Message{
+		Template: e.Template,
+		To:       e.Recipient,
+		Subject:  e.Subject,
+		Text:     e.Text,
+		HTML:     e.Body,
+	}
+	if e.UnsubscribeURL != nil {
+		msg.Unsubscribe = *e.UnsubscribeURL
+	}
+	messageID, err := sender.Send(msg)
+	if err == nil {
+		return outbox.RecordEmailAttempt(ctx, id, model.EmailSent, &messageID, nil)
+	}
+
+	status := model.EmailPending
+	switch {
+	case auth.IsHardBounce(err):
+		status = model.EmailBounced
+	case e.Attempts >= emailMaxAttempts:
+		status = model.EmailFailed
+	}
+	lastError := err.Error()
+	if rerr := outbox.RecordEmailAttempt(ctx, id, status, nil, &lastError); rerr != nil {
+		return rerr
+	}
+	if status != model.EmailPending {
+		return Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,173 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/auth"
+	"github.com/system-design-sandbox/server/internal/model"
+)
+
+// memOutbox is an in-memory Outbox holding a single email.
+type memOutbox struct {
+	email     *model.OutboxEmail
+	job       model.NewJob
+	messageID *string
+	lastError *string
+}
+
+func (o *memOutbox) EnqueueEmail(_ context.Context, e model.OutboxEmail, job model.NewJob) (pgtype.UUID, error) {
+	e.ID = pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	e.Status = model.EmailPending
+	o.email, o.job = &e, job
+	return e.ID, nil
+}
+
+func (o *memOutbox) StartEmailAttempt(_ context.Context, id pgtype.UUID) (model.OutboxEmail, error) {
+	if o.email == nil || o.email.ID != id || o.email.Status != model.EmailPending {
+		return model.OutboxEmail{}, pgx.ErrNoRows
+	}
+	o.email.Attempts++
+	return *o.email, nil
+}
+
+func (o *memOutbox) RecordEmailAttempt(_ context.Context, _ pgtype.UUID, status string, messageID, lastError *string) error {
+	o.email.Status = status
+	if messageID != nil {
+		o.messageID = messageID
+	}
+	o.lastError = lastError
+	return nil
+}
+
+// stubSender returns its errors in turn, then succeeds.
+type stubSender struct {
+	errs []error
+	sent []auth.Message
+}
+
+func (s *stubSender) Send(msg auth.Message) (string, error) {
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return "", err
+	}
+	s.sent = append(s.sent, msg)
+	return "<1@example.com>", nil
+}
+
+func queued(t *testing.T, msg auth.Message) (*memOutbox, pgtype.UUID) {
+	t.Helper()
+	o := &memOutbox{}
+	if err := QueueEmail(context.Background(), o, msg); err != nil {
+		t.Fatal(err)
+	}
+	if o.job.Kind != KindEmailSend || o.job.MaxAttempts != emailMaxAttempts {
+		t.Fatalf("unexpected job: %+v", o.job)
+	}
+	return o, o.email.ID
+}
+
+func TestSendEmailRetriesThenSends(t *testing.T) {
+	o, id := queued(t, auth.Message{Template: "login", To: "a@example.com", Subject: "Hi", Text: "code", HTML: "<p>code</p>"})
+	sender := &stubSender{errs: []error{errors.New("connection refused")}}
+	ctx := context.Background()
+
+	err := sendEmail(ctx, o, sender, id)
+	var perm permanentError
+	if err == nil || errors.As(err, &perm) {
+		t.Fatalf("first attempt should fail transiently, got %v", err)
+	}
+	if o.email.Status != model.EmailPending || o.lastError == nil {
+		t.Fatalf("status = %s, last error %v; want pending with error", o.email.Status, o.lastError)
+	}
+
+	if err := sendEmail(ctx, o, sender, id); err != nil {
+		t.Fatal(err)
+	}
+	if o.email.Status != model.EmailSent || o.messageID == nil || *o.messageID != "<1@example.com>" {
+		t.Fatalf("status = %s, message id %v; want sent", o.email.Status, o.messageID)
+	}
+	if len(sender.sent) != 1 || sender.sent[0].HTML != "<p>code</p>" || sender.sent[0].Text != "code" || sender.sent[0].To != "a@example.com" {
+		t.Fatalf("sent %+v", sender.sent)
+	}
+
+	// A rerun after a lost lease does not send twice.
+	if err := sendEmail(ctx, o, sender, id); err != nil || len(sender.sent) != 1 {
+		t.Fatalf("rerun: err %v, sent %d", err, len(sender.sent))
+	}
+}
+
+func TestSendEmailHardBounce(t *testing.T) {
+	o, id := queued(t, auth.Message{To: "nobody@example.com"})
+	sender := &stubSender{errs: []error{auth.Rejected(&textproto.Error{Code: 451, Msg: "try again later"})}}
+
+	if err := sendEmail(context.Background(), o, sender, id); err == nil {
+		t.Fatal("expected error")
+	}
+	if o.email.Status != model.EmailPending {
+		t.Fatalf("status = %s, want pending", o.email.Status)
+	}
+
+	sender.errs = []error{auth.Rejected(&textproto.Error{Code: 550, Msg: "no such user"})}
+	err := sendEmail(context.Background(), o, sender, id)
+	var perm permanentError
+	if !errors.As(err, &perm) {
+		t.Fatalf("bounce should be permanent, got %v", err)
+	}
+	if o.email.Status != model.EmailBounced {
+		t.Fatalf("status = %s, want bounced", o.email.Status)
+	}
+}
+
+func TestSendEmailLastAttemptFails(t *testing.T) {
+	o, id := queued(t, auth.Message{To: "a@example.com"})
+	o.email.Attempts = emailMaxAttempts - 1
+	sender := &stubSender{errs: []error{errors.New("timeout")}}
+
+	err := sendEmail(context.Background(), o, sender, id)
+	var perm permanentError
+	if !errors.As(err, &perm) || o.email.Status != model.EmailFailed {
+		t.Fatalf("err %v, status %s; want permanent and failed", err, o.email.Status)
+	}
+}
+
+func TestSendEmailExpired(t *testing.T) {
+	o, id := queued(t, auth.Message{To: "a@example.com", ExpiresAt: time.Now().Add(-time.Second)})
+	sender := &stubSender{}
+
+	if err := sendEmail(context.Background(), o, sender, id); err != nil {
+		t.Fatal(err)
+	}
+	if o.email.Status != model.EmailExpired || len(sender.sent) != 0 {
+		t.Fatalf("status = %s, sent %d; want expired and nothing sent", o.email.Status, len(sender.sent))
+	}
+}
+
+func TestSendEmailHandlerRejectsBadPayload(t *testing.T) {
+	h := sendEmailHandler(&memOutbox{}, &stubSender{})
+	err := h(context.Background(), json.RawMessage(`"nope"`))
+	var perm permanentError
+	if !errors.As(err, &perm) {
+		t.Fatalf("want permanent error, got %v", err)
+	}
+}
+
+func TestSendEmailKeepsUnsubscribeLink(t *testing.T) {
+	const link = "https://example.com/api/v1/email/unsubscribe?list=comment_mention&token=t"
+	o, id := queued(t, auth.Message{Template: "comment_mention", To: "a@example.com", Subject: "Hi", HTML: "<p>hi</p>", Unsubscribe: link})
+	sender := &stubSender{}
+
+	if err := sendEmail(context.Background(), o, sender, id); err != nil {
+		t.Fatal(err)
+	}
+	if len(sender.sent) != 1 || sender.sent[0].Unsubscribe != link {
+		t.Fatalf("sent %+v", sender.sent)
+	}
+}
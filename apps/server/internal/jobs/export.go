@@ -0,0 +1,165 @@
+package jobs
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/auth"
+	"github.com/system-design-sandbox/server/internal/model"
+)
+
+const (
+	// exportTTL is how long a data export can be downloaded.
+	exportTTL = 7 * 24 * time.Hour
+	// exportMaxAttempts bounds the tries at building one export.
+	exportMaxAttempts = 3
+	// exportLogPage is the session log page size read into an export.
+	exportLogPage = 500
+)
+
+// Exports stores data exports and reads the data that goes into them;
+// *storage.Storage implements it.
+type Exports interface {
+	Outbox
+	CreateUserExport(ctx context.Context, userID pgtype.UUID, ttl time.Duration, job model.NewJob) (model.UserExport, error)
+	GetUserExport(ctx context.Context, id pgtype.UUID) (model.UserExport, error)
+	CompleteUserExport(ctx context.Context, id pgtype.UUID, data []byte, tokenHash string, expiresAt time.Time) error
+	FailUserExport(ctx context.Context, id pgtype.UUID) error
+	GetUser(ctx context.Context, id pgtype.UUID) (model.User, error)
+	ExportArchitectures(ctx context.Context, userID pgtype.UUID, fn func(model.Architecture) error) error
+	ListSimulationResultsByUser(ctx context.Context, userID pgtype.UUID) ([]model.SimulationResult, error)
+	ListSessionLogs(ctx context.Context, userID pgtype.UUID, cursor string, limit int) ([]model.SessionLogEntry, string, error)
+}
+
+type exportPayload struct {
+	ID pgtype.UUID `json:"id"`
+}
+
+// QueueUserExport records a data export request of the user and queues
+// the job that builds it and emails the download link.
+func QueueUserExport(ctx context.Context, exports Exports, userID pgtype.UUID) (model.UserExport, error) {
+	return exports.CreateUserExport(ctx, userID, exportTTL, model.NewJob{Kind: KindUserExport, MaxAttempts: exportMaxAttempts})
+}
+
+// userExportHandler builds the export named by a KindUserExport job,
+// stores it under a fresh download token and emails the token's link.
+func userExportHandler(exports Exports, publicURL string) HandlerFunc {
+	return func(ctx context.Context, payload json.RawMessage) error {
+		var p exportPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return Permanent(err)
+		}
+		e, err := exports.GetUserExport(ctx, p.ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Expired before its turn, or the account is gone.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if e.Status != model.ExportPending {
+			return nil
+		}
+		user, err := exports.GetUser(ctx, e.UserID)
+		if err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+		if err := writeExport(ctx, exports, user, &buf); err != nil {
+			return err
+		}
+		token, err := auth.GenerateToken()
+		if err != nil {
+			return err
+		}
+		expiresAt := time.Now().Add(exportTTL)
+		msg, err := auth.DataExportEmail(user.Email, user.Locale, token, expiresAt, publicURL)
+		if err != nil {
+			_ = exports.FailUserExport(ctx, e.ID)
+			return Permanent(err)
+		}
+
+		if err := exports.CompleteUserExport(ctx, e.ID, buf.Bytes(), auth.HashToken(token), expiresAt); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+		if err := QueueEmail(ctx, exports, msg); err != nil {
+			// The token is gone with this run; let the user ask again.
+			_ = exports.FailUserExport(ctx, e.ID)
+			return Permanent(err)
+		}
+		slog.Info("jobs: data export ready", "user_id", user.ID, "bytes", buf.Len())
+		return nil
+	}
+}
+
+// writeExport writes the zip of a user's data to w: profile.json, one
+// architectures/<id>.json per architecture with its data decompressed,
+// simulation_results.json and session_log.json.
+func writeExport(ctx context.Context, exports Exports, user model.User, w io.Writer) error {
+	zw := zip.NewWriter(w)
+	now := time.Now()
+	add := func(name string, v any) error {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	if err := add("profile.json", user); err != nil {
+		return err
+	}
+
+	err := exports.ExportArchitectures(ctx, user.ID, func(a model.Architecture) error {
+		id, _ := a.ID.Value()
+		name, _ := id.(string)
+		return add("architectures/"+name+".json", a)
+	})
+	if err != nil {
+		return err
+	}
+
+	results, err := exports.ListSimulationResultsByUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if results == nil {
+		results = []model.SimulationResult{}
+	}
+	if err := add("simulation_results.json", results); err != nil {
+		return err
+	}
+
+	logs := []model.SessionLogEntry{}
+	cursor := ""
+	for {
+		page, next, err := exports.ListSessionLogs(ctx, user.ID, cursor, exportLogPage)
+		if err != nil {
+			return err
+		}
+		logs = append(logs, page...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if err := add("session_log.json", logs); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
@@ -0,0 +1,138 @@
+package jobs
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/auth"
+	"github.com/system-design-sandbox/server/internal/model"
+)
+
+// memExports is an in-memory Exports holding a single export.
+type memExports struct {
+	memOutbox
+	export    model.UserExport
+	tokenHash string
+	logs      []model.SessionLogEntry
+}
+
+func (m *memExports) CreateUserExport(_ context.Context, userID pgtype.UUID, ttl time.Duration, _ model.NewJob) (model.UserExport, error) {
+	m.export = model.UserExport{ID: pgtype.UUID{Bytes: [16]byte{2}, Valid: true}, UserID: userID, Status: model.ExportPending}
+	return m.export, nil
+}
+
+func (m *memExports) GetUserExport(_ context.Context, id pgtype.UUID) (model.UserExport, error) {
+	if m.export.ID != id {
+		return model.UserExport{}, pgx.ErrNoRows
+	}
+	return m.export, nil
+}
+
+func (m *memExports) CompleteUserExport(_ context.Context, _ pgtype.UUID, data []byte, tokenHash string, expiresAt time.Time) error {
+	m.export.Status, m.export.Data, m.tokenHash = model.ExportReady, data, tokenHash
+	return nil
+}
+
+func (m *memExports) FailUserExport(context.Context, pgtype.UUID) error {
+	m.export.Status = model.ExportFailed
+	return nil
+}
+
+func (m *memExports) GetUser(_ context.Context, id pgtype.UUID) (model.User, error) {
+	return model.User{ID: id, Email: "user@example.com", Name: "Ann", Locale: auth.LocaleEN}, nil
+}
+
+func (m *memExports) ExportArchitectures(_ context.Context, userID pgtype.UUID, fn func(model.Architecture) error) error {
+	return fn(model.Architecture{
+		ID:      pgtype.UUID{Bytes: [16]byte{3}, Valid: true},
+		UserID:  userID,
+		Name:    "Chat backend",
+		RawData: json.RawMessage(`{"nodes":[{"id":"db"}]}`),
+	})
+}
+
+func (m *memExports) ListSimulationResultsByUser(context.Context, pgtype.UUID) ([]model.SimulationResult, error) {
+	return nil, nil
+}
+
+// ListSessionLogs serves the entries one per page.
+func (m *memExports) ListSessionLogs(_ context.Context, _ pgtype.UUID, cursor string, _ int) ([]model.SessionLogEntry, string, error) {
+	i := len(cursor)
+	if i >= len(m.logs) {
+		return nil, "", nil
+	}
+	next := ""
+	if i+1 < len(m.logs) {
+		next = strings.Repeat("x", i+1)
+	}
+	return m.logs[i : i+1], next, nil
+}
+
+func TestUserExportHandler(t *testing.T) {
+	ctx := context.Background()
+	m := &memExports{logs: []model.SessionLogEntry{{SessionID: "s1", Action: "login"}, {SessionID: "s1", Action: "logout"}}}
+	e, err := QueueUserExport(ctx, m, pgtype.UUID{Bytes: [16]byte{1}, Valid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, _ := json.Marshal(exportPayload{ID: e.ID})
+	h := userExportHandler(m, "https://example.com")
+	if err := h(ctx, payload); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.export.Status != model.ExportReady {
+		t.Fatalf("status = %q", m.export.Status)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(m.export.Data), int64(len(m.export.Data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rc)
+		_ = rc.Close()
+		files[f.Name] = string(b)
+	}
+	if !strings.Contains(files["profile.json"], `"email": "user@example.com"`) {
+		t.Errorf("profile.json = %s", files["profile.json"])
+	}
+	if arch := files["architectures/03000000-0000-0000-0000-000000000000.json"]; !strings.Contains(arch, `"id": "db"`) {
+		t.Errorf("architecture data not decompressed into the export: %v", files)
+	}
+	if files["simulation_results.json"] != "[]\n" {
+		t.Errorf("simulation_results.json = %q", files["simulation_results.json"])
+	}
+	var logs []model.SessionLogEntry
+	if err := json.Unmarshal([]byte(files["session_log.json"]), &logs); err != nil || len(logs) != 2 {
+		t.Errorf("session_log.json has %d entries, %v", len(logs), err)
+	}
+
+	// The email carries the token whose hash opens the export.
+	if m.email == nil || m.email.Template != auth.TemplateDataExport {
+		t.Fatalf("queued email = %+v", m.email)
+	}
+	token := regexp.MustCompile(`/api/v1/exports/([0-9a-f]{64})`).FindStringSubmatch(m.email.Text)
+	if token == nil || auth.HashToken(token[1]) != m.tokenHash {
+		t.Errorf("email link does not match the stored token hash")
+	}
+
+	// A finished export is not built again.
+	m.email = nil
+	if err := h(ctx, payload); err != nil || m.email != nil {
+		t.Errorf("second run: %v, email %+v", err, m.email)
+	}
+}
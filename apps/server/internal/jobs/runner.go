@@ -0,0 +1,296 @@
+// Package jobs runs background work from a durable queue in PostgreSQL:
+// handlers registered per job kind, retries with exponential backoff,
+// dead-lettering, and cron-style schedules that enqueue jobs.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/model"
+)
+
+// Queue stores jobs for a Runner; *storage.Storage implements it.
+type Queue interface {
+	EnqueueJob(ctx context.Context, job model.NewJob) (bool, error)
+	ClaimJobs(ctx context.Context, kinds []string, limit int, lease time.Duration) ([]model.Job, error)
+	// CompleteJob and FailJob apply only while the claim of attempt holds,
+	// and report whether it did.
+	CompleteJob(ctx context.Context, id pgtype.UUID, attempt int) (bool, error)
+	FailJob(ctx context.Context, id pgtype.UUID, attempt int, msg string, retryAt *time.Time) (bool, error)
+}
+
+// HandlerFunc does the work of one job. A returned error retries the job
+// after a backoff unless it is Permanent or the job is out of attempts.
+type HandlerFunc func(ctx context.Context, payload json.RawMessage) error
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying; the job is dead-lettered.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// Backoff is the delay before retrying a job that failed its attempt-th
+// run: 30s doubling up to an hour.
+func Backoff(attempt int) time.Duration {
+	d := 30 * time.Second
+	for i := 1; i < attempt && d < time.Hour; i++ {
+		d *= 2
+	}
+	return min(d, time.Hour)
+}
+
+// Enqueue marshals payload and adds a job of kind to q.
+func Enqueue(ctx context.Context, q Queue, kind string, payload any) error {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("jobs: marshal %s payload: %w", kind, err)
+	}
+	_, err = q.EnqueueJob(ctx, model.NewJob{Kind: kind, Payload: raw})
+	return err
+}
+
+type schedule struct {
+	spec    Schedule
+	kind    string
+	payload json.RawMessage
+	next    time.Time
+}
+
+// Runner claims due jobs and runs them on up to Concurrency goroutines.
+// Configure it and register handlers and schedules before Start.
+type Runner struct {
+	Queue        Queue
+	Concurrency  int
+	PollInterval time.Duration
+	// Lease is how long a claimed job stays locked; a job still running
+	// when it runs out may be claimed again by another server.
+	Lease time.Duration
+
+	handlers  map[string]HandlerFunc
+	schedules []*schedule
+
+	stop      context.CancelFunc
+	cancelRun context.CancelFunc
+	loops     sync.WaitGroup
+	running   sync.WaitGroup
+}
+
+// NewRunner returns a Runner on q with default settings.
+func NewRunner(q Queue) *Runner {
+	return &Runner{
+		Queue:        q,
+		Concurrency:  4,
+		PollInterval: time.Second,
+		Lease:        5 * time.Minute,
+		handlers:     map[string]HandlerFunc{},
+	}
+}
+
+// Handle registers the handler for jobs of kind.
+func (r *Runner) Handle(kind string, h HandlerFunc) {
+	r.handlers[kind] = h
+}
+
+// Schedule enqueues a job of kind with payload each time the cron spec
+// fires. Every server may run the same schedules: each slot is enqueued
+// once.
+func (r *Runner) Schedule(spec, kind string, payload any) error {
+	s, err := ParseSchedule(spec)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("jobs: marshal %s payload: %w", kind, err)
+	}
+	r.schedules = append(r.schedules, &schedule{spec: s, kind: kind, payload: raw})
+	return nil
+}
+
+// Start begins polling for jobs and running schedules in the background.
+func (r *Runner) Start() {
+	ctx, stop := context.WithCancel(context.Background())
+	runCtx, cancelRun := context.WithCancel(context.Background())
+	r.stop, r.cancelRun = stop, cancelRun
+
+	now := time.Now()
+	for _, s := range r.schedules {
+		s.next = s.spec.Next(now)
+	}
+
+	r.loops.Add(2)
+	go func() {
+		defer r.loops.Done()
+		r.poll(ctx, runCtx)
+	}()
+	go func() {
+		defer r.loops.Done()
+		r.schedule(ctx)
+	}()
+}
+
+// Shutdown stops claiming jobs and waits for running ones to finish. If
+// ctx ends first, running jobs are cancelled and Shutdown returns ctx's
+// error; their leases expire and they are retried later.
+func (r *Runner) Shutdown(ctx context.Context) error {
+	if r.stop == nil {
+		return nil
+	}
+	r.stop()
+	r.loops.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		r.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		r.cancelRun()
+		return nil
+	case <-ctx.Done():
+		r.cancelRun()
+		return ctx.Err()
+	}
+}
+
+func (r *Runner) poll(ctx, runCtx context.Context) {
+	kinds := make([]string, 0, len(r.handlers))
+	for k := range r.handlers {
+		kinds = append(kinds, k)
+	}
+	slots := make(chan struct{}, max(r.Concurrency, 1))
+
+	for {
+		free := cap(slots) - len(slots)
+		claimed := 0
+		if free > 0 && len(kinds) > 0 {
+			jobs, err := r.Queue.ClaimJobs(ctx, kinds, free, r.Lease)
+			if err != nil && ctx.Err() == nil {
+				slog.Error("jobs: claim failed", "error", err)
+			}
+			for _, j := range jobs {
+				slots <- struct{}{}
+				r.running.Add(1)
+				go func() {
+					defer func() { <-slots; r.running.Done() }()
+					r.run(runCtx, j)
+				}()
+			}
+			claimed = len(jobs)
+		}
+		// A full batch suggests more are due; claim again right away.
+		if claimed > 0 && claimed == free {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.PollInterval):
+		}
+	}
+}
+
+func (r *Runner) run(ctx context.Context, j model.Job) {
+	// Bookkeeping outlives shutdown cancellation so results are recorded.
+	bg, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+
+	if j.Attempts > j.MaxAttempts {
+		held, err := r.Queue.FailJob(bg, j.ID, j.Attempts, "lease expired on the last attempt", nil)
+		r.recorded(j, held, err, "failure")
+		return
+	}
+
+	start := time.Now()
+	err := r.call(ctx, j)
+	if err == nil {
+		held, err := r.Queue.CompleteJob(bg, j.ID, j.Attempts)
+		r.recorded(j, held, err, "completion")
+		slog.Debug("jobs: done", "id", j.ID, "kind", j.Kind, "duration", time.Since(start))
+		return
+	}
+
+	var retryAt *time.Time
+	var perm permanentError
+	if !errors.As(err, &perm) && j.Attempts < j.MaxAttempts {
+		t := time.Now().Add(Backoff(j.Attempts))
+		retryAt = &t
+	}
+	if retryAt == nil {
+		slog.Error("jobs: dead-lettered", "id", j.ID, "kind", j.Kind, "attempts", j.Attempts, "error", err)
+	} else {
+		slog.Warn("jobs: attempt failed", "id", j.ID, "kind", j.Kind, "attempts", j.Attempts, "retry_at", *retryAt, "error", err)
+	}
+	held, ferr := r.Queue.FailJob(bg, j.ID, j.Attempts, err.Error(), retryAt)
+	r.recorded(j, held, ferr, "failure")
+}
+
+// recorded logs a completion or failure of j that could not be recorded,
+// because of err or because the lease ran out and the job was claimed
+// again; the later claim's outcome is the one that counts.
+func (r *Runner) recorded(j model.Job, held bool, err error, what string) {
+	switch {
+	case err != nil:
+		slog.Error("jobs: record "+what+" failed", "id", j.ID, "kind", j.Kind, "error", err)
+	case !held:
+		slog.Warn("jobs: lost lease, "+what+" not recorded", "id", j.ID, "kind", j.Kind, "attempts", j.Attempts)
+	}
+}
+
+// call runs the job's handler, turning a panic into an error.
+func (r *Runner) call(ctx context.Context, j model.Job) (err error) {
+	h, ok := r.handlers[j.Kind]
+	if !ok {
+		return Permanent(fmt.Errorf("no handler for kind %q", j.Kind))
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return h(ctx, j.Payload)
+}
+
+func (r *Runner) schedule(ctx context.Context) {
+	if len(r.schedules) == 0 {
+		return
+	}
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		for _, s := range r.schedules {
+			if s.next.IsZero() || now.Before(s.next) {
+				continue
+			}
+			key := "cron:" + s.kind + ":" + s.next.UTC().Format(time.RFC3339)
+			if _, err := r.Queue.EnqueueJob(ctx, model.NewJob{Kind: s.kind, Payload: s.payload, RunAt: s.next, DedupeKey: &key}); err != nil {
+				if ctx.Err() == nil {
+					slog.Error("jobs: enqueue scheduled job failed", "kind", s.kind, "error", err)
+				}
+				continue
+			}
+			s.next = s.spec.Next(now)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/model"
+)
+
+// memQueue is an in-memory Queue that hands out each due job once.
+type memQueue struct {
+	mu   sync.Mutex
+	jobs []*model.Job
+	keys map[string]bool
+}
+
+func (q *memQueue) EnqueueJob(_ context.Context, nj model.NewJob) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if nj.DedupeKey != nil {
+		if q.keys[*nj.DedupeKey] {
+			return false, nil
+		}
+		if q.keys == nil {
+			q.keys = map[string]bool{}
+		}
+		q.keys[*nj.DedupeKey] = true
+	}
+	maxAttempts := nj.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = 5
+	}
+	var id pgtype.UUID
+	id.Bytes[0] = byte(len(q.jobs) + 1)
+	id.Valid = true
+	q.jobs = append(q.jobs, &model.Job{ID: id, Kind: nj.Kind, Payload: nj.Payload, Status: model.JobPending, MaxAttempts: maxAttempts})
+	return true, nil
+}
+
+func (q *memQueue) ClaimJobs(_ context.Context, kinds []string, limit int, _ time.Duration) ([]model.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var out []model.Job
+	for _, j := range q.jobs {
+		if len(out) == limit {
+			break
+		}
+		if j.Status != model.JobPending || !contains(kinds, j.Kind) {
+			continue
+		}
+		j.Status = model.JobRunning
+		j.Attempts++
+		out = append(out, *j)
+	}
+	return out, nil
+}
+
+func (q *memQueue) CompleteJob(_ context.Context, id pgtype.UUID, attempt int) (bool, error) {
+	return q.update(id, attempt, func(j *model.Job) { j.Status = model.JobDone })
+}
+
+// FailJob ignores retryAt so retries happen on the next poll.
+func (q *memQueue) FailJob(_ context.Context, id pgtype.UUID, attempt int, msg string, retryAt *time.Time) (bool, error) {
+	return q.update(id, attempt, func(j *model.Job) {
+		j.LastError = &msg
+		if retryAt == nil {
+			j.Status = model.JobDead
+		} else {
+			j.Status = model.JobPending
+		}
+	})
+}
+
+// update applies f to the job while attempt is its running claim.
+func (q *memQueue) update(id pgtype.UUID, attempt int, f func(*model.Job)) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, j := range q.jobs {
+		if j.ID == id {
+			if j.Status != model.JobRunning || j.Attempts != attempt {
+				return false, nil
+			}
+			f(j)
+			return true, nil
+		}
+	}
+	return false, errors.New("no such job")
+}
+
+func (q *memQueue) snapshot() []model.Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make([]model.Job, len(q.jobs))
+	for i, j := range q.jobs {
+		out[i] = *j
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func newTestRunner(q Queue) *Runner {
+	r := NewRunner(q)
+	r.PollInterval = 5 * time.Millisecond
+	return r
+}
+
+// waitFor polls until every job except those of kind skip is done or
+// dead.
+func waitFor(t *testing.T, q *memQueue, skip string) []model.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		jobs := q.snapshot()
+		settled := true
+		for _, j := range jobs {
+			if j.Kind != skip && j.Status != model.JobDone && j.Status != model.JobDead {
+				settled = false
+			}
+		}
+		if settled {
+			return jobs
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("jobs did not settle: %+v", q.snapshot())
+	return nil
+}
+
+func TestRunnerRetriesAndDeadLetters(t *testing.T) {
+	q := &memQueue{}
+	r := newTestRunner(q)
+
+	var mu sync.Mutex
+	calls := map[string]int{}
+	r.Handle("flaky", func(_ context.Context, payload json.RawMessage) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls["flaky"]++
+		if calls["flaky"] < 3 {
+			return errors.New("try again")
+		}
+		return nil
+	})
+	r.Handle("broken", func(context.Context, json.RawMessage) error {
+		mu.Lock()
+		calls["broken"]++
+		mu.Unlock()
+		return errors.New("always fails")
+	})
+	r.Handle("bad", func(context.Context, json.RawMessage) error {
+		mu.Lock()
+		calls["bad"]++
+		mu.Unlock()
+		return Permanent(errors.New("malformed"))
+	})
+	r.Handle("panics", func(context.Context, json.RawMessage) error {
+		panic("boom")
+	})
+
+	ctx := context.Background()
+	for _, kind := range []string{"flaky", "broken", "bad", "unknown"} {
+		if err := Enqueue(ctx, q, kind, map[string]int{"n": 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := q.EnqueueJob(ctx, model.NewJob{Kind: "panics", MaxAttempts: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	r.Start()
+	jobs := waitFor(t, q, "unknown")
+	if err := r.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]struct {
+		status   string
+		attempts int
+	}{
+		"flaky":  {model.JobDone, 3},
+		"broken": {model.JobDead, 5},
+		"bad":    {model.JobDead, 1},
+		"panics": {model.JobDead, 1},
+	}
+	for _, j := range jobs {
+		w, ok := want[j.Kind]
+		if !ok {
+			continue
+		}
+		if j.Status != w.status || j.Attempts != w.attempts {
+			t.Errorf("%s: status %s after %d attempts, want %s after %d", j.Kind, j.Status, j.Attempts, w.status, w.attempts)
+		}
+	}
+	if calls["broken"] != 5 || calls["bad"] != 1 {
+		t.Errorf("calls = %v", calls)
+	}
+	// Jobs without a handler are never claimed.
+	for _, j := range jobs {
+		if j.Kind == "unknown" && j.Status != model.JobPending {
+			t.Errorf("unknown job status = %s, want pending", j.Status)
+		}
+	}
+}
+
+func TestRunnerShutdownWaitsForRunningJobs(t *testing.T) {
+	q := &memQueue{}
+	r := newTestRunner(q)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	r.Handle("slow", func(context.Context, json.RawMessage) error {
+		close(started)
+		<-release
+		return nil
+	})
+	_ = Enqueue(context.Background(), q, "slow", nil)
+
+	r.Start()
+	<-started
+
+	done := make(chan error, 1)
+	go func() { done <- r.Shutdown(context.Background()) }()
+	select {
+	case <-done:
+		t.Fatal("Shutdown returned while a job was running")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Shutdown = %v", err)
+	}
+	if got := q.snapshot()[0].Status; got != model.JobDone {
+		t.Errorf("status = %s, want done", got)
+	}
+}
+
+func TestRunnerLostLeaseIsNotRecorded(t *testing.T) {
+	q := &memQueue{}
+	r := newTestRunner(q)
+
+	// The lease runs out while the handler works and another runner claims
+	// the job again, making it the second attempt.
+	r.Handle("slow", func(context.Context, json.RawMessage) error {
+		q.mu.Lock()
+		q.jobs[0].Attempts++
+		q.mu.Unlock()
+		return nil
+	})
+	_ = Enqueue(context.Background(), q, "slow", nil)
+
+	r.Start()
+	deadline := time.Now().Add(5 * time.Second)
+	for q.snapshot()[0].Attempts < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := r.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if j := q.snapshot()[0]; j.Status != model.JobRunning || j.Attempts != 2 {
+		t.Errorf("status %s after %d attempts, want the second claim still running", j.Status, j.Attempts)
+	}
+}
+
+func TestRunnerShutdownCancelsOnDeadline(t *testing.T) {
+	q := &memQueue{}
+	r := newTestRunner(q)
+
+	started := make(chan struct{})
+	r.Handle("stuck", func(ctx context.Context, _ json.RawMessage) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	_ = Enqueue(context.Background(), q, "stuck", nil)
+
+	r.Start()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := r.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown = %v, want deadline exceeded", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt, want := range map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		7:  32 * time.Minute,
+		8:  time.Hour,
+		20: time.Hour,
+	} {
+		if got := Backoff(attempt); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/archdata"
+	"github.com/system-design-sandbox/server/internal/auth"
+	"github.com/system-design-sandbox/server/internal/config"
+	"github.com/system-design-sandbox/server/internal/model"
+	"github.com/system-design-sandbox/server/internal/storage"
+	"github.com/system-design-sandbox/server/internal/thumbnail"
+)
+
+// Job kinds.
+const (
+	KindEmailSend           = "email.send"
+	KindEmailCleanup        = "email.cleanup"
+	KindThumbnails          = "thumbnails.render"
+	KindSessionLogRetention = "session_log.retention"
+	KindSessionExpiry       = "session_log.expire"
+	KindTrashPurge          = "trash.purge"
+	KindJobsCleanup         = "jobs.cleanup"
+	KindUserExport          = "users.export"
+	KindExportCleanup       = "users.export_cleanup"
+	KindUserPurge           = "users.purge"
+)
+
+const (
+	thumbnailBatch    = 50
+	finishedJobsKept  = 7 * 24 * time.Hour
+	settledEmailKept  = 30 * 24 * time.Hour
+	sessionSweepBatch = 500
+)
+
+// Register installs the server's job handlers and maintenance schedules.
+// sessions is nil when Redis is not configured.
+func Register(r *Runner, store *storage.Storage, email auth.EmailSender, sessions *auth.RedisAuth, cfg *config.Config) error {
+	r.Handle(KindEmailSend, sendEmailHandler(store, email))
+
+	r.Handle(KindEmailCleanup, func(ctx context.Context, _ json.RawMessage) error {
+		_, err := store.DeleteSettledEmails(ctx, time.Now().Add(-settledEmailKept))
+		return err
+	})
+
+	r.Handle(KindThumbnails, func(ctx context.Context, _ json.RawMessage) error {
+		archs, err := store.ListStaleThumbnails(ctx, thumbnailBatch)
+		if err != nil {
+			return err
+		}
+		for _, a := range archs {
+			// Unparseable data still gets a blank thumbnail so it is not
+			// picked up again on every run.
+			s, err := archdata.Parse(a.RawData)
+			if err != nil {
+				s = nil
+			}
+			if err := store.SetThumbnail(ctx, a.ID, thumbnail.Render(s)); err != nil {
+				return err
+			}
+		}
+		if len(archs) > 0 {
+			slog.Info("jobs: thumbnails rendered", "architectures", len(archs))
+		}
+		return nil
+	})
+
+	r.Handle(KindSessionLogRetention, func(ctx context.Context, _ json.RawMessage) error {
+		if cfg.SessionLogRetention <= 0 {
+			return nil
+		}
+		n, err := store.DeleteSessionLogsBefore(ctx, time.Now().Add(-cfg.SessionLogRetention))
+		if err == nil && n > 0 {
+			slog.Info("jobs: session log trimmed", "entries", n)
+		}
+		return err
+	})
+
+	r.Handle(KindSessionExpiry, func(ctx context.Context, _ json.RawMessage) error {
+		if !cfg.SessionLogEnabled || sessions == nil {
+			return nil
+		}
+		n, err := sweepExpiredSessions(ctx, store, sessions)
+		if n > 0 {
+			slog.Info("jobs: expired sessions logged", "sessions", n)
+		}
+		return err
+	})
+
+	r.Handle(KindTrashPurge, func(ctx context.Context, _ json.RawMessage) error {
+		n, err := store.PurgeDeletedArchitectures(ctx, cfg.TrashRetention)
+		if err == nil && n > 0 {
+			slog.Info("jobs: trash purged", "architectures", n)
+		}
+		return err
+	})
+
+	r.Handle(KindUserExport, userExportHandler(store, cfg.PublicURL))
+
+	r.Handle(KindExportCleanup, func(ctx context.Context, _ json.RawMessage) error {
+		_, err := store.DeleteExpiredExports(ctx)
+		return err
+	})
+
+	r.Handle(KindUserPurge, func(ctx context.Context, _ json.RawMessage) error {
+		n, err := store.PurgeDeletedUsers(ctx, cfg.AccountDeletionGrace)
+		if n > 0 {
+			slog.Info("jobs: deleted accounts purged", "users", n)
+		}
+		return err
+	})
+
+	r.Handle(KindJobsCleanup, func(ctx context.Context, _ json.RawMessage) error {
+		_, err := store.DeleteFinishedJobs(ctx, time.Now().Add(-finishedJobsKept))
+		return err
+	})
+
+	for _, s := range []struct{ spec, kind string }{
+		{"*/5 * * * *", KindThumbnails},
+		{"17 3 * * *", KindSessionLogRetention},
+		{"*/10 * * * *", KindSessionExpiry},
+		{"@hourly", KindTrashPurge},
+		{"43 3 * * *", KindJobsCleanup},
+		{"47 3 * * *", KindEmailCleanup},
+		{"@hourly", KindExportCleanup},
+		{"23 * * * *", KindUserPurge},
+	} {
+		if err := r.Schedule(s.spec, s.kind, struct{}{}); err != nil {
+			return fmt.Errorf("jobs: schedule %s: %w", s.kind, err)
+		}
+	}
+	return nil
+}
+
+// sweepExpiredSessions logs an expire entry for every logged-in session
+// that is gone from Redis without a logout or revoke, and reports how many
+// it logged. Redis expires sessions silently, so the entry is dated when
+// the sweep noticed, at most one schedule interval late.
+func sweepExpiredSessions(ctx context.Context, store *storage.Storage, sessions *auth.RedisAuth) (int, error) {
+	var after pgtype.UUID
+	n := 0
+	for {
+		open, err := store.ListUnendedSessions(ctx, after, sessionSweepBatch)
+		if err != nil || len(open) == 0 {
+			return n, err
+		}
+		ids := make([]string, len(open))
+		for i, e := range open {
+			ids[i] = e.SessionID
+		}
+		live, err := sessions.SessionsExist(ctx, ids)
+		if err != nil {
+			return n, err
+		}
+		for _, e := range open {
+			if live[e.SessionID] {
+				continue
+			}
+			if err := store.CreateSessionLog(ctx, model.SessionLogEntry{
+				UserID:    e.UserID,
+				SessionID: e.SessionID,
+				Action:    "expire",
+			}); err != nil {
+				return n, err
+			}
+			n++
+		}
+		after = open[len(open)-1].ID
+	}
+}
@@ -18,10 +18,24 @@ type User struct {
 	DisplayName     *string            `json:"display_name,omitempty"`
 	GravatarAllowed bool               `json:"gravatar_allowed"`
 	ReferralSource  *string            `json:"referral_source,omitempty"`
+	Role            string             `json:"role"`
+	Locale          string             `json:"locale"`
+	EmailOptOut     []string           `json:"email_opt_out"`
 	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+	// UnsubscribeToken keys the unsubscribe links in the user's emails.
+	UnsubscribeToken pgtype.UUID `json:"-"`
+	// DeletedAt is set while the account waits out its deletion grace period.
+	DeletedAt *pgtype.Timestamptz `json:"deleted_at,omitempty"`
 }
 
-// MarshalJSON adds computed gravatar_url field to User JSON output.
+// Site-wide user roles, unrelated to workspace roles.
+const (
+	UserRoleUser  = "user"
+	UserRoleAdmin = "admin"
+)
+
+// MarshalJSON adds computed gravatar_url field to User JSON output. It is
+// derived from the current email, so it follows an email change.
 func (u User) MarshalJSON() ([]byte, error) {
 	type Alias User
 	aux := struct {
@@ -31,12 +45,17 @@ func (u User) MarshalJSON() ([]byte, error) {
 		Alias: Alias(u),
 	}
 	if u.GravatarAllowed && u.Email != "" {
-		hash := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(u.Email))))
-		aux.GravatarURL = fmt.Sprintf("https://www.gravatar.com/avatar/%x?d=identicon&s=80", hash)
+		aux.GravatarURL = GravatarURL(u.Email)
 	}
 	return json.Marshal(&aux)
 }
 
+// GravatarURL is the Gravatar image of email, an identicon when it has none.
+func GravatarURL(email string) string {
+	hash := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return fmt.Sprintf("https://www.gravatar.com/avatar/%x?d=identicon&s=80", hash)
+}
+
 type SessionLogEntry struct {
 	ID        pgtype.UUID        `json:"id"`
 	UserID    pgtype.UUID        `json:"user_id"`
@@ -55,6 +74,9 @@ type Architecture struct {
 	Name         string             `json:"name"`
 	Description  string             `json:"description"`
 	ScenarioID   *string            `json:"scenario_id,omitempty"`
+	FolderID     *pgtype.UUID       `json:"folder_id,omitempty"`
+	WorkspaceID  *pgtype.UUID       `json:"workspace_id,omitempty"`
+	Slug         string             `json:"slug"`
 	Data         []byte             `json:"-"`
 	RawData      json.RawMessage    `json:"data,omitempty"`
 	ThumbnailURL *string            `json:"thumbnail_url,omitempty"`
@@ -70,11 +92,140 @@ type ArchitectureListItem struct {
 	Name         string             `json:"name"`
 	Description  string             `json:"description"`
 	ScenarioID   *string            `json:"scenario_id,omitempty"`
+	FolderID     *pgtype.UUID       `json:"folder_id,omitempty"`
+	WorkspaceID  *pgtype.UUID       `json:"workspace_id,omitempty"`
+	Slug         string             `json:"slug"`
 	ThumbnailURL *string            `json:"thumbnail_url,omitempty"`
 	IsPublic     bool               `json:"is_public"`
 	Tags         []string           `json:"tags"`
 	CreatedAt    pgtype.Timestamptz `json:"created_at"`
 	UpdatedAt    pgtype.Timestamptz `json:"updated_at"`
+	// UnresolvedThreads counts open comment threads.
+	UnresolvedThreads int `json:"unresolved_threads"`
+	// DeletedAt is set only in trash listings.
+	DeletedAt *pgtype.Timestamptz `json:"deleted_at,omitempty"`
+}
+
+// Usage is what a user has stored and posted, against their plan's quotas.
+type Usage struct {
+	Plan          string       `json:"plan"`
+	Architectures UsageCounter `json:"architectures"`
+	StorageBytes  UsageCounter `json:"storage_bytes"`
+	ResultsToday  UsageCounter `json:"results_today"`
+}
+
+// UsageCounter pairs a usage figure with its limit; a nil Limit is
+// unlimited.
+type UsageCounter struct {
+	Used  int64  `json:"used"`
+	Limit *int64 `json:"limit"`
+}
+
+// Folder groups a user's architectures, or a workspace's when WorkspaceID
+// is set; UserID is then the member who created it. Folders nest through
+// ParentID; a nil ParentID is a top-level folder.
+type Folder struct {
+	ID          pgtype.UUID        `json:"id"`
+	UserID      pgtype.UUID        `json:"user_id"`
+	WorkspaceID *pgtype.UUID       `json:"workspace_id,omitempty"`
+	ParentID    *pgtype.UUID       `json:"parent_id,omitempty"`
+	Name        string             `json:"name"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt   pgtype.Timestamptz `json:"updated_at"`
+}
+
+// InWorkspace reports whether the folder belongs to workspaceID, nil
+// meaning a personal folder.
+func (f Folder) InWorkspace(workspaceID *pgtype.UUID) bool {
+	if f.WorkspaceID == nil || workspaceID == nil {
+		return f.WorkspaceID == nil && workspaceID == nil
+	}
+	return *f.WorkspaceID == *workspaceID
+}
+
+// Workspace roles, from most to least privileged.
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+var roleRank = map[string]int{RoleViewer: 1, RoleEditor: 2, RoleAdmin: 3, RoleOwner: 4}
+
+// ValidRole reports whether role is one of the workspace roles.
+func ValidRole(role string) bool {
+	return roleRank[role] > 0
+}
+
+// RoleAtLeast reports whether role grants everything min does.
+func RoleAtLeast(role, min string) bool {
+	return ValidRole(role) && roleRank[role] >= roleRank[min]
+}
+
+// Workspace shares architectures between its members. Role is the
+// requesting user's role in it.
+type Workspace struct {
+	ID        pgtype.UUID        `json:"id"`
+	Name      string             `json:"name"`
+	CreatedBy *pgtype.UUID       `json:"created_by,omitempty"`
+	Role      string             `json:"role"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+}
+
+type WorkspaceMember struct {
+	UserID      pgtype.UUID        `json:"user_id"`
+	Email       string             `json:"email"`
+	Name        string             `json:"name"`
+	DisplayName *string            `json:"display_name,omitempty"`
+	Role        string             `json:"role"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+}
+
+// WorkspaceInvite is a pending invitation. The token itself is only ever
+// sent by email; the database keeps its hash.
+type WorkspaceInvite struct {
+	ID          pgtype.UUID        `json:"id"`
+	WorkspaceID pgtype.UUID        `json:"workspace_id"`
+	Email       string             `json:"email"`
+	Role        string             `json:"role"`
+	InvitedBy   *pgtype.UUID       `json:"invited_by,omitempty"`
+	ExpiresAt   pgtype.Timestamptz `json:"expires_at"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+}
+
+// Comment anchor types: a thread points at a node or an edge of the
+// architecture data, or at nothing for a general thread.
+const (
+	AnchorNode = "node"
+	AnchorEdge = "edge"
+)
+
+// CommentThread is a review discussion on an architecture. Orphaned is set
+// when the anchored node or edge no longer exists in the current data.
+type CommentThread struct {
+	ID             pgtype.UUID        `json:"id"`
+	ArchitectureID pgtype.UUID        `json:"architecture_id"`
+	AnchorType     *string            `json:"anchor_type,omitempty"`
+	AnchorID       *string            `json:"anchor_id,omitempty"`
+	CreatedBy      *pgtype.UUID       `json:"created_by,omitempty"`
+	ResolvedAt     pgtype.Timestamptz `json:"resolved_at"`
+	ResolvedBy     *pgtype.UUID       `json:"resolved_by,omitempty"`
+	Orphaned       bool               `json:"orphaned"`
+	Comments       []Comment          `json:"comments"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt      pgtype.Timestamptz `json:"updated_at"`
+}
+
+type Comment struct {
+	ID         pgtype.UUID        `json:"id"`
+	ThreadID   pgtype.UUID        `json:"thread_id"`
+	UserID     *pgtype.UUID       `json:"user_id,omitempty"`
+	AuthorName string             `json:"author_name"`
+	Body       string             `json:"body"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt  pgtype.Timestamptz `json:"updated_at"`
 }
 
 type Scenario struct {
@@ -96,13 +247,105 @@ type SimulationResult struct {
 	Report         json.RawMessage    `json:"report"`
 	Metrics        json.RawMessage    `json:"metrics"`
 	DurationSec    *int               `json:"duration_sec,omitempty"`
+	Cost           json.RawMessage    `json:"cost,omitempty"`
+	MonthlyCost    *float64           `json:"monthly_cost,omitempty"`
 	CreatedAt      pgtype.Timestamptz `json:"created_at"`
 }
 
 type LeaderboardEntry struct {
-	Name       string    `json:"name"`
-	ScenarioID string    `json:"scenario_id"`
-	Score      int       `json:"score"`
-	CreatedAt  time.Time `json:"created_at"`
-	Rank       int       `json:"rank"`
+	Name        string    `json:"name"`
+	ScenarioID  string    `json:"scenario_id"`
+	Score       int       `json:"score"`
+	MonthlyCost *float64  `json:"monthly_cost,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	Rank        int       `json:"rank"`
+}
+
+// Job statuses. Failed jobs go back to pending until they run out of
+// attempts and turn dead.
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobDead    = "dead"
+)
+
+// Job is a unit of background work; Payload is decoded by the handler
+// registered for Kind.
+type Job struct {
+	ID          pgtype.UUID        `json:"id"`
+	Kind        string             `json:"kind"`
+	Payload     json.RawMessage    `json:"payload"`
+	Status      string             `json:"status"`
+	Attempts    int                `json:"attempts"`
+	MaxAttempts int                `json:"max_attempts"`
+	RunAt       pgtype.Timestamptz `json:"run_at"`
+	LockedUntil pgtype.Timestamptz `json:"locked_until"`
+	LastError   *string            `json:"last_error,omitempty"`
+	DedupeKey   *string            `json:"dedupe_key,omitempty"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt   pgtype.Timestamptz `json:"updated_at"`
+	FinishedAt  pgtype.Timestamptz `json:"finished_at"`
+}
+
+// NewJob describes a job to enqueue. A zero RunAt runs it right away, a
+// zero MaxAttempts uses the table default, and a job whose DedupeKey is
+// already taken is not enqueued again.
+type NewJob struct {
+	Kind        string
+	Payload     json.RawMessage
+	RunAt       time.Time
+	MaxAttempts int
+	DedupeKey   *string
+}
+
+// Outbox email statuses. A pending email is retried until it is sent,
+// bounced (permanently rejected), failed (out of attempts) or expired.
+const (
+	EmailPending = "pending"
+	EmailSent    = "sent"
+	EmailFailed  = "failed"
+	EmailBounced = "bounced"
+	EmailExpired = "expired"
+)
+
+// OutboxEmail is a queued message and its delivery status. Body (HTML)
+// and Text are cleared once the email is settled.
+type OutboxEmail struct {
+	ID        pgtype.UUID `json:"id"`
+	Template  string      `json:"template"`
+	Recipient string      `json:"recipient"`
+	Subject   string      `json:"subject"`
+	Body      string      `json:"-"`
+	Text      string      `json:"-"`
+	// UnsubscribeURL is sent as List-Unsubscribe for optional emails.
+	UnsubscribeURL *string            `json:"-"`
+	Status         string             `json:"status"`
+	Attempts       int                `json:"attempts"`
+	MessageID      *string            `json:"message_id,omitempty"`
+	LastError      *string            `json:"last_error,omitempty"`
+	ExpiresAt      pgtype.Timestamptz `json:"expires_at"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt      pgtype.Timestamptz `json:"updated_at"`
+	SentAt         pgtype.Timestamptz `json:"sent_at"`
+}
+
+// Data export statuses. A pending export is being built by a job; a ready
+// one can be downloaded until it expires.
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// UserExport is a data export requested by a user. Data, the zip, is only
+// loaded for download.
+type UserExport struct {
+	ID        pgtype.UUID         `json:"id"`
+	UserID    pgtype.UUID         `json:"-"`
+	Status    string              `json:"status"`
+	Data      []byte              `json:"-"`
+	ExpiresAt pgtype.Timestamptz  `json:"expires_at"`
+	CreatedAt pgtype.Timestamptz  `json:"created_at"`
+	ReadyAt   *pgtype.Timestamptz `json:"ready_at,omitempty"`
 }
@@ -0,0 +1,157 @@
+// Package ratelimit implements sliding-window rate limits in Redis.
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Rule allows Limit requests in any Window. A Limit of 0 disables it.
+type Rule struct {
+	Limit  int
+	Window time.Duration
+}
+
+// Key is one bucket to count a request in: the Redis sorted set Name
+// holding the times of recent requests, limited by Rule.
+type Key struct {
+	Name string
+	Rule Rule
+}
+
+// Result is the outcome of Allow. When a request is rejected, Rejected is
+// the index of the full key and RetryAfter is when it frees up.
+type Result struct {
+	Allowed    bool
+	Rejected   int
+	RetryAfter time.Duration
+}
+
+// allowScript checks every key and, only if all have room, records the
+// request in each, so rejected requests do not use up the other limits.
+// Times come from the Redis server, keeping app servers' clocks out of it.
+//
+// KEYS: one sorted set per key. ARGV[1] is a unique member for this
+// request, followed by the limit and window (ms) of each key.
+var allowScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+for i, key in ipairs(KEYS) do
+	local limit = tonumber(ARGV[2 * i])
+	local window = tonumber(ARGV[2 * i + 1])
+	redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+	if redis.call('ZCARD', key) >= limit then
+		local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+		return {i, tonumber(oldest[2]) + window - now}
+	end
+end
+for i, key in ipairs(KEYS) do
+	redis.call('ZADD', key, now, ARGV[1])
+	redis.call('PEXPIRE', key, ARGV[2 * i + 1])
+end
+return {0, 0}
+`)
+
+// Limiter applies sliding-window limits stored in Redis.
+type Limiter struct {
+	rdb redis.UniversalClient
+}
+
+// New returns a Limiter using rdb, or nil when rdb is nil; a nil Limiter
+// allows everything.
+func New(rdb redis.UniversalClient) *Limiter {
+	if rdb == nil {
+		return nil
+	}
+	return &Limiter{rdb: rdb}
+}
+
+// Allow counts one request against every key atomically, unless one of
+// them is already at its limit. Keys with a disabled rule are ignored.
+func (l *Limiter) Allow(ctx context.Context, keys ...Key) (Result, error) {
+	names := make([]string, 0, len(keys))
+	index := make([]int, 0, len(keys))
+	args := []any{requestID()}
+	for i, k := range keys {
+		if k.Rule.Limit <= 0 || k.Rule.Window <= 0 {
+			continue
+		}
+		names = append(names, k.Name)
+		index = append(index, i)
+		args = append(args, k.Rule.Limit, k.Rule.Window.Milliseconds())
+	}
+	if len(names) == 0 {
+		return Result{Allowed: true}, nil
+	}
+
+	res, err := allowScript.Run(ctx, l.rdb, names, args...).Int64Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("ratelimit: %w", err)
+	}
+	if res[0] == 0 {
+		return Result{Allowed: true}, nil
+	}
+	return Result{
+		Rejected:   index[res[0]-1],
+		RetryAfter: time.Duration(res[1]) * time.Millisecond,
+	}, nil
+}
+
+// rekeyScript moves the requests recorded under each KEYS[2i-1] to
+// KEYS[2i], adding them to those already there and keeping the longer
+// expiry, so the move neither resets nor loses a limit.
+var rekeyScript = redis.NewScript(`
+for i = 1, #KEYS, 2 do
+	local from, to = KEYS[i], KEYS[i + 1]
+	if redis.call('EXISTS', from) == 1 then
+		local ttl = math.max(redis.call('PTTL', from), redis.call('PTTL', to))
+		redis.call('ZUNIONSTORE', to, 2, from, to, 'AGGREGATE', 'MAX')
+		redis.call('DEL', from)
+		if ttl > 0 then
+			redis.call('PEXPIRE', to, ttl)
+		end
+	end
+end
+return 0
+`)
+
+// Rekey moves the requests counted under key from to key to in bucket b
+// of scope, e.g. when the email address a bucket is keyed by changes. A
+// nil Limiter does nothing.
+func (l *Limiter) Rekey(ctx context.Context, scope string, b Bucket, from, to string) error {
+	if l == nil || from == to {
+		return nil
+	}
+	keys := make([]string, 0, 2*len(b.Rules))
+	for _, rule := range b.Rules {
+		if rule.Window <= 0 {
+			continue
+		}
+		keys = append(keys, KeyName(scope, b.Name, from, rule), KeyName(scope, b.Name, to, rule))
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	if err := rekeyScript.Run(ctx, l.rdb, keys).Err(); err != nil {
+		return fmt.Errorf("ratelimit: %w", err)
+	}
+	return nil
+}
+
+// requestID tells apart requests recorded in the same millisecond.
+func requestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// windowName is the part of a Redis key naming the window, e.g. "60s".
+func windowName(w time.Duration) string {
+	return strconv.FormatInt(int64(w/time.Second), 10) + "s"
+}
@@ -0,0 +1,195 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// setupTestLimiter uses DB 13 of the Redis instance at localhost:6379, apart
+// from other packages testing in parallel, and skips the test when it is
+// not running.
+func setupTestLimiter(t *testing.T) *Limiter {
+	t.Helper()
+	rdb := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 13})
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		t.Skip("Redis not available, skipping rate limit integration test")
+	}
+	t.Cleanup(func() {
+		rdb.FlushDB(context.Background())
+		_ = rdb.Close()
+	})
+	return New(rdb)
+}
+
+func TestAllowSlidingWindow(t *testing.T) {
+	l := setupTestLimiter(t)
+	ctx := context.Background()
+	key := Key{Name: "test:rl:ip:1.2.3.4:1s", Rule: Rule{Limit: 3, Window: time.Second}}
+
+	for i := 0; i < 3; i++ {
+		res, err := l.Allow(ctx, key)
+		if err != nil || !res.Allowed {
+			t.Fatalf("request %d: %+v, %v", i, res, err)
+		}
+	}
+	res, err := l.Allow(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Allowed || res.RetryAfter <= 0 || res.RetryAfter > time.Second {
+		t.Fatalf("fourth request: %+v, want rejected within the window", res)
+	}
+
+	time.Sleep(res.RetryAfter + 50*time.Millisecond)
+	if res, err := l.Allow(ctx, key); err != nil || !res.Allowed {
+		t.Fatalf("after the window: %+v, %v", res, err)
+	}
+}
+
+func TestAllowRejectedRequestsAreNotCounted(t *testing.T) {
+	l := setupTestLimiter(t)
+	ctx := context.Background()
+	ip := Key{Name: "test:rl:ip:1.2.3.4:60s", Rule: Rule{Limit: 10, Window: time.Minute}}
+	email := Key{Name: "test:rl:email:a@example.com:60s", Rule: Rule{Limit: 1, Window: time.Minute}}
+
+	if res, err := l.Allow(ctx, ip, email); err != nil || !res.Allowed {
+		t.Fatalf("first request: %+v, %v", res, err)
+	}
+	for i := 0; i < 5; i++ {
+		res, err := l.Allow(ctx, ip, email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Allowed || res.Rejected != 1 {
+			t.Fatalf("request %d: %+v, want rejected by the email key", i, res)
+		}
+	}
+	// Only the allowed request used up the IP limit.
+	n, err := l.rdb.ZCard(ctx, ip.Name).Result()
+	if err != nil || n != 1 {
+		t.Fatalf("ip bucket holds %d requests, %v; want 1", n, err)
+	}
+}
+
+func TestAllowIgnoresDisabledRules(t *testing.T) {
+	l := setupTestLimiter(t)
+	res, err := l.Allow(context.Background(), Key{Name: "test:rl:off", Rule: Rule{Limit: 0, Window: time.Minute}})
+	if err != nil || !res.Allowed {
+		t.Fatalf("%+v, %v", res, err)
+	}
+}
+
+func TestRekeyMergesCounts(t *testing.T) {
+	l := setupTestLimiter(t)
+	ctx := context.Background()
+	rule := Rule{Limit: 3, Window: time.Minute}
+	b := Bucket{Name: "email", Rules: []Rule{rule}}
+	old := Key{Name: KeyName("test", "email", "old@example.com", rule), Rule: rule}
+	renamed := Key{Name: KeyName("test", "email", "new@example.com", rule), Rule: rule}
+
+	for _, k := range []Key{old, old, renamed} {
+		if res, err := l.Allow(ctx, k); err != nil || !res.Allowed {
+			t.Fatalf("%s: %+v, %v", k.Name, res, err)
+		}
+	}
+	if err := l.Rekey(ctx, "test", b, "old@example.com", "new@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if res, err := l.Allow(ctx, renamed); err != nil || !res.Allowed {
+		t.Fatalf("after rekey: %+v, %v", res, err)
+	}
+	if res, err := l.Allow(ctx, renamed); err != nil || res.Allowed {
+		t.Fatalf("requests under the old key were not carried over: %+v, %v", res, err)
+	}
+	if n, err := l.rdb.Exists(ctx, old.Name).Result(); err != nil || n != 0 {
+		t.Errorf("old key left behind: %d, %v", n, err)
+	}
+	if ttl, err := l.rdb.PTTL(ctx, renamed.Name).Result(); err != nil || ttl <= 0 {
+		t.Errorf("merged key TTL = %v, %v", ttl, err)
+	}
+}
+
+func TestRekeyNilLimiter(t *testing.T) {
+	var l *Limiter
+	b := Bucket{Name: "email", Rules: []Rule{{Limit: 1, Window: time.Minute}}}
+	if err := l.Rekey(context.Background(), "test", b, "a", "b"); err != nil {
+		t.Errorf("nil Limiter: %v", err)
+	}
+}
+
+func TestMiddlewareRejectsWithRetryAfter(t *testing.T) {
+	l := setupTestLimiter(t)
+	byHeader := Bucket{
+		Name:  "client",
+		Key:   func(r *http.Request) string { return r.Header.Get("X-Client") },
+		Rules: []Rule{{Limit: 2, Window: time.Minute}},
+	}
+	h := l.Middleware("test", byHeader)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	send := func(client string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("X-Client", client)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+	for i := 0; i < 2; i++ {
+		if w := send("a"); w.Code != http.StatusNoContent {
+			t.Fatalf("request %d: status %d", i, w.Code)
+		}
+	}
+	w := send("a")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", w.Code)
+	}
+	if s, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || s < 1 || s > 60 {
+		t.Errorf("Retry-After = %q", w.Header().Get("Retry-After"))
+	}
+	if w := send("b"); w.Code != http.StatusNoContent {
+		t.Errorf("another client was limited: status %d", w.Code)
+	}
+	if w := send(""); w.Code != http.StatusNoContent {
+		t.Errorf("request without a key was limited: status %d", w.Code)
+	}
+}
+
+func TestMiddlewareWithoutRedis(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// A nil Limiter (no Redis configured) limits nothing.
+	var nilLimiter *Limiter
+	w := httptest.NewRecorder()
+	nilLimiter.Middleware("test", Global(Rule{Limit: 1, Window: time.Minute}))(next).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("nil limiter: status %d", w.Code)
+	}
+
+	// An unreachable Redis lets requests through.
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", DialTimeout: 100 * time.Millisecond, MaxRetries: -1})
+	defer func() { _ = rdb.Close() }()
+	w = httptest.NewRecorder()
+	New(rdb).Middleware("test", Global(Rule{Limit: 1, Window: time.Minute}))(next).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("unreachable Redis: status %d", w.Code)
+	}
+}
+
+func TestKeyName(t *testing.T) {
+	got := KeyName("auth", "email", "user@example.com", Rule{Limit: 20, Window: time.Hour})
+	if got != "auth:rl:email:user@example.com:3600s" {
+		t.Errorf("KeyName = %q", got)
+	}
+}
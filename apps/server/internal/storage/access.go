@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/model"
+)
+
+// ErrForbidden is returned when the user can see an architecture but their
+// workspace role does not allow the change.
+var ErrForbidden = errors.New("storage: insufficient workspace role")
+
+// Access is what a user needs to be allowed to do with an architecture.
+// Personal architectures (no workspace) grant every level to their
+// creator; workspace architectures grant levels by member role.
+type Access int
+
+const (
+	// AccessRead lets any workspace member open and simulate.
+	AccessRead Access = iota
+	// AccessWrite lets editors change the design.
+	AccessWrite
+	// AccessManage lets admins delete and move architectures between
+	// workspaces.
+	AccessManage
+)
+
+var accessRoles = map[Access][]string{
+	AccessRead:   {model.RoleOwner, model.RoleAdmin, model.RoleEditor, model.RoleViewer},
+	AccessWrite:  {model.RoleOwner, model.RoleAdmin, model.RoleEditor},
+	AccessManage: {model.RoleOwner, model.RoleAdmin},
+}
+
+// canAccess returns an SQL condition on the architectures row in scope that
+// holds when the user bound to userParam has the given access.
+func canAccess(userParam string, level Access) string {
+	return `((workspace_id IS NULL AND user_id = ` + userParam + `)
+		 OR workspace_id IN (SELECT workspace_id FROM workspace_members
+		                     WHERE user_id = ` + userParam + ` AND role IN (` + roleList(level) + `)))`
+}
+
+// memberOf returns an SQL condition that holds when the user bound to
+// userParam has a role granting level in workspace, a parameter or a
+// qualified column.
+func memberOf(workspace, userParam string, level Access) string {
+	return `EXISTS (SELECT 1 FROM workspace_members
+		         WHERE workspace_id = ` + workspace + ` AND user_id = ` + userParam + ` AND role IN (` + roleList(level) + `))`
+}
+
+func roleList(level Access) string {
+	return "'" + strings.Join(accessRoles[level], "', '") + "'"
+}
+
+// denied explains a pgx.ErrNoRows from a write or manage query on
+// architecture id: ErrForbidden when the user can still read it, the
+// original error otherwise.
+func (s *Storage) denied(ctx context.Context, err error, id, userID pgtype.UUID) error {
+	if err != pgx.ErrNoRows {
+		return err
+	}
+	var readable bool
+	if qerr := s.Pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM architectures WHERE id = $1 AND deleted_at IS NULL AND `+canAccess("$2", AccessRead)+`)`,
+		id, userID,
+	).Scan(&readable); qerr != nil {
+		return qerr
+	}
+	if readable {
+		return ErrForbidden
+	}
+	return err
+}
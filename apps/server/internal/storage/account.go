@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/model"
+)
+
+// purgeBatch bounds the accounts one PurgeDeletedUsers call deletes.
+const purgeBatch = 100
+
+// ScheduleUserDeletion starts the deletion grace period of an account.
+// It is ErrLastOwner while the user is the only owner of a workspace that
+// has other members: ownership has to be handed over first. Scheduling an
+// account again keeps the original date.
+func (s *Storage) ScheduleUserDeletion(ctx context.Context, id pgtype.UUID) (model.User, error) {
+	var u model.User
+	err := pgx.BeginFunc(ctx, s.Pool, func(tx pgx.Tx) error {
+		var lastOwner bool
+		err := tx.QueryRow(ctx,
+			`SELECT EXISTS (
+			     SELECT 1 FROM workspace_members m
+			     WHERE m.user_id = $1 AND m.role = $2
+			       AND NOT EXISTS (SELECT 1 FROM workspace_members o
+			                       WHERE o.workspace_id = m.workspace_id AND o.user_id <> $1 AND o.role = $2)
+			       AND EXISTS (SELECT 1 FROM workspace_members o
+			                   WHERE o.workspace_id = m.workspace_id AND o.user_id <> $1)
+			 )`,
+			id, model.RoleOwner,
+		).Scan(&lastOwner)
+		if err != nil {
+			return err
+		}
+		if lastOwner {
+			return ErrLastOwner
+		}
+		u, err = scanUser(tx.QueryRow(ctx,
+			`UPDATE users SET deleted_at = COALESCE(deleted_at, now())
+			 WHERE id = $1
+			 RETURNING `+userColumns,
+			id,
+		))
+		return err
+	})
+	return u, err
+}
+
+// CancelUserDeletion keeps an account that was scheduled for deletion.
+func (s *Storage) CancelUserDeletion(ctx context.Context, id pgtype.UUID) error {
+	_, err := s.Pool.Exec(ctx,
+		`UPDATE users SET deleted_at = NULL WHERE id = $1`, id)
+	return err
+}
+
+// PurgeDeletedUsers permanently deletes accounts scheduled for deletion
+// more than grace ago and reports how many it deleted.
+func (s *Storage) PurgeDeletedUsers(ctx context.Context, grace time.Duration) (int, error) {
+	rows, err := s.Pool.Query(ctx,
+		`SELECT id FROM users
+		 WHERE deleted_at < now() - make_interval(secs => $1)
+		 ORDER BY deleted_at
+		 LIMIT $2`,
+		grace.Seconds(), purgeBatch,
+	)
+	if err != nil {
+		return 0, err
+	}
+	var ids []pgtype.UUID
+	for rows.Next() {
+		var id pgtype.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, id := range ids {
+		purged, err := s.purgeUser(ctx, id, grace)
+		if err != nil {
+			return n, err
+		}
+		if purged {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// purgeUser deletes one account and everything personal to it in a
+// single transaction, unless a sign-in has cancelled the deletion since
+// it was picked.
+//
+// Workspaces the user was alone in go, handing their architectures back
+// to their creators; in the others the longest-standing member takes over
+// as owner if the user was the last one. Personal architectures are
+// deleted, while the user's simulation results stay on the leaderboard,
+// detached from the user and from those architectures. Architectures the
+// user created in workspaces stay there without a creator.
+func (s *Storage) purgeUser(ctx context.Context, id pgtype.UUID, grace time.Duration) (bool, error) {
+	purged := false
+	err := pgx.BeginFunc(ctx, s.Pool, func(tx pgx.Tx) error {
+		var email string
+		err := tx.QueryRow(ctx,
+			`SELECT email FROM users
+			 WHERE id = $1 AND deleted_at < now() - make_interval(secs => $2)
+			 FOR UPDATE`,
+			id, grace.Seconds(),
+		).Scan(&email)
+		if err == pgx.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, stmt := range []struct {
+			sql  string
+			args []any
+		}{
+			{`DELETE FROM workspaces w
+			  WHERE w.id IN (SELECT workspace_id FROM workspace_members WHERE user_id = $1)
+			    AND NOT EXISTS (SELECT 1 FROM workspace_members m WHERE m.workspace_id = w.id AND m.user_id <> $1)`,
+				[]any{id}},
+			{`UPDATE workspace_members m SET role = $2
+			  FROM (
+			      SELECT DISTINCT ON (workspace_id) workspace_id, user_id
+			      FROM workspace_members
+			      WHERE user_id <> $1
+			        AND workspace_id IN (SELECT workspace_id FROM workspace_members WHERE user_id = $1 AND role = $2)
+			        AND workspace_id NOT IN (SELECT workspace_id FROM workspace_members WHERE user_id <> $1 AND role = $2)
+			      ORDER BY workspace_id, created_at, user_id
+			  ) heir
+			  WHERE m.workspace_id = heir.workspace_id AND m.user_id = heir.user_id`,
+				[]any{id, model.RoleOwner}},
+			{`UPDATE simulation_results SET
+			      user_id = NULL,
+			      architecture_id = CASE WHEN architecture_id IN (
+			          SELECT a.id FROM architectures a WHERE a.user_id = $1 AND a.workspace_id IS NULL
+			      ) THEN NULL ELSE architecture_id END
+			  WHERE user_id = $1`,
+				[]any{id}},
+			{`DELETE FROM architectures WHERE user_id = $1 AND workspace_id IS NULL`, []any{id}},
+			{`UPDATE architectures SET user_id = NULL WHERE user_id = $1`, []any{id}},
+			{`UPDATE folders SET user_id = NULL WHERE user_id = $1 AND workspace_id IS NOT NULL`, []any{id}},
+			{`DELETE FROM session_log WHERE user_id = $1`, []any{id}},
+			{`DELETE FROM workspace_invites WHERE lower(email) = lower($1) AND accepted_at IS NULL`, []any{email}},
+			{`DELETE FROM email_outbox WHERE lower(recipient) = lower($1)`, []any{email}},
+			{`DELETE FROM users WHERE id = $1`, []any{id}},
+		} {
+			if _, err := tx.Exec(ctx, stmt.sql, stmt.args...); err != nil {
+				return err
+			}
+		}
+		purged = true
+		return nil
+	})
+	return purged, err
+}
@@ -2,44 +2,31 @@ package storage
 
 import (
 	"context"
+	"crypto/rand"
 	"encoding/json"
+	"log/slog"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/archdata"
 	"github.com/system-design-sandbox/server/internal/compress"
 	"github.com/system-design-sandbox/server/internal/model"
 )
 
-func (s *Storage) CreateArchitecture(ctx context.Context, userID pgtype.UUID, name string, description string, scenarioID *string, data json.RawMessage, isPublic bool, tags []string) (model.Architecture, error) {
-	gz, err := compress.Gzip(data)
-	if err != nil {
-		return model.Architecture{}, err
-	}
-	if tags == nil {
-		tags = []string{}
-	}
+const architectureColumns = `id, user_id, name, description, scenario_id, folder_id, workspace_id, slug, thumbnail_url, is_public, tags, created_at, updated_at`
 
+func scanArchitecture(row interface{ Scan(dest ...any) error }) (model.Architecture, error) {
 	var a model.Architecture
-	err = s.Pool.QueryRow(ctx,
-		`INSERT INTO architectures (user_id, name, description, scenario_id, data, is_public, tags)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7)
-		 RETURNING id, user_id, name, description, scenario_id, thumbnail_url, is_public, tags, created_at, updated_at`,
-		userID, name, description, scenarioID, gz, isPublic, tags,
-	).Scan(&a.ID, &a.UserID, &a.Name, &a.Description, &a.ScenarioID, &a.ThumbnailURL, &a.IsPublic, &a.Tags, &a.CreatedAt, &a.UpdatedAt)
-	if err != nil {
-		return model.Architecture{}, err
-	}
-	a.RawData = json.RawMessage(data)
-	return a, nil
+	err := row.Scan(&a.ID, &a.UserID, &a.Name, &a.Description, &a.ScenarioID, &a.FolderID, &a.WorkspaceID, &a.Slug, &a.ThumbnailURL, &a.IsPublic, &a.Tags, &a.CreatedAt, &a.UpdatedAt)
+	return a, err
 }
 
-func (s *Storage) GetArchitecture(ctx context.Context, id pgtype.UUID) (model.Architecture, error) {
+// scanArchitectureWithData scans architectureColumns followed by the gzipped
+// data column. Data saved in an older format version is upgraded in memory;
+// UpgradeArchitectures persists the result.
+func scanArchitectureWithData(row interface{ Scan(dest ...any) error }) (model.Architecture, error) {
 	var a model.Architecture
-	err := s.Pool.QueryRow(ctx,
-		`SELECT id, user_id, name, description, scenario_id, data, thumbnail_url, is_public, tags, created_at, updated_at
-		 FROM architectures WHERE id = $1`,
-		id,
-	).Scan(&a.ID, &a.UserID, &a.Name, &a.Description, &a.ScenarioID, &a.Data, &a.ThumbnailURL, &a.IsPublic, &a.Tags, &a.CreatedAt, &a.UpdatedAt)
+	err := row.Scan(&a.ID, &a.UserID, &a.Name, &a.Description, &a.ScenarioID, &a.FolderID, &a.WorkspaceID, &a.Slug, &a.ThumbnailURL, &a.IsPublic, &a.Tags, &a.CreatedAt, &a.UpdatedAt, &a.Data)
 	if err != nil {
 		return model.Architecture{}, err
 	}
@@ -48,51 +35,104 @@ func (s *Storage) GetArchitecture(ctx context.Context, id pgtype.UUID) (model.Ar
 	if err != nil {
 		return model.Architecture{}, err
 	}
+	if upgraded, changed, err := archdata.Upgrade(raw); err != nil {
+		slog.Warn("architecture data upgrade failed", "id", a.ID, "error", err)
+	} else if changed {
+		raw = upgraded
+	}
 	a.RawData = json.RawMessage(raw)
 	return a, nil
 }
 
-func (s *Storage) GetArchitectureForUser(ctx context.Context, id, userID pgtype.UUID) (model.Architecture, error) {
-	var a model.Architecture
-	err := s.Pool.QueryRow(ctx,
-		`SELECT id, user_id, name, description, scenario_id, data, thumbnail_url, is_public, tags, created_at, updated_at
-		 FROM architectures WHERE id = $1 AND user_id = $2`,
-		id, userID,
-	).Scan(&a.ID, &a.UserID, &a.Name, &a.Description, &a.ScenarioID, &a.Data, &a.ThumbnailURL, &a.IsPublic, &a.Tags, &a.CreatedAt, &a.UpdatedAt)
+// architectureListColumns are architectureColumns plus the number of open
+// comment threads, selected from architectures.
+const architectureListColumns = architectureColumns + `,
+	(SELECT count(*) FROM comment_threads t WHERE t.architecture_id = architectures.id AND t.resolved_at IS NULL)`
+
+// scanArchitectureListItem scans architectureListColumns followed by any
+// extra columns into extra.
+func scanArchitectureListItem(row interface{ Scan(dest ...any) error }, extra ...any) (model.ArchitectureListItem, error) {
+	var a model.ArchitectureListItem
+	dest := append([]any{&a.ID, &a.UserID, &a.Name, &a.Description, &a.ScenarioID, &a.FolderID, &a.WorkspaceID, &a.Slug, &a.ThumbnailURL, &a.IsPublic, &a.Tags, &a.CreatedAt, &a.UpdatedAt, &a.UnresolvedThreads}, extra...)
+	err := row.Scan(dest...)
+	return a, err
+}
+
+// newSlug returns a short URL-safe identifier for share links.
+func newSlug() (string, error) {
+	const charset = "abcdefghijkmnpqrstuvwxyz23456789"
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = charset[b[i]%byte(len(charset))]
+	}
+	return string(b), nil
+}
+
+// CreateArchitecture saves a new architecture created by userID, either
+// personal or, with workspaceID, owned by that workspace. It returns a
+// *QuotaError when the user's plan has no room for it.
+func (s *Storage) CreateArchitecture(ctx context.Context, userID pgtype.UUID, name string, description string, scenarioID *string, folderID, workspaceID *pgtype.UUID, data json.RawMessage, isPublic bool, tags []string) (model.Architecture, error) {
+	gz, err := compress.Gzip(data)
+	if err != nil {
+		return model.Architecture{}, err
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	slug, err := newSlug()
 	if err != nil {
 		return model.Architecture{}, err
 	}
 
-	raw, err := compress.Gunzip(a.Data)
+	var a model.Architecture
+	err = pgx.BeginFunc(ctx, s.Pool, func(tx pgx.Tx) error {
+		a, err = scanArchitecture(tx.QueryRow(ctx,
+			`INSERT INTO architectures (user_id, name, description, scenario_id, folder_id, workspace_id, data, is_public, tags, slug, data_version, search_text)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			 RETURNING `+architectureColumns,
+			userID, name, description, scenarioID, folderID, workspaceID, gz, isPublic, tags, slug, archdata.CurrentVersion, archdata.SearchText(data),
+		))
+		if err != nil {
+			return err
+		}
+		return s.enforceQuota(ctx, tx, userID, usageDelta{architectures: 1, bytes: int64(len(gz))})
+	})
 	if err != nil {
 		return model.Architecture{}, err
 	}
-	a.RawData = json.RawMessage(raw)
+	a.RawData = json.RawMessage(data)
 	return a, nil
 }
 
-func (s *Storage) ListArchitecturesByUser(ctx context.Context, userID pgtype.UUID) ([]model.ArchitectureListItem, error) {
-	rows, err := s.Pool.Query(ctx,
-		`SELECT id, user_id, name, description, scenario_id, thumbnail_url, is_public, tags, created_at, updated_at
-		 FROM architectures WHERE user_id = $1 ORDER BY updated_at DESC`,
-		userID,
-	)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+func (s *Storage) GetArchitecture(ctx context.Context, id pgtype.UUID) (model.Architecture, error) {
+	return scanArchitectureWithData(s.Pool.QueryRow(ctx,
+		`SELECT `+architectureColumns+`, data FROM architectures WHERE id = $1`,
+		id,
+	))
+}
 
-	var items []model.ArchitectureListItem
-	for rows.Next() {
-		var a model.ArchitectureListItem
-		if err := rows.Scan(&a.ID, &a.UserID, &a.Name, &a.Description, &a.ScenarioID, &a.ThumbnailURL, &a.IsPublic, &a.Tags, &a.CreatedAt, &a.UpdatedAt); err != nil {
-			return nil, err
-		}
-		items = append(items, a)
-	}
-	return items, rows.Err()
+// GetArchitectureForUser returns an architecture the user can read.
+func (s *Storage) GetArchitectureForUser(ctx context.Context, id, userID pgtype.UUID) (model.Architecture, error) {
+	return scanArchitectureWithData(s.Pool.QueryRow(ctx,
+		`SELECT `+architectureColumns+`, data FROM architectures WHERE id = $1 AND deleted_at IS NULL AND `+canAccess("$2", AccessRead),
+		id, userID,
+	))
+}
+
+// GetPublicArchitectureBySlug returns a shared architecture. Private ones are reported as pgx.ErrNoRows.
+func (s *Storage) GetPublicArchitectureBySlug(ctx context.Context, slug string) (model.Architecture, error) {
+	return scanArchitectureWithData(s.Pool.QueryRow(ctx,
+		`SELECT `+architectureColumns+`, data FROM architectures WHERE slug = $1 AND is_public = true AND deleted_at IS NULL`,
+		slug,
+	))
 }
 
+// UpdateArchitectureForUser saves an architecture the user can write.
+// Workspace viewers get ErrForbidden, and data growing past the owner's
+// storage quota a *QuotaError.
 func (s *Storage) UpdateArchitectureForUser(ctx context.Context, id, userID pgtype.UUID, name string, description string, data json.RawMessage, isPublic bool, tags []string) (model.Architecture, error) {
 	gz, err := compress.Gzip(data)
 	if err != nil {
@@ -102,15 +142,32 @@ func (s *Storage) UpdateArchitectureForUser(ctx context.Context, id, userID pgty
 		tags = []string{}
 	}
 
+	// Growth counts against the architecture's owner, not the editor.
 	var a model.Architecture
-	err = s.Pool.QueryRow(ctx,
-		`UPDATE architectures SET name = $3, description = $4, data = $5, is_public = $6, tags = $7, updated_at = now()
-		 WHERE id = $1 AND user_id = $2
-		 RETURNING id, user_id, name, description, scenario_id, thumbnail_url, is_public, tags, created_at, updated_at`,
-		id, userID, name, description, gz, isPublic, tags,
-	).Scan(&a.ID, &a.UserID, &a.Name, &a.Description, &a.ScenarioID, &a.ThumbnailURL, &a.IsPublic, &a.Tags, &a.CreatedAt, &a.UpdatedAt)
+	err = pgx.BeginFunc(ctx, s.Pool, func(tx pgx.Tx) error {
+		var oldSize int64
+		if err := tx.QueryRow(ctx,
+			`SELECT octet_length(data) FROM architectures
+			 WHERE id = $1 AND deleted_at IS NULL AND `+canAccess("$2", AccessWrite)+`
+			 FOR UPDATE`,
+			id, userID,
+		).Scan(&oldSize); err != nil {
+			return err
+		}
+		a, err = scanArchitecture(tx.QueryRow(ctx,
+			`UPDATE architectures SET name = $2, description = $3, data = $4, is_public = $5, tags = $6,
+			        data_version = $7, search_text = $8, upgrade_error = NULL, updated_at = now()
+			 WHERE id = $1
+			 RETURNING `+architectureColumns,
+			id, name, description, gz, isPublic, tags, archdata.CurrentVersion, archdata.SearchText(data),
+		))
+		if err != nil {
+			return err
+		}
+		return s.enforceQuota(ctx, tx, a.UserID, usageDelta{bytes: int64(len(gz)) - oldSize})
+	})
 	if err != nil {
-		return model.Architecture{}, err
+		return model.Architecture{}, s.denied(ctx, err, id, userID)
 	}
 	a.RawData = json.RawMessage(data)
 	return a, nil
@@ -125,13 +182,13 @@ func (s *Storage) UpdateArchitecture(ctx context.Context, id pgtype.UUID, name s
 		tags = []string{}
 	}
 
-	var a model.Architecture
-	err = s.Pool.QueryRow(ctx,
-		`UPDATE architectures SET name = $2, description = $3, data = $4, is_public = $5, tags = $6, updated_at = now()
+	a, err := scanArchitecture(s.Pool.QueryRow(ctx,
+		`UPDATE architectures SET name = $2, description = $3, data = $4, is_public = $5, tags = $6,
+		        data_version = $7, search_text = $8, upgrade_error = NULL, updated_at = now()
 		 WHERE id = $1
-		 RETURNING id, user_id, name, description, scenario_id, thumbnail_url, is_public, tags, created_at, updated_at`,
-		id, name, description, gz, isPublic, tags,
-	).Scan(&a.ID, &a.UserID, &a.Name, &a.Description, &a.ScenarioID, &a.ThumbnailURL, &a.IsPublic, &a.Tags, &a.CreatedAt, &a.UpdatedAt)
+		 RETURNING `+architectureColumns,
+		id, name, description, gz, isPublic, tags, archdata.CurrentVersion, archdata.SearchText(data),
+	))
 	if err != nil {
 		return model.Architecture{}, err
 	}
@@ -139,13 +196,19 @@ func (s *Storage) UpdateArchitecture(ctx context.Context, id pgtype.UUID, name s
 	return a, nil
 }
 
+// DeleteArchitectureForUser moves an architecture the user can manage to
+// the trash. Workspace editors and viewers get ErrForbidden.
 func (s *Storage) DeleteArchitectureForUser(ctx context.Context, id, userID pgtype.UUID) error {
-	tag, err := s.Pool.Exec(ctx, `DELETE FROM architectures WHERE id = $1 AND user_id = $2`, id, userID)
+	tag, err := s.Pool.Exec(ctx,
+		`UPDATE architectures SET deleted_at = now(), deleted_by = $2
+		 WHERE id = $1 AND deleted_at IS NULL AND `+canAccess("$2", AccessManage),
+		id, userID,
+	)
 	if err != nil {
 		return err
 	}
 	if tag.RowsAffected() == 0 {
-		return pgx.ErrNoRows
+		return s.denied(ctx, pgx.ErrNoRows, id, userID)
 	}
 	return nil
 }
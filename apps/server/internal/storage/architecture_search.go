@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/model"
+)
+
+// Sort orders for ListArchitecturesByUser.
+const (
+	SortUpdated   = "updated"
+	SortCreated   = "created"
+	SortName      = "name"
+	SortRelevance = "relevance"
+)
+
+var (
+	// ErrInvalidCursor is returned for a cursor that was not produced by a
+	// listing with the same sort order.
+	ErrInvalidCursor = errors.New("storage: invalid cursor")
+	// ErrInvalidSort is returned for an unknown sort order, or relevance
+	// without a query.
+	ErrInvalidSort = errors.New("storage: invalid sort")
+)
+
+// ArchitectureFilter narrows and orders ListArchitecturesByUser.
+type ArchitectureFilter struct {
+	// Query is a web-search style query (quoted phrases, OR, -word) over
+	// name, tags, description and node labels.
+	Query      string
+	ScenarioID *string
+	// FolderID limits the listing to one folder; Unfiled to architectures
+	// outside any folder.
+	FolderID *pgtype.UUID
+	Unfiled  bool
+	// WorkspaceID lists a workspace's architectures instead of the ones
+	// the user created. The user must be a member.
+	WorkspaceID *pgtype.UUID
+	// Tags must all be present on the architecture.
+	Tags []string
+	// Sort is one of the Sort* constants. Empty means relevance when Query
+	// is set and updated otherwise.
+	Sort string
+	// Limit caps the page size; zero returns every match.
+	Limit  int
+	Cursor string
+}
+
+// sortSpec is the keyset for one sort order. key is compared with the
+// cursor as typ; desc pages towards smaller keys. The relevance key is
+// built per query.
+type sortSpec struct {
+	key  string
+	typ  string
+	desc bool
+}
+
+var sortSpecs = map[string]sortSpec{
+	SortUpdated:   {key: "updated_at", typ: "timestamptz", desc: true},
+	SortCreated:   {key: "created_at", typ: "timestamptz", desc: true},
+	SortName:      {key: "lower(name)", typ: "text"},
+	SortRelevance: {typ: "real", desc: true},
+}
+
+// ValidArchitectureSort reports whether sort can be used with a filter
+// that has (or lacks) a query.
+func ValidArchitectureSort(sort string, hasQuery bool) bool {
+	if sort == SortRelevance {
+		return hasQuery
+	}
+	_, ok := sortSpecs[sort]
+	return ok || sort == ""
+}
+
+type architectureCursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	ID   string `json:"id"`
+}
+
+// ListArchitecturesByUser returns the architectures the user created (or,
+// with f.WorkspaceID, the workspace's) matching f and, when there are more,
+// the cursor of the next page.
+func (s *Storage) ListArchitecturesByUser(ctx context.Context, userID pgtype.UUID, f ArchitectureFilter) ([]model.ArchitectureListItem, string, error) {
+	sort := f.Sort
+	if sort == "" {
+		sort = SortUpdated
+		if f.Query != "" {
+			sort = SortRelevance
+		}
+	}
+	if !ValidArchitectureSort(sort, f.Query != "") {
+		return nil, "", ErrInvalidSort
+	}
+	spec := sortSpecs[sort]
+
+	args := []any{userID}
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	where := []string{"deleted_at IS NULL", "user_id = $1"}
+	if f.WorkspaceID != nil {
+		where = []string{"deleted_at IS NULL", "workspace_id = " + arg(*f.WorkspaceID), memberOf("architectures.workspace_id", "$1", AccessRead)}
+	}
+	if f.Query != "" {
+		tsquery := "websearch_to_tsquery('simple', " + arg(f.Query) + ")"
+		where = append(where, "search_vector @@ "+tsquery)
+		if sort == SortRelevance {
+			spec.key = "ts_rank(search_vector, " + tsquery + ")"
+		}
+	}
+	if f.ScenarioID != nil {
+		where = append(where, "scenario_id = "+arg(*f.ScenarioID))
+	}
+	if f.FolderID != nil {
+		where = append(where, "folder_id = "+arg(*f.FolderID))
+	} else if f.Unfiled {
+		where = append(where, "folder_id IS NULL")
+	}
+	if len(f.Tags) > 0 {
+		where = append(where, "tags @> "+arg(f.Tags)+"::text[]")
+	}
+	if f.Cursor != "" {
+		c, err := decodeArchitectureCursor(f.Cursor)
+		if err != nil || c.Sort != sort {
+			return nil, "", ErrInvalidCursor
+		}
+		id, err := parseCursorUUID(c.ID)
+		if err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		op := ">"
+		if spec.desc {
+			op = "<"
+		}
+		where = append(where, "("+spec.key+", id) "+op+" ("+arg(c.Key)+"::"+spec.typ+", "+arg(id)+")")
+	}
+
+	dir := "ASC"
+	if spec.desc {
+		dir = "DESC"
+	}
+	query := `SELECT ` + architectureListColumns + `, (` + spec.key + `)::text
+		 FROM architectures WHERE ` + strings.Join(where, " AND ") + `
+		 ORDER BY ` + spec.key + ` ` + dir + `, id ` + dir
+	if f.Limit > 0 {
+		query += ` LIMIT ` + arg(f.Limit+1)
+	}
+
+	rows, err := s.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var items []model.ArchitectureListItem
+	var keys []string
+	for rows.Next() {
+		var key string
+		a, err := scanArchitectureListItem(rows, &key)
+		if err != nil {
+			return nil, "", err
+		}
+		items = append(items, a)
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if f.Limit <= 0 || len(items) <= f.Limit {
+		return items, "", nil
+	}
+	items = items[:f.Limit]
+	last := items[len(items)-1]
+	next, err := encodeArchitectureCursor(architectureCursor{Sort: sort, Key: keys[f.Limit-1], ID: uuidString(last.ID)})
+	return items, next, err
+}
+
+func encodeArchitectureCursor(c architectureCursor) (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeArchitectureCursor(s string) (architectureCursor, error) {
+	var c architectureCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(b, &c)
+	return c, err
+}
+
+func parseCursorUUID(s string) (pgtype.UUID, error) {
+	var id pgtype.UUID
+	err := id.Scan(s)
+	return id, err
+}
+
+func uuidString(id pgtype.UUID) string {
+	v, _ := id.Value()
+	s, _ := v.(string)
+	return s
+}
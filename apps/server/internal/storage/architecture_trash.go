@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/model"
+)
+
+// ListTrashForUser returns the deleted architectures the user can manage,
+// most recently deleted first.
+func (s *Storage) ListTrashForUser(ctx context.Context, userID pgtype.UUID) ([]model.ArchitectureListItem, error) {
+	rows, err := s.Pool.Query(ctx,
+		`SELECT `+architectureListColumns+`, deleted_at FROM architectures
+		 WHERE deleted_at IS NOT NULL AND `+canAccess("$1", AccessManage)+`
+		 ORDER BY deleted_at DESC, id`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []model.ArchitectureListItem{}
+	for rows.Next() {
+		var deletedAt pgtype.Timestamptz
+		a, err := scanArchitectureListItem(rows, &deletedAt)
+		if err != nil {
+			return nil, err
+		}
+		a.DeletedAt = &deletedAt
+		items = append(items, a)
+	}
+	return items, rows.Err()
+}
+
+// RestoreArchitectureForUser takes an architecture the user can manage out
+// of the trash. A restored architecture whose folder is gone lands at the
+// top level.
+func (s *Storage) RestoreArchitectureForUser(ctx context.Context, id, userID pgtype.UUID) (model.Architecture, error) {
+	a, err := scanArchitecture(s.Pool.QueryRow(ctx,
+		`UPDATE architectures SET deleted_at = NULL, deleted_by = NULL, updated_at = now()
+		 WHERE id = $1 AND deleted_at IS NOT NULL AND `+canAccess("$2", AccessManage)+`
+		 RETURNING `+architectureColumns,
+		id, userID,
+	))
+	if err != nil {
+		return model.Architecture{}, s.trashDenied(ctx, err, id, userID)
+	}
+	return a, nil
+}
+
+// PurgeArchitectureForUser permanently deletes an architecture from the
+// trash together with its simulation results and comments.
+func (s *Storage) PurgeArchitectureForUser(ctx context.Context, id, userID pgtype.UUID) error {
+	tag, err := s.Pool.Exec(ctx,
+		`DELETE FROM architectures WHERE id = $1 AND deleted_at IS NOT NULL AND `+canAccess("$2", AccessManage),
+		id, userID,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return s.trashDenied(ctx, pgx.ErrNoRows, id, userID)
+	}
+	return nil
+}
+
+// PurgeDeletedArchitectures permanently deletes architectures that have
+// been in the trash for longer than retention and reports how many.
+func (s *Storage) PurgeDeletedArchitectures(ctx context.Context, retention time.Duration) (int64, error) {
+	tag, err := s.Pool.Exec(ctx,
+		`DELETE FROM architectures WHERE deleted_at < now() - make_interval(secs => $1)`,
+		retention.Seconds(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// trashDenied is denied for architectures in the trash: ErrForbidden when
+// the user could read the deleted architecture but not manage it.
+func (s *Storage) trashDenied(ctx context.Context, err error, id, userID pgtype.UUID) error {
+	if err != pgx.ErrNoRows {
+		return err
+	}
+	var readable bool
+	if qerr := s.Pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM architectures WHERE id = $1 AND deleted_at IS NOT NULL AND `+canAccess("$2", AccessRead)+`)`,
+		id, userID,
+	).Scan(&readable); qerr != nil {
+		return qerr
+	}
+	if readable {
+		return ErrForbidden
+	}
+	return err
+}
@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/archdata"
+	"github.com/system-design-sandbox/server/internal/compress"
+)
+
+// ArchitectureUpgradeFailure is an architecture whose data could not be
+// brought to the current format version.
+type ArchitectureUpgradeFailure struct {
+	ID    pgtype.UUID `json:"id"`
+	Error string      `json:"error"`
+}
+
+// ArchitectureUpgradeReport summarizes one UpgradeArchitectures run.
+type ArchitectureUpgradeReport struct {
+	Checked  int `json:"checked"`
+	Upgraded int `json:"upgraded"`
+	// Skipped counts rows saved by their users while being upgraded; the
+	// save already brought them to the current version.
+	Skipped int                          `json:"skipped"`
+	Failed  []ArchitectureUpgradeFailure `json:"failed"`
+}
+
+// FailedArchitectureUpgrade is a stored architecture whose upgrade_error
+// is set, as listed for admins.
+type FailedArchitectureUpgrade struct {
+	ID          pgtype.UUID `json:"id"`
+	UserID      pgtype.UUID `json:"user_id"`
+	WorkspaceID pgtype.UUID `json:"workspace_id"`
+	Name        string      `json:"name"`
+	DataVersion *string     `json:"data_version"`
+	Error       string      `json:"error"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// errSavedMeanwhile means an architecture changed between being read for
+// an upgrade and the upgrade being written, so the upgrade was dropped.
+var errSavedMeanwhile = errors.New("storage: architecture saved during upgrade")
+
+// UpgradeArchitectures rewrites every architecture whose data_version is
+// not archdata.CurrentVersion, batchSize rows at a time, and refreshes its
+// search_text. Rows that
+// fail keep their data and get upgrade_error set, which admins list with
+// ListFailedArchitectureUpgrades; they are retried on the next run. updated_at is left alone since the user did not edit anything.
+// Every write is conditional on data_version still being the one read, so
+// a save that lands in between wins over the stale upgrade.
+func (s *Storage) UpgradeArchitectures(ctx context.Context, batchSize int) (ArchitectureUpgradeReport, error) {
+	var report ArchitectureUpgradeReport
+	var cursor pgtype.UUID
+	for {
+		rows, err := s.Pool.Query(ctx,
+			`SELECT id, data_version, data FROM architectures
+			 WHERE data_version IS DISTINCT FROM $1 AND ($2::uuid IS NULL OR id > $2)
+			 ORDER BY id LIMIT $3`,
+			archdata.CurrentVersion, cursor, batchSize,
+		)
+		if err != nil {
+			return report, err
+		}
+		type pending struct {
+			id      pgtype.UUID
+			version *string
+			data    []byte
+		}
+		var batch []pending
+		for rows.Next() {
+			var p pending
+			if err := rows.Scan(&p.id, &p.version, &p.data); err != nil {
+				rows.Close()
+				return report, err
+			}
+			batch = append(batch, p)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return report, err
+		}
+		if len(batch) == 0 {
+			return report, nil
+		}
+
+		for _, p := range batch {
+			cursor = p.id
+			report.Checked++
+			changed, err := s.upgradeArchitecture(ctx, p.id, p.version, p.data)
+			if errors.Is(err, errSavedMeanwhile) {
+				report.Skipped++
+				continue
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return report, ctx.Err()
+				}
+				msg := err.Error()
+				tag, err := s.Pool.Exec(ctx,
+					`UPDATE architectures SET upgrade_error = $3
+					 WHERE id = $1 AND data_version IS NOT DISTINCT FROM $2`,
+					p.id, p.version, msg,
+				)
+				if err != nil {
+					return report, err
+				}
+				if tag.RowsAffected() == 0 {
+					report.Skipped++
+					continue
+				}
+				report.Failed = append(report.Failed, ArchitectureUpgradeFailure{ID: p.id, Error: msg})
+				continue
+			}
+			if changed {
+				report.Upgraded++
+			}
+		}
+	}
+}
+
+// ListFailedArchitectureUpgrades returns up to limit architectures the
+// last upgrade run could not bring to the current format version, most
+// recently edited first.
+func (s *Storage) ListFailedArchitectureUpgrades(ctx context.Context, limit int) ([]FailedArchitectureUpgrade, error) {
+	rows, err := s.Pool.Query(ctx,
+		`SELECT id, user_id, workspace_id, name, data_version, upgrade_error, updated_at
+		 FROM architectures WHERE upgrade_error IS NOT NULL
+		 ORDER BY updated_at DESC, id LIMIT $1`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	failed := []FailedArchitectureUpgrade{}
+	for rows.Next() {
+		var f FailedArchitectureUpgrade
+		if err := rows.Scan(&f.ID, &f.UserID, &f.WorkspaceID, &f.Name, &f.DataVersion, &f.Error, &f.UpdatedAt); err != nil {
+			return nil, err
+		}
+		failed = append(failed, f)
+	}
+	return failed, rows.Err()
+}
+
+// upgradeArchitecture upgrades one architecture read at version. It
+// returns errSavedMeanwhile if the row has changed since.
+func (s *Storage) upgradeArchitecture(ctx context.Context, id pgtype.UUID, version *string, gz []byte) (bool, error) {
+	raw, err := compress.Gunzip(gz)
+	if err != nil {
+		return false, fmt.Errorf("gunzip: %w", err)
+	}
+	upgraded, changed, err := archdata.Upgrade(raw)
+	if err != nil {
+		return false, err
+	}
+	if !changed {
+		tag, err := s.Pool.Exec(ctx,
+			`UPDATE architectures SET data_version = $3, search_text = $4, upgrade_error = NULL
+			 WHERE id = $1 AND data_version IS NOT DISTINCT FROM $2`,
+			id, version, archdata.CurrentVersion, archdata.SearchText(raw),
+		)
+		if err == nil && tag.RowsAffected() == 0 {
+			err = errSavedMeanwhile
+		}
+		return false, err
+	}
+	if gz, err = compress.Gzip(upgraded); err != nil {
+		return false, err
+	}
+	tag, err := s.Pool.Exec(ctx,
+		`UPDATE architectures SET data = $3, data_version = $4, search_text = $5, upgrade_error = NULL
+		 WHERE id = $1 AND data_version IS NOT DISTINCT FROM $2`,
+		id, version, gz, archdata.CurrentVersion, archdata.SearchText(upgraded),
+	)
+	if err == nil && tag.RowsAffected() == 0 {
+		err = errSavedMeanwhile
+	}
+	return err == nil, err
+}
+
+// IndexArchitectureSearch fills in search_text of architectures that have
+// none yet, batchSize rows at a time, and reports how many it indexed.
+// Like the upgrade, it leaves rows a save has indexed meanwhile alone.
+func (s *Storage) IndexArchitectureSearch(ctx context.Context, batchSize int) (int, error) {
+	n := 0
+	var cursor pgtype.UUID
+	for {
+		rows, err := s.Pool.Query(ctx,
+			`SELECT id, data FROM architectures
+			 WHERE search_text IS NULL AND ($1::uuid IS NULL OR id > $1)
+			 ORDER BY id LIMIT $2`,
+			cursor, batchSize,
+		)
+		if err != nil {
+			return n, err
+		}
+		type pending struct {
+			id   pgtype.UUID
+			data []byte
+		}
+		var batch []pending
+		for rows.Next() {
+			var p pending
+			if err := rows.Scan(&p.id, &p.data); err != nil {
+				rows.Close()
+				return n, err
+			}
+			batch = append(batch, p)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return n, err
+		}
+		if len(batch) == 0 {
+			return n, nil
+		}
+
+		for _, p := range batch {
+			cursor = p.id
+			// Undecodable data is indexed as empty, as SearchText does for
+			// invalid data, so that it is not picked up again.
+			raw, _ := compress.Gunzip(p.data)
+			tag, err := s.Pool.Exec(ctx,
+				`UPDATE architectures SET search_text = $2 WHERE id = $1 AND search_text IS NULL`,
+				p.id, archdata.SearchText(raw),
+			)
+			if err != nil {
+				return n, err
+			}
+			n += int(tag.RowsAffected())
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/model"
+)
+
+const threadColumns = `t.id, t.architecture_id, t.anchor_type, t.anchor_id, t.created_by, t.resolved_at, t.resolved_by, t.created_at, t.updated_at`
+
+func scanCommentThread(row interface{ Scan(dest ...any) error }) (model.CommentThread, error) {
+	var t model.CommentThread
+	err := row.Scan(&t.ID, &t.ArchitectureID, &t.AnchorType, &t.AnchorID, &t.CreatedBy, &t.ResolvedAt, &t.ResolvedBy, &t.CreatedAt, &t.UpdatedAt)
+	return t, err
+}
+
+// commentColumns select from comments c LEFT JOIN users u; deleted users
+// leave an empty author name.
+const commentColumns = `c.id, c.thread_id, c.user_id, COALESCE(NULLIF(u.display_name, ''), u.name, ''), c.body, c.created_at, c.updated_at`
+
+func scanComment(row interface{ Scan(dest ...any) error }) (model.Comment, error) {
+	var c model.Comment
+	err := row.Scan(&c.ID, &c.ThreadID, &c.UserID, &c.AuthorName, &c.Body, &c.CreatedAt, &c.UpdatedAt)
+	return c, err
+}
+
+// threadReadable is a condition on comment_threads t that holds when the
+// user bound to $2 can read the thread's architecture.
+var threadReadable = `t.architecture_id IN (SELECT id FROM architectures WHERE deleted_at IS NULL AND ` + canAccess("$2", AccessRead) + `)`
+
+// CreateCommentThreadForUser starts a thread on an architecture the user
+// can read, optionally anchored to a node or edge, with body as its first
+// comment.
+func (s *Storage) CreateCommentThreadForUser(ctx context.Context, archID, userID pgtype.UUID, anchorType, anchorID *string, body string) (model.CommentThread, error) {
+	var t model.CommentThread
+	err := pgx.BeginFunc(ctx, s.Pool, func(tx pgx.Tx) error {
+		var err error
+		t, err = scanCommentThread(tx.QueryRow(ctx,
+			`INSERT INTO comment_threads AS t (architecture_id, anchor_type, anchor_id, created_by)
+			 SELECT $1, $3, $4, $2 FROM architectures WHERE id = $1 AND deleted_at IS NULL AND `+canAccess("$2", AccessRead)+`
+			 RETURNING `+threadColumns,
+			archID, userID, anchorType, anchorID,
+		))
+		if err != nil {
+			return err
+		}
+		c, err := insertComment(ctx, tx, t.ID, userID, body)
+		if err != nil {
+			return err
+		}
+		t.Comments = []model.Comment{c}
+		return nil
+	})
+	return t, err
+}
+
+func insertComment(ctx context.Context, tx pgx.Tx, threadID, userID pgtype.UUID, body string) (model.Comment, error) {
+	return scanComment(tx.QueryRow(ctx,
+		`WITH c AS (
+		     INSERT INTO comments (thread_id, user_id, body) VALUES ($1, $2, $3) RETURNING *
+		 )
+		 SELECT `+commentColumns+` FROM c LEFT JOIN users u ON u.id = c.user_id`,
+		threadID, userID, body,
+	))
+}
+
+// AddCommentForUser replies to a thread on an architecture the user can
+// read. Replying does not reopen a resolved thread.
+func (s *Storage) AddCommentForUser(ctx context.Context, threadID, userID pgtype.UUID, body string) (model.Comment, model.CommentThread, error) {
+	var c model.Comment
+	var t model.CommentThread
+	err := pgx.BeginFunc(ctx, s.Pool, func(tx pgx.Tx) error {
+		var err error
+		t, err = scanCommentThread(tx.QueryRow(ctx,
+			`UPDATE comment_threads AS t SET updated_at = now()
+			 WHERE t.id = $1 AND `+threadReadable+`
+			 RETURNING `+threadColumns,
+			threadID, userID,
+		))
+		if err != nil {
+			return err
+		}
+		c, err = insertComment(ctx, tx, threadID, userID, body)
+		return err
+	})
+	return c, t, err
+}
+
+// SetCommentThreadResolvedForUser resolves or reopens a thread. Anyone who
+// can read the architecture may do either.
+func (s *Storage) SetCommentThreadResolvedForUser(ctx context.Context, threadID, userID pgtype.UUID, resolved bool) (model.CommentThread, error) {
+	t, err := scanCommentThread(s.Pool.QueryRow(ctx,
+		`UPDATE comment_threads AS t
+		 SET resolved_at = CASE WHEN $3 THEN now() END,
+		     resolved_by = CASE WHEN $3 THEN $2::uuid END,
+		     updated_at = now()
+		 WHERE t.id = $1 AND `+threadReadable+`
+		 RETURNING `+threadColumns,
+		threadID, userID, resolved,
+	))
+	if err != nil {
+		return model.CommentThread{}, err
+	}
+	comments, err := s.listComments(ctx, []pgtype.UUID{t.ID})
+	if err != nil {
+		return model.CommentThread{}, err
+	}
+	t.Comments = comments[t.ID]
+	return t, nil
+}
+
+// ListCommentThreadsForUser returns the threads of an architecture the user
+// can read, oldest first, each with its comments. resolved nil lists all
+// threads, otherwise only resolved or only open ones.
+func (s *Storage) ListCommentThreadsForUser(ctx context.Context, archID, userID pgtype.UUID, resolved *bool) ([]model.CommentThread, error) {
+	rows, err := s.Pool.Query(ctx,
+		`SELECT `+threadColumns+` FROM comment_threads t
+		 WHERE t.architecture_id = $1 AND `+threadReadable+`
+		   AND ($3::boolean IS NULL OR (t.resolved_at IS NOT NULL) = $3)
+		 ORDER BY t.created_at, t.id`,
+		archID, userID, resolved,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var threads []model.CommentThread
+	var ids []pgtype.UUID
+	for rows.Next() {
+		t, err := scanCommentThread(rows)
+		if err != nil {
+			return nil, err
+		}
+		threads = append(threads, t)
+		ids = append(ids, t.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(threads) == 0 {
+		return threads, nil
+	}
+
+	comments, err := s.listComments(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range threads {
+		threads[i].Comments = comments[threads[i].ID]
+	}
+	return threads, nil
+}
+
+// listComments returns the comments of the given threads, oldest first,
+// keyed by thread ID.
+func (s *Storage) listComments(ctx context.Context, threadIDs []pgtype.UUID) (map[pgtype.UUID][]model.Comment, error) {
+	rows, err := s.Pool.Query(ctx,
+		`SELECT `+commentColumns+` FROM comments c LEFT JOIN users u ON u.id = c.user_id
+		 WHERE c.thread_id = ANY($1)
+		 ORDER BY c.created_at, c.id`,
+		threadIDs,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := make(map[pgtype.UUID][]model.Comment, len(threadIDs))
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments[c.ThreadID] = append(comments[c.ThreadID], c)
+	}
+	return comments, rows.Err()
+}
+
+// UpdateCommentForUser edits a comment. Only its author may, and only
+// while they can still read the architecture.
+func (s *Storage) UpdateCommentForUser(ctx context.Context, id, userID pgtype.UUID, body string) (model.Comment, error) {
+	return scanComment(s.Pool.QueryRow(ctx,
+		`WITH c AS (
+		     UPDATE comments SET body = $3, updated_at = now()
+		     WHERE id = $1 AND user_id = $2
+		       AND thread_id IN (SELECT t.id FROM comment_threads t WHERE `+threadReadable+`)
+		     RETURNING *
+		 )
+		 SELECT `+commentColumns+` FROM c LEFT JOIN users u ON u.id = c.user_id`,
+		id, userID, body,
+	))
+}
+
+// DeleteCommentForUser deletes one of the user's comments. A thread left
+// without comments is deleted with it.
+func (s *Storage) DeleteCommentForUser(ctx context.Context, id, userID pgtype.UUID) error {
+	return pgx.BeginFunc(ctx, s.Pool, func(tx pgx.Tx) error {
+		var threadID pgtype.UUID
+		err := tx.QueryRow(ctx,
+			`DELETE FROM comments
+			 WHERE id = $1 AND user_id = $2
+			   AND thread_id IN (SELECT t.id FROM comment_threads t WHERE `+threadReadable+`)
+			 RETURNING thread_id`,
+			id, userID,
+		).Scan(&threadID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx,
+			`DELETE FROM comment_threads WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM comments WHERE thread_id = $1)`,
+			threadID,
+		)
+		return err
+	})
+}
+
+// MentionableUsers returns those of ids that can read the architecture;
+// mentions of anyone else are dropped.
+func (s *Storage) MentionableUsers(ctx context.Context, archID pgtype.UUID, ids []pgtype.UUID) ([]model.User, error) {
+	rows, err := s.Pool.Query(ctx,
+		`SELECT `+userColumns+` FROM users
+		 WHERE id = ANY($2)
+		   AND EXISTS (SELECT 1 FROM architectures WHERE id = $1 AND deleted_at IS NULL AND `+canAccess("users.id", AccessRead)+`)`,
+		archID, ids,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []model.User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/model"
+)
+
+const emailColumns = `id, template, recipient, subject, body, text_body, unsubscribe_url, status, attempts, message_id, last_error, expires_at, created_at, updated_at, sent_at`
+
+func scanEmail(row interface{ Scan(dest ...any) error }) (model.OutboxEmail, error) {
+	var e model.OutboxEmail
+	err := row.Scan(&e.ID, &e.Template, &e.Recipient, &e.Subject, &e.Body, &e.Text, &e.UnsubscribeURL, &e.Status, &e.Attempts, &e.MessageID, &e.LastError, &e.ExpiresAt, &e.CreatedAt, &e.UpdatedAt, &e.SentAt)
+	return e, err
+}
+
+// EnqueueEmail stores a pending email and, in the same transaction, the job
+// that delivers it. The job's payload is {"id": <email id>}.
+func (s *Storage) EnqueueEmail(ctx context.Context, e model.OutboxEmail, job model.NewJob) (pgtype.UUID, error) {
+	var id pgtype.UUID
+	err := pgx.BeginFunc(ctx, s.Pool, func(tx pgx.Tx) error {
+		var expiresAt *time.Time
+		if e.ExpiresAt.Valid {
+			expiresAt = &e.ExpiresAt.Time
+		}
+		if err := tx.QueryRow(ctx,
+			`INSERT INTO email_outbox (template, recipient, subject, body, text_body, unsubscribe_url, expires_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7)
+			 RETURNING id`,
+			e.Template, e.Recipient, e.Subject, e.Body, e.Text, e.UnsubscribeURL, expiresAt,
+		).Scan(&id); err != nil {
+			return err
+		}
+
+		payload, err := json.Marshal(struct {
+			ID pgtype.UUID `json:"id"`
+		}{id})
+		if err != nil {
+			return err
+		}
+		job.Payload = payload
+		_, err = tx.Exec(ctx, insertJob, jobArgs(job)...)
+		return err
+	})
+	return id, err
+}
+
+// StartEmailAttempt counts a delivery attempt of a pending email and
+// returns it; pgx.ErrNoRows means it is gone or already settled.
+func (s *Storage) StartEmailAttempt(ctx context.Context, id pgtype.UUID) (model.OutboxEmail, error) {
+	return scanEmail(s.Pool.QueryRow(ctx,
+		`UPDATE email_outbox SET attempts = attempts + 1, updated_at = now()
+		 WHERE id = $1 AND status = 'pending'
+		 RETURNING `+emailColumns,
+		id,
+	))
+}
+
+// RecordEmailAttempt stores the outcome of a delivery attempt. Any status
+// but pending settles the email and clears its content.
+func (s *Storage) RecordEmailAttempt(ctx context.Context, id pgtype.UUID, status string, messageID, lastError *string) error {
+	_, err := s.Pool.Exec(ctx,
+		`UPDATE email_outbox
+		 SET status = $2,
+		     message_id = COALESCE($3, message_id),
+		     last_error = $4,
+		     body = CASE WHEN $2 = 'pending' THEN body ELSE '' END,
+		     text_body = CASE WHEN $2 = 'pending' THEN text_body ELSE '' END,
+		     sent_at = CASE WHEN $2 = 'sent' THEN now() END,
+		     updated_at = now()
+		 WHERE id = $1`,
+		id, status, messageID, lastError,
+	)
+	return err
+}
+
+// ListEmails returns the newest outbox emails, optionally only those with
+// status or sent to recipient.
+func (s *Storage) ListEmails(ctx context.Context, status, recipient string, limit int) ([]model.OutboxEmail, error) {
+	rows, err := s.Pool.Query(ctx,
+		`SELECT `+emailColumns+` FROM email_outbox
+		 WHERE ($1 = '' OR status = $1) AND ($2 = '' OR lower(recipient) = lower($2))
+		 ORDER BY created_at DESC, id
+		 LIMIT $3`,
+		status, recipient, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	emails := []model.OutboxEmail{}
+	for rows.Next() {
+		e, err := scanEmail(rows)
+		if err != nil {
+			return nil, err
+		}
+		emails = append(emails, e)
+	}
+	return emails, rows.Err()
+}
+
+// DeleteSettledEmails removes emails settled before before and reports how
+// many.
+func (s *Storage) DeleteSettledEmails(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := s.Pool.Exec(ctx, `DELETE FROM email_outbox WHERE status <> 'pending' AND updated_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
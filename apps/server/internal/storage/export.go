@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/model"
+)
+
+// ErrExportPending is returned when the user already has an export being
+// built.
+var ErrExportPending = errors.New("storage: a data export is already being prepared")
+
+const exportColumns = `id, user_id, status, expires_at, created_at, ready_at`
+
+func scanExport(row interface{ Scan(dest ...any) error }, extra ...any) (model.UserExport, error) {
+	var e model.UserExport
+	dest := append([]any{&e.ID, &e.UserID, &e.Status, &e.ExpiresAt, &e.CreatedAt, &e.ReadyAt}, extra...)
+	err := row.Scan(dest...)
+	return e, err
+}
+
+// CreateUserExport stores a pending export that expires after ttl and, in
+// the same transaction, the job that builds it. The job's payload is
+// {"id": <export id>}. A pending export less than an hour old, whose job
+// may still be running, is ErrExportPending.
+func (s *Storage) CreateUserExport(ctx context.Context, userID pgtype.UUID, ttl time.Duration, job model.NewJob) (model.UserExport, error) {
+	var e model.UserExport
+	err := pgx.BeginFunc(ctx, s.Pool, func(tx pgx.Tx) error {
+		// Serialize requests of the same user.
+		if _, err := tx.Exec(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+			return err
+		}
+		var pending bool
+		err := tx.QueryRow(ctx,
+			`SELECT EXISTS (
+			     SELECT 1 FROM user_exports
+			     WHERE user_id = $1 AND status = $2 AND created_at > now() - interval '1 hour'
+			 )`,
+			userID, model.ExportPending,
+		).Scan(&pending)
+		if err != nil {
+			return err
+		}
+		if pending {
+			return ErrExportPending
+		}
+
+		e, err = scanExport(tx.QueryRow(ctx,
+			`INSERT INTO user_exports (user_id, expires_at)
+			 VALUES ($1, now() + make_interval(secs => $2))
+			 RETURNING `+exportColumns,
+			userID, ttl.Seconds(),
+		))
+		if err != nil {
+			return err
+		}
+
+		payload, err := json.Marshal(struct {
+			ID pgtype.UUID `json:"id"`
+		}{e.ID})
+		if err != nil {
+			return err
+		}
+		job.Payload = payload
+		_, err = tx.Exec(ctx, insertJob, jobArgs(job)...)
+		return err
+	})
+	return e, err
+}
+
+// GetUserExport returns an export without its data.
+func (s *Storage) GetUserExport(ctx context.Context, id pgtype.UUID) (model.UserExport, error) {
+	return scanExport(s.Pool.QueryRow(ctx,
+		`SELECT `+exportColumns+` FROM user_exports WHERE id = $1`,
+		id,
+	))
+}
+
+// CompleteUserExport stores the zip of a pending export, downloadable
+// with the token hashed to tokenHash until expiresAt. pgx.ErrNoRows means
+// the export is gone or no longer pending.
+func (s *Storage) CompleteUserExport(ctx context.Context, id pgtype.UUID, data []byte, tokenHash string, expiresAt time.Time) error {
+	tag, err := s.Pool.Exec(ctx,
+		`UPDATE user_exports SET status = $2, data = $3, token_hash = $4, expires_at = $5, ready_at = now()
+		 WHERE id = $1 AND status = $6`,
+		id, model.ExportReady, data, tokenHash, expiresAt, model.ExportPending,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// FailUserExport marks a pending export as failed.
+func (s *Storage) FailUserExport(ctx context.Context, id pgtype.UUID) error {
+	_, err := s.Pool.Exec(ctx,
+		`UPDATE user_exports SET status = $2 WHERE id = $1 AND status = $3`,
+		id, model.ExportFailed, model.ExportPending,
+	)
+	return err
+}
+
+// GetUserExportByToken returns a ready, unexpired export with its data.
+// pgx.ErrNoRows means there is none for tokenHash.
+func (s *Storage) GetUserExportByToken(ctx context.Context, tokenHash string) (model.UserExport, error) {
+	var data []byte
+	e, err := scanExport(s.Pool.QueryRow(ctx,
+		`SELECT `+exportColumns+`, data FROM user_exports
+		 WHERE token_hash = $1 AND status = $2 AND expires_at > now()`,
+		tokenHash, model.ExportReady,
+	), &data)
+	e.Data = data
+	return e, err
+}
+
+// DeleteExpiredExports removes expired exports and reports how many.
+func (s *Storage) DeleteExpiredExports(ctx context.Context) (int64, error) {
+	tag, err := s.Pool.Exec(ctx, `DELETE FROM user_exports WHERE expires_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ExportArchitectures calls fn with each architecture the user created,
+// trashed ones included, data decompressed, oldest first. It streams the
+// rows, so that a large account is not held in memory at once.
+func (s *Storage) ExportArchitectures(ctx context.Context, userID pgtype.UUID, fn func(model.Architecture) error) error {
+	rows, err := s.Pool.Query(ctx,
+		`SELECT `+architectureColumns+`, data FROM architectures
+		 WHERE user_id = $1
+		 ORDER BY created_at, id`,
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a, err := scanArchitectureWithData(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ListSimulationResultsByUser returns every result the user ran, oldest
+// first.
+func (s *Storage) ListSimulationResultsByUser(ctx context.Context, userID pgtype.UUID) ([]model.SimulationResult, error) {
+	return s.listSimulationResults(ctx,
+		`SELECT `+simulationColumns+` FROM simulation_results WHERE user_id = $1 ORDER BY created_at, id`,
+		userID,
+	)
+}
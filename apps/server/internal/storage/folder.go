@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/model"
+)
+
+// ErrFolderCycle is returned when a folder would be moved into itself or
+// one of its descendants.
+var ErrFolderCycle = errors.New("storage: folder cannot be moved into itself")
+
+// ErrFolderMismatch is returned when an architecture would be filed in a
+// folder of another workspace, or a personal folder of another space.
+var ErrFolderMismatch = errors.New("storage: folder belongs to another workspace")
+
+const folderColumns = `id, user_id, workspace_id, parent_id, name, created_at, updated_at`
+
+func scanFolder(row interface{ Scan(dest ...any) error }) (model.Folder, error) {
+	var f model.Folder
+	err := row.Scan(&f.ID, &f.UserID, &f.WorkspaceID, &f.ParentID, &f.Name, &f.CreatedAt, &f.UpdatedAt)
+	return f, err
+}
+
+// CreateFolderForUser creates a folder under parentID (nil for top level),
+// personal or, with workspaceID, owned by that workspace, where the user
+// must be an editor or above. The parent must be in the same space,
+// otherwise pgx.ErrNoRows.
+func (s *Storage) CreateFolderForUser(ctx context.Context, userID pgtype.UUID, workspaceID, parentID *pgtype.UUID, name string) (model.Folder, error) {
+	return scanFolder(s.Pool.QueryRow(ctx,
+		`INSERT INTO folders (user_id, workspace_id, parent_id, name)
+		 SELECT $1, $2, $3, $4
+		 WHERE ($2::uuid IS NULL OR `+memberOf("$2", "$1", AccessWrite)+`)
+		   AND ($3::uuid IS NULL OR EXISTS (
+		       SELECT 1 FROM folders
+		       WHERE id = $3 AND workspace_id IS NOT DISTINCT FROM $2 AND `+canAccess("$1", AccessWrite)+`))
+		 RETURNING `+folderColumns,
+		userID, workspaceID, parentID, name,
+	))
+}
+
+// GetFolderForUser returns a personal folder of the user or a folder of a
+// workspace they are a member of.
+func (s *Storage) GetFolderForUser(ctx context.Context, id, userID pgtype.UUID) (model.Folder, error) {
+	return scanFolder(s.Pool.QueryRow(ctx,
+		`SELECT `+folderColumns+` FROM folders WHERE id = $1 AND `+canAccess("$2", AccessRead),
+		id, userID,
+	))
+}
+
+// ListFoldersByUser returns all of the user's personal folders, or with
+// workspaceID all folders of that workspace if the user is a member;
+// clients build the tree from parent_id.
+func (s *Storage) ListFoldersByUser(ctx context.Context, userID pgtype.UUID, workspaceID *pgtype.UUID) ([]model.Folder, error) {
+	rows, err := s.Pool.Query(ctx,
+		`SELECT `+folderColumns+` FROM folders
+		 WHERE ($2::uuid IS NULL AND workspace_id IS NULL AND user_id = $1)
+		    OR (workspace_id = $2 AND `+memberOf("$2", "$1", AccessRead)+`)
+		 ORDER BY lower(name), id`,
+		userID, workspaceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var folders []model.Folder
+	for rows.Next() {
+		f, err := scanFolder(rows)
+		if err != nil {
+			return nil, err
+		}
+		folders = append(folders, f)
+	}
+	return folders, rows.Err()
+}
+
+// lockFolderSpace checks that the user has level on folder id and locks
+// every folder of its space, personal or workspace, so that concurrent
+// moves and deletes there run one at a time. It returns the folder's
+// workspace. A member without level gets ErrForbidden.
+func lockFolderSpace(ctx context.Context, tx pgx.Tx, id, userID pgtype.UUID, level Access) (*pgtype.UUID, error) {
+	var workspaceID *pgtype.UUID
+	var allowed bool
+	err := tx.QueryRow(ctx,
+		`SELECT workspace_id, `+canAccess("$2", level)+` FROM folders WHERE id = $1 AND `+canAccess("$2", AccessRead),
+		id, userID,
+	).Scan(&workspaceID, &allowed)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrForbidden
+	}
+	// Rows are locked in id order so that two transactions cannot each
+	// hold part of the space.
+	_, err = tx.Exec(ctx,
+		`SELECT id FROM folders
+		 WHERE CASE WHEN $1::uuid IS NULL THEN workspace_id IS NULL AND user_id = $2 ELSE workspace_id = $1 END
+		 ORDER BY id FOR UPDATE`,
+		workspaceID, userID,
+	)
+	return workspaceID, err
+}
+
+// UpdateFolderForUser renames a folder and moves it under parentID (nil
+// for top level) in the same space. Moving a folder into its own subtree
+// is ErrFolderCycle; workspace folders need the editor role. The folder's
+// space is locked first, so two concurrent moves cannot both pass the
+// cycle check and leave a loop behind.
+func (s *Storage) UpdateFolderForUser(ctx context.Context, id, userID pgtype.UUID, name string, parentID *pgtype.UUID) (model.Folder, error) {
+	var f model.Folder
+	err := pgx.BeginFunc(ctx, s.Pool, func(tx pgx.Tx) error {
+		workspaceID, err := lockFolderSpace(ctx, tx, id, userID, AccessWrite)
+		if err != nil {
+			return err
+		}
+
+		if parentID != nil {
+			var cycle bool
+			err := tx.QueryRow(ctx,
+				`WITH RECURSIVE ancestors AS (
+				     SELECT id, parent_id FROM folders
+				     WHERE id = $2 AND workspace_id IS NOT DISTINCT FROM $3 AND `+canAccess("$4", AccessRead)+`
+				     UNION ALL
+				     SELECT f.id, f.parent_id FROM folders f JOIN ancestors a ON f.id = a.parent_id
+				 )
+				 SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = $1)`,
+				id, *parentID, workspaceID, userID,
+			).Scan(&cycle)
+			if err != nil {
+				return err
+			}
+			if cycle {
+				return ErrFolderCycle
+			}
+		}
+
+		f, err = scanFolder(tx.QueryRow(ctx,
+			`UPDATE folders SET name = $2, parent_id = $3, updated_at = now()
+			 WHERE id = $1
+			   AND ($3::uuid IS NULL OR EXISTS (
+			       SELECT 1 FROM folders p
+			       WHERE p.id = $3 AND p.workspace_id IS NOT DISTINCT FROM $4 AND (p.workspace_id IS NOT NULL OR p.user_id = $5)))
+			 RETURNING `+folderColumns,
+			id, name, parentID, workspaceID, userID,
+		))
+		return err
+	})
+	return f, err
+}
+
+// DeleteFolderForUser deletes a folder. With cascade, its subfolders go
+// too and every architecture in them moves to the trash; otherwise
+// subfolders and architectures move up to the folder's parent. In a
+// workspace, deleting needs the editor role and cascading the admin role,
+// as trashing architectures does.
+func (s *Storage) DeleteFolderForUser(ctx context.Context, id, userID pgtype.UUID, cascade bool) error {
+	return pgx.BeginFunc(ctx, s.Pool, func(tx pgx.Tx) error {
+		level := AccessWrite
+		if cascade {
+			level = AccessManage
+		}
+		if _, err := lockFolderSpace(ctx, tx, id, userID, level); err != nil {
+			return err
+		}
+		var parentID *pgtype.UUID
+		err := tx.QueryRow(ctx, `SELECT parent_id FROM folders WHERE id = $1`, id).Scan(&parentID)
+		if err != nil {
+			return err
+		}
+
+		if cascade {
+			_, err = tx.Exec(ctx,
+				`WITH RECURSIVE subtree AS (
+				     SELECT id FROM folders WHERE id = $1
+				     UNION ALL
+				     SELECT f.id FROM folders f JOIN subtree t ON f.parent_id = t.id
+				 )
+				 UPDATE architectures SET deleted_at = now(), deleted_by = $2
+				 WHERE deleted_at IS NULL AND folder_id IN (SELECT id FROM subtree)
+				   AND `+canAccess("$2", AccessManage),
+				id, userID,
+			)
+		} else {
+			if _, err = tx.Exec(ctx, `UPDATE folders SET parent_id = $2, updated_at = now() WHERE parent_id = $1`, id, parentID); err != nil {
+				return err
+			}
+			_, err = tx.Exec(ctx, `UPDATE architectures SET folder_id = $2 WHERE folder_id = $1`, id, parentID)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Subfolders go with ON DELETE CASCADE.
+		_, err = tx.Exec(ctx, `DELETE FROM folders WHERE id = $1`, id)
+		return err
+	})
+}
+
+// MoveArchitectureForUser puts an architecture the user can edit into
+// folderID, or back to the top level when folderID is nil. The folder must
+// be in the architecture's space: a personal folder of the user for a
+// personal architecture, a folder of the same workspace otherwise, or
+// ErrFolderMismatch. Workspace viewers get ErrForbidden.
+func (s *Storage) MoveArchitectureForUser(ctx context.Context, id, userID pgtype.UUID, folderID *pgtype.UUID) (model.Architecture, error) {
+	var a model.Architecture
+	err := pgx.BeginFunc(ctx, s.Pool, func(tx pgx.Tx) error {
+		var workspaceID *pgtype.UUID
+		err := tx.QueryRow(ctx,
+			`SELECT workspace_id FROM architectures
+			 WHERE id = $1 AND deleted_at IS NULL AND `+canAccess("$2", AccessWrite)+`
+			 FOR UPDATE`,
+			id, userID,
+		).Scan(&workspaceID)
+		if err != nil {
+			return s.denied(ctx, err, id, userID)
+		}
+
+		if folderID != nil {
+			f, err := scanFolder(tx.QueryRow(ctx,
+				`SELECT `+folderColumns+` FROM folders WHERE id = $1 AND `+canAccess("$2", AccessRead),
+				*folderID, userID,
+			))
+			if err != nil {
+				return err
+			}
+			if !f.InWorkspace(workspaceID) {
+				return ErrFolderMismatch
+			}
+		}
+
+		a, err = scanArchitecture(tx.QueryRow(ctx,
+			`UPDATE architectures SET folder_id = $2, updated_at = now()
+			 WHERE id = $1
+			 RETURNING `+architectureColumns,
+			id, folderID,
+		))
+		return err
+	})
+	return a, err
+}
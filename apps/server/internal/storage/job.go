@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/model"
+)
+
+const jobColumns = `id, kind, payload, status, attempts, max_attempts, run_at, locked_until, last_error, dedupe_key, created_at, updated_at, finished_at`
+
+func scanJob(row interface{ Scan(dest ...any) error }) (model.Job, error) {
+	var j model.Job
+	err := row.Scan(&j.ID, &j.Kind, &j.Payload, &j.Status, &j.Attempts, &j.MaxAttempts, &j.RunAt, &j.LockedUntil, &j.LastError, &j.DedupeKey, &j.CreatedAt, &j.UpdatedAt, &j.FinishedAt)
+	return j, err
+}
+
+// insertJob adds a job unless its dedupe key is taken; see jobArgs.
+const insertJob = `INSERT INTO jobs (kind, payload, run_at, max_attempts, dedupe_key)
+	VALUES ($1, $2, COALESCE($3, now()), COALESCE($4, 5), $5)
+	ON CONFLICT (dedupe_key) DO NOTHING`
+
+// jobArgs are the insertJob parameters for job, with its defaults left to
+// the database.
+func jobArgs(job model.NewJob) []any {
+	payload := job.Payload
+	if payload == nil {
+		payload = []byte("{}")
+	}
+	var runAt *time.Time
+	if !job.RunAt.IsZero() {
+		runAt = &job.RunAt
+	}
+	var maxAttempts *int
+	if job.MaxAttempts > 0 {
+		maxAttempts = &job.MaxAttempts
+	}
+	return []any{job.Kind, payload, runAt, maxAttempts, job.DedupeKey}
+}
+
+// EnqueueJob adds a job and reports whether it was added; false means a
+// job with the same dedupe key already exists.
+func (s *Storage) EnqueueJob(ctx context.Context, job model.NewJob) (bool, error) {
+	tag, err := s.Pool.Exec(ctx, insertJob, jobArgs(job)...)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ClaimJobs locks up to limit due jobs of the given kinds for lease and
+// counts the attempt. Running jobs whose lease ran out are due again.
+func (s *Storage) ClaimJobs(ctx context.Context, kinds []string, limit int, lease time.Duration) ([]model.Job, error) {
+	rows, err := s.Pool.Query(ctx,
+		`UPDATE jobs SET status = 'running', attempts = attempts + 1,
+		        locked_until = now() + make_interval(secs => $3), updated_at = now()
+		 WHERE id IN (
+		     SELECT id FROM jobs
+		     WHERE kind = ANY($1)
+		       AND ((status = 'pending' AND run_at <= now()) OR (status = 'running' AND locked_until < now()))
+		     ORDER BY run_at, id
+		     LIMIT $2
+		     FOR UPDATE SKIP LOCKED
+		 )
+		 RETURNING `+jobColumns,
+		kinds, limit, lease.Seconds(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []model.Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// CompleteJob marks a claimed job done and reports whether the claim,
+// identified by its attempt, still held. false means the lease ran out and
+// another runner has claimed the job since; nothing is changed then.
+func (s *Storage) CompleteJob(ctx context.Context, id pgtype.UUID, attempt int) (bool, error) {
+	tag, err := s.Pool.Exec(ctx,
+		`UPDATE jobs SET status = 'done', locked_until = NULL, last_error = NULL, updated_at = now(), finished_at = now()
+		 WHERE id = $1 AND status = 'running' AND attempts = $2`,
+		id, attempt,
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// FailJob records a failed attempt. With retryAt the job runs again then;
+// without it the job is dead-lettered. Like CompleteJob it reports whether
+// the claim still held.
+func (s *Storage) FailJob(ctx context.Context, id pgtype.UUID, attempt int, msg string, retryAt *time.Time) (bool, error) {
+	tag, err := s.Pool.Exec(ctx,
+		`UPDATE jobs
+		 SET status = CASE WHEN $4::timestamptz IS NULL THEN 'dead' ELSE 'pending' END,
+		     run_at = COALESCE($4, run_at),
+		     finished_at = CASE WHEN $4::timestamptz IS NULL THEN now() END,
+		     last_error = $3, locked_until = NULL, updated_at = now()
+		 WHERE id = $1 AND status = 'running' AND attempts = $2`,
+		id, attempt, msg, retryAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ListJobs returns the newest jobs, optionally only those with status or
+// kind.
+func (s *Storage) ListJobs(ctx context.Context, status, kind string, limit int) ([]model.Job, error) {
+	rows, err := s.Pool.Query(ctx,
+		`SELECT `+jobColumns+` FROM jobs
+		 WHERE ($1 = '' OR status = $1) AND ($2 = '' OR kind = $2)
+		 ORDER BY created_at DESC, id
+		 LIMIT $3`,
+		status, kind, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []model.Job{}
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// RetryJob puts a dead job back in the queue with fresh attempts.
+func (s *Storage) RetryJob(ctx context.Context, id pgtype.UUID) (model.Job, error) {
+	return scanJob(s.Pool.QueryRow(ctx,
+		`UPDATE jobs SET status = 'pending', attempts = 0, run_at = now(), finished_at = NULL, updated_at = now()
+		 WHERE id = $1 AND status = 'dead'
+		 RETURNING `+jobColumns,
+		id,
+	))
+}
+
+// DeleteFinishedJobs removes done jobs that finished before before and
+// reports how many. Dead jobs stay until retried.
+func (s *Storage) DeleteFinishedJobs(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := s.Pool.Exec(ctx, `DELETE FROM jobs WHERE status = 'done' AND finished_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -6,11 +6,15 @@ import (
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/redis/go-redis/v9"
+	"github.com/system-design-sandbox/server/internal/config"
 )
 
 type Storage struct {
 	Pool  *pgxpool.Pool
 	Redis redis.UniversalClient
+	// Quotas holds the limits of each plan; a plan without an entry is
+	// unlimited.
+	Quotas map[string]config.QuotaConfig
 }
 
 func New(ctx context.Context, databaseURL string) (*Storage, error) {
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/model"
+)
+
+// Quota names reported in QuotaError.
+const (
+	QuotaArchitectures = "architectures"
+	QuotaStorageBytes  = "storage_bytes"
+	QuotaResultsPerDay = "results_per_day"
+)
+
+// QuotaError is returned when a write would take a user past one of their
+// plan's quotas. The write is rolled back.
+type QuotaError struct {
+	Quota string
+	Limit int64
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("quota %s exceeded (limit %d)", e.Quota, e.Limit)
+}
+
+// usageDelta is how much a write grew a user's usage.
+type usageDelta struct {
+	architectures int
+	bytes         int64
+	results       int
+}
+
+// resultsToday reads user_usage uu's result count, which goes stale at
+// midnight UTC until the next result resets it.
+const resultsToday = `CASE WHEN uu.results_day = (now() AT TIME ZONE 'UTC')::date THEN uu.results_today ELSE 0 END`
+
+// enforceQuota runs after a write in tx, once the usage triggers have
+// counted it, and returns a *QuotaError if any figure the write grew is
+// now over the user's limit. Concurrent writes by the same user queue on
+// the user_usage row the triggers updated.
+func (s *Storage) enforceQuota(ctx context.Context, tx pgx.Tx, userID pgtype.UUID, d usageDelta) error {
+	if !userID.Valid || (d.architectures <= 0 && d.bytes <= 0 && d.results <= 0) {
+		return nil
+	}
+	var plan string
+	var architectures, bytes, results int64
+	err := tx.QueryRow(ctx,
+		`SELECT u.plan, uu.architectures, uu.data_bytes, `+resultsToday+`
+		 FROM users u JOIN user_usage uu ON uu.user_id = u.id
+		 WHERE u.id = $1`,
+		userID,
+	).Scan(&plan, &architectures, &bytes, &results)
+	if err != nil {
+		return err
+	}
+	q := s.Quotas[plan]
+	switch {
+	case d.architectures > 0 && q.Architectures > 0 && architectures > int64(q.Architectures):
+		return &QuotaError{Quota: QuotaArchitectures, Limit: int64(q.Architectures)}
+	case d.bytes > 0 && q.StorageBytes > 0 && bytes > q.StorageBytes:
+		return &QuotaError{Quota: QuotaStorageBytes, Limit: q.StorageBytes}
+	case d.results > 0 && q.ResultsPerDay > 0 && results > int64(q.ResultsPerDay):
+		return &QuotaError{Quota: QuotaResultsPerDay, Limit: int64(q.ResultsPerDay)}
+	}
+	return nil
+}
+
+// GetUsage returns the user's usage and the limits of their plan.
+func (s *Storage) GetUsage(ctx context.Context, userID pgtype.UUID) (model.Usage, error) {
+	var u model.Usage
+	err := s.Pool.QueryRow(ctx,
+		`SELECT u.plan, COALESCE(uu.architectures, 0), COALESCE(uu.data_bytes, 0), COALESCE(`+resultsToday+`, 0)
+		 FROM users u LEFT JOIN user_usage uu ON uu.user_id = u.id
+		 WHERE u.id = $1`,
+		userID,
+	).Scan(&u.Plan, &u.Architectures.Used, &u.StorageBytes.Used, &u.ResultsToday.Used)
+	if err != nil {
+		return model.Usage{}, err
+	}
+	q := s.Quotas[u.Plan]
+	u.Architectures.Limit = quotaLimit(int64(q.Architectures))
+	u.StorageBytes.Limit = quotaLimit(q.StorageBytes)
+	u.ResultsToday.Limit = quotaLimit(int64(q.ResultsPerDay))
+	return u, nil
+}
+
+func quotaLimit(n int64) *int64 {
+	if n <= 0 {
+		return nil
+	}
+	return &n
+}
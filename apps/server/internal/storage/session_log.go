@@ -2,6 +2,9 @@ package storage
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/system-design-sandbox/server/internal/model"
@@ -45,15 +48,105 @@ func (s *Storage) GetLoginInfoBySessionIDs(ctx context.Context, sessionIDs []str
 	return result, rows.Err()
 }
 
-func (s *Storage) ListSessionLogs(ctx context.Context, userID pgtype.UUID, limit int) ([]model.SessionLogEntry, error) {
+// ListKnownLogins returns the distinct user agents and countries the user
+// has signed in with, as far back as the session log is kept.
+func (s *Storage) ListKnownLogins(ctx context.Context, userID pgtype.UUID) ([]model.SessionLogEntry, error) {
+	rows, err := s.Pool.Query(ctx,
+		`SELECT DISTINCT COALESCE(user_agent, ''), country_code
+		 FROM session_log
+		 WHERE user_id = $1 AND action = 'login'
+		 LIMIT 500`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []model.SessionLogEntry
+	for rows.Next() {
+		var e model.SessionLogEntry
+		if err := rows.Scan(&e.UserAgent, &e.CountryCode); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// sessionLogCursor marks the last entry of a history page.
+type sessionLogCursor struct {
+	At time.Time `json:"at"`
+	ID string    `json:"id"`
+}
+
+// ListSessionLogs returns a page of the user's session events, newest
+// first, and the cursor of the next page, empty on the last one.
+func (s *Storage) ListSessionLogs(ctx context.Context, userID pgtype.UUID, cursor string, limit int) ([]model.SessionLogEntry, string, error) {
 	if limit <= 0 {
 		limit = 50
 	}
+	query := `SELECT id, user_id, session_id, action, COALESCE(ip, ''), COALESCE(user_agent, ''), COALESCE(geo, ''), country_code, created_at
+		 FROM session_log WHERE user_id = $1`
+	args := []any{userID, limit + 1}
+	if cursor != "" {
+		var c sessionLogCursor
+		b, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil || json.Unmarshal(b, &c) != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		id, err := parseCursorUUID(c.ID)
+		if err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		query += ` AND (created_at, id) < ($3, $4)`
+		args = append(args, c.At, id)
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT $2`
+
+	rows, err := s.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var entries []model.SessionLogEntry
+	for rows.Next() {
+		var e model.SessionLogEntry
+		if err := rows.Scan(&e.ID, &e.UserID, &e.SessionID, &e.Action, &e.IP, &e.UserAgent, &e.Geo, &e.CountryCode, &e.CreatedAt); err != nil {
+			return nil, "", err
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if len(entries) <= limit {
+		return entries, "", nil
+	}
+	entries = entries[:limit]
+	last := entries[limit-1]
+	b, err := json.Marshal(sessionLogCursor{At: last.CreatedAt.Time, ID: uuidString(last.ID)})
+	if err != nil {
+		return nil, "", err
+	}
+	return entries, base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ListUnendedSessions returns a page of logged-in sessions that have no
+// logout, revoke or expire entry yet, oldest first, after the login entry
+// with ID after (zero for the first page).
+func (s *Storage) ListUnendedSessions(ctx context.Context, after pgtype.UUID, limit int) ([]model.SessionLogEntry, error) {
 	rows, err := s.Pool.Query(ctx,
-		`SELECT id, user_id, session_id, action, ip, user_agent, geo, country_code, created_at
-		 FROM session_log WHERE user_id = $1
-		 ORDER BY created_at DESC LIMIT $2`,
-		userID, limit,
+		`SELECT l.id, l.user_id, l.session_id
+		 FROM session_log l
+		 WHERE l.action = 'login' AND ($1::uuid IS NULL OR l.id > $1)
+		   AND NOT EXISTS (
+		     SELECT 1 FROM session_log e
+		     WHERE e.session_id = l.session_id AND e.action IN ('logout', 'revoke', 'expire'))
+		 ORDER BY l.id LIMIT $2`,
+		after, limit,
 	)
 	if err != nil {
 		return nil, err
@@ -63,10 +156,20 @@ func (s *Storage) ListSessionLogs(ctx context.Context, userID pgtype.UUID, limit
 	var entries []model.SessionLogEntry
 	for rows.Next() {
 		var e model.SessionLogEntry
-		if err := rows.Scan(&e.ID, &e.UserID, &e.SessionID, &e.Action, &e.IP, &e.UserAgent, &e.Geo, &e.CountryCode, &e.CreatedAt); err != nil {
+		if err := rows.Scan(&e.ID, &e.UserID, &e.SessionID); err != nil {
 			return nil, err
 		}
 		entries = append(entries, e)
 	}
 	return entries, rows.Err()
 }
+
+// DeleteSessionLogsBefore removes session log entries older than before
+// and reports how many.
+func (s *Storage) DeleteSessionLogsBefore(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := s.Pool.Exec(ctx, `DELETE FROM session_log WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
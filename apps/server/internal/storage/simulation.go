@@ -4,82 +4,90 @@ import (
 	"context"
 	"encoding/json"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/system-design-sandbox/server/internal/model"
 )
 
-func (s *Storage) CreateSimulationResult(ctx context.Context, archID, userID pgtype.UUID, scenarioID *string, score *int, report, metrics json.RawMessage, durationSec *int) (model.SimulationResult, error) {
+const simulationColumns = `id, architecture_id, user_id, scenario_id, score, report, metrics, duration_sec, cost, monthly_cost, created_at`
+
+func scanSimulationResult(row interface{ Scan(dest ...any) error }) (model.SimulationResult, error) {
 	var r model.SimulationResult
-	err := s.Pool.QueryRow(ctx,
-		`INSERT INTO simulation_results (architecture_id, user_id, scenario_id, score, report, metrics, duration_sec)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7)
-		 RETURNING id, architecture_id, user_id, scenario_id, score, report, metrics, duration_sec, created_at`,
-		archID, userID, scenarioID, score, report, metrics, durationSec,
-	).Scan(&r.ID, &r.ArchitectureID, &r.UserID, &r.ScenarioID, &r.Score, &r.Report, &r.Metrics, &r.DurationSec, &r.CreatedAt)
+	err := row.Scan(&r.ID, &r.ArchitectureID, &r.UserID, &r.ScenarioID, &r.Score, &r.Report, &r.Metrics, &r.DurationSec, &r.Cost, &r.MonthlyCost, &r.CreatedAt)
 	return r, err
 }
 
-func (s *Storage) CreateSimulationResultForUser(ctx context.Context, archID, userID pgtype.UUID, scenarioID *string, score *int, report, metrics json.RawMessage, durationSec *int) (model.SimulationResult, error) {
+func (s *Storage) CreateSimulationResult(ctx context.Context, archID, userID pgtype.UUID, scenarioID *string, score *int, report, metrics json.RawMessage, durationSec *int, cost json.RawMessage, monthlyCost *float64) (model.SimulationResult, error) {
+	return scanSimulationResult(s.Pool.QueryRow(ctx,
+		`INSERT INTO simulation_results (architecture_id, user_id, scenario_id, score, report, metrics, duration_sec, cost, monthly_cost)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		 RETURNING `+simulationColumns,
+		archID, userID, scenarioID, score, report, metrics, durationSec, cost, monthlyCost,
+	))
+}
+
+// CreateSimulationResultForUser records a run by userID of an architecture
+// the user can read. Runs past the user's daily quota get a *QuotaError.
+func (s *Storage) CreateSimulationResultForUser(ctx context.Context, archID, userID pgtype.UUID, scenarioID *string, score *int, report, metrics json.RawMessage, durationSec *int, cost json.RawMessage, monthlyCost *float64) (model.SimulationResult, error) {
 	var r model.SimulationResult
-	err := s.Pool.QueryRow(ctx,
-		`INSERT INTO simulation_results (architecture_id, user_id, scenario_id, score, report, metrics, duration_sec)
-		 SELECT $1, $2, $3, $4, $5, $6, $7
-		 FROM architectures
-		 WHERE id = $1 AND user_id = $2
-		 RETURNING id, architecture_id, user_id, scenario_id, score, report, metrics, duration_sec, created_at`,
-		archID, userID, scenarioID, score, report, metrics, durationSec,
-	).Scan(&r.ID, &r.ArchitectureID, &r.UserID, &r.ScenarioID, &r.Score, &r.Report, &r.Metrics, &r.DurationSec, &r.CreatedAt)
+	err := pgx.BeginFunc(ctx, s.Pool, func(tx pgx.Tx) error {
+		var err error
+		r, err = scanSimulationResult(tx.QueryRow(ctx,
+			`INSERT INTO simulation_results (architecture_id, user_id, scenario_id, score, report, metrics, duration_sec, cost, monthly_cost)
+			 SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9
+			 FROM architectures
+			 WHERE id = $1 AND deleted_at IS NULL AND `+canAccess("$2", AccessRead)+`
+			 RETURNING `+simulationColumns,
+			archID, userID, scenarioID, score, report, metrics, durationSec, cost, monthlyCost,
+		))
+		if err != nil {
+			return err
+		}
+		return s.enforceQuota(ctx, tx, userID, usageDelta{results: 1})
+	})
 	return r, err
 }
 
 func (s *Storage) GetSimulationResult(ctx context.Context, id pgtype.UUID) (model.SimulationResult, error) {
-	var r model.SimulationResult
-	err := s.Pool.QueryRow(ctx,
-		`SELECT id, architecture_id, user_id, scenario_id, score, report, metrics, duration_sec, created_at
-		 FROM simulation_results WHERE id = $1`,
+	return scanSimulationResult(s.Pool.QueryRow(ctx,
+		`SELECT `+simulationColumns+` FROM simulation_results WHERE id = $1`,
 		id,
-	).Scan(&r.ID, &r.ArchitectureID, &r.UserID, &r.ScenarioID, &r.Score, &r.Report, &r.Metrics, &r.DurationSec, &r.CreatedAt)
-	return r, err
+	))
 }
 
+// GetSimulationResultForUser returns a result the user ran, even after
+// losing access to its architecture, or any result of an architecture the
+// user can read. Results of architectures in the trash are hidden.
 func (s *Storage) GetSimulationResultForUser(ctx context.Context, id, userID pgtype.UUID) (model.SimulationResult, error) {
-	var r model.SimulationResult
-	err := s.Pool.QueryRow(ctx,
-		`SELECT id, architecture_id, user_id, scenario_id, score, report, metrics, duration_sec, created_at
-		 FROM simulation_results WHERE id = $1 AND user_id = $2`,
+	return scanSimulationResult(s.Pool.QueryRow(ctx,
+		`SELECT `+simulationColumns+` FROM simulation_results r
+		 WHERE r.id = $1
+		   AND NOT EXISTS (SELECT 1 FROM architectures a WHERE a.id = r.architecture_id AND a.deleted_at IS NOT NULL)
+		   AND (r.user_id = $2 OR r.architecture_id IN (SELECT id FROM architectures WHERE deleted_at IS NULL AND `+canAccess("$2", AccessRead)+`))`,
 		id, userID,
-	).Scan(&r.ID, &r.ArchitectureID, &r.UserID, &r.ScenarioID, &r.Score, &r.Report, &r.Metrics, &r.DurationSec, &r.CreatedAt)
-	return r, err
+	))
 }
 
 func (s *Storage) ListSimulationResultsByArchitecture(ctx context.Context, archID pgtype.UUID) ([]model.SimulationResult, error) {
-	rows, err := s.Pool.Query(ctx,
-		`SELECT id, architecture_id, user_id, scenario_id, score, report, metrics, duration_sec, created_at
-		 FROM simulation_results WHERE architecture_id = $1 ORDER BY created_at DESC`,
+	return s.listSimulationResults(ctx,
+		`SELECT `+simulationColumns+` FROM simulation_results WHERE architecture_id = $1 ORDER BY created_at DESC`,
 		archID,
 	)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var results []model.SimulationResult
-	for rows.Next() {
-		var r model.SimulationResult
-		if err := rows.Scan(&r.ID, &r.ArchitectureID, &r.UserID, &r.ScenarioID, &r.Score, &r.Report, &r.Metrics, &r.DurationSec, &r.CreatedAt); err != nil {
-			return nil, err
-		}
-		results = append(results, r)
-	}
-	return results, rows.Err()
 }
 
+// ListSimulationResultsByArchitectureForUser lists every member's results
+// for an architecture the user can read.
 func (s *Storage) ListSimulationResultsByArchitectureForUser(ctx context.Context, archID, userID pgtype.UUID) ([]model.SimulationResult, error) {
-	rows, err := s.Pool.Query(ctx,
-		`SELECT id, architecture_id, user_id, scenario_id, score, report, metrics, duration_sec, created_at
-		 FROM simulation_results WHERE architecture_id = $1 AND user_id = $2 ORDER BY created_at DESC`,
+	return s.listSimulationResults(ctx,
+		`SELECT `+simulationColumns+` FROM simulation_results
+		 WHERE architecture_id = $1 AND architecture_id IN (SELECT id FROM architectures WHERE deleted_at IS NULL AND `+canAccess("$2", AccessRead)+`)
+		 ORDER BY created_at DESC`,
 		archID, userID,
 	)
+}
+
+func (s *Storage) listSimulationResults(ctx context.Context, query string, args ...any) ([]model.SimulationResult, error) {
+	rows, err := s.Pool.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -87,8 +95,8 @@ func (s *Storage) ListSimulationResultsByArchitectureForUser(ctx context.Context
 
 	var results []model.SimulationResult
 	for rows.Next() {
-		var r model.SimulationResult
-		if err := rows.Scan(&r.ID, &r.ArchitectureID, &r.UserID, &r.ScenarioID, &r.Score, &r.Report, &r.Metrics, &r.DurationSec, &r.CreatedAt); err != nil {
+		r, err := scanSimulationResult(rows)
+		if err != nil {
 			return nil, err
 		}
 		results = append(results, r)
@@ -98,7 +106,7 @@ func (s *Storage) ListSimulationResultsByArchitectureForUser(ctx context.Context
 
 func (s *Storage) GetLeaderboard(ctx context.Context, scenarioID string, limit int) ([]model.LeaderboardEntry, error) {
 	rows, err := s.Pool.Query(ctx,
-		`SELECT name, scenario_id, score, created_at, rank
+		`SELECT name, scenario_id, score, monthly_cost, created_at, rank
 		 FROM leaderboard WHERE scenario_id = $1 AND rank <= $2
 		 ORDER BY rank`,
 		scenarioID, limit,
@@ -111,7 +119,7 @@ func (s *Storage) GetLeaderboard(ctx context.Context, scenarioID string, limit i
 	var entries []model.LeaderboardEntry
 	for rows.Next() {
 		var e model.LeaderboardEntry
-		if err := rows.Scan(&e.Name, &e.ScenarioID, &e.Score, &e.CreatedAt, &e.Rank); err != nil {
+		if err := rows.Scan(&e.Name, &e.ScenarioID, &e.Score, &e.MonthlyCost, &e.CreatedAt, &e.Rank); err != nil {
 			return nil, err
 		}
 		entries = append(entries, e)
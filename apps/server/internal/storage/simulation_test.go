@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/system-design-sandbox/server/internal/auth"
+	"github.com/system-design-sandbox/server/internal/model"
+)
+
+func TestGetSimulationResultForUser(t *testing.T) {
+	s := setupTestStorage(t)
+	ctx := context.Background()
+
+	newUser := func() model.User {
+		token, err := auth.GenerateToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := s.CreateUser(ctx, token[:16]+"@example.com", "Test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _, _ = s.Pool.Exec(context.Background(), `DELETE FROM users WHERE id = $1`, u.ID) })
+		return u
+	}
+	owner, runner, outsider := newUser(), newUser(), newUser()
+
+	ws, err := s.CreateWorkspace(ctx, owner.ID, "Team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.DeleteWorkspace(context.Background(), ws.ID) })
+	if _, err := s.Pool.Exec(ctx,
+		`INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, $3)`,
+		ws.ID, runner.ID, model.RoleViewer,
+	); err != nil {
+		t.Fatal(err)
+	}
+	arch, err := s.CreateArchitecture(ctx, owner.ID, "Shared", "", nil, nil, &ws.ID, json.RawMessage(`{"nodes":[],"edges":[]}`), false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := s.CreateSimulationResultForUser(ctx, arch.ID, runner.ID, nil, nil, json.RawMessage(`{}`), json.RawMessage(`{}`), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The runner leaves the workspace; the owner can still read the
+	// architecture, the runner only the result they ran.
+	if err := s.RemoveWorkspaceMember(ctx, ws.ID, runner.ID); err != nil {
+		t.Fatal(err)
+	}
+	for name, u := range map[string]model.User{"owner": owner, "runner": runner} {
+		if _, err := s.GetSimulationResultForUser(ctx, result.ID, u.ID); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := s.GetSimulationResultForUser(ctx, result.ID, outsider.ID); err != pgx.ErrNoRows {
+		t.Errorf("outsider: err = %v, want pgx.ErrNoRows", err)
+	}
+}
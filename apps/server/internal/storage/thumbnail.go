@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/model"
+)
+
+// ListStaleThumbnails returns up to limit architectures, with data, whose
+// thumbnail is missing or older than their last change.
+func (s *Storage) ListStaleThumbnails(ctx context.Context, limit int) ([]model.Architecture, error) {
+	rows, err := s.Pool.Query(ctx,
+		`SELECT `+architectureColumns+`, data FROM architectures
+		 WHERE deleted_at IS NULL AND (thumbnail_at IS NULL OR thumbnail_at < updated_at)
+		 ORDER BY updated_at DESC
+		 LIMIT $1`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var archs []model.Architecture
+	for rows.Next() {
+		a, err := scanArchitectureWithData(rows)
+		if err != nil {
+			return nil, err
+		}
+		archs = append(archs, a)
+	}
+	return archs, rows.Err()
+}
+
+// SetThumbnail stores a rendered SVG thumbnail and points thumbnail_url at
+// its public share path.
+func (s *Storage) SetThumbnail(ctx context.Context, id pgtype.UUID, svg []byte) error {
+	_, err := s.Pool.Exec(ctx,
+		`UPDATE architectures
+		 SET thumbnail = $2, thumbnail_at = now(), thumbnail_url = '/s/' || slug || '/thumbnail.svg'
+		 WHERE id = $1`,
+		id, svg,
+	)
+	return err
+}
+
+// GetPublicThumbnail returns the thumbnail of a public architecture.
+func (s *Storage) GetPublicThumbnail(ctx context.Context, slug string) ([]byte, error) {
+	var svg []byte
+	err := s.Pool.QueryRow(ctx,
+		`SELECT thumbnail FROM architectures
+		 WHERE slug = $1 AND is_public = true AND deleted_at IS NULL AND thumbnail IS NOT NULL`,
+		slug,
+	).Scan(&svg)
+	return svg, err
+}
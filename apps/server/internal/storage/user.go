@@ -2,16 +2,21 @@ package storage
 
 import (
 	"context"
+	"errors"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/system-design-sandbox/server/internal/model"
 )
 
-const userColumns = `id, email, name, status, display_name, gravatar_allowed, referral_source, created_at`
+// ErrEmailTaken means another account already uses the email address.
+var ErrEmailTaken = errors.New("storage: email address is taken")
+
+const userColumns = `id, email, name, status, display_name, gravatar_allowed, referral_source, role, locale, email_opt_out, created_at, unsubscribe_token, deleted_at`
 
 func scanUser(row interface{ Scan(dest ...any) error }) (model.User, error) {
 	var u model.User
-	err := row.Scan(&u.ID, &u.Email, &u.Name, &u.Status, &u.DisplayName, &u.GravatarAllowed, &u.ReferralSource, &u.CreatedAt)
+	err := row.Scan(&u.ID, &u.Email, &u.Name, &u.Status, &u.DisplayName, &u.GravatarAllowed, &u.ReferralSource, &u.Role, &u.Locale, &u.EmailOptOut, &u.CreatedAt, &u.UnsubscribeToken, &u.DeletedAt)
 	return u, err
 }
 
@@ -23,11 +28,11 @@ func (s *Storage) CreateUser(ctx context.Context, email, name string) (model.Use
 	))
 }
 
-func (s *Storage) CreateUserWithStatus(ctx context.Context, email, name, status string) (model.User, error) {
+func (s *Storage) CreateUserWithStatus(ctx context.Context, email, name, status, locale string) (model.User, error) {
 	return scanUser(s.Pool.QueryRow(ctx,
-		`INSERT INTO users (email, name, status) VALUES ($1, $2, $3)
+		`INSERT INTO users (email, name, status, locale) VALUES ($1, $2, $3, $4)
 		 RETURNING `+userColumns,
-		email, name, status,
+		email, name, status, locale,
 	))
 }
 
@@ -51,18 +56,55 @@ func (s *Storage) ActivateUser(ctx context.Context, id pgtype.UUID) error {
 	return err
 }
 
-func (s *Storage) UpdateUserProfile(ctx context.Context, id pgtype.UUID, displayName *string, gravatarAllowed *bool, referralSource *string) (model.User, error) {
+// UpdateUserProfile changes the given fields; nil leaves a field as is.
+func (s *Storage) UpdateUserProfile(ctx context.Context, id pgtype.UUID, displayName *string, gravatarAllowed *bool, referralSource *string, locale *string, emailOptOut []string) (model.User, error) {
 	return scanUser(s.Pool.QueryRow(ctx,
 		`UPDATE users SET
 			display_name = COALESCE($2, display_name),
 			gravatar_allowed = COALESCE($3, gravatar_allowed),
-			referral_source = COALESCE($4, referral_source)
+			referral_source = COALESCE($4, referral_source),
+			locale = COALESCE($5, locale),
+			email_opt_out = COALESCE($6, email_opt_out)
 		 WHERE id = $1
 		 RETURNING `+userColumns,
-		id, displayName, gravatarAllowed, referralSource,
+		id, displayName, gravatarAllowed, referralSource, locale, emailOptOut,
 	))
 }
 
+// UnsubscribeEmail turns off the template list for the user whose emails
+// carry token. pgx.ErrNoRows means the token is unknown.
+func (s *Storage) UnsubscribeEmail(ctx context.Context, token pgtype.UUID, list string) (model.User, error) {
+	return scanUser(s.Pool.QueryRow(ctx,
+		`UPDATE users SET email_opt_out = CASE
+			WHEN $2 = ANY(email_opt_out) THEN email_opt_out
+			ELSE array_append(email_opt_out, $2)
+		 END
+		 WHERE unsubscribe_token = $1
+		 RETURNING `+userColumns,
+		token, list,
+	))
+}
+
+// ChangeUserEmail moves the account from address from to address to,
+// along with a name that is still the old address. pgx.ErrNoRows means
+// the address is no longer from, and ErrEmailTaken that another account
+// uses to.
+func (s *Storage) ChangeUserEmail(ctx context.Context, id pgtype.UUID, from, to string) (model.User, error) {
+	u, err := scanUser(s.Pool.QueryRow(ctx,
+		`UPDATE users SET
+			email = $3,
+			name = CASE WHEN name = $2 THEN $3 ELSE name END
+		 WHERE id = $1 AND email = $2
+		 RETURNING `+userColumns,
+		id, from, to,
+	))
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return u, ErrEmailTaken
+	}
+	return u, err
+}
+
 func (s *Storage) ListUsers(ctx context.Context) ([]model.User, error) {
 	rows, err := s.Pool.Query(ctx,
 		`SELECT `+userColumns+` FROM users ORDER BY created_at DESC`)
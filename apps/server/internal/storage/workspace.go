@@ -0,0 +1,348 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/system-design-sandbox/server/internal/model"
+)
+
+// ErrLastOwner is returned when a change would leave a workspace without
+// an owner.
+var ErrLastOwner = errors.New("storage: workspace must keep an owner")
+
+const workspaceColumns = `w.id, w.name, w.created_by, m.role, w.created_at, w.updated_at`
+
+func scanWorkspace(row interface{ Scan(dest ...any) error }) (model.Workspace, error) {
+	var ws model.Workspace
+	err := row.Scan(&ws.ID, &ws.Name, &ws.CreatedBy, &ws.Role, &ws.CreatedAt, &ws.UpdatedAt)
+	return ws, err
+}
+
+const workspaceInviteColumns = `id, workspace_id, email, role, invited_by, expires_at, created_at`
+
+func scanWorkspaceInvite(row interface{ Scan(dest ...any) error }) (model.WorkspaceInvite, error) {
+	var inv model.WorkspaceInvite
+	err := row.Scan(&inv.ID, &inv.WorkspaceID, &inv.Email, &inv.Role, &inv.InvitedBy, &inv.ExpiresAt, &inv.CreatedAt)
+	return inv, err
+}
+
+// CreateWorkspace creates a workspace with userID as its owner.
+func (s *Storage) CreateWorkspace(ctx context.Context, userID pgtype.UUID, name string) (model.Workspace, error) {
+	var ws model.Workspace
+	err := pgx.BeginFunc(ctx, s.Pool, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx,
+			`INSERT INTO workspaces (name, created_by) VALUES ($1, $2)
+			 RETURNING id, name, created_by, created_at, updated_at`,
+			name, userID,
+		).Scan(&ws.ID, &ws.Name, &ws.CreatedBy, &ws.CreatedAt, &ws.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		ws.Role = model.RoleOwner
+		_, err = tx.Exec(ctx,
+			`INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, $3)`,
+			ws.ID, userID, model.RoleOwner,
+		)
+		return err
+	})
+	return ws, err
+}
+
+// GetWorkspaceForUser returns a workspace the user is a member of, with
+// the user's role.
+func (s *Storage) GetWorkspaceForUser(ctx context.Context, id, userID pgtype.UUID) (model.Workspace, error) {
+	return scanWorkspace(s.Pool.QueryRow(ctx,
+		`SELECT `+workspaceColumns+`
+		 FROM workspaces w JOIN workspace_members m ON m.workspace_id = w.id
+		 WHERE w.id = $1 AND m.user_id = $2`,
+		id, userID,
+	))
+}
+
+func (s *Storage) ListWorkspacesByUser(ctx context.Context, userID pgtype.UUID) ([]model.Workspace, error) {
+	rows, err := s.Pool.Query(ctx,
+		`SELECT `+workspaceColumns+`
+		 FROM workspaces w JOIN workspace_members m ON m.workspace_id = w.id
+		 WHERE m.user_id = $1
+		 ORDER BY lower(w.name), w.id`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var workspaces []model.Workspace
+	for rows.Next() {
+		ws, err := scanWorkspace(rows)
+		if err != nil {
+			return nil, err
+		}
+		workspaces = append(workspaces, ws)
+	}
+	return workspaces, rows.Err()
+}
+
+func (s *Storage) RenameWorkspace(ctx context.Context, id pgtype.UUID, name string) error {
+	tag, err := s.Pool.Exec(ctx, `UPDATE workspaces SET name = $2, updated_at = now() WHERE id = $1`, id, name)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteWorkspace deletes a workspace. Its architectures become personal
+// architectures of the members who created them.
+func (s *Storage) DeleteWorkspace(ctx context.Context, id pgtype.UUID) error {
+	tag, err := s.Pool.Exec(ctx, `DELETE FROM workspaces WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (s *Storage) ListWorkspaceMembers(ctx context.Context, workspaceID pgtype.UUID) ([]model.WorkspaceMember, error) {
+	rows, err := s.Pool.Query(ctx,
+		`SELECT u.id, u.email, u.name, u.display_name, m.role, m.created_at
+		 FROM workspace_members m JOIN users u ON u.id = m.user_id
+		 WHERE m.workspace_id = $1
+		 ORDER BY m.created_at, u.id`,
+		workspaceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []model.WorkspaceMember
+	for rows.Next() {
+		var m model.WorkspaceMember
+		if err := rows.Scan(&m.UserID, &m.Email, &m.Name, &m.DisplayName, &m.Role, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// GetWorkspaceMemberRole returns a member's role, or pgx.ErrNoRows when the
+// user is not a member.
+func (s *Storage) GetWorkspaceMemberRole(ctx context.Context, workspaceID, userID pgtype.UUID) (string, error) {
+	var role string
+	err := s.Pool.QueryRow(ctx,
+		`SELECT role FROM workspace_members WHERE workspace_id = $1 AND user_id = $2`,
+		workspaceID, userID,
+	).Scan(&role)
+	return role, err
+}
+
+// SetWorkspaceMemberRole changes a member's role. Demoting the last owner
+// is ErrLastOwner.
+func (s *Storage) SetWorkspaceMemberRole(ctx context.Context, workspaceID, userID pgtype.UUID, role string) error {
+	return s.changeMember(ctx, workspaceID, userID, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx,
+			`UPDATE workspace_members SET role = $3 WHERE workspace_id = $1 AND user_id = $2`,
+			workspaceID, userID, role,
+		)
+		return err
+	})
+}
+
+// RemoveWorkspaceMember removes a member. Removing the last owner is
+// ErrLastOwner. Architectures the member created stay in the workspace.
+func (s *Storage) RemoveWorkspaceMember(ctx context.Context, workspaceID, userID pgtype.UUID) error {
+	return s.changeMember(ctx, workspaceID, userID, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx,
+			`DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2`,
+			workspaceID, userID,
+		)
+		return err
+	})
+}
+
+// changeMember runs change with the workspace's members locked and fails
+// with ErrLastOwner if no owner is left afterwards.
+func (s *Storage) changeMember(ctx context.Context, workspaceID, userID pgtype.UUID, change func(pgx.Tx) error) error {
+	return pgx.BeginFunc(ctx, s.Pool, func(tx pgx.Tx) error {
+		var exists bool
+		err := tx.QueryRow(ctx,
+			`SELECT EXISTS (
+			     SELECT 1 FROM workspace_members WHERE workspace_id = $1 AND user_id = $2
+			 ) FROM workspaces WHERE id = $1 FOR UPDATE`,
+			workspaceID, userID,
+		).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return pgx.ErrNoRows
+		}
+
+		if err := change(tx); err != nil {
+			return err
+		}
+
+		var owners int
+		err = tx.QueryRow(ctx,
+			`SELECT count(*) FROM workspace_members WHERE workspace_id = $1 AND role = $2`,
+			workspaceID, model.RoleOwner,
+		).Scan(&owners)
+		if err != nil {
+			return err
+		}
+		if owners == 0 {
+			return ErrLastOwner
+		}
+		return nil
+	})
+}
+
+// CreateWorkspaceInvite stores an invitation; only tokenHash of the token
+// sent to email is kept. A pending invite to the same email is replaced.
+func (s *Storage) CreateWorkspaceInvite(ctx context.Context, workspaceID, invitedBy pgtype.UUID, email, role, tokenHash string, expiresAt time.Time) (model.WorkspaceInvite, error) {
+	var inv model.WorkspaceInvite
+	err := pgx.BeginFunc(ctx, s.Pool, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx,
+			`DELETE FROM workspace_invites WHERE workspace_id = $1 AND lower(email) = lower($2) AND accepted_at IS NULL`,
+			workspaceID, email,
+		)
+		if err != nil {
+			return err
+		}
+		inv, err = scanWorkspaceInvite(tx.QueryRow(ctx,
+			`INSERT INTO workspace_invites (workspace_id, email, role, token_hash, invited_by, expires_at)
+			 VALUES ($1, $2, $3, $4, $5, $6)
+			 RETURNING `+workspaceInviteColumns,
+			workspaceID, email, role, tokenHash, invitedBy, expiresAt,
+		))
+		return err
+	})
+	return inv, err
+}
+
+// ListWorkspaceInvites returns the workspace's pending, unexpired invites.
+func (s *Storage) ListWorkspaceInvites(ctx context.Context, workspaceID pgtype.UUID) ([]model.WorkspaceInvite, error) {
+	rows, err := s.Pool.Query(ctx,
+		`SELECT `+workspaceInviteColumns+` FROM workspace_invites
+		 WHERE workspace_id = $1 AND accepted_at IS NULL AND expires_at > now()
+		 ORDER BY created_at DESC`,
+		workspaceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invites []model.WorkspaceInvite
+	for rows.Next() {
+		inv, err := scanWorkspaceInvite(rows)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, inv)
+	}
+	return invites, rows.Err()
+}
+
+func (s *Storage) DeleteWorkspaceInvite(ctx context.Context, workspaceID, inviteID pgtype.UUID) error {
+	tag, err := s.Pool.Exec(ctx,
+		`DELETE FROM workspace_invites WHERE id = $1 AND workspace_id = $2 AND accepted_at IS NULL`,
+		inviteID, workspaceID,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// AcceptWorkspaceInvite adds userID to the workspace of a pending invite.
+// The invite must be addressed to the user's email; otherwise, or when it
+// is expired or already used, the result is pgx.ErrNoRows. Existing
+// members keep their role.
+func (s *Storage) AcceptWorkspaceInvite(ctx context.Context, tokenHash string, userID pgtype.UUID) (model.Workspace, error) {
+	var ws model.Workspace
+	err := pgx.BeginFunc(ctx, s.Pool, func(tx pgx.Tx) error {
+		var workspaceID pgtype.UUID
+		var role string
+		err := tx.QueryRow(ctx,
+			`UPDATE workspace_invites SET accepted_at = now()
+			 WHERE token_hash = $1 AND accepted_at IS NULL AND expires_at > now()
+			   AND lower(email) = (SELECT lower(email) FROM users WHERE id = $2)
+			 RETURNING workspace_id, role`,
+			tokenHash, userID,
+		).Scan(&workspaceID, &role)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx,
+			`INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, $3)
+			 ON CONFLICT (workspace_id, user_id) DO NOTHING`,
+			workspaceID, userID, role,
+		)
+		if err != nil {
+			return err
+		}
+
+		ws, err = scanWorkspace(tx.QueryRow(ctx,
+			`SELECT `+workspaceColumns+`
+			 FROM workspaces w JOIN workspace_members m ON m.workspace_id = w.id
+			 WHERE w.id = $1 AND m.user_id = $2`,
+			workspaceID, userID,
+		))
+		return err
+	})
+	return ws, err
+}
+
+// MoveArchitectureToWorkspace moves an architecture the user can manage
+// into workspaceID, where the user must be an editor or above, or makes it
+// the user's personal architecture when workspaceID is nil. Folders belong
+// to one space, so the architecture leaves its folder. Lacking either role is
+// ErrForbidden. Taking over another member's architecture counts against
+// the user's quotas and returns a *QuotaError when there is no room.
+func (s *Storage) MoveArchitectureToWorkspace(ctx context.Context, id, userID pgtype.UUID, workspaceID *pgtype.UUID) (model.Architecture, error) {
+	var a model.Architecture
+	err := pgx.BeginFunc(ctx, s.Pool, func(tx pgx.Tx) error {
+		var owner pgtype.UUID
+		var size int64
+		if err := tx.QueryRow(ctx,
+			`SELECT user_id, octet_length(data) FROM architectures WHERE id = $1 FOR UPDATE`,
+			id,
+		).Scan(&owner, &size); err != nil {
+			return err
+		}
+		var err error
+		a, err = scanArchitecture(tx.QueryRow(ctx,
+			`UPDATE architectures
+			 SET workspace_id = $3, folder_id = NULL,
+			     user_id = CASE WHEN $3::uuid IS NULL THEN $2 ELSE user_id END,
+			     updated_at = now()
+			 WHERE id = $1 AND deleted_at IS NULL AND `+canAccess("$2", AccessManage)+`
+			   AND ($3::uuid IS NULL OR `+memberOf("$3", "$2", AccessWrite)+`)
+			 RETURNING `+architectureColumns,
+			id, userID, workspaceID,
+		))
+		if err != nil || workspaceID != nil || owner == userID {
+			return err
+		}
+		return s.enforceQuota(ctx, tx, userID, usageDelta{architectures: 1, bytes: size})
+	})
+	if err != nil {
+		return model.Architecture{}, s.denied(ctx, err, id, userID)
+	}
+	return a, nil
+}
@@ -0,0 +1,191 @@
+// Package thumbnail renders small SVG previews of architectures for share
+// links and listings.
+package thumbnail
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+
+	"github.com/system-design-sandbox/server/internal/archdata"
+)
+
+// Width and Height are the rendered image size in pixels; the diagram is
+// scaled to fit.
+const (
+	Width  = 480
+	Height = 270
+)
+
+const (
+	defaultNodeWidth  = 160
+	defaultNodeHeight = 60
+	padding           = 40
+	maxLabel          = 24
+)
+
+var categoryFill = map[string]string{
+	"compute":    "#dbeafe",
+	"storage":    "#dcfce7",
+	"database":   "#dcfce7",
+	"network":    "#fef3c7",
+	"messaging":  "#fae8ff",
+	"client":     "#e0e7ff",
+	"monitoring": "#fee2e2",
+}
+
+const defaultFill = "#f1f5f9"
+
+type box struct {
+	x, y, w, h float64
+	label      string
+	fill       string
+	group      bool
+}
+
+// Render draws the nodes of s as boxes and its edges as lines between box
+// centres. A nil or empty schema gives a blank canvas.
+func Render(s *archdata.Schema) []byte {
+	boxes := layout(s)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="%s" preserveAspectRatio="xMidYMid meet">`,
+		Width, Height, viewBox(boxes))
+	b.WriteString(`<rect x="-100000" y="-100000" width="200000" height="200000" fill="#ffffff"/>`)
+
+	for _, bx := range boxes {
+		if bx.group {
+			fmt.Fprintf(&b, `<rect x="%s" y="%s" width="%s" height="%s" rx="8" fill="none" stroke="#94a3b8" stroke-dasharray="6 4"/>`,
+				num(bx.x), num(bx.y), num(bx.w), num(bx.h))
+		}
+	}
+	if s != nil {
+		for _, e := range s.Edges {
+			src, ok1 := boxes[e.Source]
+			dst, ok2 := boxes[e.Target]
+			if !ok1 || !ok2 {
+				continue
+			}
+			fmt.Fprintf(&b, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="#64748b" stroke-width="2"/>`,
+				num(src.x+src.w/2), num(src.y+src.h/2), num(dst.x+dst.w/2), num(dst.y+dst.h/2))
+		}
+	}
+	for _, id := range order(s) {
+		bx := boxes[id]
+		if bx.group {
+			continue
+		}
+		fmt.Fprintf(&b, `<rect x="%s" y="%s" width="%s" height="%s" rx="8" fill="%s" stroke="#475569"/>`,
+			num(bx.x), num(bx.y), num(bx.w), num(bx.h), bx.fill)
+		fmt.Fprintf(&b, `<text x="%s" y="%s" font-family="sans-serif" font-size="14" text-anchor="middle" dominant-baseline="middle" fill="#0f172a">`,
+			num(bx.x+bx.w/2), num(bx.y+bx.h/2))
+		_ = xml.EscapeText(&b, []byte(truncate(bx.label, maxLabel)))
+		b.WriteString(`</text>`)
+	}
+	b.WriteString(`</svg>`)
+	return b.Bytes()
+}
+
+// layout resolves node positions, which are relative to the parent for
+// nested nodes, into absolute boxes keyed by node ID.
+func layout(s *archdata.Schema) map[string]box {
+	boxes := map[string]box{}
+	if s == nil {
+		return boxes
+	}
+	byID := make(map[string]archdata.Node, len(s.Nodes))
+	parents := map[string]bool{}
+	for _, n := range s.Nodes {
+		byID[n.ID] = n
+		if n.ParentID != "" {
+			parents[n.ParentID] = true
+		}
+	}
+	for _, n := range s.Nodes {
+		x, y := absolute(byID, n)
+		w, h := size(n)
+		label := n.Data.Label
+		if label == "" {
+			label = n.Data.ComponentType
+		}
+		fill, ok := categoryFill[n.Data.Category]
+		if !ok {
+			fill = defaultFill
+		}
+		boxes[n.ID] = box{x: x, y: y, w: w, h: h, label: label, fill: fill, group: parents[n.ID] || archdata.IsContainer(n.Data.ComponentType)}
+	}
+	return boxes
+}
+
+// absolute adds up positions along the parent chain, stopping at missing
+// parents and cycles.
+func absolute(byID map[string]archdata.Node, n archdata.Node) (float64, float64) {
+	x, y := n.Position.X, n.Position.Y
+	seen := map[string]bool{n.ID: true}
+	for n.ParentID != "" && !seen[n.ParentID] {
+		p, ok := byID[n.ParentID]
+		if !ok {
+			break
+		}
+		seen[p.ID] = true
+		x += p.Position.X
+		y += p.Position.Y
+		n = p
+	}
+	return x, y
+}
+
+func size(n archdata.Node) (float64, float64) {
+	w, h := float64(defaultNodeWidth), float64(defaultNodeHeight)
+	if n.Measured != nil && n.Measured.Width > 0 && n.Measured.Height > 0 {
+		w, h = n.Measured.Width, n.Measured.Height
+	}
+	if n.Width != nil && *n.Width > 0 {
+		w = *n.Width
+	}
+	if n.Height != nil && *n.Height > 0 {
+		h = *n.Height
+	}
+	return w, h
+}
+
+// viewBox frames all boxes with some padding.
+func viewBox(boxes map[string]box) string {
+	if len(boxes) == 0 {
+		return fmt.Sprintf("0 0 %d %d", Width, Height)
+	}
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, b := range boxes {
+		minX = math.Min(minX, b.x)
+		minY = math.Min(minY, b.y)
+		maxX = math.Max(maxX, b.x+b.w)
+		maxY = math.Max(maxY, b.y+b.h)
+	}
+	return fmt.Sprintf("%s %s %s %s", num(minX-padding), num(minY-padding), num(maxX-minX+2*padding), num(maxY-minY+2*padding))
+}
+
+// order lists node IDs in document order so output is stable.
+func order(s *archdata.Schema) []string {
+	if s == nil {
+		return nil
+	}
+	ids := make([]string, 0, len(s.Nodes))
+	for _, n := range s.Nodes {
+		ids = append(ids, n.ID)
+	}
+	return ids
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+func num(f float64) string {
+	return fmt.Sprintf("%.1f", f)
+}
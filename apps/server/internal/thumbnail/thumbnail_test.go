@@ -0,0 +1,72 @@
+package thumbnail
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/system-design-sandbox/server/internal/archdata"
+)
+
+func TestRender(t *testing.T) {
+	s, err := archdata.Parse([]byte(`{
+		"nodes": [
+			{"id": "vpc", "type": "group", "position": {"x": 0, "y": 0}, "width": 600, "height": 300, "data": {"label": "VPC"}},
+			{"id": "api", "parentId": "vpc", "position": {"x": 40, "y": 40}, "data": {"label": "API <v2>", "category": "compute"}},
+			{"id": "db", "parentId": "vpc", "position": {"x": 300, "y": 40}, "data": {"componentType": "postgres", "category": "database"}}
+		],
+		"edges": [
+			{"id": "e1", "source": "api", "target": "db"},
+			{"id": "e2", "source": "api", "target": "gone"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := string(Render(s))
+	if err := xml.Unmarshal([]byte(out), new(struct{})); err != nil {
+		t.Fatalf("output is not well-formed XML: %v", err)
+	}
+	for _, want := range []string{
+		`viewBox="-40.0 -40.0 680.0 380.0"`,
+		`API &lt;v2&gt;`,
+		`>postgres<`,
+		`<line x1="120.0" y1="70.0" x2="380.0" y2="70.0"`,
+		`stroke-dasharray`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out, "<line"); n != 1 {
+		t.Errorf("lines = %d, want 1 (edges to missing nodes are skipped)", n)
+	}
+}
+
+func TestRenderEmpty(t *testing.T) {
+	for _, s := range []*archdata.Schema{nil, {}} {
+		out := string(Render(s))
+		if !strings.Contains(out, `viewBox="0 0 480 270"`) {
+			t.Errorf("empty render = %s", out)
+		}
+	}
+}
+
+func TestAbsoluteStopsOnCycle(t *testing.T) {
+	a := archdata.Node{ID: "a", ParentID: "b", Position: archdata.Position{X: 1}}
+	b := archdata.Node{ID: "b", ParentID: "a", Position: archdata.Position{X: 10}}
+	x, _ := absolute(map[string]archdata.Node{"a": a, "b": b}, a)
+	if x != 11 {
+		t.Errorf("x = %v, want 11", x)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("Привет, мир", 7); got != "Привет…" {
+		t.Errorf("truncate = %q", got)
+	}
+	if got := truncate("short", 7); got != "short" {
+		t.Errorf("truncate = %q", got)
+	}
+}
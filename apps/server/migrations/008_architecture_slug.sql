@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE architectures ADD COLUMN slug TEXT;
+UPDATE architectures SET slug = substr(replace(gen_random_uuid()::text, '-', ''), 1, 10) WHERE slug IS NULL;
+ALTER TABLE architectures ALTER COLUMN slug SET NOT NULL;
+CREATE UNIQUE INDEX idx_architectures_slug ON architectures(slug);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_architectures_slug;
+ALTER TABLE architectures DROP COLUMN slug;
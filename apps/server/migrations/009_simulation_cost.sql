@@ -0,0 +1,31 @@
+-- +goose Up
+ALTER TABLE simulation_results ADD COLUMN cost JSONB;
+ALTER TABLE simulation_results ADD COLUMN monthly_cost NUMERIC(12, 2);
+
+-- Cheaper designs win ties on score.
+DROP VIEW IF EXISTS leaderboard;
+CREATE VIEW leaderboard AS
+SELECT
+    u.name,
+    s.scenario_id,
+    s.score,
+    s.monthly_cost,
+    s.created_at,
+    RANK() OVER (PARTITION BY s.scenario_id ORDER BY s.score DESC, s.monthly_cost ASC NULLS LAST) AS rank
+FROM simulation_results s
+JOIN users u ON u.id = s.user_id;
+
+-- +goose Down
+DROP VIEW IF EXISTS leaderboard;
+CREATE VIEW leaderboard AS
+SELECT
+    u.name,
+    s.scenario_id,
+    s.score,
+    s.created_at,
+    RANK() OVER (PARTITION BY s.scenario_id ORDER BY s.score DESC) AS rank
+FROM simulation_results s
+JOIN users u ON u.id = s.user_id;
+
+ALTER TABLE simulation_results DROP COLUMN monthly_cost;
+ALTER TABLE simulation_results DROP COLUMN cost;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE architectures ADD COLUMN data_version TEXT;
+ALTER TABLE architectures ADD COLUMN upgrade_error TEXT;
+CREATE INDEX idx_architectures_upgrade_error ON architectures(id) WHERE upgrade_error IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_architectures_upgrade_error;
+ALTER TABLE architectures DROP COLUMN upgrade_error;
+ALTER TABLE architectures DROP COLUMN data_version;
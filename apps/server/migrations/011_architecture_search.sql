@@ -0,0 +1,41 @@
+-- +goose Up
+-- search_text holds node labels and component types extracted from the
+-- gzipped data at save time; the trigger folds it into search_vector
+-- together with name, description and tags. NULL means not extracted yet.
+ALTER TABLE architectures ADD COLUMN search_text TEXT;
+ALTER TABLE architectures ADD COLUMN search_vector TSVECTOR NOT NULL DEFAULT ''::tsvector;
+
+-- +goose StatementBegin
+CREATE FUNCTION architectures_search_vector() RETURNS trigger AS $$
+BEGIN
+    NEW.search_vector :=
+        setweight(to_tsvector('simple', coalesce(NEW.name, '')), 'A') ||
+        setweight(to_tsvector('simple', array_to_string(NEW.tags, ' ')), 'A') ||
+        setweight(to_tsvector('simple', coalesce(NEW.description, '')), 'B') ||
+        setweight(to_tsvector('simple', coalesce(NEW.search_text, '')), 'C');
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER trg_architectures_search_vector
+    BEFORE INSERT OR UPDATE OF name, description, tags, search_text ON architectures
+    FOR EACH ROW EXECUTE FUNCTION architectures_search_vector();
+
+CREATE INDEX idx_architectures_search_vector ON architectures USING GIN (search_vector);
+CREATE INDEX idx_architectures_user_updated ON architectures(user_id, updated_at DESC, id DESC);
+
+-- Labels live in the gzipped data, so existing rows are left with a NULL
+-- search_text for storage.IndexArchitectureSearch to fill in; touching the
+-- column here indexes name, description and tags right away.
+UPDATE architectures SET search_text = NULL;
+CREATE INDEX idx_architectures_search_text_pending ON architectures(id) WHERE search_text IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_architectures_search_text_pending;
+DROP INDEX IF EXISTS idx_architectures_user_updated;
+DROP INDEX IF EXISTS idx_architectures_search_vector;
+DROP TRIGGER IF EXISTS trg_architectures_search_vector ON architectures;
+DROP FUNCTION IF EXISTS architectures_search_vector();
+ALTER TABLE architectures DROP COLUMN search_vector;
+ALTER TABLE architectures DROP COLUMN search_text;
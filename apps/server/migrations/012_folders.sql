@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE folders (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    parent_id UUID REFERENCES folders(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (parent_id IS DISTINCT FROM id)
+);
+CREATE INDEX idx_folders_user_id ON folders(user_id);
+CREATE INDEX idx_folders_parent_id ON folders(parent_id);
+
+ALTER TABLE architectures ADD COLUMN folder_id UUID REFERENCES folders(id) ON DELETE SET NULL;
+CREATE INDEX idx_architectures_folder_id ON architectures(folder_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_architectures_folder_id;
+ALTER TABLE architectures DROP COLUMN folder_id;
+DROP TABLE IF EXISTS folders;
@@ -0,0 +1,32 @@
+-- +goose Up
+-- A thread is anchored to a node or edge ID inside architectures.data, or
+-- to the architecture as a whole when anchor_type is NULL. Anchors are not
+-- foreign keys: threads outlive the elements they point at.
+CREATE TABLE comment_threads (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    architecture_id UUID NOT NULL REFERENCES architectures(id) ON DELETE CASCADE,
+    anchor_type TEXT CHECK (anchor_type IN ('node', 'edge')),
+    anchor_id TEXT,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMPTZ,
+    resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK ((anchor_type IS NULL) = (anchor_id IS NULL))
+);
+CREATE INDEX idx_comment_threads_architecture_id ON comment_threads(architecture_id, created_at);
+CREATE INDEX idx_comment_threads_unresolved ON comment_threads(architecture_id) WHERE resolved_at IS NULL;
+
+CREATE TABLE comments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    thread_id UUID NOT NULL REFERENCES comment_threads(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX idx_comments_thread_id ON comments(thread_id, created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS comments;
+DROP TABLE IF EXISTS comment_threads;
@@ -0,0 +1,45 @@
+-- +goose Up
+ALTER TABLE architectures ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE architectures ADD COLUMN deleted_by UUID REFERENCES users(id) ON DELETE SET NULL;
+CREATE INDEX idx_architectures_deleted_at ON architectures(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Purging an architecture from the trash takes its simulation results along.
+ALTER TABLE simulation_results DROP CONSTRAINT simulation_results_architecture_id_fkey;
+ALTER TABLE simulation_results ADD CONSTRAINT simulation_results_architecture_id_fkey
+    FOREIGN KEY (architecture_id) REFERENCES architectures(id) ON DELETE CASCADE;
+
+-- Results of trashed architectures leave the leaderboard until restored.
+DROP VIEW IF EXISTS leaderboard;
+CREATE VIEW leaderboard AS
+SELECT
+    u.name,
+    s.scenario_id,
+    s.score,
+    s.monthly_cost,
+    s.created_at,
+    RANK() OVER (PARTITION BY s.scenario_id ORDER BY s.score DESC, s.monthly_cost ASC NULLS LAST) AS rank
+FROM simulation_results s
+JOIN users u ON u.id = s.user_id
+LEFT JOIN architectures a ON a.id = s.architecture_id
+WHERE a.deleted_at IS NULL;
+
+-- +goose Down
+DROP VIEW IF EXISTS leaderboard;
+CREATE VIEW leaderboard AS
+SELECT
+    u.name,
+    s.scenario_id,
+    s.score,
+    s.monthly_cost,
+    s.created_at,
+    RANK() OVER (PARTITION BY s.scenario_id ORDER BY s.score DESC, s.monthly_cost ASC NULLS LAST) AS rank
+FROM simulation_results s
+JOIN users u ON u.id = s.user_id;
+
+ALTER TABLE simulation_results DROP CONSTRAINT simulation_results_architecture_id_fkey;
+ALTER TABLE simulation_results ADD CONSTRAINT simulation_results_architecture_id_fkey
+    FOREIGN KEY (architecture_id) REFERENCES architectures(id);
+
+DROP INDEX IF EXISTS idx_architectures_deleted_at;
+ALTER TABLE architectures DROP COLUMN deleted_by;
+ALTER TABLE architectures DROP COLUMN deleted_at;
@@ -0,0 +1,83 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN plan TEXT NOT NULL DEFAULT 'free' CHECK (plan IN ('free', 'pro'));
+
+-- Running totals for quota checks, kept current by the triggers below.
+-- Architectures and their gzipped data count against user_id until purged
+-- from the trash; results_today counts results posted on results_day (UTC).
+CREATE TABLE user_usage (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    architectures INT NOT NULL DEFAULT 0,
+    data_bytes BIGINT NOT NULL DEFAULT 0,
+    results_day DATE NOT NULL DEFAULT (now() AT TIME ZONE 'UTC')::date,
+    results_today INT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- +goose StatementBegin
+CREATE FUNCTION architectures_usage() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP <> 'INSERT' AND OLD.user_id IS NOT NULL THEN
+        UPDATE user_usage
+        SET architectures = architectures - 1,
+            data_bytes = data_bytes - octet_length(OLD.data),
+            updated_at = now()
+        WHERE user_id = OLD.user_id;
+    END IF;
+    IF TG_OP <> 'DELETE' AND NEW.user_id IS NOT NULL THEN
+        INSERT INTO user_usage (user_id, architectures, data_bytes)
+        VALUES (NEW.user_id, 1, octet_length(NEW.data))
+        ON CONFLICT (user_id) DO UPDATE
+        SET architectures = user_usage.architectures + 1,
+            data_bytes = user_usage.data_bytes + EXCLUDED.data_bytes,
+            updated_at = now();
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER trg_architectures_usage
+    AFTER INSERT OR DELETE OR UPDATE OF user_id, data ON architectures
+    FOR EACH ROW EXECUTE FUNCTION architectures_usage();
+
+-- +goose StatementBegin
+CREATE FUNCTION simulation_results_usage() RETURNS trigger AS $$
+BEGIN
+    IF NEW.user_id IS NOT NULL THEN
+        INSERT INTO user_usage (user_id, results_today)
+        VALUES (NEW.user_id, 1)
+        ON CONFLICT (user_id) DO UPDATE
+        SET results_today = CASE WHEN user_usage.results_day = EXCLUDED.results_day
+                                 THEN user_usage.results_today + 1 ELSE 1 END,
+            results_day = EXCLUDED.results_day,
+            updated_at = now();
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER trg_simulation_results_usage
+    AFTER INSERT ON simulation_results
+    FOR EACH ROW EXECUTE FUNCTION simulation_results_usage();
+
+INSERT INTO user_usage (user_id, architectures, data_bytes)
+SELECT user_id, count(*), coalesce(sum(octet_length(data)), 0)
+FROM architectures
+WHERE user_id IS NOT NULL
+GROUP BY user_id;
+
+INSERT INTO user_usage (user_id, results_today)
+SELECT user_id, count(*)
+FROM simulation_results
+WHERE user_id IS NOT NULL AND created_at >= (now() AT TIME ZONE 'UTC')::date AT TIME ZONE 'UTC'
+GROUP BY user_id
+ON CONFLICT (user_id) DO UPDATE SET results_today = EXCLUDED.results_today;
+
+-- +goose Down
+DROP TRIGGER IF EXISTS trg_simulation_results_usage ON simulation_results;
+DROP FUNCTION IF EXISTS simulation_results_usage();
+DROP TRIGGER IF EXISTS trg_architectures_usage ON architectures;
+DROP FUNCTION IF EXISTS architectures_usage();
+DROP TABLE IF EXISTS user_usage;
+ALTER TABLE users DROP COLUMN IF EXISTS plan;
@@ -0,0 +1,35 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin'));
+
+-- Durable background jobs. Workers claim due rows with FOR UPDATE SKIP
+-- LOCKED and hold them until locked_until; a job whose worker died is
+-- claimed again once the lease runs out. dedupe_key keeps cron slots from
+-- being enqueued twice when several servers run the scheduler.
+CREATE TABLE jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind TEXT NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'done', 'dead')),
+    attempts INT NOT NULL DEFAULT 0,
+    max_attempts INT NOT NULL DEFAULT 5,
+    run_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    locked_until TIMESTAMPTZ,
+    last_error TEXT,
+    dedupe_key TEXT UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_jobs_due ON jobs(run_at) WHERE status IN ('pending', 'running');
+CREATE INDEX idx_jobs_status_created ON jobs(status, created_at DESC);
+
+-- Rendered SVG previews; thumbnail_at older than updated_at means stale.
+ALTER TABLE architectures ADD COLUMN thumbnail BYTEA;
+ALTER TABLE architectures ADD COLUMN thumbnail_at TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE architectures DROP COLUMN IF EXISTS thumbnail_at;
+ALTER TABLE architectures DROP COLUMN IF EXISTS thumbnail;
+DROP TABLE IF EXISTS jobs;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- Outgoing email. Requests render a message and insert it here together
+-- with an email.send job, so they never wait on SMTP; the job delivers it,
+-- retrying with backoff. Settled rows keep the outcome but drop the body,
+-- which may carry a login code.
+CREATE TABLE email_outbox (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    template TEXT NOT NULL,
+    recipient TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    body TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed', 'bounced', 'expired')),
+    attempts INT NOT NULL DEFAULT 0,
+    message_id TEXT,
+    last_error TEXT,
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_email_outbox_status_created ON email_outbox(status, created_at DESC);
+CREATE INDEX idx_email_outbox_recipient ON email_outbox(lower(recipient), created_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS email_outbox;
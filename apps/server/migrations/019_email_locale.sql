@@ -0,0 +1,11 @@
+-- +goose Up
+-- Language of the emails a user receives; new users get theirs from
+-- Accept-Language at sign-up.
+ALTER TABLE users ADD COLUMN locale TEXT NOT NULL DEFAULT 'en' CHECK (locale IN ('en', 'ru'));
+
+-- The text/plain alternative sent alongside body (HTML).
+ALTER TABLE email_outbox ADD COLUMN text_body TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE email_outbox DROP COLUMN IF EXISTS text_body;
+ALTER TABLE users DROP COLUMN IF EXISTS locale;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Non-transactional emails carry a one-click unsubscribe link keyed by
+-- unsubscribe_token; email_opt_out lists the templates a user turned off.
+ALTER TABLE users ADD COLUMN unsubscribe_token UUID NOT NULL UNIQUE DEFAULT gen_random_uuid();
+ALTER TABLE users ADD COLUMN email_opt_out TEXT[] NOT NULL DEFAULT '{}';
+
+ALTER TABLE email_outbox ADD COLUMN unsubscribe_url TEXT;
+
+-- +goose Down
+ALTER TABLE email_outbox DROP COLUMN IF EXISTS unsubscribe_url;
+ALTER TABLE users DROP COLUMN IF EXISTS email_opt_out;
+ALTER TABLE users DROP COLUMN IF EXISTS unsubscribe_token;
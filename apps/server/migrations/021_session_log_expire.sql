@@ -0,0 +1,14 @@
+-- +goose Up
+-- 'expire' is logged by the sweeper for sessions that ran out in Redis
+-- without a logout or revoke. The index serves the paginated history.
+ALTER TABLE session_log DROP CONSTRAINT IF EXISTS session_log_action_check;
+ALTER TABLE session_log ADD CONSTRAINT session_log_action_check
+    CHECK (action IN ('login', 'logout', 'refresh', 'revoke', 'expire'));
+CREATE INDEX idx_session_log_user_history ON session_log(user_id, created_at DESC, id DESC);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_session_log_user_history;
+DELETE FROM session_log WHERE action = 'expire';
+ALTER TABLE session_log DROP CONSTRAINT IF EXISTS session_log_action_check;
+ALTER TABLE session_log ADD CONSTRAINT session_log_action_check
+    CHECK (action IN ('login', 'logout', 'refresh', 'revoke'));
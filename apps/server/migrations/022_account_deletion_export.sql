@@ -0,0 +1,57 @@
+-- +goose Up
+-- DELETE /users/me sets deleted_at; the account is purged for good once the
+-- grace period has passed, unless its owner signs in again before that.
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMPTZ;
+CREATE INDEX idx_users_deleted_at ON users(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Results of deleted users stay on the leaderboard without a name: they
+-- are anonymized while the deletion is pending and detached when it is
+-- carried out.
+DROP VIEW IF EXISTS leaderboard;
+CREATE VIEW leaderboard AS
+SELECT
+    CASE WHEN u.id IS NULL OR u.deleted_at IS NOT NULL THEN 'Deleted user' ELSE u.name END AS name,
+    s.scenario_id,
+    s.score,
+    s.monthly_cost,
+    s.created_at,
+    RANK() OVER (PARTITION BY s.scenario_id ORDER BY s.score DESC, s.monthly_cost ASC NULLS LAST) AS rank
+FROM simulation_results s
+LEFT JOIN users u ON u.id = s.user_id
+LEFT JOIN architectures a ON a.id = s.architecture_id
+WHERE a.deleted_at IS NULL;
+
+-- Data exports (POST /users/me/export). A job fills in the zip and emails
+-- a download link; only token_hash of the link's token is kept.
+CREATE TABLE user_exports (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready', 'failed')),
+    token_hash TEXT UNIQUE,
+    data BYTEA,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ready_at TIMESTAMPTZ
+);
+CREATE INDEX idx_user_exports_user_id ON user_exports(user_id, created_at DESC);
+CREATE INDEX idx_user_exports_expires_at ON user_exports(expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS user_exports;
+
+DROP VIEW IF EXISTS leaderboard;
+CREATE VIEW leaderboard AS
+SELECT
+    u.name,
+    s.scenario_id,
+    s.score,
+    s.monthly_cost,
+    s.created_at,
+    RANK() OVER (PARTITION BY s.scenario_id ORDER BY s.score DESC, s.monthly_cost ASC NULLS LAST) AS rank
+FROM simulation_results s
+JOIN users u ON u.id = s.user_id
+LEFT JOIN architectures a ON a.id = s.architecture_id
+WHERE a.deleted_at IS NULL;
+
+DROP INDEX IF EXISTS idx_users_deleted_at;
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
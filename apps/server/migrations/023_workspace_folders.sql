@@ -0,0 +1,15 @@
+-- +goose Up
+-- Folders belong to a workspace when workspace_id is set, and user_id is
+-- then only their creator: it is cleared when the creator's account is
+-- purged, while the folder stays with the workspace.
+ALTER TABLE folders ADD COLUMN workspace_id UUID REFERENCES workspaces(id) ON DELETE CASCADE;
+ALTER TABLE folders ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE folders ADD CONSTRAINT folders_owner_check CHECK (workspace_id IS NOT NULL OR user_id IS NOT NULL);
+CREATE INDEX idx_folders_workspace_id ON folders(workspace_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_folders_workspace_id;
+DELETE FROM folders WHERE workspace_id IS NOT NULL;
+ALTER TABLE folders DROP CONSTRAINT IF EXISTS folders_owner_check;
+ALTER TABLE folders ALTER COLUMN user_id SET NOT NULL;
+ALTER TABLE folders DROP COLUMN workspace_id;
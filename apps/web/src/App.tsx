@@ -15,6 +15,7 @@ import { TrafficPanel } from './components/panels/TrafficPanel.tsx';
 import { PlatformStatus } from './components/ui/PlatformStatus.tsx';
 import { ToastContainer } from './components/ui/ToastContainer.tsx';
 import { usePlatformMetrics } from './hooks/usePlatformMetrics.ts';
+import { useSharedArchitecture } from './hooks/useSharedArchitecture.ts';
 import { useVersionCheck } from './hooks/useVersionCheck.ts';
 import { useWhatIfMode } from './hooks/useWhatIfMode.ts';
 import { useAuthStore } from './store/authStore.ts';
@@ -230,6 +231,7 @@ function FloatingSimControls() {
 function MainApp() {
   useWhatIfMode();
   usePlatformMetrics();
+  useSharedArchitecture();
   const { updateAvailable, reload } = useVersionCheck();
   const [viewMode, setViewMode] = useState<ViewMode>('canvas');
   const [leftOpen, setLeftOpen] = useState(true);
@@ -7,6 +7,11 @@ export interface ArchitectureListItem {
   name: string;
   description: string;
   scenario_id?: string;
+  folder_id?: string;
+  workspace_id?: string;
+  unresolved_threads?: number;
+  deleted_at?: string;
+  slug: string;
   thumbnail_url?: string;
   is_public: boolean;
   tags: string[];
@@ -56,3 +61,7 @@ export function listArchitectures(): Promise<ArchitectureListItem[]> {
 export function deleteArchitecture(id: string): Promise<void> {
   return apiFetch<void>(`/api/v1/architectures/${id}`, { method: 'DELETE' });
 }
+
+export function getSharedArchitecture(slug: string): Promise<ArchitectureDetail> {
+  return apiFetch<ArchitectureDetail>(`/api/v1/shared/${encodeURIComponent(slug)}`);
+}
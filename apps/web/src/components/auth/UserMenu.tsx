@@ -1,5 +1,6 @@
 import { useCallback, useEffect, useRef, useState } from 'react';
 
+import { ApiError } from '../../api/client.ts';
 import { useAuthStore } from '../../store/authStore.ts';
 
 const modalBackdropClass =
@@ -98,6 +99,7 @@ function ProfileModal({ onClose }: { onClose: () => void }) {
   const { user, updateProfile, error, clearError } = useAuthStore();
   const [displayName, setDisplayName] = useState(user?.display_name || '');
   const [gravatarAllowed, setGravatarAllowed] = useState(user?.gravatar_allowed ?? true);
+  const [signInAlerts, setSignInAlerts] = useState(!user?.email_opt_out?.includes('new_device'));
   const [submitting, setSubmitting] = useState(false);
 
   const handleSave = useCallback(
@@ -105,13 +107,15 @@ function ProfileModal({ onClose }: { onClose: () => void }) {
       e.preventDefault();
       setSubmitting(true);
       try {
-        await updateProfile(displayName.trim(), gravatarAllowed);
+        const optOut = (user?.email_opt_out ?? []).filter((t) => t !== 'new_device');
+        if (!signInAlerts) optOut.push('new_device');
+        await updateProfile(displayName.trim(), gravatarAllowed, optOut);
         onClose();
       } finally {
         setSubmitting(false);
       }
     },
-    [displayName, gravatarAllowed, updateProfile, onClose],
+    [user, displayName, gravatarAllowed, signInAlerts, updateProfile, onClose],
   );
 
   return (
@@ -123,7 +127,7 @@ function ProfileModal({ onClose }: { onClose: () => void }) {
         <div className="mb-5">
           <p className={modalLabelClass}>Profile</p>
           <h2 className={modalTitleClass}>Edit Profile</h2>
-          <p className={`mt-1 text-sm ${modalMutedTextClass}`}>Update your public name and avatar preference.</p>
+          <p className={`mt-1 text-sm ${modalMutedTextClass}`}>Update your public name, avatar and email preferences.</p>
         </div>
 
         <form onSubmit={handleSave} className="space-y-5">
@@ -150,8 +154,25 @@ function ProfileModal({ onClose }: { onClose: () => void }) {
             </span>
           </label>
 
+          <label className="flex cursor-pointer items-start gap-3 rounded-xl border border-[rgba(87,117,146,0.86)] bg-[rgba(11,18,31,0.96)] px-4 py-3 text-sm text-slate-100 shadow-[inset_0_1px_0_rgba(255,255,255,0.03)] transition-colors hover:border-[rgba(110,220,255,0.34)]">
+            <input
+              type="checkbox"
+              checked={signInAlerts}
+              onChange={(e) => setSignInAlerts(e.target.checked)}
+              className="mt-0.5 rounded border-[rgba(87,117,146,0.92)] bg-[rgba(7,12,19,0.98)] text-[var(--color-accent)] focus:ring-[var(--color-accent)]"
+            />
+            <span className="flex-1">
+              <span className="block text-[1.05rem] font-semibold leading-6 text-[var(--color-text)]">Sign-in alerts</span>
+              <span className={`mt-1 block text-xs ${modalMutedTextClass}`}>Email me when my account is used from a new device or country.</span>
+            </span>
+          </label>
+
           {error && <p className="text-red-400 text-xs">{error}</p>}
 
+          <EmailSection email={user?.email ?? ''} />
+
+          <AccountSection email={user?.email ?? ''} />
+
           <div className="flex gap-3 pt-2">
             <button
               type="button"
@@ -174,6 +195,184 @@ function ProfileModal({ onClose }: { onClose: () => void }) {
   );
 }
 
+function EmailSection({ email }: { email: string }) {
+  const { requestEmailChange, confirmEmailChange } = useAuthStore();
+  const [step, setStep] = useState<'idle' | 'edit' | 'code'>('idle');
+  const [newEmail, setNewEmail] = useState('');
+  const [code, setCode] = useState('');
+  const [busy, setBusy] = useState(false);
+  const [message, setMessage] = useState<string | null>(null);
+
+  const handleRequest = useCallback(async () => {
+    setBusy(true);
+    setMessage(null);
+    try {
+      await requestEmailChange(newEmail.trim());
+      setStep('code');
+    } catch (e) {
+      setMessage(e instanceof ApiError ? e.message : 'Failed to send code');
+    } finally {
+      setBusy(false);
+    }
+  }, [requestEmailChange, newEmail]);
+
+  const handleConfirm = useCallback(async () => {
+    setBusy(true);
+    setMessage(null);
+    try {
+      await confirmEmailChange(code.trim());
+      setStep('idle');
+      setNewEmail('');
+      setCode('');
+    } catch (e) {
+      setMessage(e instanceof ApiError ? e.message : 'Failed to change email');
+    } finally {
+      setBusy(false);
+    }
+  }, [confirmEmailChange, code]);
+
+  const inputClass =
+    'h-9 flex-1 rounded-lg border border-[rgba(87,117,146,0.92)] bg-[rgba(7,12,19,0.98)] px-3 text-sm text-[var(--color-text)] focus:border-[rgba(110,220,255,0.34)] focus:outline-none';
+  const buttonClass =
+    'shrink-0 rounded-lg border border-[rgba(87,117,146,0.88)] px-3 py-1.5 text-xs font-semibold text-[var(--color-text)] transition-colors hover:border-[rgba(110,220,255,0.34)] disabled:opacity-50';
+
+  return (
+    <div className="space-y-2 rounded-xl border border-[rgba(87,117,146,0.86)] bg-[rgba(11,18,31,0.96)] px-4 py-3 text-sm">
+      <div className="flex items-center justify-between gap-3">
+        <span className={`text-xs ${modalMutedTextClass}`}>
+          Email: <strong>{email}</strong>
+        </span>
+        {step === 'idle' && (
+          <button type="button" onClick={() => setStep('edit')} className={buttonClass}>
+            Change email
+          </button>
+        )}
+      </div>
+
+      {step === 'edit' && (
+        <div className="flex gap-2">
+          <input
+            type="email"
+            value={newEmail}
+            onChange={(e) => setNewEmail(e.target.value)}
+            placeholder="New email address"
+            className={inputClass}
+          />
+          <button type="button" onClick={handleRequest} disabled={busy || !newEmail.includes('@')} className={buttonClass}>
+            Send code
+          </button>
+        </div>
+      )}
+
+      {step === 'code' && (
+        <div className="space-y-2">
+          <p className={`text-xs ${modalMutedTextClass}`}>
+            Enter the code we sent to <strong>{newEmail.trim()}</strong>. Your current address was told about the change.
+          </p>
+          <div className="flex gap-2">
+            <input
+              type="text"
+              value={code}
+              onChange={(e) => setCode(e.target.value)}
+              placeholder="ABC-DEF"
+              autoComplete="one-time-code"
+              className={inputClass}
+            />
+            <button type="button" onClick={handleConfirm} disabled={busy || !code.trim()} className={buttonClass}>
+              Confirm
+            </button>
+          </div>
+        </div>
+      )}
+
+      {message && <p className="text-red-400 text-xs">{message}</p>}
+    </div>
+  );
+}
+
+function AccountSection({ email }: { email: string }) {
+  const { requestDataExport, deleteAccount } = useAuthStore();
+  const [exportState, setExportState] = useState<'idle' | 'requesting' | 'requested'>('idle');
+  const [confirmDelete, setConfirmDelete] = useState(false);
+  const [confirmEmail, setConfirmEmail] = useState('');
+  const [message, setMessage] = useState<string | null>(null);
+
+  const handleExport = useCallback(async () => {
+    setExportState('requesting');
+    setMessage(null);
+    try {
+      await requestDataExport();
+      setExportState('requested');
+    } catch (e) {
+      setExportState('idle');
+      setMessage(e instanceof ApiError ? e.message : 'Failed to request export');
+    }
+  }, [requestDataExport]);
+
+  const handleDelete = useCallback(async () => {
+    setMessage(null);
+    try {
+      await deleteAccount(confirmEmail.trim());
+    } catch (e) {
+      setMessage(e instanceof ApiError ? e.message : 'Failed to delete account');
+    }
+  }, [deleteAccount, confirmEmail]);
+
+  return (
+    <div className="space-y-3 rounded-xl border border-[rgba(87,117,146,0.86)] bg-[rgba(11,18,31,0.96)] px-4 py-3 text-sm">
+      <div className="flex items-center justify-between gap-3">
+        <span className={`text-xs ${modalMutedTextClass}`}>
+          {exportState === 'requested'
+            ? 'We will email you a download link when your data is ready.'
+            : 'Download your profile, architectures, results and session history.'}
+        </span>
+        <button
+          type="button"
+          onClick={handleExport}
+          disabled={exportState !== 'idle'}
+          className="shrink-0 rounded-lg border border-[rgba(87,117,146,0.88)] px-3 py-1.5 text-xs font-semibold text-[var(--color-text)] transition-colors hover:border-[rgba(110,220,255,0.34)] disabled:opacity-50"
+        >
+          Export my data
+        </button>
+      </div>
+
+      {confirmDelete ? (
+        <div className="space-y-2">
+          <p className={`text-xs ${modalMutedTextClass}`}>
+            Your account will be deleted after a grace period; signing in before then keeps it. Type <strong>{email}</strong> to confirm.
+          </p>
+          <div className="flex gap-2">
+            <input
+              type="email"
+              value={confirmEmail}
+              onChange={(e) => setConfirmEmail(e.target.value)}
+              className="h-9 flex-1 rounded-lg border border-[rgba(87,117,146,0.92)] bg-[rgba(7,12,19,0.98)] px-3 text-sm text-[var(--color-text)] focus:border-red-400 focus:outline-none"
+            />
+            <button
+              type="button"
+              onClick={handleDelete}
+              disabled={confirmEmail.trim().toLowerCase() !== email.toLowerCase()}
+              className="rounded-lg bg-red-600 px-3 text-xs font-semibold text-white transition-opacity hover:opacity-90 disabled:opacity-40"
+            >
+              Delete account
+            </button>
+          </div>
+        </div>
+      ) : (
+        <button
+          type="button"
+          onClick={() => setConfirmDelete(true)}
+          className="text-xs font-semibold text-red-400 hover:text-red-300"
+        >
+          Delete my account...
+        </button>
+      )}
+
+      {message && <p className="text-red-400 text-xs">{message}</p>}
+    </div>
+  );
+}
+
 interface SessionInfo {
   session_id: string;
   ip: string;
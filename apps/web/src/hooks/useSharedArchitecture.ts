@@ -0,0 +1,39 @@
+import { useEffect } from 'react';
+
+import { getSharedArchitecture } from '../api/architectures.ts';
+import { useCanvasStore } from '../store/canvasStore.ts';
+import { notify } from '../utils/notifications.ts';
+
+/**
+ * Opens the design behind a share link. /s/{slug} redirects browsers to
+ * /?shared={slug}; the design is loaded as an unsaved copy, so saving it
+ * creates the visitor's own architecture instead of touching the original.
+ */
+export function useSharedArchitecture() {
+  useEffect(() => {
+    const params = new URLSearchParams(window.location.search);
+    const slug = params.get('shared');
+    if (!slug) return;
+
+    // Drop the param so a reload does not discard later edits.
+    params.delete('shared');
+    const query = params.toString();
+    window.history.replaceState(null, '', `${window.location.pathname}${query ? `?${query}` : ''}${window.location.hash}`);
+
+    getSharedArchitecture(slug)
+      .then((detail) => {
+        const store = useCanvasStore.getState();
+        const result = store.importSchema(JSON.stringify(detail.data));
+        if (!result.ok) {
+          notify.error(`Failed to open shared design: ${result.error}`);
+          return;
+        }
+        store.setArchitectureId(null);
+        store.setSchemaName(detail.name);
+        store.setSchemaDescription(detail.description ?? '');
+        store.setSchemaTags(detail.tags ?? []);
+        store.setIsPublic(false);
+      })
+      .catch((e: Error) => notify.error(`Failed to open shared design: ${e.message}`));
+  }, []);
+}
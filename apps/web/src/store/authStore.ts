@@ -14,6 +14,8 @@ export interface User {
   display_name: string | null;
   gravatar_allowed: boolean;
   gravatar_url?: string;
+  locale?: 'en' | 'ru';
+  email_opt_out?: string[];
 }
 
 interface SessionInfo {
@@ -49,8 +51,12 @@ interface AuthState {
   verifyCode: (code: string) => Promise<void>;
   checkSession: () => Promise<boolean>;
   logout: () => Promise<void>;
-  updateProfile: (displayName: string, gravatarAllowed: boolean) => Promise<void>;
+  updateProfile: (displayName: string, gravatarAllowed: boolean, emailOptOut?: string[]) => Promise<void>;
   completeOnboarding: (displayName: string, referralSource?: string) => Promise<void>;
+  requestEmailChange: (email: string) => Promise<void>;
+  confirmEmailChange: (code: string) => Promise<void>;
+  requestDataExport: () => Promise<void>;
+  deleteAccount: (email: string) => Promise<string>;
   clearError: () => void;
   fetchAuthConfig: () => Promise<void>;
 
@@ -174,12 +180,16 @@ export const useAuthStore = create<AuthState>((set, get) => ({
     postTabMessage({ type: 'auth:logout' });
   },
 
-  updateProfile: async (displayName: string, gravatarAllowed: boolean) => {
+  updateProfile: async (displayName: string, gravatarAllowed: boolean, emailOptOut?: string[]) => {
     set({ error: null });
     try {
       const user = await apiFetch<User>('/api/v1/users/me', {
         method: 'PATCH',
-        body: JSON.stringify({ display_name: displayName, gravatar_allowed: gravatarAllowed }),
+        body: JSON.stringify({
+          display_name: displayName,
+          gravatar_allowed: gravatarAllowed,
+          ...(emailOptOut !== undefined && { email_opt_out: emailOptOut }),
+        }),
       });
       set({ user });
     } catch (e) {
@@ -208,6 +218,36 @@ export const useAuthStore = create<AuthState>((set, get) => ({
     }
   },
 
+  requestEmailChange: async (email: string) => {
+    await apiFetch('/api/v1/users/me/email', {
+      method: 'POST',
+      body: JSON.stringify({ email }),
+    });
+  },
+
+  confirmEmailChange: async (code: string) => {
+    const user = await apiFetch<User>('/api/v1/users/me/email/confirm', {
+      method: 'POST',
+      body: JSON.stringify({ code }),
+    });
+    set({ user });
+  },
+
+  requestDataExport: async () => {
+    await apiFetch('/api/v1/users/me/export', { method: 'POST' });
+  },
+
+  deleteAccount: async (email: string) => {
+    const res = await apiFetch<{ delete_at: string }>('/api/v1/users/me', {
+      method: 'DELETE',
+      body: JSON.stringify({ email }),
+    });
+    localStorage.removeItem(SESSION_KEY);
+    set({ user: null, view: 'anonymous', email: '' });
+    postTabMessage({ type: 'auth:logout' });
+    return res.delete_at;
+  },
+
   listSessions: async (limit?: number) => {
     const q = limit !== undefined ? `?limit=${limit}` : '';
     return apiFetch<SessionsResult>(`/api/v1/auth/sessions${q}`);
@@ -7,6 +7,8 @@ export interface PricingModel {
 
 const HOURS_PER_MONTH = 730;
 
+// The server prices stored architectures with a catalog that mirrors these
+// models (apps/server/internal/cost/catalog); keep both in sync.
 export const pricingModels: PricingModel[] = [
   {
     type: 'service',
@@ -8,6 +8,13 @@ export interface SuccessCriteria {
   message_loss?: string;
   max_cost_month?: number;
   min_rps?: number;
+  // Server-side analyzer (GET /architectures/{id}/analysis) must report no
+  // findings at or above min_severity (default warning).
+  clean_analysis?: boolean | {
+    categories?: string[];
+    rules?: string[];
+    min_severity?: 'info' | 'warning' | 'error';
+  };
 }
 
 export interface ScenarioComponent {